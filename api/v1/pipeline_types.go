@@ -17,16 +17,25 @@ limitations under the License.
 package v1
 
 import (
+	"regexp"
+
 	batchv1 "k8s.io/api/batch/v1"
 	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
 // PipelineSpec defines the desired state of Pipeline
+// +kubebuilder:validation:XValidation:rule="(has(self.steps) && size(self.steps) > 0) || (has(self.stages) && size(self.stages) > 0)",message="at least one step or stage is required"
 type PipelineSpec struct {
 	// Steps defines the list of jobs to run
-	// +kubebuilder:validation:MinItems=1
-	Steps []PipelineStep `json:"steps"`
+	// +optional
+	Steps []PipelineStep `json:"steps,omitempty"`
+
+	// Stages groups steps; by default each stage runs after the top-level steps and
+	// the previous stage succeed. Step names are unique across all stages
+	// +optional
+	Stages []PipelineStage `json:"stages,omitempty"`
 
 	// ServiceAccountName is the service account to use for all jobs
 	// Can be overridden per step in the job's pod spec
@@ -40,6 +49,260 @@ type PipelineSpec struct {
 	// PodTemplate defines common pod configuration applied to all steps
 	// +optional
 	PodTemplate *PodTemplateDefaults `json:"podTemplate,omitempty"`
+
+	// JobDefaults defines common Job settings applied to all steps
+	// +optional
+	JobDefaults *JobDefaults `json:"jobDefaults,omitempty"`
+
+	// Hooks defines containers injected into every step's job
+	// +optional
+	Hooks *PipelineHooks `json:"hooks,omitempty"`
+
+	// ResolveSteps records each step's effective JobSpec, with all pipeline defaults
+	// applied, in status.resolvedSteps
+	// +optional
+	ResolveSteps bool `json:"resolveSteps,omitempty"`
+
+	// LogArchive persists the logs of every step attempt when it finishes, so they
+	// outlive the step's Job and pods
+	// +optional
+	LogArchive *LogArchiveSpec `json:"logArchive,omitempty"`
+
+	// OnJobLost selects what happens to a running step whose Job is deleted, for example by
+	// hand or by a ttlSecondsAfterFinished race. Finished steps always keep their phase
+	// +kubebuilder:default=Fail
+	// +optional
+	OnJobLost JobLostPolicy `json:"onJobLost,omitempty"`
+
+	// StatusOverflow selects where per-step details go once the status grows large.
+	// With ConfigMap, resolvedSteps and each step's containers and archivedLogs move
+	// to a ConfigMap named in status.stepDetailsConfigMap, keeping the status well
+	// under the object size limit of pipelines with thousands of steps
+	// +kubebuilder:default=None
+	// +optional
+	StatusOverflow StatusOverflowPolicy `json:"statusOverflow,omitempty"`
+
+	// SkipPolicy selects how a skipped step or stage counts for the steps that depend
+	// on it. Steps can override it
+	// +kubebuilder:default=Propagate
+	// +optional
+	SkipPolicy SkipPolicy `json:"skipPolicy,omitempty"`
+
+	// FailurePolicy selects what happens to the other steps once a step fails
+	// +optional
+	FailurePolicy *FailurePolicy `json:"failurePolicy,omitempty"`
+
+	// AutoRetry retries steps that failed for reasons outside their code with a new Job,
+	// so the retries do not count against the Job's backoffLimit. Without it, steps
+	// whose pod was disrupted are retried up to 3 times
+	// +optional
+	AutoRetry *AutoRetrySpec `json:"autoRetry,omitempty"`
+
+	// StuckPods fails steps whose pod is stuck, such as a pod that cannot pull its image,
+	// instead of leaving them running. Stuck pods are reported in the step status either way
+	// +optional
+	StuckPods *StuckPodPolicy `json:"stuckPods,omitempty"`
+
+	// StepCompletion selects when a step finishes: OutcomeKnown as soon as its Job's outcome
+	// is known, or PodsTerminated once its pods terminated as well
+	// +kubebuilder:default=OutcomeKnown
+	// +optional
+	StepCompletion StepCompletion `json:"stepCompletion,omitempty"`
+}
+
+// StepCompletion selects when a step finishes
+// +kubebuilder:validation:Enum=OutcomeKnown;PodsTerminated
+type StepCompletion string
+
+const (
+	// StepCompletionOutcomeKnown finishes a step on the SuccessCriteriaMet or FailureTarget
+	// condition of its Job, while its pods may still be terminating
+	StepCompletionOutcomeKnown StepCompletion = "OutcomeKnown"
+	// StepCompletionPodsTerminated waits for the Complete or Failed condition, which the Job
+	// gets once its pods terminated
+	StepCompletionPodsTerminated StepCompletion = "PodsTerminated"
+)
+
+// StuckPodPolicy fails steps whose pod stays stuck for longer than a grace period
+type StuckPodPolicy struct {
+	// FailFastOn lists the stuck states that fail a step once they outlast the grace period
+	// +listType=set
+	// +optional
+	FailFastOn []StuckReason `json:"failFastOn,omitempty"`
+
+	// GracePeriod is how long a pod may stay stuck before its step fails
+	// +kubebuilder:default="5m"
+	// +optional
+	GracePeriod *metav1.Duration `json:"gracePeriod,omitempty"`
+}
+
+// StuckReason is a state in which a pod cannot make progress on its own
+// +kubebuilder:validation:Enum=ImagePullBackOff;Unschedulable;CrashLoopBackOff
+type StuckReason string
+
+const (
+	// StuckImagePullBackOff covers containers that cannot pull their image, including
+	// ErrImagePull and InvalidImageName
+	StuckImagePullBackOff StuckReason = "ImagePullBackOff"
+	// StuckUnschedulable covers pods the scheduler cannot place on a node
+	StuckUnschedulable StuckReason = "Unschedulable"
+	// StuckCrashLoopBackOff covers containers restarted over and over after crashing
+	StuckCrashLoopBackOff StuckReason = "CrashLoopBackOff"
+)
+
+// AutoRetrySpec configures automatic retries of steps that failed for reasons outside their code
+type AutoRetrySpec struct {
+	// MaxDisruptionRetries is how many times a step is retried after its pod was evicted,
+	// preempted or removed by a node drain, as reported by a DisruptionTarget pod condition.
+	// Setting it also makes step Jobs with restartPolicy Never replace disrupted pods
+	// without counting them against backoffLimit. Unset or 0 turns disruption retries off
+	// +kubebuilder:validation:Minimum=0
+	// +optional
+	MaxDisruptionRetries *int32 `json:"maxDisruptionRetries,omitempty"`
+
+	// OOMKilled retries steps whose container ran out of memory with more memory
+	// +optional
+	OOMKilled *OOMRetrySpec `json:"oomKilled,omitempty"`
+}
+
+// OOMRetrySpec retries OOM killed steps with larger memory requests and limits
+type OOMRetrySpec struct {
+	// MaxRetries is how many times a step is retried after an OOM kill
+	// +kubebuilder:default=2
+	// +kubebuilder:validation:Minimum=1
+	// +optional
+	MaxRetries int32 `json:"maxRetries,omitempty"`
+
+	// MemoryIncreasePercent grows the memory requests and limits of every container by
+	// this percentage on each retry; 100 doubles them
+	// +kubebuilder:default=100
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:validation:Maximum=1000
+	// +optional
+	MemoryIncreasePercent int32 `json:"memoryIncreasePercent,omitempty"`
+
+	// MaxMemory caps the grown memory requests and limits
+	MaxMemory resource.Quantity `json:"maxMemory"`
+}
+
+// FailurePolicy selects what happens to the other steps once a step fails
+type FailurePolicy struct {
+	// Mode is Continue to let running and pending steps carry on, or FailFast to cancel
+	// running steps and skip pending ones. Failure handlers, steps whose runIf or
+	// stage runIf checks for failure, run in both modes
+	// +kubebuilder:default=Continue
+	// +optional
+	Mode FailureMode `json:"mode,omitempty"`
+}
+
+// FailureMode selects what happens to the other steps once a step fails
+// +kubebuilder:validation:Enum=Continue;FailFast
+type FailureMode string
+
+const (
+	// FailureModeContinue lets the other steps carry on after a failure
+	FailureModeContinue FailureMode = "Continue"
+	// FailureModeFailFast deletes the Jobs of running steps after a failure, and marks
+	// running and pending steps Skipped with reason Cancelled
+	FailureModeFailFast FailureMode = "FailFast"
+)
+
+// SkipPolicy selects how a skipped step or stage counts for the steps that depend on it
+// +kubebuilder:validation:Enum=Propagate;TreatAsSuccess;TreatAsFailure;Ignore
+type SkipPolicy string
+
+const (
+	// SkipPropagate skips sequential steps after a skipped one; a skipped step matches
+	// no condition, so an "and" condition over it is not met
+	SkipPropagate SkipPolicy = "Propagate"
+	// SkipTreatAsSuccess counts a skipped step as succeeded
+	SkipTreatAsSuccess SkipPolicy = "TreatAsSuccess"
+	// SkipTreatAsFailure counts a skipped step as failed
+	SkipTreatAsFailure SkipPolicy = "TreatAsFailure"
+	// SkipIgnore leaves skipped steps out; a condition whose steps were all skipped is not met
+	SkipIgnore SkipPolicy = "Ignore"
+)
+
+// StatusOverflowPolicy selects where per-step details go once the status grows large
+// +kubebuilder:validation:Enum=None;ConfigMap
+type StatusOverflowPolicy string
+
+const (
+	// StatusOverflowNone keeps all step details in the status
+	StatusOverflowNone StatusOverflowPolicy = "None"
+	// StatusOverflowConfigMap moves step details to a ConfigMap owned by the pipeline
+	StatusOverflowConfigMap StatusOverflowPolicy = "ConfigMap"
+)
+
+// JobLostPolicy selects what happens to a step whose Job disappears before it finishes
+// +kubebuilder:validation:Enum=Fail;Recreate;Ignore
+type JobLostPolicy string
+
+const (
+	// JobLostFail fails the step with reason JobLost
+	JobLostFail JobLostPolicy = "Fail"
+	// JobLostRecreate creates the step's Job again
+	JobLostRecreate JobLostPolicy = "Recreate"
+	// JobLostIgnore leaves the step in its last recorded phase
+	JobLostIgnore JobLostPolicy = "Ignore"
+)
+
+// LogArchiveTarget selects where step logs are archived
+// +kubebuilder:validation:Enum=ConfigMap;PVC
+type LogArchiveTarget string
+
+const (
+	// LogArchiveTargetConfigMap stores each attempt's logs in its own ConfigMap
+	LogArchiveTargetConfigMap LogArchiveTarget = "ConfigMap"
+	// LogArchiveTargetPVC writes each attempt's logs to a PersistentVolumeClaim
+	LogArchiveTargetPVC LogArchiveTarget = "PVC"
+)
+
+// LogArchiveRetention selects how long archived logs are kept
+// +kubebuilder:validation:Enum=WithPipeline;Forever
+type LogArchiveRetention string
+
+const (
+	// LogArchiveRetainWithPipeline deletes archived logs together with the pipeline
+	LogArchiveRetainWithPipeline LogArchiveRetention = "WithPipeline"
+	// LogArchiveRetainForever keeps archived logs after the pipeline is deleted
+	LogArchiveRetainForever LogArchiveRetention = "Forever"
+)
+
+// LogArchiveSpec defines where the logs of finished steps are archived
+// +kubebuilder:validation:XValidation:rule="self.target != 'PVC' || (has(self.claimName) && size(self.claimName) > 0)",message="claimName is required for the PVC target"
+type LogArchiveSpec struct {
+	// Target selects where logs are archived
+	// +kubebuilder:validation:Required
+	Target LogArchiveTarget `json:"target"`
+
+	// Retain selects whether archived logs are deleted with the pipeline
+	// Only applies to the ConfigMap target; files written to a PVC are never deleted
+	// +kubebuilder:default=WithPipeline
+	// +optional
+	Retain LogArchiveRetention `json:"retain,omitempty"`
+
+	// ClaimName is the PersistentVolumeClaim logs are written to, for the PVC target
+	// +optional
+	ClaimName string `json:"claimName,omitempty"`
+
+	// Image runs the job that copies logs to the PVC; it must provide sh and cp
+	// +kubebuilder:default="busybox:1.36"
+	// +optional
+	Image string `json:"image,omitempty"`
+}
+
+// PipelineHooks defines containers that run around every step
+type PipelineHooks struct {
+	// Pre containers run as init containers before the step's own init containers
+	// +optional
+	Pre []corev1.Container `json:"pre,omitempty"`
+
+	// Post containers run as native sidecars next to the step's containers
+	// They receive SIGTERM once the step's containers exit and can finish within the
+	// pod's termination grace period
+	// +optional
+	Post []corev1.Container `json:"post,omitempty"`
 }
 
 // SharedVolumeSpec defines the shared volume configuration
@@ -58,6 +321,38 @@ type SharedVolumeSpec struct {
 	corev1.VolumeSource `json:",inline"`
 }
 
+// JobDefaults defines common Job settings applied to all steps
+// Each field only applies to steps whose job spec leaves it unset
+type JobDefaults struct {
+	// BackoffLimit is the number of retries before a step's Job fails; without it,
+	// steps get 0
+	// +kubebuilder:validation:Minimum=0
+	// +optional
+	BackoffLimit *int32 `json:"backoffLimit,omitempty"`
+
+	// ActiveDeadlineSeconds limits how long a step's Job may run
+	// +kubebuilder:validation:Minimum=1
+	// +optional
+	ActiveDeadlineSeconds *int64 `json:"activeDeadlineSeconds,omitempty"`
+
+	// TTLSecondsAfterFinished deletes a step's Job this long after it finished
+	// +kubebuilder:validation:Minimum=0
+	// +optional
+	TTLSecondsAfterFinished *int32 `json:"ttlSecondsAfterFinished,omitempty"`
+
+	// PodFailurePolicy decides how pod failures count against the backoff limit
+	// +optional
+	PodFailurePolicy *batchv1.PodFailurePolicy `json:"podFailurePolicy,omitempty"`
+
+	// CompletionMode is NonIndexed or Indexed
+	// +optional
+	CompletionMode *batchv1.CompletionMode `json:"completionMode,omitempty"`
+
+	// PodReplacementPolicy selects when failed pods are replaced
+	// +optional
+	PodReplacementPolicy *batchv1.PodReplacementPolicy `json:"podReplacementPolicy,omitempty"`
+}
+
 // PodTemplateDefaults defines common pod settings applied to all steps
 type PodTemplateDefaults struct {
 	// NodeSelector must match a node's labels for pods to be scheduled
@@ -118,6 +413,8 @@ type PodTemplateDefaults struct {
 }
 
 // PipelineStep defines a single step in the pipeline
+// +kubebuilder:validation:XValidation:rule="has(self.jobSpec) || has(self.jobRef) || has(self.templateRef)",message="one of jobSpec, jobRef or templateRef must be set"
+// +kubebuilder:validation:XValidation:rule="!(has(self.jobRef) && has(self.templateRef))",message="jobRef and templateRef are mutually exclusive"
 type PipelineStep struct {
 	// Name is the unique identifier for this step
 	// +kubebuilder:validation:Required
@@ -132,11 +429,154 @@ type PipelineStep struct {
 	RunIf *RunIfCondition `json:"runIf,omitempty"`
 
 	// JobSpec is the specification of the job to run
+	// Ignored when JobRef or TemplateRef is set
+	// +optional
+	JobSpec *batchv1.JobSpec `json:"jobSpec,omitempty"`
+
+	// JobRef copies the job template from an existing object when the step starts
+	// Pod template defaults and the shared volume are applied on top of it
+	// +optional
+	JobRef *JobReference `json:"jobRef,omitempty"`
+
+	// TemplateRef uses a StepTemplate or ClusterStepTemplate as the step body
+	// The template is resolved and pinned when the pipeline starts
+	// +optional
+	TemplateRef *StepTemplateRef `json:"templateRef,omitempty"`
+
+	// DisableHooks opts this step out of the pipeline's pre and post hooks
+	// +optional
+	DisableHooks bool `json:"disableHooks,omitempty"`
+
+	// ForEach expands the step into one Job per item of a JSON array emitted by
+	// an earlier step, referenced as $(steps.<step>.results.<name>)
+	// Each Job gets the item in the ITEM env var and its position in ITEM_INDEX
+	// The array can hold at most 500 items; a longer one fails the step
+	// +optional
+	// +kubebuilder:validation:Pattern=`^\$\(steps\.[a-z0-9]([-a-z0-9]*[a-z0-9])?\.results\.[a-zA-Z_][a-zA-Z0-9_-]*\)$`
+	ForEach string `json:"forEach,omitempty"`
+
+	// ForEachParallelism limits how many forEach Jobs run at once
+	// If not specified, all items run in parallel
+	// +optional
+	// +kubebuilder:validation:Minimum=1
+	ForEachParallelism *int32 `json:"forEachParallelism,omitempty"`
+
+	// SkipPolicy overrides the pipeline's skipPolicy for the steps and stages this step waits for
+	// +optional
+	SkipPolicy SkipPolicy `json:"skipPolicy,omitempty"`
+
+	// AllowFailure lets the step fail without failing the pipeline. The step is recorded as
+	// Failed with reason AllowedFailure, and the steps after it and the pipeline phase treat
+	// it as succeeded
+	// +optional
+	AllowFailure bool `json:"allowFailure,omitempty"`
+
+	// AllowFailureExitCodes restricts allowFailure to failures where every container that
+	// failed exited with one of these codes
+	// +kubebuilder:validation:items:Minimum=1
+	// +kubebuilder:validation:items:Maximum=255
+	// +optional
+	AllowFailureExitCodes []int32 `json:"allowFailureExitCodes,omitempty"`
+}
+
+// StepTemplateKind is the kind of template a step references
+// +kubebuilder:validation:Enum=StepTemplate;ClusterStepTemplate
+type StepTemplateKind string
+
+const (
+	// StepTemplateKindNamespaced references a StepTemplate in the pipeline's namespace
+	StepTemplateKindNamespaced StepTemplateKind = "StepTemplate"
+	// StepTemplateKindCluster references a cluster-scoped ClusterStepTemplate
+	StepTemplateKindCluster StepTemplateKind = "ClusterStepTemplate"
+)
+
+// StepTemplateRef references a step template and the parameter values to use
+type StepTemplateRef struct {
+	// Kind is the kind of template
+	// +kubebuilder:default=StepTemplate
+	// +optional
+	Kind StepTemplateKind `json:"kind,omitempty"`
+
+	// Name is the name of the template
 	// +kubebuilder:validation:Required
-	JobSpec batchv1.JobSpec `json:"jobSpec"`
+	// +kubebuilder:validation:MinLength=1
+	Name string `json:"name"`
+
+	// Params are the parameter values passed to the template
+	// +optional
+	// +listType=map
+	// +listMapKey=name
+	Params []TemplateParam `json:"params,omitempty"`
+}
+
+// TemplateParam is a parameter value passed to a step template
+type TemplateParam struct {
+	// Name is the parameter name
+	// +kubebuilder:validation:Required
+	Name string `json:"name"`
+
+	// Value is the parameter value
+	Value string `json:"value"`
+}
+
+// PipelineStage groups steps that run together and are tracked as a unit
+type PipelineStage struct {
+	// Name is the unique identifier for this stage
+	// +kubebuilder:validation:Required
+	// +kubebuilder:validation:MinLength=1
+	// +kubebuilder:validation:MaxLength=63
+	// +kubebuilder:validation:Pattern=`^[a-z0-9]([-a-z0-9]*[a-z0-9])?$`
+	Name string `json:"name"`
+
+	// DependsOn lists stages that must all succeed before this stage starts
+	// If neither DependsOn nor RunIf is set, the stage waits for the previous stage
+	// +optional
+	DependsOn []string `json:"dependsOn,omitempty"`
+
+	// RunIf defines conditional execution for the whole stage
+	// +optional
+	RunIf *RunIfCondition `json:"runIf,omitempty"`
+
+	// Steps in this stage; without runIf they run sequentially within the stage
+	// Stored schemaless to keep the CRD compact; the steps take the fields of
+	// spec.steps and are validated by the webhook and the controller
+	// +kubebuilder:validation:Schemaless
+	// +kubebuilder:pruning:PreserveUnknownFields
+	Steps []PipelineStep `json:"steps"`
+}
+
+// JobReferenceKind is the kind of object a step's job template is copied from
+// +kubebuilder:validation:Enum=CronJob;Job;ConfigMap
+type JobReferenceKind string
+
+const (
+	// JobReferenceKindCronJob copies spec.jobTemplate.spec from a CronJob
+	JobReferenceKindCronJob JobReferenceKind = "CronJob"
+	// JobReferenceKindJob copies spec from a Job
+	JobReferenceKindJob JobReferenceKind = "Job"
+	// JobReferenceKindConfigMap parses a JobSpec stored as YAML in a ConfigMap key
+	JobReferenceKindConfigMap JobReferenceKind = "ConfigMap"
+)
+
+// JobReference points to an object in the pipeline's namespace holding a job template
+type JobReference struct {
+	// Kind is the kind of the referenced object
+	// +kubebuilder:validation:Required
+	Kind JobReferenceKind `json:"kind"`
+
+	// Name is the name of the referenced object
+	// +kubebuilder:validation:Required
+	// +kubebuilder:validation:MinLength=1
+	Name string `json:"name"`
+
+	// Key is the ConfigMap data key holding the JobSpec YAML (ConfigMap only)
+	// +kubebuilder:default=jobSpec
+	// +optional
+	Key string `json:"key,omitempty"`
 }
 
 // RunIfCondition defines when a step should run based on other steps
+// +kubebuilder:validation:XValidation:rule="(has(self.steps) && size(self.steps) > 0) || (has(self.stages) && size(self.stages) > 0)",message="at least one step or stage must be referenced"
 type RunIfCondition struct {
 	// Condition determines whether to check for success or failure
 	// +kubebuilder:validation:Enum=success;fail
@@ -151,9 +591,12 @@ type RunIfCondition struct {
 	Operator RunIfOperator `json:"operator,omitempty"`
 
 	// Steps is the list of step names to check
-	// +kubebuilder:validation:Required
-	// +kubebuilder:validation:MinItems=1
-	Steps []string `json:"steps"`
+	// +optional
+	Steps []string `json:"steps,omitempty"`
+
+	// Stages is the list of stage names to check, each treated as a unit
+	// +optional
+	Stages []string `json:"stages,omitempty"`
 }
 
 // RunIfConditionType defines whether to check for success or failure
@@ -215,9 +658,285 @@ type StepStatus struct {
 	// +optional
 	JobName string `json:"jobName,omitempty"`
 
-	// JobStatus from the underlying job
+	// Attempt counts the Jobs created for this step; a lost Job that is recreated
+	// starts a new attempt
+	// +optional
+	Attempt int32 `json:"attempt,omitempty"`
+
+	// JobStatus holds the pod counts and timings of the underlying job
+	// +optional
+	JobStatus *JobStatusSummary `json:"jobStatus,omitempty"`
+
+	// StartTime is when the step's Job started
+	// +optional
+	StartTime *metav1.Time `json:"startTime,omitempty"`
+
+	// CompletionTime is when the step's Job finished
+	// +optional
+	CompletionTime *metav1.Time `json:"completionTime,omitempty"`
+
+	// Duration is how long the step ran, set once it finishes
+	// +optional
+	Duration *metav1.Duration `json:"duration,omitempty"`
+
+	// Reason is a short CamelCase explanation of the step's state, such as
+	// BackoffLimitExceeded, DeadlineExceeded, OOMKilled, or a reason its pod is waiting
+	// on like Unschedulable or ImagePullBackOff
+	// +optional
+	Reason string `json:"reason,omitempty"`
+
+	// Message explains the step's reason, or a failure that happened outside of the step's Job
+	// +optional
+	Message string `json:"message,omitempty"`
+
+	// Indexes reports which indexes of an Indexed Job finished
+	// +optional
+	Indexes *IndexStatus `json:"indexes,omitempty"`
+
+	// StuckSince is when the step's pod got stuck in the state Reason names, cleared once
+	// the pod makes progress
+	// +optional
+	StuckSince *metav1.Time `json:"stuckSince,omitempty"`
+
+	// PodName is the name of the step's most recent pod
+	// +optional
+	PodName string `json:"podName,omitempty"`
+
+	// NodeName is the node the step's most recent pod was scheduled to
+	// +optional
+	NodeName string `json:"nodeName,omitempty"`
+
+	// Containers records how each container of the step's most recent pod terminated
+	// +optional
+	Containers []ContainerTermination `json:"containers,omitempty"`
+
+	// ArchivedLogs lists where the logs of each attempt were archived: ConfigMap names
+	// for the ConfigMap target, file paths relative to the claim for the PVC target
+	// +optional
+	ArchivedLogs []string `json:"archivedLogs,omitempty"`
+
+	// Diagnostics captures the termination message and log tail of the step's failed pod,
+	// so the reason for a failure outlives the Job and its pods
+	// +optional
+	Diagnostics *FailureDiagnostics `json:"diagnostics,omitempty"`
+
+	// Results emitted by the step as a JSON object in its termination message
+	// Only collected for steps referenced by a forEach
+	// +optional
+	Results map[string]string `json:"results,omitempty"`
+
+	// Children tracks the Jobs created for each item of a forEach step
+	// +optional
+	Children []ForEachChildStatus `json:"children,omitempty"`
+
+	// JobRef records the object the job template was copied from, for steps using jobRef
+	// +optional
+	JobRef *ResolvedJobReference `json:"jobRef,omitempty"`
+
+	// AutoRetries records the failed attempts that were retried automatically
+	// +optional
+	AutoRetries []AutoRetry `json:"autoRetries,omitempty"`
+
+	// Template pins the step template version resolved when the pipeline started
+	// +optional
+	Template *ResolvedStepTemplate `json:"template,omitempty"`
+}
+
+// IndexStatus reports the per-index progress of an Indexed Job
+type IndexStatus struct {
+	// Completions is the number of indexes the Job runs
+	Completions int32 `json:"completions"`
+
+	// Completed lists the indexes that succeeded as intervals, such as "0-3,5"
+	// +optional
+	Completed string `json:"completed,omitempty"`
+
+	// Failed lists the indexes that failed, for Jobs with backoffLimitPerIndex
+	// +optional
+	Failed string `json:"failed,omitempty"`
+}
+
+// AutoRetry records a failed attempt of a step that was retried automatically
+type AutoRetry struct {
+	// Attempt is the attempt that failed
+	Attempt int32 `json:"attempt"`
+
+	// Index is the forEach item whose Job failed, unset for the step's own Job
+	// +optional
+	Index *int32 `json:"index,omitempty"`
+
+	// JobName is the name of the Job that failed
+	JobName string `json:"jobName"`
+
+	// Reason is Disrupted or OOMKilled
+	Reason string `json:"reason"`
+
+	// Message explains the failure
+	// +optional
+	Message string `json:"message,omitempty"`
+
+	// Time is when the retry was started
+	Time metav1.Time `json:"time"`
+}
+
+// FailureDiagnostics captures output of a failed container, truncated to a bounded size
+type FailureDiagnostics struct {
+	// Container is the name of the container the diagnostics were read from
+	// +optional
+	Container string `json:"container,omitempty"`
+
+	// TerminationMessage is the container's termination message
+	// +optional
+	TerminationMessage string `json:"terminationMessage,omitempty"`
+
+	// LogTail holds the last lines of the container's log
+	// +optional
+	LogTail string `json:"logTail,omitempty"`
+}
+
+// JobStatusSummary is the part of a Job's status recorded for a step
+type JobStatusSummary struct {
+	// StartTime is when the Job started
+	// +optional
+	StartTime *metav1.Time `json:"startTime,omitempty"`
+
+	// CompletionTime is when the Job completed
+	// +optional
+	CompletionTime *metav1.Time `json:"completionTime,omitempty"`
+
+	// Active is the number of pending and running pods
+	// +optional
+	Active int32 `json:"active,omitempty"`
+
+	// Ready is the number of active pods that are ready
+	// +optional
+	Ready *int32 `json:"ready,omitempty"`
+
+	// Terminating is the number of pods that are terminating
+	// +optional
+	Terminating *int32 `json:"terminating,omitempty"`
+
+	// Succeeded is the number of pods that succeeded
+	// +optional
+	Succeeded int32 `json:"succeeded,omitempty"`
+
+	// Failed is the number of pods that failed
+	// +optional
+	Failed int32 `json:"failed,omitempty"`
+}
+
+// ContainerTermination records how a container of a step's pod terminated
+type ContainerTermination struct {
+	// Name is the name of the container
+	Name string `json:"name"`
+
+	// ExitCode is the container's exit code
+	ExitCode int32 `json:"exitCode"`
+
+	// Reason is why the container terminated, such as Completed, Error or OOMKilled
+	// +optional
+	Reason string `json:"reason,omitempty"`
+}
+
+// ForEachChildStatus defines the observed state of one item of a forEach step
+type ForEachChildStatus struct {
+	// Index is the position of the item in the forEach list
+	Index int32 `json:"index"`
+
+	// Item is the item value; strings are stored as-is, other values as JSON
+	Item string `json:"item"`
+
+	// Phase is the current phase of this item
+	Phase StepPhase `json:"phase,omitempty"`
+
+	// JobName is the name of the Job created for this item
+	// +optional
+	JobName string `json:"jobName,omitempty"`
+
+	// Attempt counts the Jobs created for this item
+	// +optional
+	Attempt int32 `json:"attempt,omitempty"`
+}
+
+// ResolvedJobReference records the exact object version a step's job template came from
+type ResolvedJobReference struct {
+	// Kind is the kind of the referenced object
+	Kind JobReferenceKind `json:"kind"`
+
+	// Name is the name of the referenced object
+	Name string `json:"name"`
+
+	// ResourceVersion of the referenced object when the job template was copied
 	// +optional
-	JobStatus *batchv1.JobStatus `json:"jobStatus,omitempty"`
+	ResourceVersion string `json:"resourceVersion,omitempty"`
+}
+
+// ResolvedStep is the JobSpec a step runs after pipeline defaults are applied
+type ResolvedStep struct {
+	// Name is the name of the step
+	Name string `json:"name"`
+
+	// JobSpec is the effective job body
+	// Stored schemaless to keep the CRD compact
+	// +kubebuilder:validation:Schemaless
+	// +kubebuilder:validation:Type=object
+	// +kubebuilder:pruning:PreserveUnknownFields
+	JobSpec *batchv1.JobSpec `json:"jobSpec,omitempty"`
+}
+
+// ResolvedStepTemplate pins the template version and rendered job body used by a step
+type ResolvedStepTemplate struct {
+	// Kind is the kind of template
+	Kind StepTemplateKind `json:"kind"`
+
+	// Name is the name of the template
+	Name string `json:"name"`
+
+	// ResourceVersion of the template when it was resolved
+	// +optional
+	ResourceVersion string `json:"resourceVersion,omitempty"`
+
+	// Generation of the template when it was resolved
+	// +optional
+	Generation int64 `json:"generation,omitempty"`
+
+	// JobSpec is the template job body with parameters substituted
+	// Stored schemaless to keep the CRD compact
+	// +kubebuilder:validation:Schemaless
+	// +kubebuilder:validation:Type=object
+	// +kubebuilder:pruning:PreserveUnknownFields
+	JobSpec *batchv1.JobSpec `json:"jobSpec,omitempty"`
+}
+
+// StageStatus defines the observed state of a stage, rolled up from its steps
+type StageStatus struct {
+	// Name is the name of the stage
+	Name string `json:"name"`
+
+	// Phase is the aggregated phase of the stage's steps
+	Phase StepPhase `json:"phase,omitempty"`
+
+	// StartTime is when the first step of the stage started
+	// +optional
+	StartTime *metav1.Time `json:"startTime,omitempty"`
+
+	// CompletionTime is when the last step of the stage finished
+	// +optional
+	CompletionTime *metav1.Time `json:"completionTime,omitempty"`
+
+	// StepCounts counts the stage's steps by phase
+	StepCounts StageStepCounts `json:"stepCounts"`
+}
+
+// StageStepCounts counts the steps of a stage by phase
+type StageStepCounts struct {
+	Total     int32 `json:"total"`
+	Pending   int32 `json:"pending,omitempty"`
+	Running   int32 `json:"running,omitempty"`
+	Suspended int32 `json:"suspended,omitempty"`
+	Succeeded int32 `json:"succeeded,omitempty"`
+	Failed    int32 `json:"failed,omitempty"`
+	Skipped   int32 `json:"skipped,omitempty"`
 }
 
 // PipelineStatus defines the observed state of Pipeline
@@ -234,10 +953,31 @@ type PipelineStatus struct {
 	// +optional
 	CompletionTime *metav1.Time `json:"completionTime,omitempty"`
 
+	// Duration is how long the pipeline ran, set once it completes
+	// +optional
+	Duration *metav1.Duration `json:"duration,omitempty"`
+
+	// Progress is the number of finished steps out of the total, such as 3/7
+	// +optional
+	Progress string `json:"progress,omitempty"`
+
 	// Steps contains the status of each step
 	// +optional
 	Steps []StepStatus `json:"steps,omitempty"`
 
+	// Stages contains the rolled-up status of each stage
+	// +optional
+	Stages []StageStatus `json:"stages,omitempty"`
+
+	// ResolvedSteps contains the effective JobSpec of each step when spec.resolveSteps is set
+	// +optional
+	ResolvedSteps []ResolvedStep `json:"resolvedSteps,omitempty"`
+
+	// StepDetailsConfigMap names the ConfigMap holding the step details moved out of the status
+	// when spec.statusOverflow is ConfigMap
+	// +optional
+	StepDetailsConfigMap string `json:"stepDetailsConfigMap,omitempty"`
+
 	// Conditions represent the latest observations of the pipeline's state
 	// +optional
 	// +patchMergeKey=type
@@ -249,8 +989,11 @@ type PipelineStatus struct {
 
 // +kubebuilder:object:root=true
 // +kubebuilder:subresource:status
+// +kubebuilder:storageversion
 // +kubebuilder:resource:shortName=pl;pipe
 // +kubebuilder:printcolumn:name="Phase",type=string,JSONPath=`.status.phase`
+// +kubebuilder:printcolumn:name="Progress",type=string,JSONPath=`.status.progress`
+// +kubebuilder:printcolumn:name="Duration",type=string,JSONPath=`.status.duration`
 // +kubebuilder:printcolumn:name="Age",type=date,JSONPath=`.metadata.creationTimestamp`
 
 // Pipeline is the Schema for the pipelines API
@@ -277,6 +1020,20 @@ func init() {
 
 // Helper methods
 
+// AllSteps returns the top-level steps followed by the steps of each stage, in order
+func (s *PipelineSpec) AllSteps() []PipelineStep {
+	if len(s.Stages) == 0 {
+		return s.Steps
+	}
+
+	steps := make([]PipelineStep, 0, len(s.Steps))
+	steps = append(steps, s.Steps...)
+	for i := range s.Stages {
+		steps = append(steps, s.Stages[i].Steps...)
+	}
+	return steps
+}
+
 func (s *SharedVolumeSpec) GetName() string {
 	if s.Name == "" {
 		return "workspace"
@@ -291,6 +1048,65 @@ func (s *SharedVolumeSpec) GetMountPath() string {
 	return s.MountPath
 }
 
+// ApplyTo sets the fields a job spec leaves unset to these defaults
+func (d *JobDefaults) ApplyTo(jobSpec *batchv1.JobSpec) {
+	if d == nil {
+		return
+	}
+	if d.BackoffLimit != nil && jobSpec.BackoffLimit == nil {
+		backoffLimit := *d.BackoffLimit
+		jobSpec.BackoffLimit = &backoffLimit
+	}
+	if d.ActiveDeadlineSeconds != nil && jobSpec.ActiveDeadlineSeconds == nil {
+		activeDeadlineSeconds := *d.ActiveDeadlineSeconds
+		jobSpec.ActiveDeadlineSeconds = &activeDeadlineSeconds
+	}
+	if d.TTLSecondsAfterFinished != nil && jobSpec.TTLSecondsAfterFinished == nil {
+		ttlSeconds := *d.TTLSecondsAfterFinished
+		jobSpec.TTLSecondsAfterFinished = &ttlSeconds
+	}
+	if d.PodFailurePolicy != nil && jobSpec.PodFailurePolicy == nil {
+		jobSpec.PodFailurePolicy = d.PodFailurePolicy.DeepCopy()
+	}
+	if d.CompletionMode != nil && jobSpec.CompletionMode == nil {
+		completionMode := *d.CompletionMode
+		jobSpec.CompletionMode = &completionMode
+	}
+	if d.PodReplacementPolicy != nil && jobSpec.PodReplacementPolicy == nil {
+		podReplacementPolicy := *d.PodReplacementPolicy
+		jobSpec.PodReplacementPolicy = &podReplacementPolicy
+	}
+}
+
+// GetKey returns the ConfigMap data key (defaults to jobSpec)
+func (r *JobReference) GetKey() string {
+	if r.Key == "" {
+		return "jobSpec"
+	}
+	return r.Key
+}
+
+// GetKind returns the template kind (defaults to StepTemplate)
+func (r *StepTemplateRef) GetKind() StepTemplateKind {
+	if r.Kind == "" {
+		return StepTemplateKindNamespaced
+	}
+	return r.Kind
+}
+
+// forEachPattern matches $(steps.<step>.results.<name>) references
+var forEachPattern = regexp.MustCompile(`^\$\(steps\.([a-z0-9]([-a-z0-9]*[a-z0-9])?)\.results\.([a-zA-Z_][a-zA-Z0-9_-]*)\)$`)
+
+// ForEachSource returns the step and result names referenced by forEach
+// ok is false if the step has no forEach or the reference is malformed
+func (s *PipelineStep) ForEachSource() (stepName, resultName string, ok bool) {
+	match := forEachPattern.FindStringSubmatch(s.ForEach)
+	if match == nil {
+		return "", "", false
+	}
+	return match[1], match[3], true
+}
+
 // HasConditionalExecution returns true if the step has a runIf condition
 func (s *PipelineStep) HasConditionalExecution() bool {
 	return s.RunIf != nil
@@ -0,0 +1,104 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1
+
+import (
+	batchv1 "k8s.io/api/batch/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// StepTemplateSpec defines a reusable, parameterized step body
+type StepTemplateSpec struct {
+	// Description explains what the template does
+	// +optional
+	Description string `json:"description,omitempty"`
+
+	// Params declares the parameters accepted by the template
+	// +optional
+	// +listType=map
+	// +listMapKey=name
+	Params []StepTemplateParam `json:"params,omitempty"`
+
+	// JobSpec is the job body; $(params.<name>) placeholders are replaced with parameter values
+	// +kubebuilder:validation:Required
+	JobSpec batchv1.JobSpec `json:"jobSpec"`
+}
+
+// StepTemplateParam declares a single template parameter
+type StepTemplateParam struct {
+	// Name is the parameter name used in $(params.<name>) placeholders
+	// +kubebuilder:validation:Required
+	// +kubebuilder:validation:Pattern=`^[a-zA-Z_][a-zA-Z0-9_-]*$`
+	Name string `json:"name"`
+
+	// Description explains the parameter
+	// +optional
+	Description string `json:"description,omitempty"`
+
+	// Default is used when the pipeline does not set the parameter
+	// Parameters without a default are required
+	// +optional
+	Default *string `json:"default,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+// +kubebuilder:resource:shortName=st
+// +kubebuilder:printcolumn:name="Description",type=string,JSONPath=`.spec.description`
+// +kubebuilder:printcolumn:name="Age",type=date,JSONPath=`.metadata.creationTimestamp`
+
+// StepTemplate is the Schema for the namespaced steptemplates API
+type StepTemplate struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec StepTemplateSpec `json:"spec,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// StepTemplateList contains a list of StepTemplate
+type StepTemplateList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []StepTemplate `json:"items"`
+}
+
+// +kubebuilder:object:root=true
+// +kubebuilder:resource:scope=Cluster,shortName=cst
+// +kubebuilder:printcolumn:name="Description",type=string,JSONPath=`.spec.description`
+// +kubebuilder:printcolumn:name="Age",type=date,JSONPath=`.metadata.creationTimestamp`
+
+// ClusterStepTemplate is the Schema for the cluster-scoped clustersteptemplates API
+type ClusterStepTemplate struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec StepTemplateSpec `json:"spec,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// ClusterStepTemplateList contains a list of ClusterStepTemplate
+type ClusterStepTemplateList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []ClusterStepTemplate `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&StepTemplate{}, &StepTemplateList{}, &ClusterStepTemplate{}, &ClusterStepTemplateList{})
+}
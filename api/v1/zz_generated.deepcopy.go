@@ -27,6 +27,309 @@ import (
 	runtime "k8s.io/apimachinery/pkg/runtime"
 )
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *AutoRetry) DeepCopyInto(out *AutoRetry) {
+	*out = *in
+	if in.Index != nil {
+		in, out := &in.Index, &out.Index
+		*out = new(int32)
+		**out = **in
+	}
+	in.Time.DeepCopyInto(&out.Time)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AutoRetry.
+func (in *AutoRetry) DeepCopy() *AutoRetry {
+	if in == nil {
+		return nil
+	}
+	out := new(AutoRetry)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *AutoRetrySpec) DeepCopyInto(out *AutoRetrySpec) {
+	*out = *in
+	if in.MaxDisruptionRetries != nil {
+		in, out := &in.MaxDisruptionRetries, &out.MaxDisruptionRetries
+		*out = new(int32)
+		**out = **in
+	}
+	if in.OOMKilled != nil {
+		in, out := &in.OOMKilled, &out.OOMKilled
+		*out = new(OOMRetrySpec)
+		(*in).DeepCopyInto(*out)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AutoRetrySpec.
+func (in *AutoRetrySpec) DeepCopy() *AutoRetrySpec {
+	if in == nil {
+		return nil
+	}
+	out := new(AutoRetrySpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ClusterStepTemplate) DeepCopyInto(out *ClusterStepTemplate) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Spec.DeepCopyInto(&out.Spec)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ClusterStepTemplate.
+func (in *ClusterStepTemplate) DeepCopy() *ClusterStepTemplate {
+	if in == nil {
+		return nil
+	}
+	out := new(ClusterStepTemplate)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *ClusterStepTemplate) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ClusterStepTemplateList) DeepCopyInto(out *ClusterStepTemplateList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]ClusterStepTemplate, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ClusterStepTemplateList.
+func (in *ClusterStepTemplateList) DeepCopy() *ClusterStepTemplateList {
+	if in == nil {
+		return nil
+	}
+	out := new(ClusterStepTemplateList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *ClusterStepTemplateList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ContainerTermination) DeepCopyInto(out *ContainerTermination) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ContainerTermination.
+func (in *ContainerTermination) DeepCopy() *ContainerTermination {
+	if in == nil {
+		return nil
+	}
+	out := new(ContainerTermination)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *FailureDiagnostics) DeepCopyInto(out *FailureDiagnostics) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new FailureDiagnostics.
+func (in *FailureDiagnostics) DeepCopy() *FailureDiagnostics {
+	if in == nil {
+		return nil
+	}
+	out := new(FailureDiagnostics)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *FailurePolicy) DeepCopyInto(out *FailurePolicy) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new FailurePolicy.
+func (in *FailurePolicy) DeepCopy() *FailurePolicy {
+	if in == nil {
+		return nil
+	}
+	out := new(FailurePolicy)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ForEachChildStatus) DeepCopyInto(out *ForEachChildStatus) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ForEachChildStatus.
+func (in *ForEachChildStatus) DeepCopy() *ForEachChildStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(ForEachChildStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *IndexStatus) DeepCopyInto(out *IndexStatus) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new IndexStatus.
+func (in *IndexStatus) DeepCopy() *IndexStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(IndexStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *JobDefaults) DeepCopyInto(out *JobDefaults) {
+	*out = *in
+	if in.BackoffLimit != nil {
+		in, out := &in.BackoffLimit, &out.BackoffLimit
+		*out = new(int32)
+		**out = **in
+	}
+	if in.ActiveDeadlineSeconds != nil {
+		in, out := &in.ActiveDeadlineSeconds, &out.ActiveDeadlineSeconds
+		*out = new(int64)
+		**out = **in
+	}
+	if in.TTLSecondsAfterFinished != nil {
+		in, out := &in.TTLSecondsAfterFinished, &out.TTLSecondsAfterFinished
+		*out = new(int32)
+		**out = **in
+	}
+	if in.PodFailurePolicy != nil {
+		in, out := &in.PodFailurePolicy, &out.PodFailurePolicy
+		*out = new(batchv1.PodFailurePolicy)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.CompletionMode != nil {
+		in, out := &in.CompletionMode, &out.CompletionMode
+		*out = new(batchv1.CompletionMode)
+		**out = **in
+	}
+	if in.PodReplacementPolicy != nil {
+		in, out := &in.PodReplacementPolicy, &out.PodReplacementPolicy
+		*out = new(batchv1.PodReplacementPolicy)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new JobDefaults.
+func (in *JobDefaults) DeepCopy() *JobDefaults {
+	if in == nil {
+		return nil
+	}
+	out := new(JobDefaults)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *JobReference) DeepCopyInto(out *JobReference) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new JobReference.
+func (in *JobReference) DeepCopy() *JobReference {
+	if in == nil {
+		return nil
+	}
+	out := new(JobReference)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *JobStatusSummary) DeepCopyInto(out *JobStatusSummary) {
+	*out = *in
+	if in.StartTime != nil {
+		in, out := &in.StartTime, &out.StartTime
+		*out = (*in).DeepCopy()
+	}
+	if in.CompletionTime != nil {
+		in, out := &in.CompletionTime, &out.CompletionTime
+		*out = (*in).DeepCopy()
+	}
+	if in.Ready != nil {
+		in, out := &in.Ready, &out.Ready
+		*out = new(int32)
+		**out = **in
+	}
+	if in.Terminating != nil {
+		in, out := &in.Terminating, &out.Terminating
+		*out = new(int32)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new JobStatusSummary.
+func (in *JobStatusSummary) DeepCopy() *JobStatusSummary {
+	if in == nil {
+		return nil
+	}
+	out := new(JobStatusSummary)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *LogArchiveSpec) DeepCopyInto(out *LogArchiveSpec) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new LogArchiveSpec.
+func (in *LogArchiveSpec) DeepCopy() *LogArchiveSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(LogArchiveSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *OOMRetrySpec) DeepCopyInto(out *OOMRetrySpec) {
+	*out = *in
+	out.MaxMemory = in.MaxMemory.DeepCopy()
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new OOMRetrySpec.
+func (in *OOMRetrySpec) DeepCopy() *OOMRetrySpec {
+	if in == nil {
+		return nil
+	}
+	out := new(OOMRetrySpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *Pipeline) DeepCopyInto(out *Pipeline) {
 	*out = *in
@@ -54,6 +357,35 @@ func (in *Pipeline) DeepCopyObject() runtime.Object {
 	return nil
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PipelineHooks) DeepCopyInto(out *PipelineHooks) {
+	*out = *in
+	if in.Pre != nil {
+		in, out := &in.Pre, &out.Pre
+		*out = make([]corev1.Container, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.Post != nil {
+		in, out := &in.Post, &out.Post
+		*out = make([]corev1.Container, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PipelineHooks.
+func (in *PipelineHooks) DeepCopy() *PipelineHooks {
+	if in == nil {
+		return nil
+	}
+	out := new(PipelineHooks)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *PipelineList) DeepCopyInto(out *PipelineList) {
 	*out = *in
@@ -96,24 +428,93 @@ func (in *PipelineSpec) DeepCopyInto(out *PipelineSpec) {
 			(*in)[i].DeepCopyInto(&(*out)[i])
 		}
 	}
-	if in.SharedVolume != nil {
-		in, out := &in.SharedVolume, &out.SharedVolume
-		*out = new(SharedVolumeSpec)
-		(*in).DeepCopyInto(*out)
-	}
-	if in.PodTemplate != nil {
-		in, out := &in.PodTemplate, &out.PodTemplate
-		*out = new(PodTemplateDefaults)
-		(*in).DeepCopyInto(*out)
-	}
+	if in.Stages != nil {
+		in, out := &in.Stages, &out.Stages
+		*out = make([]PipelineStage, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.SharedVolume != nil {
+		in, out := &in.SharedVolume, &out.SharedVolume
+		*out = new(SharedVolumeSpec)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.PodTemplate != nil {
+		in, out := &in.PodTemplate, &out.PodTemplate
+		*out = new(PodTemplateDefaults)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.JobDefaults != nil {
+		in, out := &in.JobDefaults, &out.JobDefaults
+		*out = new(JobDefaults)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Hooks != nil {
+		in, out := &in.Hooks, &out.Hooks
+		*out = new(PipelineHooks)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.LogArchive != nil {
+		in, out := &in.LogArchive, &out.LogArchive
+		*out = new(LogArchiveSpec)
+		**out = **in
+	}
+	if in.FailurePolicy != nil {
+		in, out := &in.FailurePolicy, &out.FailurePolicy
+		*out = new(FailurePolicy)
+		**out = **in
+	}
+	if in.AutoRetry != nil {
+		in, out := &in.AutoRetry, &out.AutoRetry
+		*out = new(AutoRetrySpec)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.StuckPods != nil {
+		in, out := &in.StuckPods, &out.StuckPods
+		*out = new(StuckPodPolicy)
+		(*in).DeepCopyInto(*out)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PipelineSpec.
+func (in *PipelineSpec) DeepCopy() *PipelineSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(PipelineSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PipelineStage) DeepCopyInto(out *PipelineStage) {
+	*out = *in
+	if in.DependsOn != nil {
+		in, out := &in.DependsOn, &out.DependsOn
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.RunIf != nil {
+		in, out := &in.RunIf, &out.RunIf
+		*out = new(RunIfCondition)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Steps != nil {
+		in, out := &in.Steps, &out.Steps
+		*out = make([]PipelineStep, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PipelineSpec.
-func (in *PipelineSpec) DeepCopy() *PipelineSpec {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PipelineStage.
+func (in *PipelineStage) DeepCopy() *PipelineStage {
 	if in == nil {
 		return nil
 	}
-	out := new(PipelineSpec)
+	out := new(PipelineStage)
 	in.DeepCopyInto(out)
 	return out
 }
@@ -129,6 +530,11 @@ func (in *PipelineStatus) DeepCopyInto(out *PipelineStatus) {
 		in, out := &in.CompletionTime, &out.CompletionTime
 		*out = (*in).DeepCopy()
 	}
+	if in.Duration != nil {
+		in, out := &in.Duration, &out.Duration
+		*out = new(metav1.Duration)
+		**out = **in
+	}
 	if in.Steps != nil {
 		in, out := &in.Steps, &out.Steps
 		*out = make([]StepStatus, len(*in))
@@ -136,6 +542,20 @@ func (in *PipelineStatus) DeepCopyInto(out *PipelineStatus) {
 			(*in)[i].DeepCopyInto(&(*out)[i])
 		}
 	}
+	if in.Stages != nil {
+		in, out := &in.Stages, &out.Stages
+		*out = make([]StageStatus, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.ResolvedSteps != nil {
+		in, out := &in.ResolvedSteps, &out.ResolvedSteps
+		*out = make([]ResolvedStep, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
 	if in.Conditions != nil {
 		in, out := &in.Conditions, &out.Conditions
 		*out = make([]metav1.Condition, len(*in))
@@ -163,7 +583,31 @@ func (in *PipelineStep) DeepCopyInto(out *PipelineStep) {
 		*out = new(RunIfCondition)
 		(*in).DeepCopyInto(*out)
 	}
-	in.JobSpec.DeepCopyInto(&out.JobSpec)
+	if in.JobSpec != nil {
+		in, out := &in.JobSpec, &out.JobSpec
+		*out = new(batchv1.JobSpec)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.JobRef != nil {
+		in, out := &in.JobRef, &out.JobRef
+		*out = new(JobReference)
+		**out = **in
+	}
+	if in.TemplateRef != nil {
+		in, out := &in.TemplateRef, &out.TemplateRef
+		*out = new(StepTemplateRef)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.ForEachParallelism != nil {
+		in, out := &in.ForEachParallelism, &out.ForEachParallelism
+		*out = new(int32)
+		**out = **in
+	}
+	if in.AllowFailureExitCodes != nil {
+		in, out := &in.AllowFailureExitCodes, &out.AllowFailureExitCodes
+		*out = make([]int32, len(*in))
+		copy(*out, *in)
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PipelineStep.
@@ -258,6 +702,61 @@ func (in *PodTemplateDefaults) DeepCopy() *PodTemplateDefaults {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ResolvedJobReference) DeepCopyInto(out *ResolvedJobReference) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ResolvedJobReference.
+func (in *ResolvedJobReference) DeepCopy() *ResolvedJobReference {
+	if in == nil {
+		return nil
+	}
+	out := new(ResolvedJobReference)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ResolvedStep) DeepCopyInto(out *ResolvedStep) {
+	*out = *in
+	if in.JobSpec != nil {
+		in, out := &in.JobSpec, &out.JobSpec
+		*out = new(batchv1.JobSpec)
+		(*in).DeepCopyInto(*out)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ResolvedStep.
+func (in *ResolvedStep) DeepCopy() *ResolvedStep {
+	if in == nil {
+		return nil
+	}
+	out := new(ResolvedStep)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ResolvedStepTemplate) DeepCopyInto(out *ResolvedStepTemplate) {
+	*out = *in
+	if in.JobSpec != nil {
+		in, out := &in.JobSpec, &out.JobSpec
+		*out = new(batchv1.JobSpec)
+		(*in).DeepCopyInto(*out)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ResolvedStepTemplate.
+func (in *ResolvedStepTemplate) DeepCopy() *ResolvedStepTemplate {
+	if in == nil {
+		return nil
+	}
+	out := new(ResolvedStepTemplate)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *RunIfCondition) DeepCopyInto(out *RunIfCondition) {
 	*out = *in
@@ -266,6 +765,11 @@ func (in *RunIfCondition) DeepCopyInto(out *RunIfCondition) {
 		*out = make([]string, len(*in))
 		copy(*out, *in)
 	}
+	if in.Stages != nil {
+		in, out := &in.Stages, &out.Stages
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new RunIfCondition.
@@ -294,12 +798,117 @@ func (in *SharedVolumeSpec) DeepCopy() *SharedVolumeSpec {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *StageStatus) DeepCopyInto(out *StageStatus) {
+	*out = *in
+	if in.StartTime != nil {
+		in, out := &in.StartTime, &out.StartTime
+		*out = (*in).DeepCopy()
+	}
+	if in.CompletionTime != nil {
+		in, out := &in.CompletionTime, &out.CompletionTime
+		*out = (*in).DeepCopy()
+	}
+	out.StepCounts = in.StepCounts
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new StageStatus.
+func (in *StageStatus) DeepCopy() *StageStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(StageStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *StageStepCounts) DeepCopyInto(out *StageStepCounts) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new StageStepCounts.
+func (in *StageStepCounts) DeepCopy() *StageStepCounts {
+	if in == nil {
+		return nil
+	}
+	out := new(StageStepCounts)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *StepStatus) DeepCopyInto(out *StepStatus) {
 	*out = *in
 	if in.JobStatus != nil {
 		in, out := &in.JobStatus, &out.JobStatus
-		*out = new(batchv1.JobStatus)
+		*out = new(JobStatusSummary)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.StartTime != nil {
+		in, out := &in.StartTime, &out.StartTime
+		*out = (*in).DeepCopy()
+	}
+	if in.CompletionTime != nil {
+		in, out := &in.CompletionTime, &out.CompletionTime
+		*out = (*in).DeepCopy()
+	}
+	if in.Duration != nil {
+		in, out := &in.Duration, &out.Duration
+		*out = new(metav1.Duration)
+		**out = **in
+	}
+	if in.Indexes != nil {
+		in, out := &in.Indexes, &out.Indexes
+		*out = new(IndexStatus)
+		**out = **in
+	}
+	if in.StuckSince != nil {
+		in, out := &in.StuckSince, &out.StuckSince
+		*out = (*in).DeepCopy()
+	}
+	if in.Containers != nil {
+		in, out := &in.Containers, &out.Containers
+		*out = make([]ContainerTermination, len(*in))
+		copy(*out, *in)
+	}
+	if in.ArchivedLogs != nil {
+		in, out := &in.ArchivedLogs, &out.ArchivedLogs
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Diagnostics != nil {
+		in, out := &in.Diagnostics, &out.Diagnostics
+		*out = new(FailureDiagnostics)
+		**out = **in
+	}
+	if in.Results != nil {
+		in, out := &in.Results, &out.Results
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.Children != nil {
+		in, out := &in.Children, &out.Children
+		*out = make([]ForEachChildStatus, len(*in))
+		copy(*out, *in)
+	}
+	if in.JobRef != nil {
+		in, out := &in.JobRef, &out.JobRef
+		*out = new(ResolvedJobReference)
+		**out = **in
+	}
+	if in.AutoRetries != nil {
+		in, out := &in.AutoRetries, &out.AutoRetries
+		*out = make([]AutoRetry, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.Template != nil {
+		in, out := &in.Template, &out.Template
+		*out = new(ResolvedStepTemplate)
 		(*in).DeepCopyInto(*out)
 	}
 }
@@ -313,3 +922,164 @@ func (in *StepStatus) DeepCopy() *StepStatus {
 	in.DeepCopyInto(out)
 	return out
 }
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *StepTemplate) DeepCopyInto(out *StepTemplate) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Spec.DeepCopyInto(&out.Spec)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new StepTemplate.
+func (in *StepTemplate) DeepCopy() *StepTemplate {
+	if in == nil {
+		return nil
+	}
+	out := new(StepTemplate)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *StepTemplate) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *StepTemplateList) DeepCopyInto(out *StepTemplateList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]StepTemplate, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new StepTemplateList.
+func (in *StepTemplateList) DeepCopy() *StepTemplateList {
+	if in == nil {
+		return nil
+	}
+	out := new(StepTemplateList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *StepTemplateList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *StepTemplateParam) DeepCopyInto(out *StepTemplateParam) {
+	*out = *in
+	if in.Default != nil {
+		in, out := &in.Default, &out.Default
+		*out = new(string)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new StepTemplateParam.
+func (in *StepTemplateParam) DeepCopy() *StepTemplateParam {
+	if in == nil {
+		return nil
+	}
+	out := new(StepTemplateParam)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *StepTemplateRef) DeepCopyInto(out *StepTemplateRef) {
+	*out = *in
+	if in.Params != nil {
+		in, out := &in.Params, &out.Params
+		*out = make([]TemplateParam, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new StepTemplateRef.
+func (in *StepTemplateRef) DeepCopy() *StepTemplateRef {
+	if in == nil {
+		return nil
+	}
+	out := new(StepTemplateRef)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *StepTemplateSpec) DeepCopyInto(out *StepTemplateSpec) {
+	*out = *in
+	if in.Params != nil {
+		in, out := &in.Params, &out.Params
+		*out = make([]StepTemplateParam, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	in.JobSpec.DeepCopyInto(&out.JobSpec)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new StepTemplateSpec.
+func (in *StepTemplateSpec) DeepCopy() *StepTemplateSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(StepTemplateSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *StuckPodPolicy) DeepCopyInto(out *StuckPodPolicy) {
+	*out = *in
+	if in.FailFastOn != nil {
+		in, out := &in.FailFastOn, &out.FailFastOn
+		*out = make([]StuckReason, len(*in))
+		copy(*out, *in)
+	}
+	if in.GracePeriod != nil {
+		in, out := &in.GracePeriod, &out.GracePeriod
+		*out = new(metav1.Duration)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new StuckPodPolicy.
+func (in *StuckPodPolicy) DeepCopy() *StuckPodPolicy {
+	if in == nil {
+		return nil
+	}
+	out := new(StuckPodPolicy)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *TemplateParam) DeepCopyInto(out *TemplateParam) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new TemplateParam.
+func (in *TemplateParam) DeepCopy() *TemplateParam {
+	if in == nil {
+		return nil
+	}
+	out := new(TemplateParam)
+	in.DeepCopyInto(out)
+	return out
+}
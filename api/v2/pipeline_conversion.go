@@ -0,0 +1,596 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v2
+
+import (
+	"slices"
+	"strings"
+
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"sigs.k8s.io/controller-runtime/pkg/conversion"
+
+	pipelinev1 "github.com/yaacov/jobrunner/api/v1"
+)
+
+const (
+	// ContainerTasksAnnotation lists, on the stored v1 object, the tasks that were
+	// written as Container tasks so they convert back to the compact form
+	ContainerTasksAnnotation = "pipeline.yaacov.io/container-tasks"
+
+	// containerTaskName is the name of the single container of a Container task
+	containerTaskName = "main"
+
+	v1ResultPrefix = "$(steps."
+	v2ResultPrefix = "$(tasks."
+)
+
+// ConvertTo converts this Pipeline to the Hub version (v1).
+func (src *Pipeline) ConvertTo(dstRaw conversion.Hub) error {
+	dst := dstRaw.(*pipelinev1.Pipeline)
+
+	dst.ObjectMeta = *src.ObjectMeta.DeepCopy()
+
+	var containerTasks []string
+	dst.Spec.Steps = convertTasksToSteps(src.Spec.Tasks, &containerTasks)
+	dst.Spec.Stages = nil
+	for _, stage := range src.Spec.Stages {
+		dst.Spec.Stages = append(dst.Spec.Stages, pipelinev1.PipelineStage{
+			Name:      stage.Name,
+			DependsOn: stage.DependsOn,
+			RunIf:     convertNeedsToRunIf(stage.Needs),
+			Steps:     convertTasksToSteps(stage.Tasks, &containerTasks),
+		})
+	}
+	dst.Spec.ServiceAccountName = src.Spec.ServiceAccountName
+	dst.Spec.SharedVolume = (*pipelinev1.SharedVolumeSpec)(src.Spec.SharedVolume)
+	dst.Spec.PodTemplate = (*pipelinev1.PodTemplateDefaults)(src.Spec.PodTemplate)
+	dst.Spec.JobDefaults = (*pipelinev1.JobDefaults)(src.Spec.JobDefaults)
+	dst.Spec.Hooks = (*pipelinev1.PipelineHooks)(src.Spec.Hooks)
+	dst.Spec.ResolveSteps = src.Spec.ResolveTasks
+	dst.Spec.LogArchive = convertLogArchiveToV1(src.Spec.LogArchive)
+	dst.Spec.OnJobLost = pipelinev1.JobLostPolicy(src.Spec.OnJobLost)
+	dst.Spec.StatusOverflow = pipelinev1.StatusOverflowPolicy(src.Spec.StatusOverflow)
+	dst.Spec.SkipPolicy = pipelinev1.SkipPolicy(src.Spec.SkipPolicy)
+	dst.Spec.FailurePolicy = convertFailurePolicyToV1(src.Spec.FailurePolicy)
+	dst.Spec.AutoRetry = convertAutoRetryToV1(src.Spec.AutoRetry)
+	dst.Spec.StuckPods = convertStuckPodsToV1(src.Spec.StuckPods)
+	dst.Spec.StepCompletion = pipelinev1.StepCompletion(src.Spec.TaskCompletion)
+
+	if len(containerTasks) > 0 {
+		if dst.Annotations == nil {
+			dst.Annotations = map[string]string{}
+		}
+		dst.Annotations[ContainerTasksAnnotation] = strings.Join(containerTasks, ",")
+	}
+
+	dst.Status = pipelinev1.PipelineStatus{
+		Phase:          pipelinev1.PipelinePhase(src.Status.Phase),
+		StartTime:      src.Status.StartTime,
+		CompletionTime: src.Status.CompletionTime,
+		Duration:       src.Status.Duration,
+		Progress:       src.Status.Progress,
+		Conditions:     src.Status.Conditions,
+	}
+	for _, task := range src.Status.Tasks {
+		dst.Status.Steps = append(dst.Status.Steps, convertTaskStatusToStepStatus(task))
+	}
+	for _, stage := range src.Status.Stages {
+		dst.Status.Stages = append(dst.Status.Stages, pipelinev1.StageStatus{
+			Name:           stage.Name,
+			Phase:          pipelinev1.StepPhase(stage.Phase),
+			StartTime:      stage.StartTime,
+			CompletionTime: stage.CompletionTime,
+			StepCounts:     pipelinev1.StageStepCounts(stage.TaskCounts),
+		})
+	}
+	for _, resolved := range src.Status.ResolvedTasks {
+		dst.Status.ResolvedSteps = append(dst.Status.ResolvedSteps, pipelinev1.ResolvedStep(resolved))
+	}
+	dst.Status.StepDetailsConfigMap = src.Status.TaskDetailsConfigMap
+
+	return nil
+}
+
+// ConvertFrom converts from the Hub version (v1) to this version.
+func (dst *Pipeline) ConvertFrom(srcRaw conversion.Hub) error {
+	src := srcRaw.(*pipelinev1.Pipeline)
+
+	dst.ObjectMeta = *src.ObjectMeta.DeepCopy()
+
+	var containerTasks []string
+	if value, ok := dst.Annotations[ContainerTasksAnnotation]; ok {
+		containerTasks = strings.Split(value, ",")
+		delete(dst.Annotations, ContainerTasksAnnotation)
+		if len(dst.Annotations) == 0 {
+			dst.Annotations = nil
+		}
+	}
+
+	dst.Spec.Tasks = convertStepsToTasks(src.Spec.Steps, containerTasks)
+	dst.Spec.Stages = nil
+	for _, stage := range src.Spec.Stages {
+		dst.Spec.Stages = append(dst.Spec.Stages, PipelineStage{
+			Name:      stage.Name,
+			DependsOn: stage.DependsOn,
+			Needs:     convertRunIfToNeeds(stage.RunIf),
+			Tasks:     convertStepsToTasks(stage.Steps, containerTasks),
+		})
+	}
+	dst.Spec.ServiceAccountName = src.Spec.ServiceAccountName
+	dst.Spec.SharedVolume = (*SharedVolumeSpec)(src.Spec.SharedVolume)
+	dst.Spec.PodTemplate = (*PodTemplateDefaults)(src.Spec.PodTemplate)
+	dst.Spec.JobDefaults = (*JobDefaults)(src.Spec.JobDefaults)
+	dst.Spec.Hooks = (*PipelineHooks)(src.Spec.Hooks)
+	dst.Spec.ResolveTasks = src.Spec.ResolveSteps
+	dst.Spec.LogArchive = convertLogArchiveFromV1(src.Spec.LogArchive)
+	dst.Spec.OnJobLost = JobLostPolicy(src.Spec.OnJobLost)
+	dst.Spec.StatusOverflow = StatusOverflowPolicy(src.Spec.StatusOverflow)
+	dst.Spec.SkipPolicy = SkipPolicy(src.Spec.SkipPolicy)
+	dst.Spec.FailurePolicy = convertFailurePolicyFromV1(src.Spec.FailurePolicy)
+	dst.Spec.AutoRetry = convertAutoRetryFromV1(src.Spec.AutoRetry)
+	dst.Spec.StuckPods = convertStuckPodsFromV1(src.Spec.StuckPods)
+	dst.Spec.TaskCompletion = TaskCompletion(src.Spec.StepCompletion)
+
+	dst.Status = PipelineStatus{
+		Phase:          PipelinePhase(src.Status.Phase),
+		StartTime:      src.Status.StartTime,
+		CompletionTime: src.Status.CompletionTime,
+		Duration:       src.Status.Duration,
+		Progress:       src.Status.Progress,
+		Conditions:     src.Status.Conditions,
+	}
+	for _, step := range src.Status.Steps {
+		dst.Status.Tasks = append(dst.Status.Tasks, convertStepStatusToTaskStatus(step))
+	}
+	for _, stage := range src.Status.Stages {
+		dst.Status.Stages = append(dst.Status.Stages, StageStatus{
+			Name:           stage.Name,
+			Phase:          TaskPhase(stage.Phase),
+			StartTime:      stage.StartTime,
+			CompletionTime: stage.CompletionTime,
+			TaskCounts:     StageTaskCounts(stage.StepCounts),
+		})
+	}
+	for _, resolved := range src.Status.ResolvedSteps {
+		dst.Status.ResolvedTasks = append(dst.Status.ResolvedTasks, ResolvedTask(resolved))
+	}
+	dst.Status.TaskDetailsConfigMap = src.Status.StepDetailsConfigMap
+
+	return nil
+}
+
+// convertTasksToSteps converts v2 tasks to v1 steps, collecting the names of Container tasks
+func convertTasksToSteps(tasks []PipelineTask, containerTasks *[]string) []pipelinev1.PipelineStep {
+	if tasks == nil {
+		return nil
+	}
+
+	steps := make([]pipelinev1.PipelineStep, 0, len(tasks))
+	for i := range tasks {
+		task := &tasks[i]
+		step := pipelinev1.PipelineStep{
+			Name:         task.Name,
+			RunIf:        convertNeedsToRunIf(task.Needs),
+			DisableHooks: task.DisableHooks,
+			SkipPolicy:   pipelinev1.SkipPolicy(task.SkipPolicy),
+
+			AllowFailure:          task.AllowFailure,
+			AllowFailureExitCodes: slices.Clone(task.AllowFailureExitCodes),
+		}
+
+		switch task.Kind {
+		case TaskKindContainer:
+			if task.Container != nil {
+				step.JobSpec = containerJobSpec(task.Container)
+				*containerTasks = append(*containerTasks, task.Name)
+			}
+		case TaskKindJob:
+			if task.Job != nil {
+				step.JobSpec = task.Job.DeepCopy()
+			}
+		case TaskKindJobRef:
+			if task.JobRef != nil {
+				step.JobRef = &pipelinev1.JobReference{
+					Kind: pipelinev1.JobReferenceKind(task.JobRef.Kind),
+					Name: task.JobRef.Name,
+					Key:  task.JobRef.Key,
+				}
+			}
+		case TaskKindTemplate:
+			if task.TemplateRef != nil {
+				step.TemplateRef = &pipelinev1.StepTemplateRef{
+					Kind:   pipelinev1.StepTemplateKind(task.TemplateRef.Kind),
+					Name:   task.TemplateRef.Name,
+					Params: convertParamsToV1(task.TemplateRef.Params),
+				}
+			}
+		}
+
+		if task.ForEach != nil {
+			step.ForEach = replacePrefix(task.ForEach.Items, v2ResultPrefix, v1ResultPrefix)
+			step.ForEachParallelism = task.ForEach.Parallelism
+		}
+
+		steps = append(steps, step)
+	}
+	return steps
+}
+
+// convertStepsToTasks converts v1 steps to v2 tasks
+// Steps listed in containerTasks whose jobSpec still has the compact form become Container tasks
+func convertStepsToTasks(steps []pipelinev1.PipelineStep, containerTasks []string) []PipelineTask {
+	if steps == nil {
+		return nil
+	}
+
+	tasks := make([]PipelineTask, 0, len(steps))
+	for i := range steps {
+		step := &steps[i]
+		task := PipelineTask{
+			Name:         step.Name,
+			Needs:        convertRunIfToNeeds(step.RunIf),
+			DisableHooks: step.DisableHooks,
+			SkipPolicy:   SkipPolicy(step.SkipPolicy),
+
+			AllowFailure:          step.AllowFailure,
+			AllowFailureExitCodes: slices.Clone(step.AllowFailureExitCodes),
+		}
+
+		switch {
+		case step.TemplateRef != nil:
+			task.Kind = TaskKindTemplate
+			task.TemplateRef = &StepTemplateRef{
+				Kind:   StepTemplateKind(step.TemplateRef.Kind),
+				Name:   step.TemplateRef.Name,
+				Params: convertParamsFromV1(step.TemplateRef.Params),
+			}
+		case step.JobRef != nil:
+			task.Kind = TaskKindJobRef
+			task.JobRef = &JobReference{
+				Kind: JobReferenceKind(step.JobRef.Kind),
+				Name: step.JobRef.Name,
+				Key:  step.JobRef.Key,
+			}
+		default:
+			if container, ok := containerFromJobSpec(step.JobSpec); ok && slices.Contains(containerTasks, step.Name) {
+				task.Kind = TaskKindContainer
+				task.Container = container
+			} else {
+				task.Kind = TaskKindJob
+				task.Job = step.JobSpec.DeepCopy()
+			}
+		}
+
+		if step.ForEach != "" || step.ForEachParallelism != nil {
+			task.ForEach = &ForEachSpec{
+				Items:       replacePrefix(step.ForEach, v1ResultPrefix, v2ResultPrefix),
+				Parallelism: step.ForEachParallelism,
+			}
+		}
+
+		tasks = append(tasks, task)
+	}
+	return tasks
+}
+
+// containerJobSpec renders a Container task as the JobSpec it runs
+func containerJobSpec(container *ContainerTask) *batchv1.JobSpec {
+	return &batchv1.JobSpec{
+		BackoffLimit:          container.BackoffLimit,
+		ActiveDeadlineSeconds: container.ActiveDeadlineSeconds,
+		Template: corev1.PodTemplateSpec{
+			Spec: corev1.PodSpec{
+				RestartPolicy: corev1.RestartPolicyNever,
+				Containers: []corev1.Container{{
+					Name:         containerTaskName,
+					Image:        container.Image,
+					Command:      container.Command,
+					Args:         container.Args,
+					WorkingDir:   container.WorkingDir,
+					Env:          container.Env,
+					EnvFrom:      container.EnvFrom,
+					Resources:    container.Resources,
+					VolumeMounts: container.VolumeMounts,
+				}},
+			},
+		},
+	}
+}
+
+// containerFromJobSpec returns the Container task a JobSpec was rendered from
+// ok is false if the JobSpec uses anything the compact form cannot express
+func containerFromJobSpec(jobSpec *batchv1.JobSpec) (*ContainerTask, bool) {
+	if jobSpec == nil || len(jobSpec.Template.Spec.Containers) != 1 {
+		return nil, false
+	}
+
+	c := &jobSpec.Template.Spec.Containers[0]
+	container := &ContainerTask{
+		Image:                 c.Image,
+		Command:               c.Command,
+		Args:                  c.Args,
+		WorkingDir:            c.WorkingDir,
+		Env:                   c.Env,
+		EnvFrom:               c.EnvFrom,
+		Resources:             c.Resources,
+		VolumeMounts:          c.VolumeMounts,
+		BackoffLimit:          jobSpec.BackoffLimit,
+		ActiveDeadlineSeconds: jobSpec.ActiveDeadlineSeconds,
+	}
+	if !equality.Semantic.DeepEqual(containerJobSpec(container), jobSpec) {
+		return nil, false
+	}
+	return container, true
+}
+
+// convertNeedsToRunIf converts v2 task needs to a v1 runIf condition
+func convertNeedsToRunIf(needs *TaskNeeds) *pipelinev1.RunIfCondition {
+	if needs == nil {
+		return nil
+	}
+	return &pipelinev1.RunIfCondition{
+		Condition: pipelinev1.RunIfConditionType(needs.Condition),
+		Operator:  pipelinev1.RunIfOperator(needs.Operator),
+		Steps:     needs.Tasks,
+		Stages:    needs.Stages,
+	}
+}
+
+// convertRunIfToNeeds converts a v1 runIf condition to v2 task needs
+func convertRunIfToNeeds(runIf *pipelinev1.RunIfCondition) *TaskNeeds {
+	if runIf == nil {
+		return nil
+	}
+	return &TaskNeeds{
+		Tasks:     runIf.Steps,
+		Stages:    runIf.Stages,
+		Condition: NeedsCondition(runIf.Condition),
+		Operator:  NeedsOperator(runIf.Operator),
+	}
+}
+
+// convertTaskStatusToStepStatus converts a v2 task status to a v1 step status
+func convertTaskStatusToStepStatus(task TaskStatus) pipelinev1.StepStatus {
+	step := pipelinev1.StepStatus{
+		Name:           task.Name,
+		Phase:          pipelinev1.StepPhase(task.Phase),
+		JobName:        task.JobName,
+		Attempt:        task.Attempt,
+		JobStatus:      (*pipelinev1.JobStatusSummary)(task.JobStatus),
+		StartTime:      task.StartTime,
+		CompletionTime: task.CompletionTime,
+		Duration:       task.Duration,
+		Reason:         task.Reason,
+		Message:        task.Message,
+		StuckSince:     task.StuckSince,
+		Indexes:        (*pipelinev1.IndexStatus)(task.Indexes),
+		PodName:        task.PodName,
+		NodeName:       task.NodeName,
+		Results:        task.Results,
+	}
+	for _, container := range task.Containers {
+		step.Containers = append(step.Containers, pipelinev1.ContainerTermination(container))
+	}
+	step.Diagnostics = (*pipelinev1.FailureDiagnostics)(task.Diagnostics)
+	step.ArchivedLogs = task.ArchivedLogs
+	for _, retry := range task.AutoRetries {
+		step.AutoRetries = append(step.AutoRetries, pipelinev1.AutoRetry(retry))
+	}
+	for _, item := range task.Items {
+		step.Children = append(step.Children, pipelinev1.ForEachChildStatus{
+			Index:   item.Index,
+			Item:    item.Item,
+			Phase:   pipelinev1.StepPhase(item.Phase),
+			JobName: item.JobName,
+			Attempt: item.Attempt,
+		})
+	}
+	if task.JobRef != nil {
+		step.JobRef = &pipelinev1.ResolvedJobReference{
+			Kind:            pipelinev1.JobReferenceKind(task.JobRef.Kind),
+			Name:            task.JobRef.Name,
+			ResourceVersion: task.JobRef.ResourceVersion,
+		}
+	}
+	if task.Template != nil {
+		step.Template = &pipelinev1.ResolvedStepTemplate{
+			Kind:            pipelinev1.StepTemplateKind(task.Template.Kind),
+			Name:            task.Template.Name,
+			ResourceVersion: task.Template.ResourceVersion,
+			Generation:      task.Template.Generation,
+			JobSpec:         task.Template.JobSpec,
+		}
+	}
+	return step
+}
+
+// convertStepStatusToTaskStatus converts a v1 step status to a v2 task status
+func convertStepStatusToTaskStatus(step pipelinev1.StepStatus) TaskStatus {
+	task := TaskStatus{
+		Name:           step.Name,
+		Phase:          TaskPhase(step.Phase),
+		JobName:        step.JobName,
+		Attempt:        step.Attempt,
+		JobStatus:      (*JobStatusSummary)(step.JobStatus),
+		StartTime:      step.StartTime,
+		CompletionTime: step.CompletionTime,
+		Duration:       step.Duration,
+		Reason:         step.Reason,
+		Message:        step.Message,
+		StuckSince:     step.StuckSince,
+		Indexes:        (*IndexStatus)(step.Indexes),
+		PodName:        step.PodName,
+		NodeName:       step.NodeName,
+		Results:        step.Results,
+	}
+	for _, container := range step.Containers {
+		task.Containers = append(task.Containers, ContainerTermination(container))
+	}
+	task.Diagnostics = (*FailureDiagnostics)(step.Diagnostics)
+	task.ArchivedLogs = step.ArchivedLogs
+	for _, retry := range step.AutoRetries {
+		task.AutoRetries = append(task.AutoRetries, AutoRetry(retry))
+	}
+	for _, child := range step.Children {
+		task.Items = append(task.Items, ForEachItemStatus{
+			Index:   child.Index,
+			Item:    child.Item,
+			Phase:   TaskPhase(child.Phase),
+			JobName: child.JobName,
+			Attempt: child.Attempt,
+		})
+	}
+	if step.JobRef != nil {
+		task.JobRef = &ResolvedJobReference{
+			Kind:            JobReferenceKind(step.JobRef.Kind),
+			Name:            step.JobRef.Name,
+			ResourceVersion: step.JobRef.ResourceVersion,
+		}
+	}
+	if step.Template != nil {
+		task.Template = &ResolvedTemplate{
+			Kind:            StepTemplateKind(step.Template.Kind),
+			Name:            step.Template.Name,
+			ResourceVersion: step.Template.ResourceVersion,
+			Generation:      step.Template.Generation,
+			JobSpec:         step.Template.JobSpec,
+		}
+	}
+	return task
+}
+
+// convertParamsToV1 converts template parameters to v1
+func convertParamsToV1(params []TemplateParam) []pipelinev1.TemplateParam {
+	if params == nil {
+		return nil
+	}
+	out := make([]pipelinev1.TemplateParam, 0, len(params))
+	for _, param := range params {
+		out = append(out, pipelinev1.TemplateParam(param))
+	}
+	return out
+}
+
+// convertParamsFromV1 converts template parameters from v1
+func convertParamsFromV1(params []pipelinev1.TemplateParam) []TemplateParam {
+	if params == nil {
+		return nil
+	}
+	out := make([]TemplateParam, 0, len(params))
+	for _, param := range params {
+		out = append(out, TemplateParam(param))
+	}
+	return out
+}
+
+// convertLogArchiveToV1 converts the log archive settings to v1
+func convertLogArchiveToV1(archive *LogArchiveSpec) *pipelinev1.LogArchiveSpec {
+	if archive == nil {
+		return nil
+	}
+	return &pipelinev1.LogArchiveSpec{
+		Target:    pipelinev1.LogArchiveTarget(archive.Target),
+		Retain:    pipelinev1.LogArchiveRetention(archive.Retain),
+		ClaimName: archive.ClaimName,
+		Image:     archive.Image,
+	}
+}
+
+// convertLogArchiveFromV1 converts the log archive settings from v1
+func convertLogArchiveFromV1(archive *pipelinev1.LogArchiveSpec) *LogArchiveSpec {
+	if archive == nil {
+		return nil
+	}
+	return &LogArchiveSpec{
+		Target:    LogArchiveTarget(archive.Target),
+		Retain:    LogArchiveRetention(archive.Retain),
+		ClaimName: archive.ClaimName,
+		Image:     archive.Image,
+	}
+}
+
+// convertFailurePolicyToV1 converts the failure policy to v1
+func convertFailurePolicyToV1(policy *FailurePolicy) *pipelinev1.FailurePolicy {
+	if policy == nil {
+		return nil
+	}
+	return &pipelinev1.FailurePolicy{Mode: pipelinev1.FailureMode(policy.Mode)}
+}
+
+// convertFailurePolicyFromV1 converts the failure policy from v1
+func convertFailurePolicyFromV1(policy *pipelinev1.FailurePolicy) *FailurePolicy {
+	if policy == nil {
+		return nil
+	}
+	return &FailurePolicy{Mode: FailureMode(policy.Mode)}
+}
+
+// convertAutoRetryToV1 converts the automatic retry settings to v1
+func convertAutoRetryToV1(autoRetry *AutoRetrySpec) *pipelinev1.AutoRetrySpec {
+	if autoRetry == nil {
+		return nil
+	}
+	return &pipelinev1.AutoRetrySpec{
+		MaxDisruptionRetries: autoRetry.MaxDisruptionRetries,
+		OOMKilled:            (*pipelinev1.OOMRetrySpec)(autoRetry.OOMKilled),
+	}
+}
+
+// convertAutoRetryFromV1 converts the automatic retry settings from v1
+func convertAutoRetryFromV1(autoRetry *pipelinev1.AutoRetrySpec) *AutoRetrySpec {
+	if autoRetry == nil {
+		return nil
+	}
+	return &AutoRetrySpec{
+		MaxDisruptionRetries: autoRetry.MaxDisruptionRetries,
+		OOMKilled:            (*OOMRetrySpec)(autoRetry.OOMKilled),
+	}
+}
+
+// convertStuckPodsToV1 converts the stuck pod policy to v1
+func convertStuckPodsToV1(policy *StuckPodPolicy) *pipelinev1.StuckPodPolicy {
+	if policy == nil {
+		return nil
+	}
+	dst := &pipelinev1.StuckPodPolicy{GracePeriod: policy.GracePeriod}
+	for _, reason := range policy.FailFastOn {
+		dst.FailFastOn = append(dst.FailFastOn, pipelinev1.StuckReason(reason))
+	}
+	return dst
+}
+
+// convertStuckPodsFromV1 converts the stuck pod policy from v1
+func convertStuckPodsFromV1(policy *pipelinev1.StuckPodPolicy) *StuckPodPolicy {
+	if policy == nil {
+		return nil
+	}
+	dst := &StuckPodPolicy{GracePeriod: policy.GracePeriod}
+	for _, reason := range policy.FailFastOn {
+		dst.FailFastOn = append(dst.FailFastOn, StuckReason(reason))
+	}
+	return dst
+}
+
+// replacePrefix swaps the prefix of a result reference between versions
+func replacePrefix(ref, from, to string) string {
+	if rest, ok := strings.CutPrefix(ref, from); ok {
+		return to + rest
+	}
+	return ref
+}
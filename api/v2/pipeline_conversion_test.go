@@ -0,0 +1,218 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v2
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/randfill"
+
+	pipelinev1 "github.com/yaacov/jobrunner/api/v1"
+)
+
+const fuzzIterations = 200
+
+// newFiller returns a filler that only produces objects the API server would accept:
+// names are DNS labels and each step or task has exactly one job body
+// TypeMeta is left empty since the conversion webhook sets it on the result
+func newFiller(seed int64) *randfill.Filler {
+	name := func(c randfill.Continue) string {
+		return fmt.Sprintf("n%d", c.Uint32())
+	}
+
+	return randfill.NewWithSeed(seed).NilChance(0.3).NumElements(1, 2).Funcs(
+		func(*metav1.TypeMeta, randfill.Continue) {},
+		func(step *pipelinev1.PipelineStep, c randfill.Continue) {
+			c.FillNoCustom(step)
+			step.Name = name(c)
+			switch c.Intn(3) {
+			case 0:
+				step.JobRef, step.TemplateRef = nil, nil
+			case 1:
+				step.JobSpec, step.TemplateRef = nil, nil
+				if step.JobRef == nil {
+					step.JobRef = &pipelinev1.JobReference{}
+				}
+			case 2:
+				step.JobSpec, step.JobRef = nil, nil
+				if step.TemplateRef == nil {
+					step.TemplateRef = &pipelinev1.StepTemplateRef{}
+				}
+			}
+		},
+		func(task *PipelineTask, c randfill.Continue) {
+			c.FillNoCustom(task)
+			task.Name = name(c)
+			container, job, jobRef, templateRef := task.Container, task.Job, task.JobRef, task.TemplateRef
+			task.Container, task.Job, task.JobRef, task.TemplateRef = nil, nil, nil, nil
+			switch c.Intn(4) {
+			case 0:
+				task.Kind, task.Container = TaskKindContainer, container
+				if task.Container == nil {
+					task.Container = &ContainerTask{}
+				}
+			case 1:
+				task.Kind, task.Job = TaskKindJob, job
+				if task.Job == nil {
+					task.Job = &batchv1.JobSpec{}
+				}
+			case 2:
+				task.Kind, task.JobRef = TaskKindJobRef, jobRef
+				if task.JobRef == nil {
+					task.JobRef = &JobReference{}
+				}
+			case 3:
+				task.Kind, task.TemplateRef = TaskKindTemplate, templateRef
+				if task.TemplateRef == nil {
+					task.TemplateRef = &StepTemplateRef{}
+				}
+			}
+			if task.ForEach != nil && task.ForEach.Items == "" {
+				task.ForEach.Items = fmt.Sprintf("$(tasks.%s.results.items)", name(c))
+			}
+		},
+	)
+}
+
+func TestPipelineConversionRoundTrip(t *testing.T) {
+	t.Run("v1 to v2 and back", func(t *testing.T) {
+		f := newFiller(1)
+		for i := 0; i < fuzzIterations; i++ {
+			original := &pipelinev1.Pipeline{}
+			f.Fill(original)
+
+			spoke := &Pipeline{}
+			if err := spoke.ConvertFrom(original.DeepCopy()); err != nil {
+				t.Fatalf("ConvertFrom failed: %v", err)
+			}
+			hub := &pipelinev1.Pipeline{}
+			if err := spoke.ConvertTo(hub); err != nil {
+				t.Fatalf("ConvertTo failed: %v", err)
+			}
+
+			if !equality.Semantic.DeepEqual(original, hub) {
+				t.Fatalf("iteration %d: round trip changed the object (-original +converted):\n%s", i, cmp.Diff(original, hub))
+			}
+		}
+	})
+
+	t.Run("v2 to v1 and back", func(t *testing.T) {
+		f := newFiller(2)
+		for i := 0; i < fuzzIterations; i++ {
+			original := &Pipeline{}
+			f.Fill(original)
+
+			hub := &pipelinev1.Pipeline{}
+			if err := original.DeepCopy().ConvertTo(hub); err != nil {
+				t.Fatalf("ConvertTo failed: %v", err)
+			}
+			spoke := &Pipeline{}
+			if err := spoke.ConvertFrom(hub); err != nil {
+				t.Fatalf("ConvertFrom failed: %v", err)
+			}
+
+			if !equality.Semantic.DeepEqual(original, spoke) {
+				t.Fatalf("iteration %d: round trip changed the object (-original +converted):\n%s", i, cmp.Diff(original, spoke))
+			}
+		}
+	})
+}
+
+func TestPipelineConversion(t *testing.T) {
+	v2Pipeline := &Pipeline{
+		ObjectMeta: metav1.ObjectMeta{Name: "release"},
+		Spec: PipelineSpec{Tasks: []PipelineTask{
+			{
+				Name: "build",
+				Kind: TaskKindContainer,
+				Container: &ContainerTask{
+					Image:   "golang:1.24",
+					Command: []string{"make", "build"},
+				},
+			},
+			{
+				Name:    "shard",
+				Kind:    TaskKindTemplate,
+				Needs:   &TaskNeeds{Tasks: []string{"build"}, Condition: NeedsConditionSuccess},
+				ForEach: &ForEachSpec{Items: "$(tasks.build.results.shards)"},
+				TemplateRef: &StepTemplateRef{
+					Name:   "process",
+					Params: []TemplateParam{{Name: "mode", Value: "fast"}},
+				},
+			},
+		}},
+	}
+
+	hub := &pipelinev1.Pipeline{}
+	if err := v2Pipeline.ConvertTo(hub); err != nil {
+		t.Fatalf("ConvertTo failed: %v", err)
+	}
+
+	if hub.Annotations[ContainerTasksAnnotation] != "build" {
+		t.Errorf("expected container tasks annotation %q, got %q", "build", hub.Annotations[ContainerTasksAnnotation])
+	}
+	build := hub.Spec.Steps[0]
+	podSpec := build.JobSpec.Template.Spec
+	if podSpec.RestartPolicy != corev1.RestartPolicyNever || len(podSpec.Containers) != 1 {
+		t.Fatalf("expected a single container with restartPolicy Never, got %+v", podSpec)
+	}
+	if podSpec.Containers[0].Name != "main" || podSpec.Containers[0].Image != "golang:1.24" {
+		t.Errorf("unexpected container %+v", podSpec.Containers[0])
+	}
+	shard := hub.Spec.Steps[1]
+	if shard.ForEach != "$(steps.build.results.shards)" {
+		t.Errorf("expected forEach to reference steps, got %q", shard.ForEach)
+	}
+	if shard.RunIf == nil || shard.RunIf.Steps[0] != "build" || shard.TemplateRef.Name != "process" {
+		t.Errorf("unexpected step %+v", shard)
+	}
+
+	t.Run("edited container step converts back as a Job task", func(t *testing.T) {
+		edited := hub.DeepCopy()
+		edited.Spec.Steps[0].JobSpec.Template.Spec.NodeName = "node-1"
+
+		spoke := &Pipeline{}
+		if err := spoke.ConvertFrom(edited); err != nil {
+			t.Fatalf("ConvertFrom failed: %v", err)
+		}
+		if spoke.Spec.Tasks[0].Kind != TaskKindJob || spoke.Spec.Tasks[0].Job == nil {
+			t.Errorf("expected a Job task, got %+v", spoke.Spec.Tasks[0])
+		}
+		if _, ok := spoke.Annotations[ContainerTasksAnnotation]; ok {
+			t.Error("expected the conversion annotation to be removed")
+		}
+	})
+
+	t.Run("v1 steps without the annotation convert to Job tasks", func(t *testing.T) {
+		plain := hub.DeepCopy()
+		delete(plain.Annotations, ContainerTasksAnnotation)
+
+		spoke := &Pipeline{}
+		if err := spoke.ConvertFrom(plain); err != nil {
+			t.Fatalf("ConvertFrom failed: %v", err)
+		}
+		if spoke.Spec.Tasks[0].Kind != TaskKindJob {
+			t.Errorf("expected a Job task, got %s", spoke.Spec.Tasks[0].Kind)
+		}
+	})
+}
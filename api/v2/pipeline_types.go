@@ -0,0 +1,1047 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v2
+
+import (
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// PipelineSpec defines the desired state of Pipeline
+// +kubebuilder:validation:XValidation:rule="(has(self.tasks) && size(self.tasks) > 0) || (has(self.stages) && size(self.stages) > 0)",message="at least one task or stage is required"
+type PipelineSpec struct {
+	// Tasks defines the list of jobs to run
+	// +optional
+	Tasks []PipelineTask `json:"tasks,omitempty"`
+
+	// Stages groups tasks; by default each stage runs after the top-level tasks and
+	// the previous stage succeed. Task names are unique across all stages
+	// +optional
+	Stages []PipelineStage `json:"stages,omitempty"`
+
+	// ServiceAccountName is the service account to use for all jobs
+	// +optional
+	ServiceAccountName string `json:"serviceAccountName,omitempty"`
+
+	// SharedVolume defines a volume that will be mounted to all tasks
+	// +optional
+	SharedVolume *SharedVolumeSpec `json:"sharedVolume,omitempty"`
+
+	// PodTemplate defines common pod configuration applied to all tasks
+	// +optional
+	PodTemplate *PodTemplateDefaults `json:"podTemplate,omitempty"`
+
+	// JobDefaults defines common Job settings applied to all tasks
+	// +optional
+	JobDefaults *JobDefaults `json:"jobDefaults,omitempty"`
+
+	// Hooks defines containers injected into every task's job
+	// +optional
+	Hooks *PipelineHooks `json:"hooks,omitempty"`
+
+	// ResolveTasks records each task's effective JobSpec, with all pipeline defaults
+	// applied, in status.resolvedTasks
+	// +optional
+	ResolveTasks bool `json:"resolveTasks,omitempty"`
+
+	// LogArchive persists the logs of every task attempt when it finishes, so they
+	// outlive the task's Job and pods
+	// +optional
+	LogArchive *LogArchiveSpec `json:"logArchive,omitempty"`
+
+	// OnJobLost selects what happens to a running task whose Job is deleted, for example by
+	// hand or by a ttlSecondsAfterFinished race. Finished tasks always keep their phase
+	// +kubebuilder:default=Fail
+	// +optional
+	OnJobLost JobLostPolicy `json:"onJobLost,omitempty"`
+
+	// StatusOverflow selects where per-task details go once the status grows large.
+	// With ConfigMap, resolvedTasks and each task's containers and archivedLogs move
+	// to a ConfigMap named in status.taskDetailsConfigMap, keeping the status well
+	// under the object size limit of pipelines with thousands of tasks
+	// +kubebuilder:default=None
+	// +optional
+	StatusOverflow StatusOverflowPolicy `json:"statusOverflow,omitempty"`
+
+	// SkipPolicy selects how a skipped task or stage counts for the tasks that depend
+	// on it. Tasks can override it
+	// +kubebuilder:default=Propagate
+	// +optional
+	SkipPolicy SkipPolicy `json:"skipPolicy,omitempty"`
+
+	// FailurePolicy selects what happens to the other tasks once a task fails
+	// +optional
+	FailurePolicy *FailurePolicy `json:"failurePolicy,omitempty"`
+
+	// AutoRetry retries tasks that failed for reasons outside their code with a new Job,
+	// so the retries do not count against the Job's backoffLimit. Without it, tasks
+	// whose pod was disrupted are retried up to 3 times
+	// +optional
+	AutoRetry *AutoRetrySpec `json:"autoRetry,omitempty"`
+
+	// StuckPods fails tasks whose pod is stuck, such as a pod that cannot pull its image,
+	// instead of leaving them running. Stuck pods are reported in the task status either way
+	// +optional
+	StuckPods *StuckPodPolicy `json:"stuckPods,omitempty"`
+
+	// TaskCompletion selects when a task finishes: OutcomeKnown as soon as its Job's outcome
+	// is known, or PodsTerminated once its pods terminated as well
+	// +kubebuilder:default=OutcomeKnown
+	// +optional
+	TaskCompletion TaskCompletion `json:"taskCompletion,omitempty"`
+}
+
+// TaskCompletion selects when a task finishes
+// +kubebuilder:validation:Enum=OutcomeKnown;PodsTerminated
+type TaskCompletion string
+
+const (
+	// TaskCompletionOutcomeKnown finishes a task on the SuccessCriteriaMet or FailureTarget
+	// condition of its Job, while its pods may still be terminating
+	TaskCompletionOutcomeKnown TaskCompletion = "OutcomeKnown"
+	// TaskCompletionPodsTerminated waits for the Complete or Failed condition, which the Job
+	// gets once its pods terminated
+	TaskCompletionPodsTerminated TaskCompletion = "PodsTerminated"
+)
+
+// StuckPodPolicy fails tasks whose pod stays stuck for longer than a grace period
+type StuckPodPolicy struct {
+	// FailFastOn lists the stuck states that fail a task once they outlast the grace period
+	// +listType=set
+	// +optional
+	FailFastOn []StuckReason `json:"failFastOn,omitempty"`
+
+	// GracePeriod is how long a pod may stay stuck before its task fails
+	// +kubebuilder:default="5m"
+	// +optional
+	GracePeriod *metav1.Duration `json:"gracePeriod,omitempty"`
+}
+
+// StuckReason is a state in which a pod cannot make progress on its own
+// +kubebuilder:validation:Enum=ImagePullBackOff;Unschedulable;CrashLoopBackOff
+type StuckReason string
+
+const (
+	// StuckImagePullBackOff covers containers that cannot pull their image, including
+	// ErrImagePull and InvalidImageName
+	StuckImagePullBackOff StuckReason = "ImagePullBackOff"
+	// StuckUnschedulable covers pods the scheduler cannot place on a node
+	StuckUnschedulable StuckReason = "Unschedulable"
+	// StuckCrashLoopBackOff covers containers restarted over and over after crashing
+	StuckCrashLoopBackOff StuckReason = "CrashLoopBackOff"
+)
+
+// AutoRetrySpec configures automatic retries of tasks that failed for reasons outside their code
+type AutoRetrySpec struct {
+	// MaxDisruptionRetries is how many times a task is retried after its pod was evicted,
+	// preempted or removed by a node drain, as reported by a DisruptionTarget pod condition.
+	// Setting it also makes task Jobs with restartPolicy Never replace disrupted pods
+	// without counting them against backoffLimit. Unset or 0 turns disruption retries off
+	// +kubebuilder:validation:Minimum=0
+	// +optional
+	MaxDisruptionRetries *int32 `json:"maxDisruptionRetries,omitempty"`
+
+	// OOMKilled retries tasks whose container ran out of memory with more memory
+	// +optional
+	OOMKilled *OOMRetrySpec `json:"oomKilled,omitempty"`
+}
+
+// OOMRetrySpec retries OOM killed tasks with larger memory requests and limits
+type OOMRetrySpec struct {
+	// MaxRetries is how many times a task is retried after an OOM kill
+	// +kubebuilder:default=2
+	// +kubebuilder:validation:Minimum=1
+	// +optional
+	MaxRetries int32 `json:"maxRetries,omitempty"`
+
+	// MemoryIncreasePercent grows the memory requests and limits of every container by
+	// this percentage on each retry; 100 doubles them
+	// +kubebuilder:default=100
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:validation:Maximum=1000
+	// +optional
+	MemoryIncreasePercent int32 `json:"memoryIncreasePercent,omitempty"`
+
+	// MaxMemory caps the grown memory requests and limits
+	MaxMemory resource.Quantity `json:"maxMemory"`
+}
+
+// FailurePolicy selects what happens to the other tasks once a task fails
+type FailurePolicy struct {
+	// Mode is Continue to let running and pending tasks carry on, or FailFast to cancel
+	// running tasks and skip pending ones. Failure handlers, tasks whose runIf or
+	// stage runIf checks for failure, run in both modes
+	// +kubebuilder:default=Continue
+	// +optional
+	Mode FailureMode `json:"mode,omitempty"`
+}
+
+// FailureMode selects what happens to the other tasks once a task fails
+// +kubebuilder:validation:Enum=Continue;FailFast
+type FailureMode string
+
+const (
+	// FailureModeContinue lets the other tasks carry on after a failure
+	FailureModeContinue FailureMode = "Continue"
+	// FailureModeFailFast deletes the Jobs of running tasks after a failure, and marks
+	// running and pending tasks Skipped with reason Cancelled
+	FailureModeFailFast FailureMode = "FailFast"
+)
+
+// SkipPolicy selects how a skipped task or stage counts for the tasks that depend on it
+// +kubebuilder:validation:Enum=Propagate;TreatAsSuccess;TreatAsFailure;Ignore
+type SkipPolicy string
+
+const (
+	// SkipPropagate skips sequential tasks after a skipped one; a skipped task matches
+	// no condition, so an "and" condition over it is not met
+	SkipPropagate SkipPolicy = "Propagate"
+	// SkipTreatAsSuccess counts a skipped task as succeeded
+	SkipTreatAsSuccess SkipPolicy = "TreatAsSuccess"
+	// SkipTreatAsFailure counts a skipped task as failed
+	SkipTreatAsFailure SkipPolicy = "TreatAsFailure"
+	// SkipIgnore leaves skipped tasks out; a condition whose tasks were all skipped is not met
+	SkipIgnore SkipPolicy = "Ignore"
+)
+
+// StatusOverflowPolicy selects where per-task details go once the status grows large
+// +kubebuilder:validation:Enum=None;ConfigMap
+type StatusOverflowPolicy string
+
+const (
+	// StatusOverflowNone keeps all task details in the status
+	StatusOverflowNone StatusOverflowPolicy = "None"
+	// StatusOverflowConfigMap moves task details to a ConfigMap owned by the pipeline
+	StatusOverflowConfigMap StatusOverflowPolicy = "ConfigMap"
+)
+
+// JobLostPolicy selects what happens to a task whose Job disappears before it finishes
+// +kubebuilder:validation:Enum=Fail;Recreate;Ignore
+type JobLostPolicy string
+
+const (
+	// JobLostFail fails the task with reason JobLost
+	JobLostFail JobLostPolicy = "Fail"
+	// JobLostRecreate creates the task's Job again
+	JobLostRecreate JobLostPolicy = "Recreate"
+	// JobLostIgnore leaves the task in its last recorded phase
+	JobLostIgnore JobLostPolicy = "Ignore"
+)
+
+// LogArchiveTarget selects where step logs are archived
+// +kubebuilder:validation:Enum=ConfigMap;PVC
+type LogArchiveTarget string
+
+const (
+	// LogArchiveTargetConfigMap stores each attempt's logs in its own ConfigMap
+	LogArchiveTargetConfigMap LogArchiveTarget = "ConfigMap"
+	// LogArchiveTargetPVC writes each attempt's logs to a PersistentVolumeClaim
+	LogArchiveTargetPVC LogArchiveTarget = "PVC"
+)
+
+// LogArchiveRetention selects how long archived logs are kept
+// +kubebuilder:validation:Enum=WithPipeline;Forever
+type LogArchiveRetention string
+
+const (
+	// LogArchiveRetainWithPipeline deletes archived logs together with the pipeline
+	LogArchiveRetainWithPipeline LogArchiveRetention = "WithPipeline"
+	// LogArchiveRetainForever keeps archived logs after the pipeline is deleted
+	LogArchiveRetainForever LogArchiveRetention = "Forever"
+)
+
+// LogArchiveSpec defines where the logs of finished tasks are archived
+// +kubebuilder:validation:XValidation:rule="self.target != 'PVC' || (has(self.claimName) && size(self.claimName) > 0)",message="claimName is required for the PVC target"
+type LogArchiveSpec struct {
+	// Target selects where logs are archived
+	// +kubebuilder:validation:Required
+	Target LogArchiveTarget `json:"target"`
+
+	// Retain selects whether archived logs are deleted with the pipeline
+	// Only applies to the ConfigMap target; files written to a PVC are never deleted
+	// +kubebuilder:default=WithPipeline
+	// +optional
+	Retain LogArchiveRetention `json:"retain,omitempty"`
+
+	// ClaimName is the PersistentVolumeClaim logs are written to, for the PVC target
+	// +optional
+	ClaimName string `json:"claimName,omitempty"`
+
+	// Image runs the job that copies logs to the PVC; it must provide sh and cp
+	// +kubebuilder:default="busybox:1.36"
+	// +optional
+	Image string `json:"image,omitempty"`
+}
+
+// PipelineHooks defines containers that run around every task
+type PipelineHooks struct {
+	// Pre containers run as init containers before the task's own init containers
+	// +optional
+	Pre []corev1.Container `json:"pre,omitempty"`
+
+	// Post containers run as native sidecars next to the task's containers
+	// +optional
+	Post []corev1.Container `json:"post,omitempty"`
+}
+
+// SharedVolumeSpec defines the shared volume configuration
+type SharedVolumeSpec struct {
+	// Name is the name of the volume
+	// +kubebuilder:default=workspace
+	// +optional
+	Name string `json:"name,omitempty"`
+
+	// MountPath is where the volume will be mounted in each task
+	// +kubebuilder:default=/workspace
+	// +optional
+	MountPath string `json:"mountPath,omitempty"`
+
+	// VolumeSource defines the volume source (PVC, emptyDir, etc.)
+	corev1.VolumeSource `json:",inline"`
+}
+
+// JobDefaults defines common Job settings applied to all tasks
+// Each field only applies to tasks whose job spec leaves it unset
+type JobDefaults struct {
+	// BackoffLimit is the number of retries before a task's Job fails; without it,
+	// tasks get 0
+	// +kubebuilder:validation:Minimum=0
+	// +optional
+	BackoffLimit *int32 `json:"backoffLimit,omitempty"`
+
+	// ActiveDeadlineSeconds limits how long a task's Job may run
+	// +kubebuilder:validation:Minimum=1
+	// +optional
+	ActiveDeadlineSeconds *int64 `json:"activeDeadlineSeconds,omitempty"`
+
+	// TTLSecondsAfterFinished deletes a task's Job this long after it finished
+	// +kubebuilder:validation:Minimum=0
+	// +optional
+	TTLSecondsAfterFinished *int32 `json:"ttlSecondsAfterFinished,omitempty"`
+
+	// PodFailurePolicy decides how pod failures count against the backoff limit
+	// +optional
+	PodFailurePolicy *batchv1.PodFailurePolicy `json:"podFailurePolicy,omitempty"`
+
+	// CompletionMode is NonIndexed or Indexed
+	// +optional
+	CompletionMode *batchv1.CompletionMode `json:"completionMode,omitempty"`
+
+	// PodReplacementPolicy selects when failed pods are replaced
+	// +optional
+	PodReplacementPolicy *batchv1.PodReplacementPolicy `json:"podReplacementPolicy,omitempty"`
+}
+
+// PodTemplateDefaults defines common pod settings applied to all tasks
+type PodTemplateDefaults struct {
+	// NodeSelector must match a node's labels for pods to be scheduled
+	// +optional
+	NodeSelector map[string]string `json:"nodeSelector,omitempty"`
+
+	// Affinity defines scheduling constraints
+	// +optional
+	Affinity *corev1.Affinity `json:"affinity,omitempty"`
+
+	// Tolerations allow scheduling onto nodes with matching taints
+	// +optional
+	Tolerations []corev1.Toleration `json:"tolerations,omitempty"`
+
+	// SecurityContext holds pod-level security attributes
+	// +optional
+	SecurityContext *corev1.PodSecurityContext `json:"securityContext,omitempty"`
+
+	// ImagePullSecrets for pulling container images
+	// +optional
+	ImagePullSecrets []corev1.LocalObjectReference `json:"imagePullSecrets,omitempty"`
+
+	// PriorityClassName for pod priority
+	// +optional
+	PriorityClassName string `json:"priorityClassName,omitempty"`
+
+	// RuntimeClassName for container runtime
+	// +optional
+	RuntimeClassName *string `json:"runtimeClassName,omitempty"`
+
+	// SchedulerName for custom scheduler
+	// +optional
+	SchedulerName string `json:"schedulerName,omitempty"`
+
+	// Labels to add to all pods
+	// +optional
+	Labels map[string]string `json:"labels,omitempty"`
+
+	// Annotations to add to all pods
+	// +optional
+	Annotations map[string]string `json:"annotations,omitempty"`
+
+	// DefaultResources applied to containers without resource specs
+	// +optional
+	DefaultResources *corev1.ResourceRequirements `json:"defaultResources,omitempty"`
+
+	// Image is the default container image applied to containers without an image
+	// +optional
+	Image string `json:"image,omitempty"`
+
+	// Env variables injected into all containers
+	// +optional
+	Env []corev1.EnvVar `json:"env,omitempty"`
+
+	// EnvFrom sources injected into all containers
+	// +optional
+	EnvFrom []corev1.EnvFromSource `json:"envFrom,omitempty"`
+}
+
+// TaskKind selects how a task's job body is defined
+// +kubebuilder:validation:Enum=Container;Job;JobRef;Template
+type TaskKind string
+
+const (
+	// TaskKindContainer runs a single container
+	TaskKindContainer TaskKind = "Container"
+	// TaskKindJob runs a full JobSpec
+	TaskKindJob TaskKind = "Job"
+	// TaskKindJobRef copies the job template from an existing object
+	TaskKindJobRef TaskKind = "JobRef"
+	// TaskKindTemplate uses a StepTemplate or ClusterStepTemplate
+	TaskKindTemplate TaskKind = "Template"
+)
+
+// PipelineTask defines a single task in the pipeline
+// +kubebuilder:validation:XValidation:rule="self.kind != 'Container' || has(self.container)",message="container is required for Container tasks"
+// +kubebuilder:validation:XValidation:rule="self.kind != 'Job' || has(self.job)",message="job is required for Job tasks"
+// +kubebuilder:validation:XValidation:rule="self.kind != 'JobRef' || has(self.jobRef)",message="jobRef is required for JobRef tasks"
+// +kubebuilder:validation:XValidation:rule="self.kind != 'Template' || has(self.templateRef)",message="templateRef is required for Template tasks"
+// +kubebuilder:validation:XValidation:rule="[has(self.container), has(self.job), has(self.jobRef), has(self.templateRef)].filter(x, x).size() == 1",message="exactly one of container, job, jobRef or templateRef must be set"
+type PipelineTask struct {
+	// Name is the unique identifier for this task
+	// +kubebuilder:validation:Required
+	// +kubebuilder:validation:MinLength=1
+	// +kubebuilder:validation:MaxLength=63
+	// +kubebuilder:validation:Pattern=`^[a-z0-9]([-a-z0-9]*[a-z0-9])?$`
+	Name string `json:"name"`
+
+	// Kind selects which of container, job, jobRef or templateRef defines the task
+	// +kubebuilder:validation:Required
+	Kind TaskKind `json:"kind"`
+
+	// Needs defines conditional execution for this task, like runIf in v1
+	// If not specified, the task runs sequentially (after all tasks listed before it succeed)
+	// +optional
+	Needs *TaskNeeds `json:"needs,omitempty"`
+
+	// Container runs a single container with restartPolicy Never
+	// +optional
+	Container *ContainerTask `json:"container,omitempty"`
+
+	// Job is the specification of the job to run
+	// Stored schemaless to keep the CRD compact; it is validated when the Job is created
+	// +optional
+	// +kubebuilder:validation:Schemaless
+	// +kubebuilder:validation:Type=object
+	// +kubebuilder:pruning:PreserveUnknownFields
+	Job *batchv1.JobSpec `json:"job,omitempty"`
+
+	// JobRef copies the job template from an existing object when the task starts
+	// +optional
+	JobRef *JobReference `json:"jobRef,omitempty"`
+
+	// TemplateRef uses a StepTemplate or ClusterStepTemplate as the task body
+	// +optional
+	TemplateRef *StepTemplateRef `json:"templateRef,omitempty"`
+
+	// ForEach expands the task into one Job per item of a list emitted by an earlier task
+	// +optional
+	ForEach *ForEachSpec `json:"forEach,omitempty"`
+
+	// DisableHooks opts this task out of the pipeline's pre and post hooks
+	// +optional
+	DisableHooks bool `json:"disableHooks,omitempty"`
+
+	// SkipPolicy overrides the pipeline's skipPolicy for the tasks and stages this task needs
+	// +optional
+	SkipPolicy SkipPolicy `json:"skipPolicy,omitempty"`
+
+	// AllowFailure lets the task fail without failing the pipeline. The task is recorded as
+	// Failed with reason AllowedFailure, and the tasks after it and the pipeline phase treat
+	// it as succeeded
+	// +optional
+	AllowFailure bool `json:"allowFailure,omitempty"`
+
+	// AllowFailureExitCodes restricts allowFailure to failures where every container that
+	// failed exited with one of these codes
+	// +kubebuilder:validation:items:Minimum=1
+	// +kubebuilder:validation:items:Maximum=255
+	// +optional
+	AllowFailureExitCodes []int32 `json:"allowFailureExitCodes,omitempty"`
+}
+
+// ContainerTask is a compact job body made of a single container
+type ContainerTask struct {
+	// Image is the container image; defaults to podTemplate.image
+	// +optional
+	Image string `json:"image,omitempty"`
+
+	// Command is the entrypoint array
+	// +optional
+	Command []string `json:"command,omitempty"`
+
+	// Args are the arguments to the entrypoint
+	// +optional
+	Args []string `json:"args,omitempty"`
+
+	// WorkingDir is the container's working directory
+	// +optional
+	WorkingDir string `json:"workingDir,omitempty"`
+
+	// Env variables set in the container
+	// +optional
+	Env []corev1.EnvVar `json:"env,omitempty"`
+
+	// EnvFrom sources set in the container
+	// +optional
+	EnvFrom []corev1.EnvFromSource `json:"envFrom,omitempty"`
+
+	// Resources required by the container
+	// +optional
+	Resources corev1.ResourceRequirements `json:"resources,omitempty"`
+
+	// VolumeMounts to mount into the container
+	// +optional
+	VolumeMounts []corev1.VolumeMount `json:"volumeMounts,omitempty"`
+
+	// BackoffLimit is the number of retries before the task is marked failed
+	// +optional
+	BackoffLimit *int32 `json:"backoffLimit,omitempty"`
+
+	// ActiveDeadlineSeconds limits how long the task may run
+	// +optional
+	ActiveDeadlineSeconds *int64 `json:"activeDeadlineSeconds,omitempty"`
+}
+
+// ForEachSpec expands a task into one Job per item of a JSON array
+type ForEachSpec struct {
+	// Items references a JSON array emitted by an earlier task, as $(tasks.<task>.results.<name>)
+	// Each Job gets the item in the ITEM env var and its position in ITEM_INDEX
+	// The array can hold at most 500 items; a longer one fails the task
+	// +kubebuilder:validation:Pattern=`^\$\(tasks\.[a-z0-9]([-a-z0-9]*[a-z0-9])?\.results\.[a-zA-Z_][a-zA-Z0-9_-]*\)$`
+	Items string `json:"items"`
+
+	// Parallelism limits how many Jobs run at once
+	// If not specified, all items run in parallel
+	// +optional
+	// +kubebuilder:validation:Minimum=1
+	Parallelism *int32 `json:"parallelism,omitempty"`
+}
+
+// TaskNeeds defines when a task should run based on other tasks, and converts to a v1 RunIfCondition
+// +kubebuilder:validation:XValidation:rule="(has(self.tasks) && size(self.tasks) > 0) || (has(self.stages) && size(self.stages) > 0)",message="at least one task or stage must be referenced"
+type TaskNeeds struct {
+	// Tasks is the list of task names to check
+	// +optional
+	Tasks []string `json:"tasks,omitempty"`
+
+	// Stages is the list of stage names to check, each treated as a unit
+	// +optional
+	Stages []string `json:"stages,omitempty"`
+
+	// Condition determines whether to check for success or failure
+	// +kubebuilder:validation:Enum=success;fail
+	// +kubebuilder:default=success
+	// +optional
+	Condition NeedsCondition `json:"condition,omitempty"`
+
+	// Operator determines whether ALL or ANY of them must meet the condition
+	// +kubebuilder:validation:Enum=and;or
+	// +kubebuilder:default=and
+	// +optional
+	Operator NeedsOperator `json:"operator,omitempty"`
+}
+
+// NeedsCondition defines whether to check for success or failure
+type NeedsCondition string
+
+const (
+	// NeedsConditionSuccess checks if the tasks succeeded
+	NeedsConditionSuccess NeedsCondition = "success"
+	// NeedsConditionFail checks if the tasks failed
+	NeedsConditionFail NeedsCondition = "fail"
+)
+
+// NeedsOperator defines whether ALL or ANY tasks must meet the condition
+type NeedsOperator string
+
+const (
+	// NeedsOperatorAnd requires ALL tasks to meet the condition
+	NeedsOperatorAnd NeedsOperator = "and"
+	// NeedsOperatorOr requires ANY task to meet the condition
+	NeedsOperatorOr NeedsOperator = "or"
+)
+
+// StepTemplateKind is the kind of template a task references
+// +kubebuilder:validation:Enum=StepTemplate;ClusterStepTemplate
+type StepTemplateKind string
+
+// StepTemplateRef references a step template and the parameter values to use
+type StepTemplateRef struct {
+	// Kind is the kind of template
+	// +kubebuilder:default=StepTemplate
+	// +optional
+	Kind StepTemplateKind `json:"kind,omitempty"`
+
+	// Name is the name of the template
+	// +kubebuilder:validation:Required
+	// +kubebuilder:validation:MinLength=1
+	Name string `json:"name"`
+
+	// Params are the parameter values passed to the template
+	// +optional
+	// +listType=map
+	// +listMapKey=name
+	Params []TemplateParam `json:"params,omitempty"`
+}
+
+// TemplateParam is a parameter value passed to a step template
+type TemplateParam struct {
+	// Name is the parameter name
+	// +kubebuilder:validation:Required
+	Name string `json:"name"`
+
+	// Value is the parameter value
+	Value string `json:"value"`
+}
+
+// JobReferenceKind is the kind of object a task's job template is copied from
+// +kubebuilder:validation:Enum=CronJob;Job;ConfigMap
+type JobReferenceKind string
+
+// JobReference points to an object in the pipeline's namespace holding a job template
+type JobReference struct {
+	// Kind is the kind of the referenced object
+	// +kubebuilder:validation:Required
+	Kind JobReferenceKind `json:"kind"`
+
+	// Name is the name of the referenced object
+	// +kubebuilder:validation:Required
+	// +kubebuilder:validation:MinLength=1
+	Name string `json:"name"`
+
+	// Key is the ConfigMap data key holding the JobSpec YAML (ConfigMap only)
+	// +kubebuilder:default=jobSpec
+	// +optional
+	Key string `json:"key,omitempty"`
+}
+
+// PipelineStage groups tasks that run together and are tracked as a unit
+type PipelineStage struct {
+	// Name is the unique identifier for this stage
+	// +kubebuilder:validation:Required
+	// +kubebuilder:validation:MinLength=1
+	// +kubebuilder:validation:MaxLength=63
+	// +kubebuilder:validation:Pattern=`^[a-z0-9]([-a-z0-9]*[a-z0-9])?$`
+	Name string `json:"name"`
+
+	// DependsOn lists stages that must all succeed before this stage starts
+	// If neither DependsOn nor Needs is set, the stage waits for the previous stage
+	// +optional
+	DependsOn []string `json:"dependsOn,omitempty"`
+
+	// Needs defines conditional execution for the whole stage
+	// +optional
+	Needs *TaskNeeds `json:"needs,omitempty"`
+
+	// Tasks in this stage; without needs they run sequentially within the stage
+	// +kubebuilder:validation:MinItems=1
+	Tasks []PipelineTask `json:"tasks"`
+}
+
+// PipelinePhase represents the current phase of the pipeline
+// +kubebuilder:validation:Enum=Pending;Running;Suspended;Succeeded;Failed
+type PipelinePhase string
+
+// TaskPhase represents the current phase of a task
+// +kubebuilder:validation:Enum=Pending;Running;Suspended;Succeeded;Failed;Skipped
+type TaskPhase string
+
+// TaskStatus defines the observed state of a single task
+type TaskStatus struct {
+	// Name is the name of the task
+	Name string `json:"name"`
+
+	// Phase is the current phase of this task
+	Phase TaskPhase `json:"phase,omitempty"`
+
+	// JobName is the name of the Job created for this task
+	// +optional
+	JobName string `json:"jobName,omitempty"`
+
+	// Attempt counts the Jobs created for this task; a lost Job that is recreated
+	// starts a new attempt
+	// +optional
+	Attempt int32 `json:"attempt,omitempty"`
+
+	// JobStatus holds the pod counts and timings of the underlying job
+	// +optional
+	JobStatus *JobStatusSummary `json:"jobStatus,omitempty"`
+
+	// StartTime is when the task's Job started
+	// +optional
+	StartTime *metav1.Time `json:"startTime,omitempty"`
+
+	// CompletionTime is when the task's Job finished
+	// +optional
+	CompletionTime *metav1.Time `json:"completionTime,omitempty"`
+
+	// Duration is how long the task ran, set once it finishes
+	// +optional
+	Duration *metav1.Duration `json:"duration,omitempty"`
+
+	// Reason is a short CamelCase explanation of the task's state, such as
+	// BackoffLimitExceeded, DeadlineExceeded, OOMKilled, or a reason its pod is waiting
+	// on like Unschedulable or ImagePullBackOff
+	// +optional
+	Reason string `json:"reason,omitempty"`
+
+	// Message explains the task's reason, or a failure that happened outside of the task's Job
+	// +optional
+	Message string `json:"message,omitempty"`
+
+	// Indexes reports which indexes of an Indexed Job finished
+	// +optional
+	Indexes *IndexStatus `json:"indexes,omitempty"`
+
+	// StuckSince is when the task's pod got stuck in the state Reason names, cleared once
+	// the pod makes progress
+	// +optional
+	StuckSince *metav1.Time `json:"stuckSince,omitempty"`
+
+	// PodName is the name of the task's most recent pod
+	// +optional
+	PodName string `json:"podName,omitempty"`
+
+	// NodeName is the node the task's most recent pod was scheduled to
+	// +optional
+	NodeName string `json:"nodeName,omitempty"`
+
+	// Containers records how each container of the task's most recent pod terminated
+	// +optional
+	Containers []ContainerTermination `json:"containers,omitempty"`
+
+	// ArchivedLogs lists where the logs of each attempt were archived: ConfigMap names
+	// for the ConfigMap target, file paths relative to the claim for the PVC target
+	// +optional
+	ArchivedLogs []string `json:"archivedLogs,omitempty"`
+
+	// Diagnostics captures the termination message and log tail of the task's failed pod,
+	// so the reason for a failure outlives the Job and its pods
+	// +optional
+	Diagnostics *FailureDiagnostics `json:"diagnostics,omitempty"`
+
+	// Results emitted by the task as a JSON object in its termination message
+	// +optional
+	Results map[string]string `json:"results,omitempty"`
+
+	// Items tracks the Jobs created for each item of a forEach task
+	// +optional
+	Items []ForEachItemStatus `json:"items,omitempty"`
+
+	// JobRef records the object the job template was copied from, for jobRef tasks
+	// +optional
+	JobRef *ResolvedJobReference `json:"jobRef,omitempty"`
+
+	// AutoRetries records the failed attempts that were retried automatically
+	// +optional
+	AutoRetries []AutoRetry `json:"autoRetries,omitempty"`
+
+	// Template pins the step template version resolved when the pipeline started
+	// +optional
+	Template *ResolvedTemplate `json:"template,omitempty"`
+}
+
+// IndexStatus reports the per-index progress of an Indexed Job
+type IndexStatus struct {
+	// Completions is the number of indexes the Job runs
+	Completions int32 `json:"completions"`
+
+	// Completed lists the indexes that succeeded as intervals, such as "0-3,5"
+	// +optional
+	Completed string `json:"completed,omitempty"`
+
+	// Failed lists the indexes that failed, for Jobs with backoffLimitPerIndex
+	// +optional
+	Failed string `json:"failed,omitempty"`
+}
+
+// AutoRetry records a failed attempt of a task that was retried automatically
+type AutoRetry struct {
+	// Attempt is the attempt that failed
+	Attempt int32 `json:"attempt"`
+
+	// Index is the forEach item whose Job failed, unset for the task's own Job
+	// +optional
+	Index *int32 `json:"index,omitempty"`
+
+	// JobName is the name of the Job that failed
+	JobName string `json:"jobName"`
+
+	// Reason is Disrupted or OOMKilled
+	Reason string `json:"reason"`
+
+	// Message explains the failure
+	// +optional
+	Message string `json:"message,omitempty"`
+
+	// Time is when the retry was started
+	Time metav1.Time `json:"time"`
+}
+
+// FailureDiagnostics captures output of a failed container, truncated to a bounded size
+type FailureDiagnostics struct {
+	// Container is the name of the container the diagnostics were read from
+	// +optional
+	Container string `json:"container,omitempty"`
+
+	// TerminationMessage is the container's termination message
+	// +optional
+	TerminationMessage string `json:"terminationMessage,omitempty"`
+
+	// LogTail holds the last lines of the container's log
+	// +optional
+	LogTail string `json:"logTail,omitempty"`
+}
+
+// JobStatusSummary is the part of a Job's status recorded for a task
+type JobStatusSummary struct {
+	// StartTime is when the Job started
+	// +optional
+	StartTime *metav1.Time `json:"startTime,omitempty"`
+
+	// CompletionTime is when the Job completed
+	// +optional
+	CompletionTime *metav1.Time `json:"completionTime,omitempty"`
+
+	// Active is the number of pending and running pods
+	// +optional
+	Active int32 `json:"active,omitempty"`
+
+	// Ready is the number of active pods that are ready
+	// +optional
+	Ready *int32 `json:"ready,omitempty"`
+
+	// Terminating is the number of pods that are terminating
+	// +optional
+	Terminating *int32 `json:"terminating,omitempty"`
+
+	// Succeeded is the number of pods that succeeded
+	// +optional
+	Succeeded int32 `json:"succeeded,omitempty"`
+
+	// Failed is the number of pods that failed
+	// +optional
+	Failed int32 `json:"failed,omitempty"`
+}
+
+// ContainerTermination records how a container of a task's pod terminated
+type ContainerTermination struct {
+	// Name is the name of the container
+	Name string `json:"name"`
+
+	// ExitCode is the container's exit code
+	ExitCode int32 `json:"exitCode"`
+
+	// Reason is why the container terminated, such as Completed, Error or OOMKilled
+	// +optional
+	Reason string `json:"reason,omitempty"`
+}
+
+// ForEachItemStatus defines the observed state of one item of a forEach task
+type ForEachItemStatus struct {
+	// Index is the position of the item in the forEach list
+	Index int32 `json:"index"`
+
+	// Item is the item value; strings are stored as-is, other values as JSON
+	Item string `json:"item"`
+
+	// Phase is the current phase of this item
+	Phase TaskPhase `json:"phase,omitempty"`
+
+	// JobName is the name of the Job created for this item
+	// +optional
+	JobName string `json:"jobName,omitempty"`
+
+	// Attempt counts the Jobs created for this item
+	// +optional
+	Attempt int32 `json:"attempt,omitempty"`
+}
+
+// ResolvedJobReference records the exact object version a task's job template came from
+type ResolvedJobReference struct {
+	// Kind is the kind of the referenced object
+	Kind JobReferenceKind `json:"kind"`
+
+	// Name is the name of the referenced object
+	Name string `json:"name"`
+
+	// ResourceVersion of the referenced object when the job template was copied
+	// +optional
+	ResourceVersion string `json:"resourceVersion,omitempty"`
+}
+
+// ResolvedTask is the JobSpec a task runs after pipeline defaults are applied
+type ResolvedTask struct {
+	// Name is the name of the task
+	Name string `json:"name"`
+
+	// JobSpec is the effective job body
+	// +kubebuilder:validation:Schemaless
+	// +kubebuilder:validation:Type=object
+	// +kubebuilder:pruning:PreserveUnknownFields
+	JobSpec *batchv1.JobSpec `json:"jobSpec,omitempty"`
+}
+
+// ResolvedTemplate pins the template version and rendered job body used by a task
+type ResolvedTemplate struct {
+	// Kind is the kind of template
+	Kind StepTemplateKind `json:"kind"`
+
+	// Name is the name of the template
+	Name string `json:"name"`
+
+	// ResourceVersion of the template when it was resolved
+	// +optional
+	ResourceVersion string `json:"resourceVersion,omitempty"`
+
+	// Generation of the template when it was resolved
+	// +optional
+	Generation int64 `json:"generation,omitempty"`
+
+	// JobSpec is the template job body with parameters substituted
+	// +kubebuilder:validation:Schemaless
+	// +kubebuilder:validation:Type=object
+	// +kubebuilder:pruning:PreserveUnknownFields
+	JobSpec *batchv1.JobSpec `json:"jobSpec,omitempty"`
+}
+
+// StageStatus defines the observed state of a stage, rolled up from its tasks
+type StageStatus struct {
+	// Name is the name of the stage
+	Name string `json:"name"`
+
+	// Phase is the aggregated phase of the stage's tasks
+	Phase TaskPhase `json:"phase,omitempty"`
+
+	// StartTime is when the first task of the stage started
+	// +optional
+	StartTime *metav1.Time `json:"startTime,omitempty"`
+
+	// CompletionTime is when the last task of the stage finished
+	// +optional
+	CompletionTime *metav1.Time `json:"completionTime,omitempty"`
+
+	// TaskCounts counts the stage's tasks by phase
+	TaskCounts StageTaskCounts `json:"taskCounts"`
+}
+
+// StageTaskCounts counts the tasks of a stage by phase
+type StageTaskCounts struct {
+	Total     int32 `json:"total"`
+	Pending   int32 `json:"pending,omitempty"`
+	Running   int32 `json:"running,omitempty"`
+	Suspended int32 `json:"suspended,omitempty"`
+	Succeeded int32 `json:"succeeded,omitempty"`
+	Failed    int32 `json:"failed,omitempty"`
+	Skipped   int32 `json:"skipped,omitempty"`
+}
+
+// PipelineStatus defines the observed state of Pipeline
+type PipelineStatus struct {
+	// Phase is the current phase of the pipeline
+	// +kubebuilder:default=Pending
+	Phase PipelinePhase `json:"phase,omitempty"`
+
+	// StartTime is when the pipeline started
+	// +optional
+	StartTime *metav1.Time `json:"startTime,omitempty"`
+
+	// CompletionTime is when the pipeline completed
+	// +optional
+	CompletionTime *metav1.Time `json:"completionTime,omitempty"`
+
+	// Duration is how long the pipeline ran, set once it completes
+	// +optional
+	Duration *metav1.Duration `json:"duration,omitempty"`
+
+	// Progress is the number of finished tasks out of the total, such as 3/7
+	// +optional
+	Progress string `json:"progress,omitempty"`
+
+	// Tasks contains the status of each task
+	// +optional
+	Tasks []TaskStatus `json:"tasks,omitempty"`
+
+	// Stages contains the rolled-up status of each stage
+	// +optional
+	Stages []StageStatus `json:"stages,omitempty"`
+
+	// ResolvedTasks contains the effective JobSpec of each task when spec.resolveTasks is set
+	// +optional
+	ResolvedTasks []ResolvedTask `json:"resolvedTasks,omitempty"`
+
+	// TaskDetailsConfigMap names the ConfigMap holding the task details moved out of the status
+	// when spec.statusOverflow is ConfigMap
+	// +optional
+	TaskDetailsConfigMap string `json:"taskDetailsConfigMap,omitempty"`
+
+	// Conditions represent the latest observations of the pipeline's state
+	// +optional
+	// +patchMergeKey=type
+	// +patchStrategy=merge
+	// +listType=map
+	// +listMapKey=type
+	Conditions []metav1.Condition `json:"conditions,omitempty" patchStrategy:"merge" patchMergeKey:"type"`
+}
+
+// +kubebuilder:object:root=true
+// +kubebuilder:subresource:status
+// +kubebuilder:resource:shortName=pl;pipe
+// +kubebuilder:printcolumn:name="Phase",type=string,JSONPath=`.status.phase`
+// +kubebuilder:printcolumn:name="Progress",type=string,JSONPath=`.status.progress`
+// +kubebuilder:printcolumn:name="Duration",type=string,JSONPath=`.status.duration`
+// +kubebuilder:printcolumn:name="Age",type=date,JSONPath=`.metadata.creationTimestamp`
+
+// Pipeline is the Schema for the pipelines API
+type Pipeline struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   PipelineSpec   `json:"spec,omitempty"`
+	Status PipelineStatus `json:"status,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// PipelineList contains a list of Pipeline
+type PipelineList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []Pipeline `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&Pipeline{}, &PipelineList{})
+}
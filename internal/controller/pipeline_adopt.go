@@ -0,0 +1,147 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+
+	batchv1 "k8s.io/api/batch/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	pipelinev1 "github.com/yaacov/jobrunner/api/v1"
+)
+
+// conditionReasonJobConflict marks a pipeline blocked by a Job it does not own
+const conditionReasonJobConflict = "JobConflict"
+
+// jobConflictError reports a Job that has the name of a step's Job but belongs elsewhere
+type jobConflictError struct {
+	jobName string
+	reason  string
+}
+
+func (e *jobConflictError) Error() string {
+	return fmt.Sprintf("job %s already exists and %s; delete it to let the pipeline continue", e.jobName, e.reason)
+}
+
+// createOrAdoptJob creates a step's Job, adopting an existing Job of the same name
+// when it was created by an earlier reconcile of this pipeline run
+// A Job created moments before a failed status update or a controller restart is
+// adopted instead of failing every following reconcile with AlreadyExists
+func (r *PipelineReconciler) createOrAdoptJob(ctx context.Context, pipeline *pipelinev1.Pipeline, job *batchv1.Job) error {
+	err := r.Create(ctx, job)
+	if err == nil || !apierrors.IsAlreadyExists(err) {
+		return err
+	}
+
+	existing := &batchv1.Job{}
+	if err := r.reader().Get(ctx, client.ObjectKeyFromObject(job), existing); err != nil {
+		return err
+	}
+	if err := checkJobOwnership(pipeline, job, existing); err != nil {
+		return err
+	}
+
+	log.FromContext(ctx).Info("Adopted existing job", "job", existing.Name, "step", existing.Labels[stepLabel])
+	return nil
+}
+
+// checkJobOwnership returns a jobConflictError unless existing is controlled by the pipeline
+// and carries the run, step and item labels the pipeline would have given it
+func checkJobOwnership(pipeline *pipelinev1.Pipeline, want, existing *batchv1.Job) error {
+	if !metav1.IsControlledBy(existing, pipeline) {
+		owner := "it has no controller"
+		if ref := metav1.GetControllerOf(existing); ref != nil {
+			owner = fmt.Sprintf("it is controlled by %s %s", ref.Kind, ref.Name)
+		}
+		return &jobConflictError{jobName: existing.Name, reason: owner}
+	}
+
+	for _, label := range []string{pipelineLabel, stepLabel, runUIDLabel, forEachIndexLabel} {
+		if existing.Labels[label] != want.Labels[label] {
+			return &jobConflictError{
+				jobName: existing.Name,
+				reason:  fmt.Sprintf("its label %s is %q, expected %q", label, existing.Labels[label], want.Labels[label]),
+			}
+		}
+	}
+	return nil
+}
+
+// recordJobConflict sets the Ready condition when err is a jobConflictError
+// Returns true if it was one
+func (r *PipelineReconciler) recordJobConflict(pipeline *pipelinev1.Pipeline, err error) bool {
+	var conflict *jobConflictError
+	if !errors.As(err, &conflict) {
+		return false
+	}
+
+	r.setNotReadyCondition(pipeline, conditionReasonJobConflict, conflict.Error())
+	return true
+}
+
+// latestStepJob returns the step's Job with the highest attempt in jobs, or nil if there is none
+// forEach item Jobs and Jobs being deleted are not considered
+func latestStepJob(jobs map[string]*batchv1.Job, stepName string) (*batchv1.Job, int32) {
+	var latest *batchv1.Job
+	var latestAttempt int32
+	for _, job := range jobs {
+		if job.Labels[stepLabel] != stepName {
+			continue
+		}
+		if _, isItem := job.Labels[forEachIndexLabel]; isItem || job.DeletionTimestamp != nil {
+			continue
+		}
+		attempt, err := strconv.ParseInt(job.Labels[stepAttemptLabel], 10, 32)
+		if err != nil {
+			continue
+		}
+		if latest == nil || int32(attempt) > latestAttempt {
+			latest, latestAttempt = job, int32(attempt)
+		}
+	}
+	return latest, latestAttempt
+}
+
+// recoverStepJob points a pending step without a Job at a newer Job of the run created
+// for it, which happens when the status update recording the Job was lost
+// Returns true if the step status changed
+func recoverStepJob(ctx context.Context, jobs map[string]*batchv1.Job, stepStatus *pipelinev1.StepStatus) bool {
+	if stepStatus.Phase != pipelinev1.StepPhasePending || stepStatus.JobName != "" || len(stepStatus.Children) > 0 {
+		return false
+	}
+
+	job, attempt := latestStepJob(jobs, stepStatus.Name)
+	if job == nil || attempt <= stepStatus.Attempt {
+		return false
+	}
+
+	log.FromContext(ctx).Info("Recovered step from existing job",
+		"step", stepStatus.Name,
+		"job", job.Name,
+		"attempt", attempt)
+	stepStatus.JobName = job.Name
+	stepStatus.Attempt = attempt
+	stepStatus.Phase = pipelinev1.StepPhaseRunning
+	return true
+}
@@ -0,0 +1,201 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"strings"
+	"testing"
+
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+
+	pipelinev1 "github.com/yaacov/jobrunner/api/v1"
+)
+
+func newAdoptPipeline() *pipelinev1.Pipeline {
+	return &pipelinev1.Pipeline{
+		ObjectMeta: metav1.ObjectMeta{Name: "release", Namespace: "default", UID: types.UID("release-uid")},
+		Spec: pipelinev1.PipelineSpec{Steps: []pipelinev1.PipelineStep{
+			{Name: "build", JobSpec: &batchv1.JobSpec{Template: corev1.PodTemplateSpec{Spec: corev1.PodSpec{
+				RestartPolicy: corev1.RestartPolicyNever,
+				Containers:    []corev1.Container{{Name: "main", Image: "busybox"}},
+			}}}},
+		}},
+		Status: pipelinev1.PipelineStatus{Steps: []pipelinev1.StepStatus{
+			{Name: "build", Phase: pipelinev1.StepPhasePending},
+		}},
+	}
+}
+
+// newExistingJob returns a Job for an attempt of the build step, controlled by owner
+func newExistingJob(t *testing.T, pipeline, owner *pipelinev1.Pipeline, attempt int32) *batchv1.Job {
+	job := &batchv1.Job{ObjectMeta: metav1.ObjectMeta{
+		Name:      stepJobName(pipeline, "build", attempt),
+		Namespace: "default",
+		Labels: map[string]string{
+			"pipeline.yaacov.io/pipeline": owner.Name,
+			"pipeline.yaacov.io/step":     "build",
+			runUIDLabel:                   string(owner.UID),
+			stepAttemptLabel:              strconv.Itoa(int(attempt)),
+		},
+	}}
+	if owner.UID != "" {
+		if err := controllerutil.SetControllerReference(owner, job, newFakeClient().Scheme()); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+	return job
+}
+
+func TestStartReadyStepsAdoption(t *testing.T) {
+	ctx := context.Background()
+
+	other := &pipelinev1.Pipeline{ObjectMeta: metav1.ObjectMeta{Name: "other", Namespace: "default", UID: types.UID("other-uid")}}
+	unowned := &pipelinev1.Pipeline{ObjectMeta: metav1.ObjectMeta{Name: "release"}}
+
+	tests := []struct {
+		name         string
+		owner        *pipelinev1.Pipeline
+		wantErr      string
+		wantPhase    pipelinev1.StepPhase
+		wantJobName  bool
+		wantReadyMsg string
+	}{
+		{
+			name:        "adopts a job created by an earlier reconcile",
+			wantPhase:   pipelinev1.StepPhaseRunning,
+			wantJobName: true,
+		},
+		{
+			name:         "rejects a job controlled by another object",
+			owner:        other,
+			wantErr:      "controlled by Pipeline other",
+			wantPhase:    pipelinev1.StepPhasePending,
+			wantReadyMsg: "controlled by Pipeline other",
+		},
+		{
+			name:         "rejects a job without a controller",
+			owner:        unowned,
+			wantErr:      "has no controller",
+			wantPhase:    pipelinev1.StepPhasePending,
+			wantReadyMsg: "has no controller",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pipeline := newAdoptPipeline()
+			owner := tt.owner
+			if owner == nil {
+				owner = pipeline
+			}
+			c := newFakeClient(pipeline, newExistingJob(t, pipeline, owner, 1))
+			r := &PipelineReconciler{Client: c, Scheme: c.Scheme()}
+
+			err := r.startReadySteps(ctx, pipeline)
+			if tt.wantErr == "" && err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if tt.wantErr != "" {
+				var conflict *jobConflictError
+				if !errors.As(err, &conflict) || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("expected a job conflict containing %q, got %v", tt.wantErr, err)
+				}
+			}
+
+			build := pipeline.Status.Steps[0]
+			if build.Phase != tt.wantPhase || (build.JobName != "") != tt.wantJobName {
+				t.Errorf("expected phase %s with job %v, got %s with job %q", tt.wantPhase, tt.wantJobName, build.Phase, build.JobName)
+			}
+
+			ready := meta.FindStatusCondition(pipeline.Status.Conditions, "Ready")
+			if tt.wantReadyMsg == "" {
+				if ready != nil && ready.Reason == conditionReasonJobConflict {
+					t.Errorf("expected no job conflict condition, got %q", ready.Message)
+				}
+				return
+			}
+			if ready == nil || ready.Reason != conditionReasonJobConflict || !strings.Contains(ready.Message, tt.wantReadyMsg) {
+				t.Errorf("expected a JobConflict condition containing %q, got %+v", tt.wantReadyMsg, ready)
+			}
+		})
+	}
+}
+
+func TestCheckJobOwnership(t *testing.T) {
+	pipeline := newAdoptPipeline()
+	want := newExistingJob(t, pipeline, pipeline, 1)
+
+	existing := want.DeepCopy()
+	existing.Labels["pipeline.yaacov.io/step"] = "deploy"
+
+	err := checkJobOwnership(pipeline, want, existing)
+	if err == nil || !strings.Contains(err.Error(), `its label pipeline.yaacov.io/step is "deploy", expected "build"`) {
+		t.Errorf("expected a label mismatch error, got %v", err)
+	}
+	if err := checkJobOwnership(pipeline, want, want.DeepCopy()); err != nil {
+		t.Errorf("expected a matching job to be adopted, got %v", err)
+	}
+}
+
+func TestUpdateStepStatusesRecoversJob(t *testing.T) {
+	ctx := context.Background()
+
+	tests := []struct {
+		name        string
+		attempt     int32
+		jobAttempt  int32
+		wantPhase   pipelinev1.StepPhase
+		wantJobName bool
+	}{
+		{name: "recovers a job whose status update was lost", jobAttempt: 1, wantPhase: pipelinev1.StepPhaseRunning, wantJobName: true},
+		{name: "recovers a later attempt", attempt: 1, jobAttempt: 2, wantPhase: pipelinev1.StepPhaseRunning, wantJobName: true},
+		{name: "ignores the job of an attempt already replaced", attempt: 1, jobAttempt: 1, wantPhase: pipelinev1.StepPhasePending},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pipeline := newAdoptPipeline()
+			pipeline.Status.Steps[0].Attempt = tt.attempt
+			job := newExistingJob(t, pipeline, pipeline, tt.jobAttempt)
+			c := newFakeClient(pipeline, job)
+			r := &PipelineReconciler{Client: c, Scheme: c.Scheme()}
+
+			if err := r.updateStepStatuses(ctx, pipeline); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			build := pipeline.Status.Steps[0]
+			if build.Phase != tt.wantPhase {
+				t.Errorf("expected phase %s, got %s", tt.wantPhase, build.Phase)
+			}
+			if tt.wantJobName && (build.JobName != job.Name || build.Attempt != tt.jobAttempt) {
+				t.Errorf("expected job %q attempt %d, got %q attempt %d", job.Name, tt.jobAttempt, build.JobName, build.Attempt)
+			}
+			if !tt.wantJobName && build.JobName != "" {
+				t.Errorf("expected no job, got %q", build.JobName)
+			}
+		})
+	}
+}
@@ -0,0 +1,91 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	pipelinev1 "github.com/yaacov/jobrunner/api/v1"
+)
+
+// stepReasonAllowedFailure marks a failed step whose failure does not fail the pipeline
+const stepReasonAllowedFailure = "AllowedFailure"
+
+// allowFailure records a step that just failed as an allowed failure when its spec sets
+// allowFailure, and, with allowFailureExitCodes, every failed container exited with one
+// of those codes. The original reason moves into the message
+// Returns true if the failure is allowed
+func allowFailure(step *pipelinev1.PipelineStep, stepStatus *pipelinev1.StepStatus) bool {
+	if step == nil || !step.AllowFailure || stepStatus.Phase != pipelinev1.StepPhaseFailed {
+		return false
+	}
+
+	var details []string
+	if len(step.AllowFailureExitCodes) > 0 {
+		for _, container := range stepStatus.Containers {
+			if container.ExitCode == 0 {
+				continue
+			}
+			if !slices.Contains(step.AllowFailureExitCodes, container.ExitCode) {
+				return false
+			}
+			details = append(details, fmt.Sprintf("container %s exited with %d", container.Name, container.ExitCode))
+		}
+		// Without a failed container, for example when the pod is already gone, the exit
+		// codes are unknown
+		if len(details) == 0 {
+			return false
+		}
+	} else if stepStatus.Reason != "" {
+		details = append(details, stepStatus.Reason)
+	}
+	if stepStatus.Message != "" {
+		details = append(details, stepStatus.Message)
+	}
+
+	stepStatus.Reason = stepReasonAllowedFailure
+	stepStatus.Message = "failure allowed"
+	if len(details) > 0 {
+		stepStatus.Message += ": " + strings.Join(details, ": ")
+	}
+	return true
+}
+
+// isAllowedFailure returns true if a step failed with a failure its spec allows
+func isAllowedFailure(stepStatus *pipelinev1.StepStatus) bool {
+	return stepStatus.Phase == pipelinev1.StepPhaseFailed && stepStatus.Reason == stepReasonAllowedFailure
+}
+
+// specStep returns the spec of a step by name, or nil if the spec has no such step
+func specStep(pipeline *pipelinev1.Pipeline, name string) *pipelinev1.PipelineStep {
+	for i := range pipeline.Spec.Steps {
+		if pipeline.Spec.Steps[i].Name == name {
+			return &pipeline.Spec.Steps[i]
+		}
+	}
+	for i := range pipeline.Spec.Stages {
+		stage := &pipeline.Spec.Stages[i]
+		for j := range stage.Steps {
+			if stage.Steps[j].Name == name {
+				return &stage.Steps[j]
+			}
+		}
+	}
+	return nil
+}
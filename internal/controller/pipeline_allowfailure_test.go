@@ -0,0 +1,194 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+
+	pipelinev1 "github.com/yaacov/jobrunner/api/v1"
+)
+
+func TestAllowFailure(t *testing.T) {
+	failed := func(exitCodes ...int32) *pipelinev1.StepStatus {
+		status := &pipelinev1.StepStatus{
+			Name:    "lint",
+			Phase:   pipelinev1.StepPhaseFailed,
+			Reason:  "BackoffLimitExceeded",
+			Message: "Job has reached the specified backoff limit",
+		}
+		for _, code := range exitCodes {
+			status.Containers = append(status.Containers, pipelinev1.ContainerTermination{Name: "main", ExitCode: code})
+		}
+		return status
+	}
+
+	tests := []struct {
+		name        string
+		step        *pipelinev1.PipelineStep
+		status      *pipelinev1.StepStatus
+		wantAllowed bool
+		wantMessage string
+	}{
+		{
+			name:   "failure not allowed by default",
+			step:   &pipelinev1.PipelineStep{Name: "lint"},
+			status: failed(1),
+		},
+		{
+			name:        "any failure allowed",
+			step:        &pipelinev1.PipelineStep{Name: "lint", AllowFailure: true},
+			status:      failed(1),
+			wantAllowed: true,
+			wantMessage: "failure allowed: BackoffLimitExceeded: Job has reached the specified backoff limit",
+		},
+		{
+			name:        "allowed exit code",
+			step:        &pipelinev1.PipelineStep{Name: "lint", AllowFailure: true, AllowFailureExitCodes: []int32{1, 2}},
+			status:      failed(0, 2),
+			wantAllowed: true,
+			wantMessage: "failure allowed: container main exited with 2: Job has reached the specified backoff limit",
+		},
+		{
+			name:   "other exit code",
+			step:   &pipelinev1.PipelineStep{Name: "lint", AllowFailure: true, AllowFailureExitCodes: []int32{1}},
+			status: failed(1, 137),
+		},
+		{
+			name:   "unknown exit codes",
+			step:   &pipelinev1.PipelineStep{Name: "lint", AllowFailure: true, AllowFailureExitCodes: []int32{1}},
+			status: failed(),
+		},
+		{
+			name:   "step without spec",
+			status: failed(1),
+		},
+		{
+			name:   "succeeded step",
+			step:   &pipelinev1.PipelineStep{Name: "lint", AllowFailure: true},
+			status: &pipelinev1.StepStatus{Name: "lint", Phase: pipelinev1.StepPhaseSucceeded},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			before := tt.status.DeepCopy()
+			allowed := allowFailure(tt.step, tt.status)
+			if allowed != tt.wantAllowed || isAllowedFailure(tt.status) != tt.wantAllowed {
+				t.Fatalf("expected allowed %v, got %v with reason %q", tt.wantAllowed, allowed, tt.status.Reason)
+			}
+			if !tt.wantAllowed {
+				if tt.status.Reason != before.Reason || tt.status.Message != before.Message {
+					t.Errorf("expected the status to be unchanged, got %+v", tt.status)
+				}
+				return
+			}
+			if tt.status.Message != tt.wantMessage {
+				t.Errorf("expected message %q, got %q", tt.wantMessage, tt.status.Message)
+			}
+		})
+	}
+}
+
+func TestUpdateStepStatusesAllowedFailure(t *testing.T) {
+	ctx := context.Background()
+
+	tests := []struct {
+		name        string
+		exitCode    int32
+		wantAllowed bool
+		wantPhase   pipelinev1.PipelinePhase
+	}{
+		{name: "allowed exit code lets the pipeline succeed", exitCode: 1, wantAllowed: true, wantPhase: pipelinev1.PipelinePhaseSucceeded},
+		{name: "other exit code fails the pipeline", exitCode: 2, wantPhase: pipelinev1.PipelinePhaseFailed},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pipeline := &pipelinev1.Pipeline{
+				ObjectMeta: metav1.ObjectMeta{Name: "release", Namespace: "default", UID: types.UID("release-uid")},
+				Spec: pipelinev1.PipelineSpec{Steps: []pipelinev1.PipelineStep{
+					{Name: "lint", AllowFailure: true, AllowFailureExitCodes: []int32{1}},
+					{Name: "build"},
+				}},
+				Status: pipelinev1.PipelineStatus{Steps: []pipelinev1.StepStatus{
+					{Name: "lint", Phase: pipelinev1.StepPhaseRunning, JobName: "release-lint", Attempt: 1},
+					{Name: "build", Phase: pipelinev1.StepPhasePending},
+				}},
+			}
+			job := &batchv1.Job{
+				ObjectMeta: metav1.ObjectMeta{Name: "release-lint", Namespace: "default", Labels: map[string]string{runUIDLabel: "release-uid"}},
+				Status: batchv1.JobStatus{Conditions: []batchv1.JobCondition{{
+					Type:    batchv1.JobFailed,
+					Status:  corev1.ConditionTrue,
+					Reason:  "BackoffLimitExceeded",
+					Message: "Job has reached the specified backoff limit",
+				}}},
+			}
+			pod := newStepPod("release-lint-abc", time.Now(), corev1.PodStatus{
+				ContainerStatuses: []corev1.ContainerStatus{terminatedContainer("main", tt.exitCode, "")},
+			})
+			pod.Labels[batchv1.JobNameLabel] = "release-lint"
+			c := newFakeClient(pipeline)
+			if err := controllerutil.SetControllerReference(pipeline, job, c.Scheme()); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			for _, obj := range []client.Object{job, pod} {
+				if err := c.Create(ctx, obj); err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+			}
+			r := &PipelineReconciler{Client: c, Scheme: c.Scheme()}
+
+			// A second pass must keep the reason the failure was recorded with
+			for range 2 {
+				if err := r.updateStepStatuses(ctx, pipeline); err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+			}
+			lint := r.getStepStatus(pipeline, "lint")
+			if lint.Phase != pipelinev1.StepPhaseFailed || isAllowedFailure(lint) != tt.wantAllowed {
+				t.Fatalf("expected lint Failed with allowed failure %v, got %s with reason %q", tt.wantAllowed, lint.Phase, lint.Reason)
+			}
+
+			g := newPipelineGraph(pipeline)
+			ready, skip := r.areDependenciesSatisfied(g, &pipeline.Spec.Steps[1])
+			if ready != tt.wantAllowed || skip == tt.wantAllowed {
+				t.Errorf("expected build ready %v, got (ready=%v, skip=%v)", tt.wantAllowed, ready, skip)
+			}
+
+			// Finish the pipeline the way the next passes would
+			if ready {
+				r.getStepStatus(pipeline, "build").Phase = pipelinev1.StepPhaseSucceeded
+			} else {
+				r.getStepStatus(pipeline, "build").Phase = pipelinev1.StepPhaseSkipped
+			}
+			r.updatePipelinePhase(ctx, pipeline, r.analyzePipelineState(pipeline))
+			if pipeline.Status.Phase != tt.wantPhase {
+				t.Errorf("expected pipeline %s, got %s", tt.wantPhase, pipeline.Status.Phase)
+			}
+		})
+	}
+}
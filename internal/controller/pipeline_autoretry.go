@@ -0,0 +1,234 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"fmt"
+
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	pipelinev1 "github.com/yaacov/jobrunner/api/v1"
+)
+
+const (
+	// autoRetryDisrupted marks an automatic retry after the pod was evicted, preempted or drained
+	autoRetryDisrupted = "Disrupted"
+
+	// defaultMaxOOMRetries applies when spec.autoRetry.oomKilled does not set maxRetries
+	defaultMaxOOMRetries = 2
+	// defaultMemoryIncreasePercent applies when spec.autoRetry.oomKilled does not set memoryIncreasePercent
+	defaultMemoryIncreasePercent = 100
+)
+
+// autoRetryReason returns why a failed pod may be retried automatically: Disrupted when
+// it carried a DisruptionTarget condition, OOMKilled when a container ran out of memory,
+// or "" when it failed on its own
+func autoRetryReason(pod *corev1.Pod) (reason string, message string) {
+	for _, condition := range pod.Status.Conditions {
+		if condition.Type == corev1.DisruptionTarget && condition.Status == corev1.ConditionTrue {
+			return autoRetryDisrupted, fmt.Sprintf("pod %s was disrupted (%s): %s", pod.Name, condition.Reason, condition.Message)
+		}
+	}
+
+	statuses := append(append([]corev1.ContainerStatus{}, pod.Status.InitContainerStatuses...), pod.Status.ContainerStatuses...)
+	for _, containerStatus := range statuses {
+		if terminated := containerStatus.State.Terminated; terminated != nil && terminated.Reason == stepReasonOOMKilled {
+			return stepReasonOOMKilled, fmt.Sprintf("container %s was OOM killed (exit code %d)", containerStatus.Name, terminated.ExitCode)
+		}
+	}
+	return "", ""
+}
+
+// maxAutoRetries returns how many automatic retries spec.autoRetry allows for a reason
+func maxAutoRetries(pipeline *pipelinev1.Pipeline, reason string) int {
+	autoRetry := pipeline.Spec.AutoRetry
+	switch reason {
+	case autoRetryDisrupted:
+		if autoRetry == nil || autoRetry.MaxDisruptionRetries == nil {
+			return 0
+		}
+		return int(*autoRetry.MaxDisruptionRetries)
+	case stepReasonOOMKilled:
+		if autoRetry == nil || autoRetry.OOMKilled == nil {
+			return 0
+		}
+		if autoRetry.OOMKilled.MaxRetries == 0 {
+			return defaultMaxOOMRetries
+		}
+		return int(autoRetry.OOMKilled.MaxRetries)
+	default:
+		return 0
+	}
+}
+
+// autoRetryCount counts the automatic retries for a reason of a step's own Job, when
+// index is nil, or of the Jobs of one of its forEach items
+func autoRetryCount(stepStatus *pipelinev1.StepStatus, index *int32, reason string) int {
+	count := 0
+	for _, retry := range stepStatus.AutoRetries {
+		if retry.Reason != reason || (retry.Index == nil) != (index == nil) {
+			continue
+		}
+		if index == nil || *retry.Index == *index {
+			count++
+		}
+	}
+	return count
+}
+
+// autoRetry records an automatic retry of a failed Job of a step, or of its forEach item
+// at index, when its pod failed for a reason spec.autoRetry covers and retries remain
+// Returns true if the caller should start a new attempt
+func (r *PipelineReconciler) autoRetry(ctx context.Context, pipeline *pipelinev1.Pipeline, stepStatus *pipelinev1.StepStatus, index *int32, jobName string, attempt int32, pod *corev1.Pod) bool {
+	if pod == nil {
+		return false
+	}
+	reason, message := autoRetryReason(pod)
+	if reason == "" {
+		return false
+	}
+
+	logger := log.FromContext(ctx)
+	retries, limit := autoRetryCount(stepStatus, index, reason), maxAutoRetries(pipeline, reason)
+	if retries >= limit {
+		logger.Info("Not retrying step automatically, retries used up",
+			"step", stepStatus.Name,
+			"job", jobName,
+			"reason", reason,
+			"retries", retries)
+		return false
+	}
+
+	stepStatus.AutoRetries = append(stepStatus.AutoRetries, pipelinev1.AutoRetry{
+		Attempt: attempt,
+		Index:   index,
+		JobName: jobName,
+		Reason:  reason,
+		Message: message,
+		Time:    metav1.Now(),
+	})
+	logger.Info("Retrying step automatically",
+		"step", stepStatus.Name,
+		"job", jobName,
+		"reason", reason,
+		"retry", retries+1,
+		"maxRetries", limit)
+	return true
+}
+
+// applyDisruptionPolicy makes a Job ignore pod disruptions when spec.autoRetry turns
+// disruption retries on, so the Job replaces an evicted, preempted or drained pod without
+// counting it against backoffLimit
+// The rule goes first so it wins over the step's own rules, unless one of them already
+// handles disruptions; pod failure policies require restartPolicy Never
+func applyDisruptionPolicy(pipeline *pipelinev1.Pipeline, jobSpec *batchv1.JobSpec) {
+	if maxAutoRetries(pipeline, autoRetryDisrupted) == 0 || jobSpec.Template.Spec.RestartPolicy != corev1.RestartPolicyNever {
+		return
+	}
+	if jobSpec.PodFailurePolicy == nil {
+		jobSpec.PodFailurePolicy = &batchv1.PodFailurePolicy{}
+	}
+	for _, rule := range jobSpec.PodFailurePolicy.Rules {
+		for _, pattern := range rule.OnPodConditions {
+			if pattern.Type == corev1.DisruptionTarget {
+				return
+			}
+		}
+	}
+
+	ignoreDisruptions := batchv1.PodFailurePolicyRule{
+		Action: batchv1.PodFailurePolicyActionIgnore,
+		OnPodConditions: []batchv1.PodFailurePolicyOnPodConditionsPattern{{
+			Type:   corev1.DisruptionTarget,
+			Status: corev1.ConditionTrue,
+		}},
+	}
+	jobSpec.PodFailurePolicy.Rules = append([]batchv1.PodFailurePolicyRule{ignoreDisruptions}, jobSpec.PodFailurePolicy.Rules...)
+}
+
+// resetStepAttempt returns a step to Pending without a Job, so its next attempt starts
+// once its dependencies are checked again
+func resetStepAttempt(stepStatus *pipelinev1.StepStatus) {
+	stepStatus.Phase = pipelinev1.StepPhasePending
+	stepStatus.JobName = ""
+	stepStatus.JobStatus = nil
+	stepStatus.StartTime = nil
+	stepStatus.CompletionTime = nil
+	stepStatus.Duration = nil
+	stepStatus.Reason = ""
+	stepStatus.Message = ""
+	stepStatus.StuckSince = nil
+	stepStatus.Indexes = nil
+	stepStatus.PodName = ""
+	stepStatus.NodeName = ""
+	stepStatus.Containers = nil
+	stepStatus.Diagnostics = nil
+}
+
+// applyOOMRetries grows the memory requests and limits of a Job's containers by
+// memoryIncreasePercent for each OOM retry of the step, or of its forEach item at index,
+// up to maxMemory
+func applyOOMRetries(pipeline *pipelinev1.Pipeline, stepStatus *pipelinev1.StepStatus, index *int32, job *batchv1.Job) {
+	if pipeline.Spec.AutoRetry == nil || pipeline.Spec.AutoRetry.OOMKilled == nil {
+		return
+	}
+	oom := pipeline.Spec.AutoRetry.OOMKilled
+	retries := autoRetryCount(stepStatus, index, stepReasonOOMKilled)
+	if retries == 0 {
+		return
+	}
+
+	percent := oom.MemoryIncreasePercent
+	if percent == 0 {
+		percent = defaultMemoryIncreasePercent
+	}
+	podSpec := &job.Spec.Template.Spec
+	for _, containers := range [][]corev1.Container{podSpec.InitContainers, podSpec.Containers} {
+		for i := range containers {
+			resources := &containers[i].Resources
+			resources.Requests = scaleMemory(resources.Requests, retries, percent, oom.MaxMemory)
+			resources.Limits = scaleMemory(resources.Limits, retries, percent, oom.MaxMemory)
+		}
+	}
+}
+
+// scaleMemory returns a copy of a resource list with its memory grown by percent for each
+// of retries, up to ceiling; memory already at or above the ceiling is kept
+func scaleMemory(resources corev1.ResourceList, retries int, percent int32, ceiling resource.Quantity) corev1.ResourceList {
+	memory, ok := resources[corev1.ResourceMemory]
+	if !ok || memory.Cmp(ceiling) >= 0 {
+		return resources
+	}
+
+	value := float64(memory.Value())
+	for range retries {
+		value *= 1 + float64(percent)/100
+	}
+
+	scaled := resources.DeepCopy()
+	if value >= float64(ceiling.Value()) {
+		scaled[corev1.ResourceMemory] = ceiling.DeepCopy()
+	} else {
+		scaled[corev1.ResourceMemory] = *resource.NewQuantity(int64(value), resource.BinarySI)
+	}
+	return scaled
+}
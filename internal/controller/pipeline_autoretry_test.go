@@ -0,0 +1,319 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"slices"
+	"testing"
+	"time"
+
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/utils/ptr"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+
+	pipelinev1 "github.com/yaacov/jobrunner/api/v1"
+)
+
+func TestAutoRetryReason(t *testing.T) {
+	tests := []struct {
+		name       string
+		status     corev1.PodStatus
+		wantReason string
+	}{
+		{
+			name: "evicted pod",
+			status: corev1.PodStatus{Conditions: []corev1.PodCondition{{
+				Type:   corev1.DisruptionTarget,
+				Status: corev1.ConditionTrue,
+				Reason: "EvictionByEvictionAPI",
+			}}},
+			wantReason: autoRetryDisrupted,
+		},
+		{
+			name: "OOM killed init container",
+			status: corev1.PodStatus{InitContainerStatuses: []corev1.ContainerStatus{
+				terminatedContainer("setup", 137, ""),
+			}},
+			wantReason: stepReasonOOMKilled,
+		},
+		{
+			name: "failed container",
+			status: corev1.PodStatus{ContainerStatuses: []corev1.ContainerStatus{
+				terminatedContainer("main", 1, ""),
+			}},
+		},
+	}
+	tests[1].status.InitContainerStatuses[0].State.Terminated.Reason = stepReasonOOMKilled
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pod := newStepPod("release-build-abc", time.Now(), tt.status)
+			if reason, message := autoRetryReason(pod); reason != tt.wantReason || (reason != "") != (message != "") {
+				t.Errorf("expected reason %q, got %q with message %q", tt.wantReason, reason, message)
+			}
+		})
+	}
+}
+
+func TestMaxAutoRetries(t *testing.T) {
+	tests := []struct {
+		name          string
+		autoRetry     *pipelinev1.AutoRetrySpec
+		wantDisrupted int
+		wantOOMKilled int
+	}{
+		{name: "defaults"},
+		{
+			name:          "disruption retries enabled",
+			autoRetry:     &pipelinev1.AutoRetrySpec{MaxDisruptionRetries: ptr.To[int32](3)},
+			wantDisrupted: 3,
+		},
+		{
+			name:          "disruption retries disabled",
+			autoRetry:     &pipelinev1.AutoRetrySpec{MaxDisruptionRetries: ptr.To[int32](0)},
+			wantDisrupted: 0,
+		},
+		{
+			name:          "OOM retries enabled",
+			autoRetry:     &pipelinev1.AutoRetrySpec{OOMKilled: &pipelinev1.OOMRetrySpec{MaxMemory: resource.MustParse("4Gi")}},
+			wantOOMKilled: defaultMaxOOMRetries,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pipeline := &pipelinev1.Pipeline{Spec: pipelinev1.PipelineSpec{AutoRetry: tt.autoRetry}}
+			if got := maxAutoRetries(pipeline, autoRetryDisrupted); got != tt.wantDisrupted {
+				t.Errorf("expected %d disruption retries, got %d", tt.wantDisrupted, got)
+			}
+			if got := maxAutoRetries(pipeline, stepReasonOOMKilled); got != tt.wantOOMKilled {
+				t.Errorf("expected %d OOM retries, got %d", tt.wantOOMKilled, got)
+			}
+		})
+	}
+}
+
+func TestApplyDisruptionPolicy(t *testing.T) {
+	enabled := &pipelinev1.AutoRetrySpec{MaxDisruptionRetries: ptr.To[int32](2)}
+	failJob := batchv1.PodFailurePolicyRule{
+		Action:      batchv1.PodFailurePolicyActionFailJob,
+		OnExitCodes: &batchv1.PodFailurePolicyOnExitCodesRequirement{Operator: batchv1.PodFailurePolicyOnExitCodesOpIn, Values: []int32{42}},
+	}
+	ownDisruptionRule := batchv1.PodFailurePolicyRule{
+		Action:          batchv1.PodFailurePolicyActionCount,
+		OnPodConditions: []batchv1.PodFailurePolicyOnPodConditionsPattern{{Type: corev1.DisruptionTarget, Status: corev1.ConditionTrue}},
+	}
+
+	tests := []struct {
+		name          string
+		autoRetry     *pipelinev1.AutoRetrySpec
+		restartPolicy corev1.RestartPolicy
+		policy        *batchv1.PodFailurePolicy
+		wantRules     []batchv1.PodFailurePolicyAction
+	}{
+		{name: "disruption retries off", restartPolicy: corev1.RestartPolicyNever},
+		{
+			name:          "ignores disruptions",
+			autoRetry:     enabled,
+			restartPolicy: corev1.RestartPolicyNever,
+			wantRules:     []batchv1.PodFailurePolicyAction{batchv1.PodFailurePolicyActionIgnore},
+		},
+		{
+			name:          "goes before the step's rules",
+			autoRetry:     enabled,
+			restartPolicy: corev1.RestartPolicyNever,
+			policy:        &batchv1.PodFailurePolicy{Rules: []batchv1.PodFailurePolicyRule{failJob}},
+			wantRules:     []batchv1.PodFailurePolicyAction{batchv1.PodFailurePolicyActionIgnore, batchv1.PodFailurePolicyActionFailJob},
+		},
+		{
+			name:          "keeps the step's own disruption rule",
+			autoRetry:     enabled,
+			restartPolicy: corev1.RestartPolicyNever,
+			policy:        &batchv1.PodFailurePolicy{Rules: []batchv1.PodFailurePolicyRule{ownDisruptionRule}},
+			wantRules:     []batchv1.PodFailurePolicyAction{batchv1.PodFailurePolicyActionCount},
+		},
+		{name: "restartPolicy OnFailure", autoRetry: enabled, restartPolicy: corev1.RestartPolicyOnFailure},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pipeline := &pipelinev1.Pipeline{Spec: pipelinev1.PipelineSpec{AutoRetry: tt.autoRetry}}
+			jobSpec := &batchv1.JobSpec{PodFailurePolicy: tt.policy.DeepCopy()}
+			jobSpec.Template.Spec.RestartPolicy = tt.restartPolicy
+
+			applyDisruptionPolicy(pipeline, jobSpec)
+			var got []batchv1.PodFailurePolicyAction
+			if jobSpec.PodFailurePolicy != nil {
+				for _, rule := range jobSpec.PodFailurePolicy.Rules {
+					got = append(got, rule.Action)
+				}
+			}
+			if !slices.Equal(got, tt.wantRules) {
+				t.Fatalf("expected rules %v, got %v", tt.wantRules, got)
+			}
+			if len(got) > 0 && got[0] == batchv1.PodFailurePolicyActionIgnore {
+				pattern := jobSpec.PodFailurePolicy.Rules[0].OnPodConditions
+				if len(pattern) != 1 || pattern[0].Type != corev1.DisruptionTarget || pattern[0].Status != corev1.ConditionTrue {
+					t.Errorf("expected the rule to match DisruptionTarget, got %+v", pattern)
+				}
+			}
+		})
+	}
+}
+
+func TestApplyOOMRetries(t *testing.T) {
+	tests := []struct {
+		name        string
+		retries     int
+		request     string
+		wantRequest string
+		wantLimit   string
+	}{
+		{name: "first attempt keeps memory", request: "256Mi", wantRequest: "256Mi", wantLimit: "512Mi"},
+		{name: "memory doubles per retry", retries: 1, request: "256Mi", wantRequest: "512Mi", wantLimit: "1Gi"},
+		{name: "memory is capped", retries: 2, request: "256Mi", wantRequest: "1Gi", wantLimit: "1500Mi"},
+		{name: "memory above the cap is kept", retries: 1, request: "2Gi", wantRequest: "2Gi", wantLimit: "1Gi"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pipeline := &pipelinev1.Pipeline{Spec: pipelinev1.PipelineSpec{AutoRetry: &pipelinev1.AutoRetrySpec{
+				OOMKilled: &pipelinev1.OOMRetrySpec{MemoryIncreasePercent: 100, MaxMemory: resource.MustParse("1500Mi")},
+			}}}
+			stepStatus := &pipelinev1.StepStatus{Name: "build"}
+			for range tt.retries {
+				stepStatus.AutoRetries = append(stepStatus.AutoRetries, pipelinev1.AutoRetry{Reason: stepReasonOOMKilled})
+			}
+			// Retries of forEach items do not count for the step's own Job
+			stepStatus.AutoRetries = append(stepStatus.AutoRetries, pipelinev1.AutoRetry{Reason: stepReasonOOMKilled, Index: ptr.To[int32](0)})
+
+			requests := corev1.ResourceList{corev1.ResourceMemory: resource.MustParse(tt.request)}
+			job := &batchv1.Job{Spec: batchv1.JobSpec{Template: corev1.PodTemplateSpec{Spec: corev1.PodSpec{
+				Containers: []corev1.Container{{Name: "main", Resources: corev1.ResourceRequirements{
+					Requests: requests,
+					Limits:   corev1.ResourceList{corev1.ResourceMemory: resource.MustParse("512Mi")},
+				}}},
+			}}}}
+
+			applyOOMRetries(pipeline, stepStatus, nil, job)
+
+			resources := job.Spec.Template.Spec.Containers[0].Resources
+			if got := resources.Requests[corev1.ResourceMemory]; got.Cmp(resource.MustParse(tt.wantRequest)) != 0 {
+				t.Errorf("expected memory request %s, got %s", tt.wantRequest, got.String())
+			}
+			if got := resources.Limits[corev1.ResourceMemory]; got.Cmp(resource.MustParse(tt.wantLimit)) != 0 {
+				t.Errorf("expected memory limit %s, got %s", tt.wantLimit, got.String())
+			}
+			if got := requests[corev1.ResourceMemory]; got.Cmp(resource.MustParse(tt.request)) != 0 {
+				t.Errorf("expected the original resource list to be unchanged, got %s", got.String())
+			}
+		})
+	}
+}
+
+func TestUpdateStepStatusesAutoRetry(t *testing.T) {
+	ctx := context.Background()
+
+	tests := []struct {
+		name        string
+		autoRetry   *pipelinev1.AutoRetrySpec
+		wantPhase   pipelinev1.StepPhase
+		wantRetries int
+	}{
+		{
+			name:        "disrupted step is retried",
+			autoRetry:   &pipelinev1.AutoRetrySpec{MaxDisruptionRetries: ptr.To[int32](3)},
+			wantPhase:   pipelinev1.StepPhasePending,
+			wantRetries: 1,
+		},
+		{name: "disruption retries off by default", wantPhase: pipelinev1.StepPhaseFailed},
+		{
+			name:      "disruption retries disabled",
+			autoRetry: &pipelinev1.AutoRetrySpec{MaxDisruptionRetries: ptr.To[int32](0)},
+			wantPhase: pipelinev1.StepPhaseFailed,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pipeline := &pipelinev1.Pipeline{
+				ObjectMeta: metav1.ObjectMeta{Name: "release", Namespace: "default", UID: types.UID("release-uid")},
+				Spec: pipelinev1.PipelineSpec{
+					AutoRetry: tt.autoRetry,
+					Steps:     []pipelinev1.PipelineStep{{Name: "build"}},
+				},
+				Status: pipelinev1.PipelineStatus{Steps: []pipelinev1.StepStatus{
+					{Name: "build", Phase: pipelinev1.StepPhaseRunning, JobName: "release-build", Attempt: 1},
+				}},
+			}
+			job := &batchv1.Job{
+				ObjectMeta: metav1.ObjectMeta{Name: "release-build", Namespace: "default", Labels: map[string]string{runUIDLabel: "release-uid"}},
+				Status: batchv1.JobStatus{Conditions: []batchv1.JobCondition{{
+					Type:   batchv1.JobFailed,
+					Status: corev1.ConditionTrue,
+					Reason: "BackoffLimitExceeded",
+				}}},
+			}
+			pod := newStepPod("release-build-abc", time.Now(), corev1.PodStatus{
+				Conditions: []corev1.PodCondition{{
+					Type:    corev1.DisruptionTarget,
+					Status:  corev1.ConditionTrue,
+					Reason:  "TerminationByKubelet",
+					Message: "node is shutting down",
+				}},
+				ContainerStatuses: []corev1.ContainerStatus{terminatedContainer("main", 137, "")},
+			})
+			c := newFakeClient(pipeline)
+			if err := controllerutil.SetControllerReference(pipeline, job, c.Scheme()); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			for _, obj := range []client.Object{job, pod} {
+				if err := c.Create(ctx, obj); err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+			}
+			r := &PipelineReconciler{Client: c, Scheme: c.Scheme()}
+
+			// The failed Job is still listed on the next pass and must not be adopted again
+			for range 2 {
+				if err := r.updateStepStatuses(ctx, pipeline); err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+			}
+			build := r.getStepStatus(pipeline, "build")
+			if build.Phase != tt.wantPhase || len(build.AutoRetries) != tt.wantRetries {
+				t.Fatalf("expected build %s with %d retries, got %s with %+v", tt.wantPhase, tt.wantRetries, build.Phase, build.AutoRetries)
+			}
+			if tt.wantRetries == 0 {
+				return
+			}
+			retry := build.AutoRetries[0]
+			if retry.Reason != autoRetryDisrupted || retry.JobName != "release-build" || retry.Attempt != 1 || retry.Index != nil {
+				t.Errorf("unexpected retry record %+v", retry)
+			}
+			if build.JobName != "" || build.Attempt != 1 || build.Reason != "" {
+				t.Errorf("expected build to wait for a new attempt, got %+v", build)
+			}
+		})
+	}
+}
@@ -21,33 +21,68 @@ import (
 	"time"
 
 	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/selection"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/record"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
 	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 
 	pipelinev1 "github.com/yaacov/jobrunner/api/v1"
 )
 
 const (
 	pipelineFinalizer = "pipeline.yaacov.io/finalizer"
+
+	// pipelineLabel names the pipeline a Job or pod belongs to
+	pipelineLabel = "pipeline.yaacov.io/pipeline"
+	// stepLabel names the step a Job or pod runs
+	stepLabel = "pipeline.yaacov.io/step"
+
+	// jobOwnerIndex indexes Jobs by the name of the Pipeline controlling them
+	jobOwnerIndex = ".metadata.controller"
+	// jobPipelineIndex indexes Jobs by their pipeline label
+	jobPipelineIndex = ".metadata.labels.pipeline"
 )
 
 // PipelineReconciler reconciles a Pipeline object
 type PipelineReconciler struct {
 	client.Client
 	Scheme *runtime.Scheme
+
+	// APIReader reads objects referenced by steps without going through the cache
+	// +optional
+	APIReader client.Reader
+
+	// Clientset reads the logs of failed pods, which the controller-runtime client cannot
+	// +optional
+	Clientset kubernetes.Interface
+
+	// Recorder reports problems that do not stop the pipeline, such as failed log archival
+	// +optional
+	Recorder record.EventRecorder
 }
 
 // +kubebuilder:rbac:groups=pipeline.yaacov.io,resources=pipelines,verbs=get;list;watch;create;update;patch;delete
 // +kubebuilder:rbac:groups=pipeline.yaacov.io,resources=pipelines/status,verbs=get;update;patch
 // +kubebuilder:rbac:groups=pipeline.yaacov.io,resources=pipelines/finalizers,verbs=update
+// +kubebuilder:rbac:groups=pipeline.yaacov.io,resources=steptemplates;clustersteptemplates,verbs=get;list;watch
 // +kubebuilder:rbac:groups=batch,resources=jobs,verbs=get;list;watch;create;update;patch;delete
 // +kubebuilder:rbac:groups=batch,resources=jobs/status,verbs=get
+// +kubebuilder:rbac:groups=batch,resources=cronjobs,verbs=get
 // +kubebuilder:rbac:groups="",resources=pods,verbs=get;list;watch
+// +kubebuilder:rbac:groups="",resources=pods/log,verbs=get
+// +kubebuilder:rbac:groups="",resources=configmaps,verbs=get;create;update
+// +kubebuilder:rbac:groups="",resources=events,verbs=create;patch
 
 // Reconcile is part of the main kubernetes reconciliation loop which aims to
 // move the current state of the cluster closer to the desired state.
@@ -78,16 +113,6 @@ func (r *PipelineReconciler) Reconcile(ctx context.Context, req ctrl.Request) (c
 		}
 	}
 
-	// Initialize status if needed
-	if pipeline.Status.Phase == "" {
-		pipeline.Status.Phase = pipelinev1.PipelinePhasePending
-		pipeline.Status.StartTime = &metav1.Time{Time: time.Now()}
-		if err := r.Status().Update(ctx, pipeline); err != nil {
-			return ctrl.Result{}, err
-		}
-		return ctrl.Result{Requeue: true}, nil
-	}
-
 	// Skip reconciliation for completed pipelines
 	if pipeline.Status.Phase == pipelinev1.PipelinePhaseSucceeded ||
 		pipeline.Status.Phase == pipelinev1.PipelinePhaseFailed {
@@ -95,14 +120,85 @@ func (r *PipelineReconciler) Reconcile(ctx context.Context, req ctrl.Request) (c
 		return ctrl.Result{}, nil
 	}
 
-	// Reconcile the pipeline
-	return r.reconcilePipeline(ctx, pipeline)
+	// The status is computed in memory and written once, after the pass
+	original := pipeline.DeepCopy()
+
+	// Initialize status if needed
+	if pipeline.Status.Phase == "" {
+		pipeline.Status.Phase = pipelinev1.PipelinePhasePending
+		pipeline.Status.StartTime = &metav1.Time{Time: time.Now()}
+	}
+
+	// Reconcile the pipeline, recording whatever it got done even when it failed part way
+	result, err := r.reconcilePipeline(ctx, pipeline)
+	if overflowErr := r.overflowStepDetails(ctx, pipeline); overflowErr != nil {
+		// The details stay in the status until they can be moved
+		logger.Error(overflowErr, "Failed to move step details out of the pipeline status")
+		if err == nil {
+			err = overflowErr
+		}
+	}
+	if patchErr := r.patchStatus(ctx, original, pipeline); patchErr != nil {
+		logger.Error(patchErr, "Failed to update pipeline status")
+		if err == nil {
+			err = patchErr
+		}
+	}
+	return result, err
 }
 
 // SetupWithManager sets up the controller with the Manager.
+// Progress is driven by Job and pod events rather than polling
 func (r *PipelineReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	ctx := context.Background()
+	if err := mgr.GetFieldIndexer().IndexField(ctx, &batchv1.Job{}, jobOwnerIndex, indexJobOwner); err != nil {
+		return err
+	}
+	if err := mgr.GetFieldIndexer().IndexField(ctx, &batchv1.Job{}, jobPipelineIndex, indexJobPipeline); err != nil {
+		return err
+	}
+
 	return ctrl.NewControllerManagedBy(mgr).
 		For(&pipelinev1.Pipeline{}).
+		// Job changes and deletions enqueue the owning pipeline, so finished steps hand off
+		// to the next ones and lost Jobs are handled right away
 		Owns(&batchv1.Job{}).
+		// Pod changes a Job does not reflect, like a pod that cannot be scheduled, are
+		// picked up through the pipeline label on the pod
+		Watches(&corev1.Pod{}, handler.EnqueueRequestsFromMapFunc(podToPipeline)).
 		Complete(r)
 }
+
+// PodCacheSelector selects the pods of pipeline steps, so the manager does not cache
+// every pod in the cluster
+func PodCacheSelector() labels.Selector {
+	requirement, _ := labels.NewRequirement(pipelineLabel, selection.Exists, nil)
+	return labels.NewSelector().Add(*requirement)
+}
+
+// indexJobOwner returns the name of the Pipeline controlling a Job
+func indexJobOwner(obj client.Object) []string {
+	owner := metav1.GetControllerOf(obj)
+	if owner == nil || owner.APIVersion != pipelinev1.GroupVersion.String() || owner.Kind != "Pipeline" {
+		return nil
+	}
+	return []string{owner.Name}
+}
+
+// indexJobPipeline returns the pipeline label of a Job
+func indexJobPipeline(obj client.Object) []string {
+	name, ok := obj.GetLabels()[pipelineLabel]
+	if !ok {
+		return nil
+	}
+	return []string{name}
+}
+
+// podToPipeline maps a step pod to the pipeline named by its pipeline label
+func podToPipeline(_ context.Context, obj client.Object) []reconcile.Request {
+	name, ok := obj.GetLabels()[pipelineLabel]
+	if !ok {
+		return nil
+	}
+	return []reconcile.Request{{NamespacedName: types.NamespacedName{Namespace: obj.GetNamespace(), Name: name}}}
+}
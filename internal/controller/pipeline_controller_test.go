@@ -0,0 +1,119 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"testing"
+
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+
+	pipelinev1 "github.com/yaacov/jobrunner/api/v1"
+)
+
+func TestIndexJobOwner(t *testing.T) {
+	pipeline := &pipelinev1.Pipeline{ObjectMeta: metav1.ObjectMeta{Name: "release", Namespace: "default", UID: types.UID("release-uid")}}
+	cronJob := &batchv1.CronJob{ObjectMeta: metav1.ObjectMeta{Name: "nightly", Namespace: "default", UID: types.UID("nightly-uid")}}
+	scheme := newFakeClient().Scheme()
+
+	owned := &batchv1.Job{ObjectMeta: metav1.ObjectMeta{Name: "owned", Namespace: "default"}}
+	if err := controllerutil.SetControllerReference(pipeline, owned, scheme); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	foreign := &batchv1.Job{ObjectMeta: metav1.ObjectMeta{Name: "foreign", Namespace: "default"}}
+	if err := controllerutil.SetControllerReference(cronJob, foreign, scheme); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	tests := []struct {
+		name string
+		job  *batchv1.Job
+		want []string
+	}{
+		{name: "controlled by a pipeline", job: owned, want: []string{"release"}},
+		{name: "controlled by something else", job: foreign},
+		{name: "without a controller", job: &batchv1.Job{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := indexJobOwner(tt.job)
+			if len(got) != len(tt.want) || (len(got) > 0 && got[0] != tt.want[0]) {
+				t.Errorf("expected %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestPodToPipeline(t *testing.T) {
+	pod := &corev1.Pod{ObjectMeta: metav1.ObjectMeta{
+		Name:      "release-build-61608f-1-x7k2p",
+		Namespace: "default",
+		Labels:    map[string]string{pipelineLabel: "release", stepLabel: "build"},
+	}}
+
+	requests := podToPipeline(context.Background(), pod)
+	if len(requests) != 1 || requests[0].Namespace != "default" || requests[0].Name != "release" {
+		t.Errorf("expected a request for default/release, got %v", requests)
+	}
+
+	if requests := podToPipeline(context.Background(), &corev1.Pod{}); len(requests) != 0 {
+		t.Errorf("expected no requests for a pod without the pipeline label, got %v", requests)
+	}
+
+	if !PodCacheSelector().Matches(labels.Set(pod.Labels)) || PodCacheSelector().Matches(labels.Set{}) {
+		t.Errorf("expected the cache selector to match only pipeline pods")
+	}
+}
+
+func TestReconcileDoesNotPoll(t *testing.T) {
+	ctx := context.Background()
+
+	pipeline := newAdoptPipeline()
+	pipeline.Finalizers = []string{pipelineFinalizer}
+	pipeline.Status = pipelinev1.PipelineStatus{}
+	c := newFakeClient(pipeline)
+	r := &PipelineReconciler{Client: c, Scheme: c.Scheme()}
+
+	result, err := r.Reconcile(ctx, ctrl.Request{NamespacedName: types.NamespacedName{Namespace: "default", Name: "release"}})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if result.RequeueAfter != 0 {
+		t.Errorf("expected no timed requeue, got %s", result.RequeueAfter)
+	}
+
+	jobs, err := r.listRunJobs(ctx, pipeline)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(jobs) != 1 {
+		t.Fatalf("expected one job, got %d", len(jobs))
+	}
+	for _, job := range jobs {
+		podLabels := job.Spec.Template.Labels
+		if podLabels[pipelineLabel] != "release" || podLabels[stepLabel] != "build" {
+			t.Errorf("expected the pod template to carry the pipeline labels, got %v", podLabels)
+		}
+	}
+}
@@ -0,0 +1,219 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	pipelinev1 "github.com/yaacov/jobrunner/api/v1"
+)
+
+const (
+	// conditionTypeStalled reports a pipeline in which no step could make progress
+	conditionTypeStalled = "Stalled"
+	// stepReasonUnreachable marks a step that could never start
+	stepReasonUnreachable = "Unreachable"
+	// stalledReasonResolved clears the Stalled condition once no step is unreachable
+	stalledReasonResolved = "Resolved"
+)
+
+// deadlock lists the pending steps of a pipeline that can never start
+type deadlock struct {
+	blocked []*pipelinev1.StepStatus
+	// missing holds the untracked steps and stages each blocked step waits for, if any
+	missing map[string][]string
+}
+
+// findDeadlock detects a pipeline in which no step can ever make progress: nothing is
+// running or suspended, and no pending step is ready to start or to be skipped
+// It only reads the pipeline; resolveDeadlock applies what it found
+// Returns nil if there is no deadlock
+func (r *PipelineReconciler) findDeadlock(pipeline *pipelinev1.Pipeline) *deadlock {
+	g := newPipelineGraph(pipeline)
+
+	var blocked []*pipelinev1.PipelineStep
+	var statuses []*pipelinev1.StepStatus
+	for _, step := range g.steps {
+		status := g.stepStatus(step.Name)
+		if status == nil {
+			continue
+		}
+		switch status.Phase {
+		case pipelinev1.StepPhaseRunning, pipelinev1.StepPhaseSuspended:
+			// A running step will change the state when it finishes, and a suspended
+			// one when it is resumed
+			return nil
+		case pipelinev1.StepPhasePending:
+			if ready, shouldSkip := r.areDependenciesSatisfied(g, step); ready || shouldSkip {
+				return nil
+			}
+			blocked = append(blocked, step)
+			statuses = append(statuses, status)
+		}
+	}
+	if len(blocked) == 0 {
+		return nil
+	}
+
+	untracked := g.untrackedSteps()
+	missing := make(map[string][]string, len(blocked))
+	for _, step := range blocked {
+		if refs := g.missingReferences(step, untracked); len(refs) > 0 {
+			missing[step.Name] = refs
+		}
+	}
+	return &deadlock{blocked: statuses, missing: missing}
+}
+
+// resolveDeadlock marks the steps of a deadlock unreachable and sets the Stalled condition
+// Steps that reference a step or stage the pipeline does not track are marked Failed; the
+// steps waiting for them are marked Skipped. When no step has such a reference, the
+// blocked steps wait for each other and are all marked Failed
+func (r *PipelineReconciler) resolveDeadlock(ctx context.Context, pipeline *pipelinev1.Pipeline, d *deadlock) {
+	var failed, skipped []string
+	for _, status := range d.blocked {
+		status.Reason = stepReasonUnreachable
+		switch refs := d.missing[status.Name]; {
+		case len(refs) > 0:
+			status.Phase = pipelinev1.StepPhaseFailed
+			status.Message = fmt.Sprintf("step waits for %s", strings.Join(refs, ", "))
+			failed = append(failed, fmt.Sprintf("%s (waits for %s)", status.Name, strings.Join(refs, ", ")))
+		case len(d.missing) == 0:
+			status.Phase = pipelinev1.StepPhaseFailed
+			status.Message = "step waits for steps that wait for it in turn"
+			failed = append(failed, status.Name)
+		default:
+			status.Phase = pipelinev1.StepPhaseSkipped
+			status.Message = "step waits for steps that can never start"
+			skipped = append(skipped, status.Name)
+		}
+	}
+
+	message := fmt.Sprintf("No step can make progress; unreachable steps failed: %s", strings.Join(failed, ", "))
+	if len(d.missing) == 0 {
+		message += " (dependency cycle)"
+	}
+	if len(skipped) > 0 {
+		message += fmt.Sprintf("; skipped: %s", strings.Join(skipped, ", "))
+	}
+
+	log.FromContext(ctx).Info("Pipeline stalled, marking unreachable steps",
+		"failed", len(failed),
+		"skipped", len(skipped))
+	meta.SetStatusCondition(&pipeline.Status.Conditions, metav1.Condition{
+		Type:    conditionTypeStalled,
+		Status:  metav1.ConditionTrue,
+		Reason:  stepReasonUnreachable,
+		Message: message,
+	})
+}
+
+// clearStalled sets the Stalled condition to False once no step is unreachable anymore,
+// that is once the unreachable steps return to Pending
+func clearStalled(pipeline *pipelinev1.Pipeline) {
+	if !meta.IsStatusConditionTrue(pipeline.Status.Conditions, conditionTypeStalled) {
+		return
+	}
+	for _, status := range pipeline.Status.Steps {
+		if status.Reason == stepReasonUnreachable {
+			return
+		}
+	}
+	meta.SetStatusCondition(&pipeline.Status.Conditions, metav1.Condition{
+		Type:    conditionTypeStalled,
+		Status:  metav1.ConditionFalse,
+		Reason:  stalledReasonResolved,
+		Message: "No step is unreachable anymore",
+	})
+}
+
+// missingReferences describes the steps and stages a step, or its stage, waits for that
+// the pipeline does not track: removed from the spec, or added after the pipeline started
+// untracked lists the steps of the spec without a status
+func (g *pipelineGraph) missingReferences(step *pipelinev1.PipelineStep, untracked []string) []string {
+	var refs []string
+	addSteps := func(names []string) {
+		for _, name := range names {
+			if g.stepStatus(name) != nil {
+				continue
+			}
+			if _, inSpec := g.list[name]; inSpec {
+				refs = append(refs, fmt.Sprintf("step %q added after the pipeline started", name))
+			} else {
+				refs = append(refs, fmt.Sprintf("unknown step %q", name))
+			}
+		}
+	}
+	addStages := func(names []string) {
+		for _, name := range names {
+			if stage, _ := g.stage(name); stage == nil {
+				refs = append(refs, fmt.Sprintf("unknown stage %q", name))
+			}
+		}
+	}
+	// addUntracked adds the untracked steps of list l, or those before position end
+	addUntracked := func(l, end int) {
+		for _, name := range untracked {
+			if g.list[name] == l && (end < 0 || g.position[name] < end) {
+				addSteps([]string{name})
+			}
+		}
+	}
+
+	stage, stageIndex := g.stageOf(step.Name)
+	if step.HasConditionalExecution() {
+		addSteps(step.RunIf.Steps)
+		addStages(step.RunIf.Stages)
+	} else {
+		// Sequential steps wait for the steps before them
+		addUntracked(g.list[step.Name], g.position[step.Name])
+	}
+	if sourceName, _, ok := step.ForEachSource(); ok {
+		addSteps([]string{sourceName})
+	}
+
+	switch {
+	case stage == nil:
+	case stage.RunIf != nil:
+		addSteps(stage.RunIf.Steps)
+		addStages(stage.RunIf.Stages)
+		addStages(stage.DependsOn)
+	case len(stage.DependsOn) > 0:
+		addStages(stage.DependsOn)
+	default:
+		// By default a stage waits for the previous stage, or the first for the top-level steps
+		addUntracked(stageIndex, -1)
+	}
+	return refs
+}
+
+// untrackedSteps returns the steps of the spec that have no status
+func (g *pipelineGraph) untrackedSteps() []string {
+	var names []string
+	for _, step := range g.steps {
+		if g.stepStatus(step.Name) == nil {
+			names = append(names, step.Name)
+		}
+	}
+	return names
+}
@@ -0,0 +1,175 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"slices"
+	"strings"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	pipelinev1 "github.com/yaacov/jobrunner/api/v1"
+)
+
+func TestResolveDeadlock(t *testing.T) {
+	runIf := func(names ...string) *pipelinev1.RunIfCondition {
+		return &pipelinev1.RunIfCondition{Steps: names}
+	}
+
+	tests := []struct {
+		name        string
+		steps       []pipelinev1.PipelineStep
+		phases      map[string]pipelinev1.StepPhase
+		untracked   []string
+		wantStalled bool
+		wantPhases  map[string]pipelinev1.StepPhase
+		wantMessage string
+	}{
+		{
+			name:   "no deadlock while a step runs",
+			steps:  []pipelinev1.PipelineStep{{Name: "a"}, {Name: "b", RunIf: runIf("lint")}},
+			phases: map[string]pipelinev1.StepPhase{"a": pipelinev1.StepPhaseRunning},
+		},
+		{
+			name:   "no deadlock when a step can start",
+			steps:  []pipelinev1.PipelineStep{{Name: "a"}, {Name: "b"}},
+			phases: map[string]pipelinev1.StepPhase{"a": pipelinev1.StepPhaseSucceeded},
+		},
+		{
+			name:   "no deadlock after a suspended step",
+			steps:  []pipelinev1.PipelineStep{{Name: "a"}, {Name: "b"}},
+			phases: map[string]pipelinev1.StepPhase{"a": pipelinev1.StepPhaseSuspended},
+		},
+		{
+			name:   "no deadlock while a step waits for a suspended step",
+			steps:  []pipelinev1.PipelineStep{{Name: "a"}, {Name: "b", RunIf: runIf("a")}},
+			phases: map[string]pipelinev1.StepPhase{"a": pipelinev1.StepPhaseSuspended},
+		},
+		{
+			name:        "fails a step waiting for an unknown step and skips those waiting for it",
+			steps:       []pipelinev1.PipelineStep{{Name: "a"}, {Name: "b", RunIf: runIf("lint")}, {Name: "c"}},
+			phases:      map[string]pipelinev1.StepPhase{"a": pipelinev1.StepPhaseSucceeded},
+			wantStalled: true,
+			wantPhases:  map[string]pipelinev1.StepPhase{"b": pipelinev1.StepPhaseFailed, "c": pipelinev1.StepPhaseSkipped},
+			wantMessage: `unreachable steps failed: b (waits for unknown step "lint"); skipped: c`,
+		},
+		{
+			name:        "fails steps waiting for each other",
+			steps:       []pipelinev1.PipelineStep{{Name: "a", RunIf: runIf("b")}, {Name: "b", RunIf: runIf("a")}},
+			wantStalled: true,
+			wantPhases:  map[string]pipelinev1.StepPhase{"a": pipelinev1.StepPhaseFailed, "b": pipelinev1.StepPhaseFailed},
+			wantMessage: "unreachable steps failed: a, b (dependency cycle)",
+		},
+		{
+			name:        "fails a step waiting for a step added after the pipeline started",
+			steps:       []pipelinev1.PipelineStep{{Name: "a"}, {Name: "added"}, {Name: "c"}},
+			phases:      map[string]pipelinev1.StepPhase{"a": pipelinev1.StepPhaseSucceeded},
+			untracked:   []string{"added"},
+			wantStalled: true,
+			wantPhases:  map[string]pipelinev1.StepPhase{"c": pipelinev1.StepPhaseFailed},
+			wantMessage: `c (waits for step "added" added after the pipeline started)`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pipeline := &pipelinev1.Pipeline{Spec: pipelinev1.PipelineSpec{Steps: tt.steps}}
+			for _, step := range tt.steps {
+				if slices.Contains(tt.untracked, step.Name) {
+					continue
+				}
+				phase, ok := tt.phases[step.Name]
+				if !ok {
+					phase = pipelinev1.StepPhasePending
+				}
+				pipeline.Status.Steps = append(pipeline.Status.Steps, pipelinev1.StepStatus{Name: step.Name, Phase: phase})
+			}
+			r := &PipelineReconciler{}
+
+			before := pipeline.Status.DeepCopy()
+			state := r.analyzePipelineState(pipeline)
+			if stalled := state.deadlock != nil; stalled != tt.wantStalled {
+				t.Fatalf("expected stalled %v, got %v", tt.wantStalled, stalled)
+			}
+			if !equality.Semantic.DeepEqual(before, &pipeline.Status) {
+				t.Fatalf("expected the analysis to leave the status unchanged")
+			}
+
+			r.updatePipelinePhase(context.Background(), pipeline, state)
+
+			stalled := meta.FindStatusCondition(pipeline.Status.Conditions, conditionTypeStalled)
+			if !tt.wantStalled {
+				if stalled != nil {
+					t.Errorf("expected no Stalled condition, got %q", stalled.Message)
+				}
+				return
+			}
+			if stalled == nil || !strings.Contains(stalled.Message, tt.wantMessage) {
+				t.Errorf("expected a Stalled condition containing %q, got %+v", tt.wantMessage, stalled)
+			}
+			if pipeline.Status.Phase != pipelinev1.PipelinePhaseFailed {
+				t.Errorf("expected the unreachable steps to fail the pipeline, got %s", pipeline.Status.Phase)
+			}
+			for name, want := range tt.wantPhases {
+				status := r.getStepStatus(pipeline, name)
+				if status.Phase != want || status.Reason != stepReasonUnreachable {
+					t.Errorf("step %s: expected %s with reason %s, got %s with reason %q", name, want, stepReasonUnreachable, status.Phase, status.Reason)
+				}
+			}
+		})
+	}
+}
+
+func TestStalledClearsWhenResolved(t *testing.T) {
+	ctx := context.Background()
+	r := &PipelineReconciler{}
+
+	// b was marked unreachable and its failure handler is left to run
+	pipeline := &pipelinev1.Pipeline{Spec: pipelinev1.PipelineSpec{Steps: []pipelinev1.PipelineStep{
+		{Name: "a"},
+		{Name: "b"},
+		{Name: "cleanup", RunIf: &pipelinev1.RunIfCondition{Condition: pipelinev1.RunIfConditionFail, Steps: []string{"b"}}},
+	}}}
+	pipeline.Status.Steps = []pipelinev1.StepStatus{
+		{Name: "a", Phase: pipelinev1.StepPhaseSucceeded},
+		{Name: "b", Phase: pipelinev1.StepPhaseFailed, Reason: stepReasonUnreachable},
+		{Name: "cleanup", Phase: pipelinev1.StepPhasePending},
+	}
+	meta.SetStatusCondition(&pipeline.Status.Conditions, metav1.Condition{
+		Type:   conditionTypeStalled,
+		Status: metav1.ConditionTrue,
+		Reason: stepReasonUnreachable,
+	})
+
+	// The failure handler running does not make b reachable
+	pipeline.Status.Steps[2].Phase = pipelinev1.StepPhaseRunning
+	r.updatePipelinePhase(ctx, pipeline, r.analyzePipelineState(pipeline))
+	if !meta.IsStatusConditionTrue(pipeline.Status.Conditions, conditionTypeStalled) {
+		t.Fatalf("expected the pipeline to stay stalled while b is unreachable, got %+v", pipeline.Status.Conditions)
+	}
+
+	resetStepAttempt(&pipeline.Status.Steps[1])
+	r.updatePipelinePhase(ctx, pipeline, r.analyzePipelineState(pipeline))
+	stalled := meta.FindStatusCondition(pipeline.Status.Conditions, conditionTypeStalled)
+	if stalled == nil || stalled.Status != metav1.ConditionFalse || stalled.Reason != stalledReasonResolved {
+		t.Errorf("expected Stalled to be cleared once b is pending again, got %+v", stalled)
+	}
+}
@@ -26,83 +26,83 @@ import (
 // Returns (ready, shouldSkip) where:
 //   - ready=true means the step can start now
 //   - shouldSkip=true means the step should be skipped (conditions not met)
-func (r *PipelineReconciler) areDependenciesSatisfied(pipeline *pipelinev1.Pipeline, step *pipelinev1.PipelineStep) (ready bool, shouldSkip bool) {
+func (r *PipelineReconciler) areDependenciesSatisfied(g *pipelineGraph, step *pipelinev1.PipelineStep) (ready bool, shouldSkip bool) {
+	// Steps in a stage wait for the stage itself to be allowed to start
+	if stage, stageIndex := g.stageOf(step.Name); stage != nil {
+		if ready, shouldSkip := r.checkStageGate(g, stage, stageIndex); !ready || shouldSkip {
+			return ready, shouldSkip
+		}
+	}
+
+	// forEach steps also wait for the step that emits their items
+	if step.ForEach != "" {
+		if ready, shouldSkip := r.checkForEachSource(g, step); !ready || shouldSkip {
+			return ready, shouldSkip
+		}
+	}
+
 	// If step has a runIf condition, check it
 	if step.HasConditionalExecution() {
-		return r.checkConditionalExecution(pipeline, step)
+		return r.checkConditionalExecution(g, step)
 	}
 
 	// Default behavior: sequential execution - wait for all previous steps to succeed
-	return r.checkSequentialExecution(pipeline, step)
+	return r.checkSequentialExecution(g, step)
 }
 
 // checkSequentialExecution checks if all previous steps have succeeded (default behavior)
 // Steps run in order of the list - each step waits for all previous steps to succeed
-func (r *PipelineReconciler) checkSequentialExecution(pipeline *pipelinev1.Pipeline, step *pipelinev1.PipelineStep) (ready bool, shouldSkip bool) {
-	// Find the index of this step
-	stepIndex := -1
-	for i, s := range pipeline.Spec.Steps {
-		if s.Name == step.Name {
-			stepIndex = i
-			break
-		}
-	}
-
-	if stepIndex == 0 {
+// Steps in a stage only wait for the previous steps of the same stage
+// The graph keeps running counts of the previous steps, so each check is constant time
+func (r *PipelineReconciler) checkSequentialExecution(g *pipelineGraph, step *pipelinev1.PipelineStep) (ready bool, shouldSkip bool) {
+	if g.position[step.Name] == 0 {
 		// First step is always ready
 		log.Log.V(1).Info("First step in pipeline is ready",
 			"step", step.Name)
 		return true, false
 	}
 
-	// Check all previous steps
-	pendingSteps := []string{}
-	failedSteps := []string{}
-	for i := 0; i < stepIndex; i++ {
-		prevStep := &pipeline.Spec.Steps[i]
-		prevStatus := r.getStepStatus(pipeline, prevStep.Name)
-
-		switch prevStatus.Phase {
-		case pipelinev1.StepPhaseSucceeded:
-			// Previous step succeeded, continue checking
-			continue
-		case pipelinev1.StepPhaseFailed:
-			// A previous step failed, skip this step
-			failedSteps = append(failedSteps, prevStep.Name)
-		case pipelinev1.StepPhaseSkipped:
-			// A previous step was skipped, skip this step too
-			failedSteps = append(failedSteps, prevStep.Name)
-		case pipelinev1.StepPhasePending, pipelinev1.StepPhaseRunning:
-			// Previous step not complete, wait
-			pendingSteps = append(pendingSteps, prevStep.Name)
-		}
-	}
+	// Previous steps that are pending or running, that failed, and that were skipped
+	pendingSteps, failedSteps, skippedSteps := g.predecessorCounts(step.Name)
 
 	// If any previous steps are pending or running, wait
-	if len(pendingSteps) > 0 {
+	if pendingSteps > 0 {
 		log.Log.V(1).Info("Step waiting for previous steps to complete (sequential execution)",
 			"step", step.Name,
 			"pendingSteps", pendingSteps)
 		return false, false
 	}
 
+	// Skipped steps count as failed unless the skip policy lets them pass; ignored
+	// steps only pass while at least one previous step ran
+	switch g.skipPolicy(step) {
+	case pipelinev1.SkipTreatAsSuccess:
+	case pipelinev1.SkipIgnore:
+		if skippedSteps == g.position[step.Name] {
+			failedSteps += skippedSteps
+		}
+	default:
+		failedSteps += skippedSteps
+	}
+
 	// If any previous steps failed or were skipped, skip this step
-	if len(failedSteps) > 0 {
+	if failedSteps > 0 {
 		log.Log.Info("Step skipped - previous steps failed or were skipped (sequential execution)",
 			"step", step.Name,
 			"failedSteps", failedSteps)
 		return false, true
 	}
 
-	// All previous steps succeeded
+	// All previous steps succeeded, or were skipped and the skip policy lets them pass
 	log.Log.Info("Step ready to run - all previous steps succeeded (sequential execution)",
-		"step", step.Name)
+		"step", step.Name,
+		"skippedSteps", skippedSteps)
 	return true, false
 }
 
 // checkConditionalExecution checks the runIf condition
 // These allow steps to run out of order based on specific conditions
-func (r *PipelineReconciler) checkConditionalExecution(pipeline *pipelinev1.Pipeline, step *pipelinev1.PipelineStep) (ready bool, shouldSkip bool) {
+func (r *PipelineReconciler) checkConditionalExecution(g *pipelineGraph, step *pipelinev1.PipelineStep) (ready bool, shouldSkip bool) {
 	runIf := step.RunIf
 	if runIf == nil {
 		// Should not happen, but handle gracefully
@@ -112,14 +112,15 @@ func (r *PipelineReconciler) checkConditionalExecution(pipeline *pipelinev1.Pipe
 	checkFailure := runIf.IsCheckingFailure()
 	checkAll := runIf.RequiresAll()
 
-	conditionMet, allComplete := r.checkStepStatuses(pipeline, runIf.Steps, checkAll, checkFailure)
+	conditionMet, allComplete := r.checkUnitStatuses(g, runIf.Steps, runIf.Stages, checkAll, checkFailure, g.skipPolicy(step))
 
 	if !allComplete {
 		log.Log.V(1).Info("Step waiting for runIf steps to complete",
 			"step", step.Name,
 			"condition", runIf.GetCondition(),
 			"operator", runIf.GetOperator(),
-			"waitingFor", runIf.Steps)
+			"waitingFor", runIf.Steps,
+			"waitingForStages", runIf.Stages)
 		return false, false
 	}
 
@@ -128,7 +129,8 @@ func (r *PipelineReconciler) checkConditionalExecution(pipeline *pipelinev1.Pipe
 			"step", step.Name,
 			"condition", runIf.GetCondition(),
 			"operator", runIf.GetOperator(),
-			"steps", runIf.Steps)
+			"steps", runIf.Steps,
+			"stages", runIf.Stages)
 		return false, true
 	}
 
@@ -136,7 +138,8 @@ func (r *PipelineReconciler) checkConditionalExecution(pipeline *pipelinev1.Pipe
 		"step", step.Name,
 		"condition", runIf.GetCondition(),
 		"operator", runIf.GetOperator(),
-		"steps", runIf.Steps)
+		"steps", runIf.Steps,
+		"stages", runIf.Stages)
 	return true, false
 }
 
@@ -147,21 +150,45 @@ func (r *PipelineReconciler) checkConditionalExecution(pipeline *pipelinev1.Pipe
 //
 // If checkAll is true, checks if ALL steps meet the condition; otherwise checks if ANY step meets it
 // If checkFailure is true, checks for failure; otherwise checks for success
-func (r *PipelineReconciler) checkStepStatuses(pipeline *pipelinev1.Pipeline, stepNames []string, checkAll bool, checkFailure bool) (conditionMet bool, allComplete bool) {
+// Skipped steps count according to the pipeline's skipPolicy
+func (r *PipelineReconciler) checkStepStatuses(g *pipelineGraph, stepNames []string, checkAll bool, checkFailure bool) (conditionMet bool, allComplete bool) {
+	return r.checkUnitStatuses(g, stepNames, nil, checkAll, checkFailure, g.skipPolicy(nil))
+}
+
+// checkUnitStatuses checks the status of a list of steps and stages together
+// Each stage counts as a single unit with its rolled-up phase; see checkStepStatuses
+// skipPolicy selects how skipped units count: as successes, as failures, not at all, or,
+// with Propagate, as matching no condition
+func (r *PipelineReconciler) checkUnitStatuses(g *pipelineGraph, stepNames []string, stageNames []string, checkAll bool, checkFailure bool, skipPolicy pipelinev1.SkipPolicy) (conditionMet bool, allComplete bool) {
 	allComplete = true
 	matchCount := 0
+	ignored := 0
 
+	phases := make([]pipelinev1.StepPhase, 0, len(stepNames)+len(stageNames))
 	for _, name := range stepNames {
-		status := r.getStepStatus(pipeline, name)
-		if status == nil {
+		if g.stepStatus(name) == nil {
 			log.Log.Info("Referenced step not found",
 				"referencedStep", name,
-				"pipeline", pipeline.Name)
+				"pipeline", g.pipeline.Name)
+			allComplete = false
+			continue
+		}
+		phases = append(phases, g.stepPhase(name))
+	}
+	for _, name := range stageNames {
+		phase, found := g.stagePhase(name)
+		if !found {
+			log.Log.Info("Referenced stage not found",
+				"referencedStage", name,
+				"pipeline", g.pipeline.Name)
 			allComplete = false
 			continue
 		}
+		phases = append(phases, phase)
+	}
 
-		switch status.Phase {
+	for _, phase := range phases {
+		switch phase {
 		case pipelinev1.StepPhaseSucceeded:
 			if !checkFailure {
 				matchCount++
@@ -171,8 +198,19 @@ func (r *PipelineReconciler) checkStepStatuses(pipeline *pipelinev1.Pipeline, st
 				matchCount++
 			}
 		case pipelinev1.StepPhaseSkipped:
-			// Skipped steps don't match any condition
-			continue
+			switch skipPolicy {
+			case pipelinev1.SkipTreatAsSuccess:
+				if !checkFailure {
+					matchCount++
+				}
+			case pipelinev1.SkipTreatAsFailure:
+				if checkFailure {
+					matchCount++
+				}
+			case pipelinev1.SkipIgnore:
+				ignored++
+			}
+			// With Propagate, skipped steps don't match any condition
 		case pipelinev1.StepPhasePending, pipelinev1.StepPhaseRunning:
 			allComplete = false
 		}
@@ -183,10 +221,14 @@ func (r *PipelineReconciler) checkStepStatuses(pipeline *pipelinev1.Pipeline, st
 	}
 
 	// Check if condition is met
-	if checkAll {
-		// All steps must match
-		conditionMet = (matchCount == len(stepNames))
-	} else {
+	switch {
+	case ignored > 0 && ignored == len(phases):
+		// Every unit was skipped and ignored, so there is nothing to meet the condition
+		conditionMet = false
+	case checkAll:
+		// All steps that are not ignored must match
+		conditionMet = (matchCount == len(phases)-ignored)
+	default:
 		// At least one step must match
 		conditionMet = (matchCount > 0)
 	}
@@ -196,34 +238,40 @@ func (r *PipelineReconciler) checkStepStatuses(pipeline *pipelinev1.Pipeline, st
 
 // shouldSkipStep checks if a step should be skipped for other reasons
 // This is a placeholder for future validation logic
-func (r *PipelineReconciler) shouldSkipStep(pipeline *pipelinev1.Pipeline, step *pipelinev1.PipelineStep) bool {
+func (r *PipelineReconciler) shouldSkipStep(g *pipelineGraph, step *pipelinev1.PipelineStep) bool {
 	// Additional validation logic can go here
 	// For now, this is a placeholder for future enhancements
 	return false
 }
 
+// isFailureHandler returns true if a step, or the stage it belongs to, runs on failure
+func (g *pipelineGraph) isFailureHandler(step *pipelinev1.PipelineStep) bool {
+	if step.RunIf != nil && step.RunIf.IsCheckingFailure() {
+		return true
+	}
+	stage, _ := g.stageOf(step.Name)
+	return stage != nil && stage.RunIf != nil && stage.RunIf.IsCheckingFailure()
+}
+
 // hasPendingFailureHandlers checks if there are pending steps that handle failures
-func (r *PipelineReconciler) hasPendingFailureHandlers(pipeline *pipelinev1.Pipeline) bool {
+func (r *PipelineReconciler) hasPendingFailureHandlers(g *pipelineGraph) bool {
 	pendingHandlers := []string{}
 
 	// Check if there are any pending steps that could run on failure
-	for i := range pipeline.Spec.Steps {
-		step := &pipeline.Spec.Steps[i]
-		stepStatus := r.getStepStatus(pipeline, step.Name)
-
-		if stepStatus.Phase != pipelinev1.StepPhasePending {
+	for i := range g.steps {
+		step := g.steps[i]
+		if g.stepPhase(step.Name) != pipelinev1.StepPhasePending {
 			continue
 		}
 
-		// Check if this step has failure-related conditions
-		if step.RunIf != nil && step.RunIf.IsCheckingFailure() {
+		if g.isFailureHandler(step) {
 			pendingHandlers = append(pendingHandlers, step.Name)
 		}
 	}
 
 	if len(pendingHandlers) > 0 {
 		log.Log.Info("Pending failure handlers detected",
-			"pipeline", pipeline.Name,
+			"pipeline", g.pipeline.Name,
 			"handlers", pendingHandlers)
 		return true
 	}
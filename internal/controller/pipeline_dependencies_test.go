@@ -39,8 +39,8 @@ func TestCheckSequentialExecution(t *testing.T) {
 			pipeline: &pipelinev1.Pipeline{
 				Spec: pipelinev1.PipelineSpec{
 					Steps: []pipelinev1.PipelineStep{
-						{Name: "step1", JobSpec: batchv1.JobSpec{}},
-						{Name: "step2", JobSpec: batchv1.JobSpec{}},
+						{Name: "step1", JobSpec: &batchv1.JobSpec{}},
+						{Name: "step2", JobSpec: &batchv1.JobSpec{}},
 					},
 				},
 				Status: pipelinev1.PipelineStatus{
@@ -59,8 +59,8 @@ func TestCheckSequentialExecution(t *testing.T) {
 			pipeline: &pipelinev1.Pipeline{
 				Spec: pipelinev1.PipelineSpec{
 					Steps: []pipelinev1.PipelineStep{
-						{Name: "step1", JobSpec: batchv1.JobSpec{}},
-						{Name: "step2", JobSpec: batchv1.JobSpec{}},
+						{Name: "step1", JobSpec: &batchv1.JobSpec{}},
+						{Name: "step2", JobSpec: &batchv1.JobSpec{}},
 					},
 				},
 				Status: pipelinev1.PipelineStatus{
@@ -79,8 +79,8 @@ func TestCheckSequentialExecution(t *testing.T) {
 			pipeline: &pipelinev1.Pipeline{
 				Spec: pipelinev1.PipelineSpec{
 					Steps: []pipelinev1.PipelineStep{
-						{Name: "step1", JobSpec: batchv1.JobSpec{}},
-						{Name: "step2", JobSpec: batchv1.JobSpec{}},
+						{Name: "step1", JobSpec: &batchv1.JobSpec{}},
+						{Name: "step2", JobSpec: &batchv1.JobSpec{}},
 					},
 				},
 				Status: pipelinev1.PipelineStatus{
@@ -99,8 +99,8 @@ func TestCheckSequentialExecution(t *testing.T) {
 			pipeline: &pipelinev1.Pipeline{
 				Spec: pipelinev1.PipelineSpec{
 					Steps: []pipelinev1.PipelineStep{
-						{Name: "step1", JobSpec: batchv1.JobSpec{}},
-						{Name: "step2", JobSpec: batchv1.JobSpec{}},
+						{Name: "step1", JobSpec: &batchv1.JobSpec{}},
+						{Name: "step2", JobSpec: &batchv1.JobSpec{}},
 					},
 				},
 				Status: pipelinev1.PipelineStatus{
@@ -119,8 +119,8 @@ func TestCheckSequentialExecution(t *testing.T) {
 			pipeline: &pipelinev1.Pipeline{
 				Spec: pipelinev1.PipelineSpec{
 					Steps: []pipelinev1.PipelineStep{
-						{Name: "step1", JobSpec: batchv1.JobSpec{}},
-						{Name: "step2", JobSpec: batchv1.JobSpec{}},
+						{Name: "step1", JobSpec: &batchv1.JobSpec{}},
+						{Name: "step2", JobSpec: &batchv1.JobSpec{}},
 					},
 				},
 				Status: pipelinev1.PipelineStatus{
@@ -139,8 +139,8 @@ func TestCheckSequentialExecution(t *testing.T) {
 			pipeline: &pipelinev1.Pipeline{
 				Spec: pipelinev1.PipelineSpec{
 					Steps: []pipelinev1.PipelineStep{
-						{Name: "step1", JobSpec: batchv1.JobSpec{}},
-						{Name: "step2", JobSpec: batchv1.JobSpec{}},
+						{Name: "step1", JobSpec: &batchv1.JobSpec{}},
+						{Name: "step2", JobSpec: &batchv1.JobSpec{}},
 					},
 				},
 				Status: pipelinev1.PipelineStatus{
@@ -159,9 +159,9 @@ func TestCheckSequentialExecution(t *testing.T) {
 			pipeline: &pipelinev1.Pipeline{
 				Spec: pipelinev1.PipelineSpec{
 					Steps: []pipelinev1.PipelineStep{
-						{Name: "step1", JobSpec: batchv1.JobSpec{}},
-						{Name: "step2", JobSpec: batchv1.JobSpec{}},
-						{Name: "step3", JobSpec: batchv1.JobSpec{}},
+						{Name: "step1", JobSpec: &batchv1.JobSpec{}},
+						{Name: "step2", JobSpec: &batchv1.JobSpec{}},
+						{Name: "step3", JobSpec: &batchv1.JobSpec{}},
 					},
 				},
 				Status: pipelinev1.PipelineStatus{
@@ -181,9 +181,9 @@ func TestCheckSequentialExecution(t *testing.T) {
 			pipeline: &pipelinev1.Pipeline{
 				Spec: pipelinev1.PipelineSpec{
 					Steps: []pipelinev1.PipelineStep{
-						{Name: "step1", JobSpec: batchv1.JobSpec{}},
-						{Name: "step2", JobSpec: batchv1.JobSpec{}},
-						{Name: "step3", JobSpec: batchv1.JobSpec{}},
+						{Name: "step1", JobSpec: &batchv1.JobSpec{}},
+						{Name: "step2", JobSpec: &batchv1.JobSpec{}},
+						{Name: "step3", JobSpec: &batchv1.JobSpec{}},
 					},
 				},
 				Status: pipelinev1.PipelineStatus{
@@ -202,7 +202,7 @@ func TestCheckSequentialExecution(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			ready, skip := r.checkSequentialExecution(tt.pipeline, tt.step)
+			ready, skip := r.checkSequentialExecution(newPipelineGraph(tt.pipeline), tt.step)
 			if ready != tt.wantReady {
 				t.Errorf("ready = %v, want %v", ready, tt.wantReady)
 			}
@@ -228,9 +228,9 @@ func TestCheckConditionalExecution(t *testing.T) {
 			pipeline: &pipelinev1.Pipeline{
 				Spec: pipelinev1.PipelineSpec{
 					Steps: []pipelinev1.PipelineStep{
-						{Name: "step1", JobSpec: batchv1.JobSpec{}},
-						{Name: "step2", JobSpec: batchv1.JobSpec{}},
-						{Name: "step3", JobSpec: batchv1.JobSpec{}},
+						{Name: "step1", JobSpec: &batchv1.JobSpec{}},
+						{Name: "step2", JobSpec: &batchv1.JobSpec{}},
+						{Name: "step3", JobSpec: &batchv1.JobSpec{}},
 					},
 				},
 				Status: pipelinev1.PipelineStatus{
@@ -257,9 +257,9 @@ func TestCheckConditionalExecution(t *testing.T) {
 			pipeline: &pipelinev1.Pipeline{
 				Spec: pipelinev1.PipelineSpec{
 					Steps: []pipelinev1.PipelineStep{
-						{Name: "step1", JobSpec: batchv1.JobSpec{}},
-						{Name: "step2", JobSpec: batchv1.JobSpec{}},
-						{Name: "step3", JobSpec: batchv1.JobSpec{}},
+						{Name: "step1", JobSpec: &batchv1.JobSpec{}},
+						{Name: "step2", JobSpec: &batchv1.JobSpec{}},
+						{Name: "step3", JobSpec: &batchv1.JobSpec{}},
 					},
 				},
 				Status: pipelinev1.PipelineStatus{
@@ -286,9 +286,9 @@ func TestCheckConditionalExecution(t *testing.T) {
 			pipeline: &pipelinev1.Pipeline{
 				Spec: pipelinev1.PipelineSpec{
 					Steps: []pipelinev1.PipelineStep{
-						{Name: "step1", JobSpec: batchv1.JobSpec{}},
-						{Name: "step2", JobSpec: batchv1.JobSpec{}},
-						{Name: "step3", JobSpec: batchv1.JobSpec{}},
+						{Name: "step1", JobSpec: &batchv1.JobSpec{}},
+						{Name: "step2", JobSpec: &batchv1.JobSpec{}},
+						{Name: "step3", JobSpec: &batchv1.JobSpec{}},
 					},
 				},
 				Status: pipelinev1.PipelineStatus{
@@ -315,9 +315,9 @@ func TestCheckConditionalExecution(t *testing.T) {
 			pipeline: &pipelinev1.Pipeline{
 				Spec: pipelinev1.PipelineSpec{
 					Steps: []pipelinev1.PipelineStep{
-						{Name: "step1", JobSpec: batchv1.JobSpec{}},
-						{Name: "step2", JobSpec: batchv1.JobSpec{}},
-						{Name: "step3", JobSpec: batchv1.JobSpec{}},
+						{Name: "step1", JobSpec: &batchv1.JobSpec{}},
+						{Name: "step2", JobSpec: &batchv1.JobSpec{}},
+						{Name: "step3", JobSpec: &batchv1.JobSpec{}},
 					},
 				},
 				Status: pipelinev1.PipelineStatus{
@@ -344,9 +344,9 @@ func TestCheckConditionalExecution(t *testing.T) {
 			pipeline: &pipelinev1.Pipeline{
 				Spec: pipelinev1.PipelineSpec{
 					Steps: []pipelinev1.PipelineStep{
-						{Name: "step1", JobSpec: batchv1.JobSpec{}},
-						{Name: "step2", JobSpec: batchv1.JobSpec{}},
-						{Name: "cleanup", JobSpec: batchv1.JobSpec{}},
+						{Name: "step1", JobSpec: &batchv1.JobSpec{}},
+						{Name: "step2", JobSpec: &batchv1.JobSpec{}},
+						{Name: "cleanup", JobSpec: &batchv1.JobSpec{}},
 					},
 				},
 				Status: pipelinev1.PipelineStatus{
@@ -373,9 +373,9 @@ func TestCheckConditionalExecution(t *testing.T) {
 			pipeline: &pipelinev1.Pipeline{
 				Spec: pipelinev1.PipelineSpec{
 					Steps: []pipelinev1.PipelineStep{
-						{Name: "step1", JobSpec: batchv1.JobSpec{}},
-						{Name: "step2", JobSpec: batchv1.JobSpec{}},
-						{Name: "cleanup", JobSpec: batchv1.JobSpec{}},
+						{Name: "step1", JobSpec: &batchv1.JobSpec{}},
+						{Name: "step2", JobSpec: &batchv1.JobSpec{}},
+						{Name: "cleanup", JobSpec: &batchv1.JobSpec{}},
 					},
 				},
 				Status: pipelinev1.PipelineStatus{
@@ -402,8 +402,8 @@ func TestCheckConditionalExecution(t *testing.T) {
 			pipeline: &pipelinev1.Pipeline{
 				Spec: pipelinev1.PipelineSpec{
 					Steps: []pipelinev1.PipelineStep{
-						{Name: "step1", JobSpec: batchv1.JobSpec{}},
-						{Name: "step2", JobSpec: batchv1.JobSpec{}},
+						{Name: "step1", JobSpec: &batchv1.JobSpec{}},
+						{Name: "step2", JobSpec: &batchv1.JobSpec{}},
 					},
 				},
 				Status: pipelinev1.PipelineStatus{
@@ -429,8 +429,8 @@ func TestCheckConditionalExecution(t *testing.T) {
 			pipeline: &pipelinev1.Pipeline{
 				Spec: pipelinev1.PipelineSpec{
 					Steps: []pipelinev1.PipelineStep{
-						{Name: "step1", JobSpec: batchv1.JobSpec{}},
-						{Name: "step2", JobSpec: batchv1.JobSpec{}},
+						{Name: "step1", JobSpec: &batchv1.JobSpec{}},
+						{Name: "step2", JobSpec: &batchv1.JobSpec{}},
 					},
 				},
 				Status: pipelinev1.PipelineStatus{
@@ -455,7 +455,7 @@ func TestCheckConditionalExecution(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			ready, skip := r.checkConditionalExecution(tt.pipeline, tt.step)
+			ready, skip := r.checkConditionalExecution(newPipelineGraph(tt.pipeline), tt.step)
 			if ready != tt.wantReady {
 				t.Errorf("ready = %v, want %v", ready, tt.wantReady)
 			}
@@ -481,8 +481,8 @@ func TestAreDependenciesSatisfied(t *testing.T) {
 			pipeline: &pipelinev1.Pipeline{
 				Spec: pipelinev1.PipelineSpec{
 					Steps: []pipelinev1.PipelineStep{
-						{Name: "step1", JobSpec: batchv1.JobSpec{}},
-						{Name: "step2", JobSpec: batchv1.JobSpec{}},
+						{Name: "step1", JobSpec: &batchv1.JobSpec{}},
+						{Name: "step2", JobSpec: &batchv1.JobSpec{}},
 					},
 				},
 				Status: pipelinev1.PipelineStatus{
@@ -507,8 +507,8 @@ func TestAreDependenciesSatisfied(t *testing.T) {
 			pipeline: &pipelinev1.Pipeline{
 				Spec: pipelinev1.PipelineSpec{
 					Steps: []pipelinev1.PipelineStep{
-						{Name: "step1", JobSpec: batchv1.JobSpec{}},
-						{Name: "step2", JobSpec: batchv1.JobSpec{}},
+						{Name: "step1", JobSpec: &batchv1.JobSpec{}},
+						{Name: "step2", JobSpec: &batchv1.JobSpec{}},
 					},
 				},
 				Status: pipelinev1.PipelineStatus{
@@ -526,7 +526,7 @@ func TestAreDependenciesSatisfied(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			ready, skip := r.areDependenciesSatisfied(tt.pipeline, tt.step)
+			ready, skip := r.areDependenciesSatisfied(newPipelineGraph(tt.pipeline), tt.step)
 			if ready != tt.wantReady {
 				t.Errorf("ready = %v, want %v", ready, tt.wantReady)
 			}
@@ -665,7 +665,7 @@ func TestCheckStepStatuses(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			conditionMet, allComplete := r.checkStepStatuses(tt.pipeline, tt.stepNames, tt.checkAll, tt.checkFailure)
+			conditionMet, allComplete := r.checkStepStatuses(newPipelineGraph(tt.pipeline), tt.stepNames, tt.checkAll, tt.checkFailure)
 			if conditionMet != tt.wantConditionMet {
 				t.Errorf("conditionMet = %v, want %v", conditionMet, tt.wantConditionMet)
 			}
@@ -689,14 +689,14 @@ func TestHasPendingFailureHandlers(t *testing.T) {
 			pipeline: &pipelinev1.Pipeline{
 				Spec: pipelinev1.PipelineSpec{
 					Steps: []pipelinev1.PipelineStep{
-						{Name: "step1", JobSpec: batchv1.JobSpec{}},
+						{Name: "step1", JobSpec: &batchv1.JobSpec{}},
 						{
 							Name: "cleanup",
 							RunIf: &pipelinev1.RunIfCondition{
 								Condition: pipelinev1.RunIfConditionFail,
 								Steps:     []string{"step1"},
 							},
-							JobSpec: batchv1.JobSpec{},
+							JobSpec: &batchv1.JobSpec{},
 						},
 					},
 				},
@@ -714,8 +714,8 @@ func TestHasPendingFailureHandlers(t *testing.T) {
 			pipeline: &pipelinev1.Pipeline{
 				Spec: pipelinev1.PipelineSpec{
 					Steps: []pipelinev1.PipelineStep{
-						{Name: "step1", JobSpec: batchv1.JobSpec{}},
-						{Name: "step2", JobSpec: batchv1.JobSpec{}},
+						{Name: "step1", JobSpec: &batchv1.JobSpec{}},
+						{Name: "step2", JobSpec: &batchv1.JobSpec{}},
 					},
 				},
 				Status: pipelinev1.PipelineStatus{
@@ -732,14 +732,14 @@ func TestHasPendingFailureHandlers(t *testing.T) {
 			pipeline: &pipelinev1.Pipeline{
 				Spec: pipelinev1.PipelineSpec{
 					Steps: []pipelinev1.PipelineStep{
-						{Name: "step1", JobSpec: batchv1.JobSpec{}},
+						{Name: "step1", JobSpec: &batchv1.JobSpec{}},
 						{
 							Name: "cleanup",
 							RunIf: &pipelinev1.RunIfCondition{
 								Condition: pipelinev1.RunIfConditionFail,
 								Steps:     []string{"step1"},
 							},
-							JobSpec: batchv1.JobSpec{},
+							JobSpec: &batchv1.JobSpec{},
 						},
 					},
 				},
@@ -757,14 +757,14 @@ func TestHasPendingFailureHandlers(t *testing.T) {
 			pipeline: &pipelinev1.Pipeline{
 				Spec: pipelinev1.PipelineSpec{
 					Steps: []pipelinev1.PipelineStep{
-						{Name: "step1", JobSpec: batchv1.JobSpec{}},
+						{Name: "step1", JobSpec: &batchv1.JobSpec{}},
 						{
 							Name: "step2",
 							RunIf: &pipelinev1.RunIfCondition{
 								Condition: pipelinev1.RunIfConditionSuccess,
 								Steps:     []string{"step1"},
 							},
-							JobSpec: batchv1.JobSpec{},
+							JobSpec: &batchv1.JobSpec{},
 						},
 					},
 				},
@@ -781,10 +781,76 @@ func TestHasPendingFailureHandlers(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			got := r.hasPendingFailureHandlers(tt.pipeline)
+			got := r.hasPendingFailureHandlers(newPipelineGraph(tt.pipeline))
 			if got != tt.want {
 				t.Errorf("hasPendingFailureHandlers() = %v, want %v", got, tt.want)
 			}
 		})
 	}
 }
+
+func TestSkipPolicy(t *testing.T) {
+	r := &PipelineReconciler{}
+
+	// build-fast and build-full are alternatives, exactly one of which runs
+	newRejoinPipeline := func(pipelinePolicy, stepPolicy pipelinev1.SkipPolicy, runIf *pipelinev1.RunIfCondition, fastPhase pipelinev1.StepPhase) *pipelinev1.Pipeline {
+		return &pipelinev1.Pipeline{
+			Spec: pipelinev1.PipelineSpec{
+				SkipPolicy: pipelinePolicy,
+				Steps: []pipelinev1.PipelineStep{
+					{Name: "build-fast"},
+					{Name: "build-full"},
+					{Name: "deploy", RunIf: runIf, SkipPolicy: stepPolicy},
+				},
+			},
+			Status: pipelinev1.PipelineStatus{
+				Steps: []pipelinev1.StepStatus{
+					{Name: "build-fast", Phase: fastPhase},
+					{Name: "build-full", Phase: pipelinev1.StepPhaseSkipped},
+					{Name: "deploy", Phase: pipelinev1.StepPhasePending},
+				},
+			},
+		}
+	}
+	allSucceeded := &pipelinev1.RunIfCondition{Steps: []string{"build-fast", "build-full"}}
+	anyFailed := &pipelinev1.RunIfCondition{
+		Steps:     []string{"build-fast", "build-full"},
+		Condition: pipelinev1.RunIfConditionFail,
+		Operator:  pipelinev1.RunIfOperatorOr,
+	}
+
+	tests := []struct {
+		name           string
+		pipelinePolicy pipelinev1.SkipPolicy
+		stepPolicy     pipelinev1.SkipPolicy
+		runIf          *pipelinev1.RunIfCondition
+		fastPhase      pipelinev1.StepPhase
+		wantReady      bool
+		wantSkip       bool
+	}{
+		{name: "sequential step propagates skips by default", fastPhase: pipelinev1.StepPhaseSucceeded, wantSkip: true},
+		{name: "sequential step rejoins when skips count as success", pipelinePolicy: pipelinev1.SkipTreatAsSuccess, fastPhase: pipelinev1.StepPhaseSucceeded, wantReady: true},
+		{name: "sequential step rejoins when skips are ignored", stepPolicy: pipelinev1.SkipIgnore, fastPhase: pipelinev1.StepPhaseSucceeded, wantReady: true},
+		{name: "sequential step skipped when all previous steps are ignored", stepPolicy: pipelinev1.SkipIgnore, fastPhase: pipelinev1.StepPhaseSkipped, wantSkip: true},
+		{name: "sequential step still skipped after a failure", stepPolicy: pipelinev1.SkipTreatAsSuccess, fastPhase: pipelinev1.StepPhaseFailed, wantSkip: true},
+		{name: "step policy overrides the pipeline", pipelinePolicy: pipelinev1.SkipTreatAsSuccess, stepPolicy: pipelinev1.SkipPropagate, fastPhase: pipelinev1.StepPhaseSucceeded, wantSkip: true},
+		{name: "and condition not met by a skipped step by default", runIf: allSucceeded, fastPhase: pipelinev1.StepPhaseSucceeded, wantSkip: true},
+		{name: "and condition met when skips count as success", runIf: allSucceeded, stepPolicy: pipelinev1.SkipTreatAsSuccess, fastPhase: pipelinev1.StepPhaseSucceeded, wantReady: true},
+		{name: "and condition met when skips are ignored", runIf: allSucceeded, pipelinePolicy: pipelinev1.SkipIgnore, fastPhase: pipelinev1.StepPhaseSucceeded, wantReady: true},
+		{name: "and condition not met when all steps are ignored", runIf: allSucceeded, pipelinePolicy: pipelinev1.SkipIgnore, fastPhase: pipelinev1.StepPhaseSkipped, wantSkip: true},
+		{name: "and condition not met when skips count as failure", runIf: allSucceeded, stepPolicy: pipelinev1.SkipTreatAsFailure, fastPhase: pipelinev1.StepPhaseSucceeded, wantSkip: true},
+		{name: "failure handler not run for a skip by default", runIf: anyFailed, fastPhase: pipelinev1.StepPhaseSucceeded, wantSkip: true},
+		{name: "failure handler runs when skips count as failure", runIf: anyFailed, stepPolicy: pipelinev1.SkipTreatAsFailure, fastPhase: pipelinev1.StepPhaseSucceeded, wantReady: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pipeline := newRejoinPipeline(tt.pipelinePolicy, tt.stepPolicy, tt.runIf, tt.fastPhase)
+			g := newPipelineGraph(pipeline)
+			ready, skip := r.areDependenciesSatisfied(g, &pipeline.Spec.Steps[2])
+			if ready != tt.wantReady || skip != tt.wantSkip {
+				t.Errorf("expected (ready=%v, skip=%v), got (ready=%v, skip=%v)", tt.wantReady, tt.wantSkip, ready, skip)
+			}
+		})
+	}
+}
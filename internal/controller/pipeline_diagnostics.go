@@ -0,0 +1,139 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	pipelinev1 "github.com/yaacov/jobrunner/api/v1"
+)
+
+const (
+	// diagnosticsLogLines is how many log lines are kept from a failed container
+	diagnosticsLogLines = 20
+	// maxLogTailBytes bounds the log tail stored in the step status
+	maxLogTailBytes = 2048
+	// maxTerminationMessageBytes bounds the termination message stored in the step status
+	maxTerminationMessageBytes = 1024
+	// maxConditionDiagnosticsBytes bounds the diagnostics added to the Ready condition message
+	maxConditionDiagnosticsBytes = 4096
+
+	truncatedMarker = "..."
+)
+
+// recordFailureDiagnostics stores the termination message and log tail of the failed
+// container of a step's pod
+// Diagnostics are best effort: a pod whose logs cannot be read still fails the step
+func (r *PipelineReconciler) recordFailureDiagnostics(ctx context.Context, stepStatus *pipelinev1.StepStatus, pod *corev1.Pod) {
+	logger := log.FromContext(ctx)
+
+	containerStatus := failedContainer(pod)
+	if containerStatus == nil {
+		return
+	}
+
+	diagnostics := &pipelinev1.FailureDiagnostics{Container: containerStatus.Name}
+	if terminated := containerStatus.State.Terminated; terminated != nil {
+		diagnostics.TerminationMessage = truncateHead(strings.TrimSpace(terminated.Message), maxTerminationMessageBytes)
+	}
+
+	if r.Clientset != nil {
+		tailLines := int64(diagnosticsLogLines)
+		limitBytes := int64(maxLogTailBytes)
+		raw, err := r.Clientset.CoreV1().Pods(pod.Namespace).GetLogs(pod.Name, &corev1.PodLogOptions{
+			Container:  containerStatus.Name,
+			TailLines:  &tailLines,
+			LimitBytes: &limitBytes,
+		}).DoRaw(ctx)
+		if err != nil {
+			logger.Info("Unable to read logs of failed step",
+				"step", stepStatus.Name,
+				"pod", pod.Name,
+				"container", containerStatus.Name,
+				"error", err.Error())
+		} else {
+			diagnostics.LogTail = truncateTail(strings.TrimRight(string(raw), "\n"), maxLogTailBytes)
+		}
+	}
+
+	if diagnostics.TerminationMessage == "" && diagnostics.LogTail == "" {
+		return
+	}
+	stepStatus.Diagnostics = diagnostics
+}
+
+// failedContainer returns the status of the container that made the pod fail
+// Init containers are checked first since a failing one keeps the others from starting
+func failedContainer(pod *corev1.Pod) *corev1.ContainerStatus {
+	for _, statuses := range [][]corev1.ContainerStatus{pod.Status.InitContainerStatuses, pod.Status.ContainerStatuses} {
+		for i := range statuses {
+			if terminated := statuses[i].State.Terminated; terminated != nil && terminated.ExitCode != 0 {
+				return &statuses[i]
+			}
+		}
+	}
+
+	// Pods killed by a deadline may have no failed container; fall back to the main one
+	if len(pod.Status.ContainerStatuses) > 0 {
+		return &pod.Status.ContainerStatuses[0]
+	}
+	return nil
+}
+
+// failureDiagnosticsMessage summarizes the diagnostics of failed steps for the Ready condition
+func failureDiagnosticsMessage(pipeline *pipelinev1.Pipeline) string {
+	var b strings.Builder
+	for _, step := range pipeline.Status.Steps {
+		if step.Phase != pipelinev1.StepPhaseFailed || step.Diagnostics == nil || isAllowedFailure(&step) {
+			continue
+		}
+
+		fmt.Fprintf(&b, "\nstep %s", step.Name)
+		if step.Reason != "" {
+			fmt.Fprintf(&b, " (%s)", step.Reason)
+		}
+		fmt.Fprintf(&b, ", container %s:", step.Diagnostics.Container)
+		if step.Diagnostics.TerminationMessage != "" {
+			fmt.Fprintf(&b, "\n%s", step.Diagnostics.TerminationMessage)
+		}
+		if step.Diagnostics.LogTail != "" {
+			fmt.Fprintf(&b, "\n%s", step.Diagnostics.LogTail)
+		}
+	}
+	return truncateHead(b.String(), maxConditionDiagnosticsBytes)
+}
+
+// truncateHead keeps the beginning of s, at most limit bytes
+func truncateHead(s string, limit int) string {
+	if len(s) <= limit {
+		return s
+	}
+	return strings.ToValidUTF8(s[:limit-len(truncatedMarker)], "") + truncatedMarker
+}
+
+// truncateTail keeps the end of s, at most limit bytes
+func truncateTail(s string, limit int) string {
+	if len(s) <= limit {
+		return s
+	}
+	return truncatedMarker + strings.ToValidUTF8(s[len(s)-limit+len(truncatedMarker):], "")
+}
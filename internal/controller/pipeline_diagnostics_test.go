@@ -0,0 +1,171 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	kubefake "k8s.io/client-go/kubernetes/fake"
+
+	pipelinev1 "github.com/yaacov/jobrunner/api/v1"
+)
+
+func terminatedContainer(name string, exitCode int32, message string) corev1.ContainerStatus {
+	return corev1.ContainerStatus{
+		Name: name,
+		State: corev1.ContainerState{Terminated: &corev1.ContainerStateTerminated{
+			ExitCode: exitCode,
+			Message:  message,
+		}},
+	}
+}
+
+func TestFailedContainer(t *testing.T) {
+	tests := []struct {
+		name   string
+		status corev1.PodStatus
+		want   string
+	}{
+		{
+			name: "failed init container",
+			status: corev1.PodStatus{
+				InitContainerStatuses: []corev1.ContainerStatus{terminatedContainer("setup", 1, "")},
+				ContainerStatuses:     []corev1.ContainerStatus{{Name: "main"}},
+			},
+			want: "setup",
+		},
+		{
+			name: "failed container after a successful one",
+			status: corev1.PodStatus{ContainerStatuses: []corev1.ContainerStatus{
+				terminatedContainer("main", 0, ""),
+				terminatedContainer("upload", 2, ""),
+			}},
+			want: "upload",
+		},
+		{
+			name:   "no failed container falls back to the first",
+			status: corev1.PodStatus{ContainerStatuses: []corev1.ContainerStatus{{Name: "main"}}},
+			want:   "main",
+		},
+		{
+			name: "no containers",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := failedContainer(&corev1.Pod{Status: tt.status})
+			if tt.want == "" {
+				if got != nil {
+					t.Errorf("expected no container, got %q", got.Name)
+				}
+				return
+			}
+			if got == nil || got.Name != tt.want {
+				t.Errorf("expected container %q, got %v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestRecordFailureDiagnostics(t *testing.T) {
+	ctx := context.Background()
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: "release-build-x7k2p", Namespace: "default"},
+		Status: corev1.PodStatus{ContainerStatuses: []corev1.ContainerStatus{
+			terminatedContainer("main", 1, strings.Repeat("m", 2*maxTerminationMessageBytes)),
+		}},
+	}
+
+	t.Run("records the termination message and log tail", func(t *testing.T) {
+		r := &PipelineReconciler{Clientset: kubefake.NewClientset(pod)}
+		stepStatus := &pipelinev1.StepStatus{Name: "build", Phase: pipelinev1.StepPhaseFailed}
+
+		r.recordFailureDiagnostics(ctx, stepStatus, pod)
+
+		diagnostics := stepStatus.Diagnostics
+		if diagnostics == nil || diagnostics.Container != "main" {
+			t.Fatalf("expected diagnostics for container main, got %+v", diagnostics)
+		}
+		if len(diagnostics.TerminationMessage) != maxTerminationMessageBytes ||
+			!strings.HasSuffix(diagnostics.TerminationMessage, truncatedMarker) {
+			t.Errorf("expected a truncated termination message, got %d bytes", len(diagnostics.TerminationMessage))
+		}
+		if diagnostics.LogTail != "fake logs" {
+			t.Errorf("expected the log tail, got %q", diagnostics.LogTail)
+		}
+	})
+
+	t.Run("works without a clientset", func(t *testing.T) {
+		r := &PipelineReconciler{}
+		stepStatus := &pipelinev1.StepStatus{Name: "build", Phase: pipelinev1.StepPhaseFailed}
+
+		r.recordFailureDiagnostics(ctx, stepStatus, pod)
+
+		if stepStatus.Diagnostics == nil || stepStatus.Diagnostics.LogTail != "" {
+			t.Errorf("expected diagnostics without a log tail, got %+v", stepStatus.Diagnostics)
+		}
+	})
+}
+
+func TestTruncate(t *testing.T) {
+	if got := truncateHead("short", 10); got != "short" {
+		t.Errorf("expected short strings to be unchanged, got %q", got)
+	}
+	if got := truncateHead("0123456789abc", 10); got != "0123456..." {
+		t.Errorf("expected the head to be kept, got %q", got)
+	}
+	if got := truncateTail("0123456789abc", 10); got != "...6789abc" {
+		t.Errorf("expected the tail to be kept, got %q", got)
+	}
+	if got := truncateTail("abécdefghij", 10); len(got) > 10 || !strings.HasPrefix(got, truncatedMarker) {
+		t.Errorf("expected at most 10 bytes of valid text, got %q", got)
+	}
+}
+
+func TestUpdateConditionsFailureDiagnostics(t *testing.T) {
+	r := &PipelineReconciler{}
+	pipeline := &pipelinev1.Pipeline{Status: pipelinev1.PipelineStatus{
+		Phase: pipelinev1.PipelinePhaseFailed,
+		Steps: []pipelinev1.StepStatus{{
+			Name:   "build",
+			Phase:  pipelinev1.StepPhaseFailed,
+			Reason: "BackoffLimitExceeded",
+			Diagnostics: &pipelinev1.FailureDiagnostics{
+				Container: "main",
+				LogTail:   "compiling...\nerror: undefined: foo",
+			},
+		}},
+	}}
+
+	r.updateConditions(pipeline, pipelineState{})
+
+	condition := meta.FindStatusCondition(pipeline.Status.Conditions, "Ready")
+	if condition == nil {
+		t.Fatal("expected a Ready condition")
+	}
+	for _, want := range []string{"failed steps: [build]", "step build (BackoffLimitExceeded), container main:", "error: undefined: foo"} {
+		if !strings.Contains(condition.Message, want) {
+			t.Errorf("expected condition message to contain %q, got %q", want, condition.Message)
+		}
+	}
+}
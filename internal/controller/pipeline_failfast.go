@@ -0,0 +1,134 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"fmt"
+
+	batchv1 "k8s.io/api/batch/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	pipelinev1 "github.com/yaacov/jobrunner/api/v1"
+)
+
+// stepReasonCancelled marks a step stopped or skipped by failurePolicy FailFast
+const stepReasonCancelled = "Cancelled"
+
+// failFast applies failurePolicy FailFast once a step has failed: the Jobs of running
+// steps are deleted, and running and pending steps are marked Skipped with reason
+// Cancelled, so they count as skipped rather than failed. Failure handlers are left to run
+func (r *PipelineReconciler) failFast(ctx context.Context, pipeline *pipelinev1.Pipeline) error {
+	if pipeline.Spec.FailurePolicy == nil || pipeline.Spec.FailurePolicy.Mode != pipelinev1.FailureModeFailFast {
+		return nil
+	}
+	failed := firstFailedStep(pipeline)
+	if failed == "" {
+		return nil
+	}
+
+	logger := log.FromContext(ctx)
+	g := newPipelineGraph(pipeline)
+	cancelled, skipped := 0, 0
+	for _, step := range g.steps {
+		status := g.stepStatus(step.Name)
+		if status == nil || g.isFailureHandler(step) {
+			continue
+		}
+
+		switch status.Phase {
+		case pipelinev1.StepPhasePending:
+			status.Phase = pipelinev1.StepPhaseSkipped
+			status.Reason = stepReasonCancelled
+			status.Message = fmt.Sprintf("skipped after step %s failed", failed)
+			skipped++
+		case pipelinev1.StepPhaseRunning, pipelinev1.StepPhaseSuspended:
+			if err := r.cancelStep(ctx, pipeline, status); err != nil {
+				return err
+			}
+			now := metav1.Now()
+			status.Phase = pipelinev1.StepPhaseSkipped
+			status.Reason = stepReasonCancelled
+			status.Message = fmt.Sprintf("cancelled after step %s failed", failed)
+			status.CompletionTime = &now
+			if status.StartTime != nil {
+				status.Duration = roundedDuration(status.StartTime, status.CompletionTime)
+			}
+			cancelled++
+		}
+	}
+
+	if cancelled > 0 || skipped > 0 {
+		logger.Info("Failing fast, cancelled other steps",
+			"failedStep", failed,
+			"cancelled", cancelled,
+			"skipped", skipped)
+		r.updateSummaryStatus(pipeline)
+	}
+	return nil
+}
+
+// firstFailedStep returns the first step, in status order, that failed and whose failure
+// is not allowed, or "" if there is none
+func firstFailedStep(pipeline *pipelinev1.Pipeline) string {
+	for i := range pipeline.Status.Steps {
+		status := &pipeline.Status.Steps[i]
+		if status.Phase == pipelinev1.StepPhaseFailed && !isAllowedFailure(status) {
+			return status.Name
+		}
+	}
+	return ""
+}
+
+// cancelStep deletes the Jobs of a running step, or of the unfinished items of a forEach
+// step, archiving their logs first
+func (r *PipelineReconciler) cancelStep(ctx context.Context, pipeline *pipelinev1.Pipeline, stepStatus *pipelinev1.StepStatus) error {
+	if len(stepStatus.Children) == 0 {
+		return r.cancelJob(ctx, pipeline, stepStatus, stepStatus.JobName, stepStatus.Attempt)
+	}
+
+	for i := range stepStatus.Children {
+		child := &stepStatus.Children[i]
+		switch child.Phase {
+		case pipelinev1.StepPhasePending, pipelinev1.StepPhaseRunning, pipelinev1.StepPhaseSuspended:
+			if err := r.cancelJob(ctx, pipeline, stepStatus, child.JobName, child.Attempt); err != nil {
+				return err
+			}
+			child.Phase = pipelinev1.StepPhaseSkipped
+		}
+	}
+	return nil
+}
+
+// cancelJob archives the logs of an attempt of a step's Job and deletes it along with its pods
+func (r *PipelineReconciler) cancelJob(ctx context.Context, pipeline *pipelinev1.Pipeline, stepStatus *pipelinev1.StepStatus, jobName string, attempt int32) error {
+	if jobName == "" {
+		return nil
+	}
+
+	r.archiveStepLogs(ctx, pipeline, stepStatus, jobName, attempt)
+
+	job := &batchv1.Job{ObjectMeta: metav1.ObjectMeta{Name: jobName, Namespace: pipeline.Namespace}}
+	if err := r.Delete(ctx, job, client.PropagationPolicy(metav1.DeletePropagationBackground)); err != nil && !apierrors.IsNotFound(err) {
+		return fmt.Errorf("cancelling step %s: %w", stepStatus.Name, err)
+	}
+	log.FromContext(ctx).Info("Cancelled step job", "step", stepStatus.Name, "job", jobName)
+	return nil
+}
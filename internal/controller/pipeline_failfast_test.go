@@ -0,0 +1,190 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"testing"
+
+	batchv1 "k8s.io/api/batch/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+
+	pipelinev1 "github.com/yaacov/jobrunner/api/v1"
+)
+
+// newFailFastPipeline returns a pipeline whose lint step failed while the test and
+// docs branches still run, with a pending deploy step, a pending rollback handler and
+// a pending handler for test failures
+func newFailFastPipeline(mode pipelinev1.FailureMode) *pipelinev1.Pipeline {
+	return &pipelinev1.Pipeline{
+		ObjectMeta: metav1.ObjectMeta{Name: "release", Namespace: "default"},
+		Spec: pipelinev1.PipelineSpec{
+			FailurePolicy: &pipelinev1.FailurePolicy{Mode: mode},
+			Steps: []pipelinev1.PipelineStep{
+				{Name: "checkout"},
+				{Name: "lint", RunIf: &pipelinev1.RunIfCondition{Steps: []string{"checkout"}}},
+				{Name: "test", RunIf: &pipelinev1.RunIfCondition{Steps: []string{"checkout"}}},
+				{Name: "docs", RunIf: &pipelinev1.RunIfCondition{Steps: []string{"checkout"}}},
+				{Name: "deploy", RunIf: &pipelinev1.RunIfCondition{Steps: []string{"lint", "test", "docs"}}},
+				{Name: "rollback", RunIf: &pipelinev1.RunIfCondition{
+					Condition: pipelinev1.RunIfConditionFail,
+					Operator:  pipelinev1.RunIfOperatorOr,
+					Steps:     []string{"lint", "test", "docs", "deploy"},
+				}},
+				{Name: "test-report", RunIf: &pipelinev1.RunIfCondition{
+					Condition: pipelinev1.RunIfConditionFail,
+					Steps:     []string{"test"},
+				}},
+			},
+		},
+		Status: pipelinev1.PipelineStatus{Steps: []pipelinev1.StepStatus{
+			{Name: "checkout", Phase: pipelinev1.StepPhaseSucceeded, JobName: "release-checkout"},
+			{Name: "lint", Phase: pipelinev1.StepPhaseFailed, JobName: "release-lint"},
+			{Name: "test", Phase: pipelinev1.StepPhaseRunning, JobName: "release-test", StartTime: &metav1.Time{}},
+			{Name: "docs", Phase: pipelinev1.StepPhaseRunning, Children: []pipelinev1.ForEachChildStatus{
+				{Index: 0, Item: "en", Phase: pipelinev1.StepPhaseRunning, JobName: "release-docs-0"},
+				{Index: 1, Item: "fr", Phase: pipelinev1.StepPhasePending},
+			}},
+			{Name: "deploy", Phase: pipelinev1.StepPhasePending},
+			{Name: "rollback", Phase: pipelinev1.StepPhasePending},
+			{Name: "test-report", Phase: pipelinev1.StepPhasePending},
+		}},
+	}
+}
+
+func TestFailFast(t *testing.T) {
+	ctx := context.Background()
+
+	tests := []struct {
+		name       string
+		mode       pipelinev1.FailureMode
+		wantPhases map[string]pipelinev1.StepPhase
+		wantJobs   bool
+	}{
+		{
+			name: "continue leaves other branches running",
+			mode: pipelinev1.FailureModeContinue,
+			wantPhases: map[string]pipelinev1.StepPhase{
+				"test":     pipelinev1.StepPhaseRunning,
+				"docs":     pipelinev1.StepPhaseRunning,
+				"deploy":   pipelinev1.StepPhasePending,
+				"rollback": pipelinev1.StepPhasePending,
+			},
+			wantJobs: true,
+		},
+		{
+			name: "failFast cancels running steps and skips pending ones",
+			mode: pipelinev1.FailureModeFailFast,
+			wantPhases: map[string]pipelinev1.StepPhase{
+				"test":     pipelinev1.StepPhaseSkipped,
+				"docs":     pipelinev1.StepPhaseSkipped,
+				"deploy":   pipelinev1.StepPhaseSkipped,
+				"rollback": pipelinev1.StepPhasePending,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pipeline := newFailFastPipeline(tt.mode)
+			c := newFakeClient(pipeline,
+				&batchv1.Job{ObjectMeta: metav1.ObjectMeta{Name: "release-test", Namespace: "default"}},
+				&batchv1.Job{ObjectMeta: metav1.ObjectMeta{Name: "release-docs-0", Namespace: "default"}})
+			r := &PipelineReconciler{Client: c, Scheme: c.Scheme()}
+
+			if err := r.failFast(ctx, pipeline); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			for name, want := range tt.wantPhases {
+				status := r.getStepStatus(pipeline, name)
+				if status.Phase != want {
+					t.Errorf("expected step %s to be %s, got %s", name, want, status.Phase)
+				}
+				cancelled := status.Reason == stepReasonCancelled
+				if wantCancelled := want != pipelinev1.StepPhaseRunning && name != "rollback" && tt.mode == pipelinev1.FailureModeFailFast; cancelled != wantCancelled {
+					t.Errorf("expected step %s cancelled %v, got reason %q", name, wantCancelled, status.Reason)
+				}
+			}
+
+			for _, jobName := range []string{"release-test", "release-docs-0"} {
+				err := c.Get(ctx, types.NamespacedName{Namespace: "default", Name: jobName}, &batchv1.Job{})
+				if exists := err == nil; exists != tt.wantJobs {
+					t.Errorf("expected job %s to exist %v, got error %v", jobName, tt.wantJobs, err)
+				}
+				if err != nil && !apierrors.IsNotFound(err) {
+					t.Errorf("unexpected error: %v", err)
+				}
+			}
+
+			if tt.mode == pipelinev1.FailureModeFailFast {
+				docs := r.getStepStatus(pipeline, "docs")
+				if docs.Children[0].Phase != pipelinev1.StepPhaseSkipped || docs.Children[1].Phase != pipelinev1.StepPhaseSkipped {
+					t.Errorf("expected the docs items to be Skipped, got %+v", docs.Children)
+				}
+				if test := r.getStepStatus(pipeline, "test"); test.CompletionTime == nil || test.Message != "cancelled after step lint failed" {
+					t.Errorf("expected test to be completed with a cancel message, got %+v", test)
+				}
+
+				// Cancelled steps are not failures: only lint failed, and handlers run for
+				// lint alone
+				if failed := firstFailedStep(pipeline); failed != "lint" {
+					t.Errorf("expected lint to be the failed step, got %q", failed)
+				}
+				g := newPipelineGraph(pipeline)
+				if ready, skip := r.areDependenciesSatisfied(g, specStep(pipeline, "rollback")); !ready || skip {
+					t.Errorf("expected the rollback handler to run, got ready %v skip %v", ready, skip)
+				}
+				if _, skip := r.areDependenciesSatisfied(g, specStep(pipeline, "test-report")); !skip {
+					t.Errorf("expected the handler waiting only for the cancelled test step to be skipped")
+				}
+			}
+		})
+	}
+}
+
+func TestFailFastKeepsCancelledSteps(t *testing.T) {
+	ctx := context.Background()
+	pipeline := newFailFastPipeline(pipelinev1.FailureModeFailFast)
+	pipeline.UID = types.UID("release-uid")
+	// The Job of a cancelled step is still listed while it is being deleted
+	job := &batchv1.Job{
+		ObjectMeta: metav1.ObjectMeta{Name: "release-test", Namespace: "default", Labels: map[string]string{runUIDLabel: "release-uid"}},
+		Status:     batchv1.JobStatus{Active: 1},
+	}
+	c := newFakeClient(pipeline)
+	if err := controllerutil.SetControllerReference(pipeline, job, c.Scheme()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	r := &PipelineReconciler{Client: c, Scheme: c.Scheme()}
+	if err := r.failFast(ctx, pipeline); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := c.Create(ctx, job); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if err := r.updateStepStatuses(ctx, pipeline); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if test := r.getStepStatus(pipeline, "test"); test.Phase != pipelinev1.StepPhaseSkipped || test.Reason != stepReasonCancelled {
+		t.Errorf("expected the cancelled step to stay Skipped, got %s with reason %q", test.Phase, test.Reason)
+	}
+}
@@ -0,0 +1,383 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	pipelinev1 "github.com/yaacov/jobrunner/api/v1"
+)
+
+const (
+	// forEachItemEnv holds the item a forEach Job processes
+	forEachItemEnv = "ITEM"
+	// forEachIndexEnv holds the position of the item in the forEach list
+	forEachIndexEnv = "ITEM_INDEX"
+	// forEachIndexLabel marks the Jobs created for a forEach step with their item index
+	forEachIndexLabel = "pipeline.yaacov.io/foreach-index"
+	// maxForEachItems caps the Jobs a forEach step creates, and the items tracked in its status
+	maxForEachItems = 500
+)
+
+// parseForEach splits a step's forEach reference into the source step and result names
+func parseForEach(step *pipelinev1.PipelineStep) (stepName, resultName string, err error) {
+	stepName, resultName, ok := step.ForEachSource()
+	if !ok {
+		return "", "", fmt.Errorf("invalid forEach reference %q, expected $(steps.<step>.results.<name>)", step.ForEach)
+	}
+	return stepName, resultName, nil
+}
+
+// checkForEachSource checks whether the step emitting a forEach step's items has succeeded
+// Returns (ready, shouldSkip) with the same meaning as areDependenciesSatisfied
+func (r *PipelineReconciler) checkForEachSource(g *pipelineGraph, step *pipelinev1.PipelineStep) (ready bool, shouldSkip bool) {
+	sourceName, _, err := parseForEach(step)
+	if err != nil {
+		log.Log.Info("Invalid forEach reference", "step", step.Name, "forEach", step.ForEach)
+		return false, false
+	}
+
+	sourceStatus := g.stepStatus(sourceName)
+	if sourceStatus == nil {
+		log.Log.Info("Referenced step not found",
+			"step", step.Name,
+			"referencedStep", sourceName)
+		return false, false
+	}
+
+	switch sourceStatus.Phase {
+	case pipelinev1.StepPhaseSucceeded:
+		return true, false
+	case pipelinev1.StepPhaseFailed, pipelinev1.StepPhaseSkipped:
+		log.Log.Info("Step skipped - forEach source did not succeed",
+			"step", step.Name,
+			"source", sourceName,
+			"sourcePhase", sourceStatus.Phase)
+		return false, true
+	default:
+		return false, false
+	}
+}
+
+// isResultsSource returns true if any forEach step reads the results of the given step
+func isResultsSource(pipeline *pipelinev1.Pipeline, stepName string) bool {
+	for _, step := range pipeline.Spec.AllSteps() {
+		if sourceName, _, ok := step.ForEachSource(); ok && sourceName == stepName {
+			return true
+		}
+	}
+	return false
+}
+
+// collectStepResults reads the results a succeeded step wrote to its termination message
+// Each container may write a JSON object; keys from later containers win
+func (r *PipelineReconciler) collectStepResults(ctx context.Context, pipeline *pipelinev1.Pipeline, stepStatus *pipelinev1.StepStatus) error {
+	logger := log.FromContext(ctx)
+
+	podList := &corev1.PodList{}
+	if err := r.reader().List(ctx, podList, client.InNamespace(pipeline.Namespace), client.MatchingLabels{
+		batchv1.JobNameLabel: stepStatus.JobName,
+	}); err != nil {
+		logger.Error(err, "Failed to list pods for step results", "step", stepStatus.Name)
+		return err
+	}
+
+	results := map[string]string{}
+	for _, pod := range podList.Items {
+		if pod.Status.Phase != corev1.PodSucceeded {
+			continue
+		}
+		for _, containerStatus := range pod.Status.ContainerStatuses {
+			terminated := containerStatus.State.Terminated
+			if terminated == nil || terminated.Message == "" {
+				continue
+			}
+			fields := map[string]json.RawMessage{}
+			if err := json.Unmarshal([]byte(terminated.Message), &fields); err != nil {
+				logger.V(1).Info("Ignoring termination message that is not a JSON object",
+					"step", stepStatus.Name,
+					"container", containerStatus.Name)
+				continue
+			}
+			for name, raw := range fields {
+				results[name] = jsonValueString(raw)
+			}
+		}
+	}
+
+	logger.Info("Collected step results", "step", stepStatus.Name, "count", len(results))
+	stepStatus.Results = results
+	return nil
+}
+
+// forEachItems returns the items a forEach step expands to
+func (r *PipelineReconciler) forEachItems(pipeline *pipelinev1.Pipeline, step *pipelinev1.PipelineStep) ([]string, error) {
+	sourceName, resultName, err := parseForEach(step)
+	if err != nil {
+		return nil, err
+	}
+
+	sourceStatus := r.getStepStatus(pipeline, sourceName)
+	if sourceStatus == nil {
+		return nil, fmt.Errorf("forEach references unknown step %q", sourceName)
+	}
+	value, ok := sourceStatus.Results[resultName]
+	if !ok {
+		return nil, fmt.Errorf("step %q did not emit result %q", sourceName, resultName)
+	}
+
+	var raw []json.RawMessage
+	if err := json.Unmarshal([]byte(value), &raw); err != nil {
+		return nil, fmt.Errorf("result %q of step %q is not a JSON array", resultName, sourceName)
+	}
+	if len(raw) > maxForEachItems {
+		return nil, fmt.Errorf("result %q of step %q has %d items, more than the %d a forEach step can run",
+			resultName, sourceName, len(raw), maxForEachItems)
+	}
+
+	items := make([]string, 0, len(raw))
+	for _, item := range raw {
+		items = append(items, jsonValueString(item))
+	}
+	return items, nil
+}
+
+// jsonValueString returns JSON strings unquoted and other values as compact JSON
+func jsonValueString(raw json.RawMessage) string {
+	var s string
+	if err := json.Unmarshal(raw, &s); err == nil {
+		return s
+	}
+	compact := &bytes.Buffer{}
+	if err := json.Compact(compact, raw); err != nil {
+		return string(raw)
+	}
+	return compact.String()
+}
+
+// startForEachStep expands a forEach step into its items and starts the first Jobs
+// A step whose items cannot be resolved fails with a message instead of returning an error
+func (r *PipelineReconciler) startForEachStep(ctx context.Context, g *pipelineGraph, step *pipelinev1.PipelineStep, stepStatus *pipelinev1.StepStatus) error {
+	logger := log.FromContext(ctx)
+	pipeline := g.pipeline
+
+	items, err := r.forEachItems(pipeline, step)
+	if err != nil {
+		logger.Info("Failing forEach step", "step", step.Name, "reason", err.Error())
+		stepStatus.Phase = pipelinev1.StepPhaseFailed
+		stepStatus.Message = err.Error()
+		return nil
+	}
+
+	stepStatus.Children = make([]pipelinev1.ForEachChildStatus, 0, len(items))
+	for i, item := range items {
+		stepStatus.Children = append(stepStatus.Children, pipelinev1.ForEachChildStatus{
+			Index: int32(i),
+			Item:  item,
+			Phase: pipelinev1.StepPhasePending,
+		})
+	}
+
+	logger.Info("Expanded forEach step", "step", step.Name, "items", len(items))
+
+	if len(items) == 0 {
+		stepStatus.Phase = pipelinev1.StepPhaseSucceeded
+		return nil
+	}
+
+	stepStatus.Phase = pipelinev1.StepPhaseRunning
+	_, err = r.startForEachChildren(ctx, g, step, stepStatus)
+	return err
+}
+
+// startForEachChildren creates Jobs for pending items up to the step's parallelism
+// Returns true if any Job was created
+func (r *PipelineReconciler) startForEachChildren(ctx context.Context, g *pipelineGraph, step *pipelinev1.PipelineStep, stepStatus *pipelinev1.StepStatus) (bool, error) {
+	logger := log.FromContext(ctx)
+	pipeline := g.pipeline
+
+	slots := len(stepStatus.Children)
+	if step.ForEachParallelism != nil {
+		slots = int(*step.ForEachParallelism)
+	}
+	for _, child := range stepStatus.Children {
+		if child.Phase == pipelinev1.StepPhaseRunning || child.Phase == pipelinev1.StepPhaseSuspended {
+			slots--
+		}
+	}
+
+	started := false
+	for i := range stepStatus.Children {
+		if slots <= 0 {
+			break
+		}
+		child := &stepStatus.Children[i]
+		if child.Phase != pipelinev1.StepPhasePending {
+			continue
+		}
+
+		attempt := child.Attempt + 1
+		jobName := itemJobName(pipeline, step.Name, child.Index, attempt)
+		job, err := r.buildJobForStep(ctx, pipeline, step, stepStatus, jobName, attempt)
+		if err != nil {
+			return started, err
+		}
+		applyOOMRetries(pipeline, stepStatus, &child.Index, job)
+		g.recordResolvedStep(step.Name, &job.Spec)
+		applyForEachItem(job, child)
+
+		if err := r.createOrAdoptJob(ctx, pipeline, job); err != nil {
+			logger.Error(err, "Failed to create job", "job", jobName, "step", step.Name)
+			return started, err
+		}
+
+		logger.Info("Started forEach item", "step", step.Name, "index", child.Index, "job", jobName)
+		child.JobName = jobName
+		child.Attempt = attempt
+		child.Phase = pipelinev1.StepPhaseRunning
+		started = true
+		slots--
+	}
+
+	return started, nil
+}
+
+// applyForEachItem labels a Job with its item index and passes the item to all containers
+func applyForEachItem(job *batchv1.Job, child *pipelinev1.ForEachChildStatus) {
+	index := strconv.Itoa(int(child.Index))
+	job.Labels[forEachIndexLabel] = index
+
+	env := []corev1.EnvVar{
+		{Name: forEachItemEnv, Value: child.Item},
+		{Name: forEachIndexEnv, Value: index},
+	}
+
+	podSpec := &job.Spec.Template.Spec
+	for i := range podSpec.InitContainers {
+		podSpec.InitContainers[i].Env = append(podSpec.InitContainers[i].Env, env...)
+	}
+	for i := range podSpec.Containers {
+		podSpec.Containers[i].Env = append(podSpec.Containers[i].Env, env...)
+	}
+}
+
+// updateForEachChildren refreshes the item phases of a forEach step and rolls them up
+// Returns true if the step status changed
+func (r *PipelineReconciler) updateForEachChildren(ctx context.Context, pipeline *pipelinev1.Pipeline, jobs map[string]*batchv1.Job, stepStatus *pipelinev1.StepStatus) (bool, error) {
+	logger := log.FromContext(ctx)
+
+	if isTerminalStepPhase(stepStatus.Phase) {
+		return false, nil
+	}
+
+	changed := false
+	for i := range stepStatus.Children {
+		child := &stepStatus.Children[i]
+		if child.JobName == "" || isTerminalStepPhase(child.Phase) {
+			continue
+		}
+
+		job, err := r.findRunJob(ctx, pipeline, jobs, child.JobName)
+		if err != nil {
+			logger.Error(err, "Failed to fetch job",
+				"job", child.JobName,
+				"step", stepStatus.Name)
+			return false, err
+		}
+		if job == nil {
+			if r.handleLostChildJob(ctx, pipeline, stepStatus, child) {
+				changed = true
+			}
+			continue
+		}
+
+		if newPhase := r.determineStepPhase(job, child.Phase, pipeline.Spec.StepCompletion); newPhase != child.Phase {
+			if isTerminalStepPhase(newPhase) {
+				r.archiveStepLogs(ctx, pipeline, stepStatus, child.JobName, child.Attempt)
+			}
+
+			// Items that failed for reasons outside their code start a new attempt
+			if newPhase == pipelinev1.StepPhaseFailed {
+				pod, err := r.jobPod(ctx, pipeline, child.JobName)
+				if err != nil {
+					return false, err
+				}
+				index := child.Index
+				if r.autoRetry(ctx, pipeline, stepStatus, &index, child.JobName, child.Attempt, pod) {
+					child.Phase = pipelinev1.StepPhasePending
+					child.JobName = ""
+					changed = true
+					continue
+				}
+			}
+			logger.Info("forEach item phase changed",
+				"step", stepStatus.Name,
+				"index", child.Index,
+				"oldPhase", child.Phase,
+				"newPhase", newPhase)
+			child.Phase = newPhase
+			changed = true
+		}
+	}
+
+	if newPhase := forEachPhase(stepStatus.Children); newPhase != stepStatus.Phase {
+		logger.Info("Step phase changed",
+			"step", stepStatus.Name,
+			"oldPhase", stepStatus.Phase,
+			"newPhase", newPhase)
+		stepStatus.Phase = newPhase
+		changed = true
+	}
+
+	return changed, nil
+}
+
+// forEachPhase rolls up the phases of a forEach step's items
+// The step runs until every item finished, then fails if any item failed
+func forEachPhase(children []pipelinev1.ForEachChildStatus) pipelinev1.StepPhase {
+	running, suspended, failed := 0, 0, 0
+	for _, child := range children {
+		switch child.Phase {
+		case pipelinev1.StepPhasePending, pipelinev1.StepPhaseRunning:
+			running++
+		case pipelinev1.StepPhaseSuspended:
+			suspended++
+		case pipelinev1.StepPhaseFailed:
+			failed++
+		}
+	}
+
+	switch {
+	case running > 0:
+		return pipelinev1.StepPhaseRunning
+	case suspended > 0:
+		return pipelinev1.StepPhaseSuspended
+	case failed > 0:
+		return pipelinev1.StepPhaseFailed
+	default:
+		return pipelinev1.StepPhaseSucceeded
+	}
+}
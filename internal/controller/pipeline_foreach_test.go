@@ -0,0 +1,292 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+
+	pipelinev1 "github.com/yaacov/jobrunner/api/v1"
+)
+
+// newForEachPipeline builds a pipeline where "process" fans out over the shards emitted by "discover"
+func newForEachPipeline(results map[string]string, parallelism *int32) *pipelinev1.Pipeline {
+	return &pipelinev1.Pipeline{
+		ObjectMeta: metav1.ObjectMeta{Name: "data", Namespace: "default", UID: "uid"},
+		Spec: pipelinev1.PipelineSpec{
+			Steps: []pipelinev1.PipelineStep{
+				{Name: "discover"},
+				{
+					Name:               "process",
+					ForEach:            "$(steps.discover.results.shards)",
+					ForEachParallelism: parallelism,
+					JobSpec: &batchv1.JobSpec{Template: corev1.PodTemplateSpec{Spec: corev1.PodSpec{
+						Containers: []corev1.Container{{Name: "main", Image: "busybox"}},
+					}}},
+				},
+			},
+		},
+		Status: pipelinev1.PipelineStatus{
+			Steps: []pipelinev1.StepStatus{
+				{Name: "discover", Phase: pipelinev1.StepPhaseSucceeded, JobName: "data-discover", Results: results},
+				{Name: "process", Phase: pipelinev1.StepPhasePending},
+			},
+		},
+	}
+}
+
+func TestForEachItems(t *testing.T) {
+	r := &PipelineReconciler{}
+
+	tests := []struct {
+		name      string
+		results   map[string]string
+		wantItems []string
+		wantErr   string
+	}{
+		{
+			name:      "strings are passed as-is",
+			results:   map[string]string{"shards": `["a","b"]`},
+			wantItems: []string{"a", "b"},
+		},
+		{
+			name:      "other values are passed as JSON",
+			results:   map[string]string{"shards": `[1, {"from": 0, "to": 10}]`},
+			wantItems: []string{"1", `{"from":0,"to":10}`},
+		},
+		{
+			name:      "empty list",
+			results:   map[string]string{"shards": `[]`},
+			wantItems: []string{},
+		},
+		{
+			name:    "missing result",
+			results: map[string]string{"other": `[]`},
+			wantErr: `step "discover" did not emit result "shards"`,
+		},
+		{
+			name:    "result is not an array",
+			results: map[string]string{"shards": `a`},
+			wantErr: `result "shards" of step "discover" is not a JSON array`,
+		},
+		{
+			name:      "as many items as the limit",
+			results:   map[string]string{"shards": "[" + strings.Repeat("0,", maxForEachItems-1) + "0]"},
+			wantItems: strings.Split(strings.Repeat("0,", maxForEachItems-1)+"0", ","),
+		},
+		{
+			name:    "too many items",
+			results: map[string]string{"shards": "[" + strings.Repeat("0,", maxForEachItems) + "0]"},
+			wantErr: `result "shards" of step "discover" has 501 items, more than the 500 a forEach step can run`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pipeline := newForEachPipeline(tt.results, nil)
+			items, err := r.forEachItems(pipeline, &pipeline.Spec.Steps[1])
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("expected error containing %q, got %v", tt.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if strings.Join(items, "|") != strings.Join(tt.wantItems, "|") || len(items) != len(tt.wantItems) {
+				t.Errorf("expected items %v, got %v", tt.wantItems, items)
+			}
+		})
+	}
+}
+
+func TestCollectStepResults(t *testing.T) {
+	pod := func(name string, phase corev1.PodPhase, messages ...string) *corev1.Pod {
+		p := &corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      name,
+				Namespace: "default",
+				Labels:    map[string]string{batchv1.JobNameLabel: "data-discover"},
+			},
+			Status: corev1.PodStatus{Phase: phase},
+		}
+		for _, message := range messages {
+			p.Status.ContainerStatuses = append(p.Status.ContainerStatuses, corev1.ContainerStatus{
+				State: corev1.ContainerState{Terminated: &corev1.ContainerStateTerminated{Message: message}},
+			})
+		}
+		return p
+	}
+
+	r := &PipelineReconciler{Client: newFakeClient(
+		pod("failed-attempt", corev1.PodFailed, `{"shards": ["stale"]}`),
+		pod("succeeded", corev1.PodSucceeded, `{"shards": ["a", "b"], "count": 2}`, "plain text output"),
+	)}
+
+	pipeline := newForEachPipeline(nil, nil)
+	stepStatus := &pipeline.Status.Steps[0]
+	if err := r.collectStepResults(context.Background(), pipeline, stepStatus); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if stepStatus.Results["shards"] != `["a","b"]` {
+		t.Errorf("expected shards from the succeeded pod, got %q", stepStatus.Results["shards"])
+	}
+	if stepStatus.Results["count"] != "2" {
+		t.Errorf("expected count 2, got %q", stepStatus.Results["count"])
+	}
+
+	if !isResultsSource(pipeline, "discover") || isResultsSource(pipeline, "process") {
+		t.Error("expected only discover to be a results source")
+	}
+}
+
+func TestForEachExecution(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("starts items up to the parallelism limit", func(t *testing.T) {
+		c := newFakeClient()
+		r := &PipelineReconciler{Client: c, Scheme: c.Scheme()}
+		pipeline := newForEachPipeline(map[string]string{"shards": `["a","b","c"]`}, int32Ptr(2))
+		step, stepStatus := &pipeline.Spec.Steps[1], &pipeline.Status.Steps[1]
+
+		if err := r.startForEachStep(ctx, newPipelineGraph(pipeline), step, stepStatus); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if stepStatus.Phase != pipelinev1.StepPhaseRunning || len(stepStatus.Children) != 3 {
+			t.Fatalf("expected running step with 3 items, got %s with %d", stepStatus.Phase, len(stepStatus.Children))
+		}
+		if stepStatus.Children[2].Phase != pipelinev1.StepPhasePending || stepStatus.Children[2].JobName != "" {
+			t.Errorf("expected third item to wait, got %+v", stepStatus.Children[2])
+		}
+
+		job := &batchv1.Job{}
+		if err := c.Get(ctx, types.NamespacedName{Namespace: "default", Name: stepStatus.Children[1].JobName}, job); err != nil {
+			t.Fatalf("expected job for item 1: %v", err)
+		}
+		if job.Labels[forEachIndexLabel] != "1" {
+			t.Errorf("expected index label 1, got %q", job.Labels[forEachIndexLabel])
+		}
+		env := job.Spec.Template.Spec.Containers[0].Env
+		if len(env) != 2 || env[0].Value != "b" || env[1].Value != "1" {
+			t.Errorf("expected ITEM=b and ITEM_INDEX=1, got %v", env)
+		}
+
+		// Finish the first item and let the third one start
+		finished := &batchv1.Job{}
+		if err := c.Get(ctx, types.NamespacedName{Namespace: "default", Name: stepStatus.Children[0].JobName}, finished); err != nil {
+			t.Fatalf("expected job for item 0: %v", err)
+		}
+		finished.Status.Conditions = []batchv1.JobCondition{{Type: batchv1.JobComplete, Status: corev1.ConditionTrue}}
+		if err := c.Status().Update(ctx, finished); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		if _, err := r.updateForEachChildren(ctx, pipeline, nil, stepStatus); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if stepStatus.Children[0].Phase != pipelinev1.StepPhaseSucceeded {
+			t.Errorf("expected first item to succeed, got %s", stepStatus.Children[0].Phase)
+		}
+
+		started, err := r.startForEachChildren(ctx, newPipelineGraph(pipeline), step, stepStatus)
+		if err != nil || !started {
+			t.Fatalf("expected third item to start, got started=%v err=%v", started, err)
+		}
+		if want := itemJobName(pipeline, "process", 2, 1); stepStatus.Children[2].JobName != want {
+			t.Errorf("expected third item job %q, got %q", want, stepStatus.Children[2].JobName)
+		}
+	})
+
+	t.Run("empty list succeeds without jobs", func(t *testing.T) {
+		r := &PipelineReconciler{Client: newFakeClient()}
+		pipeline := newForEachPipeline(map[string]string{"shards": `[]`}, nil)
+
+		if err := r.startForEachStep(ctx, newPipelineGraph(pipeline), &pipeline.Spec.Steps[1], &pipeline.Status.Steps[1]); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if pipeline.Status.Steps[1].Phase != pipelinev1.StepPhaseSucceeded {
+			t.Errorf("expected step to succeed, got %s", pipeline.Status.Steps[1].Phase)
+		}
+	})
+
+	t.Run("invalid items fail the step with a message", func(t *testing.T) {
+		r := &PipelineReconciler{Client: newFakeClient()}
+		pipeline := newForEachPipeline(nil, nil)
+
+		if err := r.startForEachStep(ctx, newPipelineGraph(pipeline), &pipeline.Spec.Steps[1], &pipeline.Status.Steps[1]); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		stepStatus := pipeline.Status.Steps[1]
+		if stepStatus.Phase != pipelinev1.StepPhaseFailed || !strings.Contains(stepStatus.Message, "did not emit result") {
+			t.Errorf("expected failed step with message, got %s %q", stepStatus.Phase, stepStatus.Message)
+		}
+	})
+}
+
+func TestForEachPhase(t *testing.T) {
+	child := func(phase pipelinev1.StepPhase) pipelinev1.ForEachChildStatus {
+		return pipelinev1.ForEachChildStatus{Phase: phase}
+	}
+
+	tests := []struct {
+		name     string
+		children []pipelinev1.ForEachChildStatus
+		want     pipelinev1.StepPhase
+	}{
+		{
+			name:     "running while items are pending",
+			children: []pipelinev1.ForEachChildStatus{child(pipelinev1.StepPhaseSucceeded), child(pipelinev1.StepPhasePending)},
+			want:     pipelinev1.StepPhaseRunning,
+		},
+		{
+			name:     "waits for all items before failing",
+			children: []pipelinev1.ForEachChildStatus{child(pipelinev1.StepPhaseFailed), child(pipelinev1.StepPhaseRunning)},
+			want:     pipelinev1.StepPhaseRunning,
+		},
+		{
+			name:     "failed when any item failed",
+			children: []pipelinev1.ForEachChildStatus{child(pipelinev1.StepPhaseFailed), child(pipelinev1.StepPhaseSucceeded)},
+			want:     pipelinev1.StepPhaseFailed,
+		},
+		{
+			name:     "suspended when an item is suspended",
+			children: []pipelinev1.ForEachChildStatus{child(pipelinev1.StepPhaseSuspended), child(pipelinev1.StepPhaseSucceeded)},
+			want:     pipelinev1.StepPhaseSuspended,
+		},
+		{
+			name:     "succeeded when all items succeeded",
+			children: []pipelinev1.ForEachChildStatus{child(pipelinev1.StepPhaseSucceeded), child(pipelinev1.StepPhaseSucceeded)},
+			want:     pipelinev1.StepPhaseSucceeded,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := forEachPhase(tt.children); got != tt.want {
+				t.Errorf("forEachPhase() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
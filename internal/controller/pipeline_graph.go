@@ -0,0 +1,203 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	pipelinev1 "github.com/yaacov/jobrunner/api/v1"
+)
+
+// pipelineGraph indexes the steps and stages of a pipeline for one reconcile, so
+// dependency checks on pipelines with thousands of steps stay linear overall
+// Step phases are read from the pipeline status; callers that change a phase
+// report it with stepChanged so the derived counts are refreshed
+type pipelineGraph struct {
+	pipeline *pipelinev1.Pipeline
+	// steps lists the top-level steps followed by the steps of each stage, in spec order
+	steps []*pipelinev1.PipelineStep
+
+	statuses map[string]*pipelinev1.StepStatus
+	stages   map[string]int
+
+	// lists holds the top-level steps at 0 and the steps of stage i at i+1,
+	// the units sequential execution is ordered in
+	lists    []sequentialList
+	list     map[string]int
+	position map[string]int
+
+	// stagePhases caches the rolled-up phase of each stage, "" when it must be recomputed
+	stagePhases []pipelinev1.StepPhase
+
+	// resolved indexes status.resolvedSteps by step name, built on first use
+	resolved map[string]int
+}
+
+// sequentialList holds running counts of the phases of an ordered list of steps
+type sequentialList struct {
+	names []string
+	// waiting[i] counts the Pending or Running steps among the first i steps, failed[i]
+	// the Failed ones and skipped[i] the Skipped ones; all are valid up to index valid
+	waiting []int
+	failed  []int
+	skipped []int
+	valid   int
+}
+
+// newPipelineGraph indexes a pipeline's spec and status
+func newPipelineGraph(pipeline *pipelinev1.Pipeline) *pipelineGraph {
+	count := len(pipeline.Spec.Steps)
+	for i := range pipeline.Spec.Stages {
+		count += len(pipeline.Spec.Stages[i].Steps)
+	}
+
+	g := &pipelineGraph{
+		pipeline:    pipeline,
+		steps:       make([]*pipelinev1.PipelineStep, 0, count),
+		statuses:    make(map[string]*pipelinev1.StepStatus, len(pipeline.Status.Steps)),
+		stages:      make(map[string]int, len(pipeline.Spec.Stages)),
+		lists:       make([]sequentialList, len(pipeline.Spec.Stages)+1),
+		list:        make(map[string]int, count),
+		position:    make(map[string]int, count),
+		stagePhases: make([]pipelinev1.StepPhase, len(pipeline.Spec.Stages)),
+	}
+
+	for i := range pipeline.Status.Steps {
+		g.statuses[pipeline.Status.Steps[i].Name] = &pipeline.Status.Steps[i]
+	}
+
+	g.addList(0, pipeline.Spec.Steps)
+	for i := range pipeline.Spec.Stages {
+		g.stages[pipeline.Spec.Stages[i].Name] = i
+		g.addList(i+1, pipeline.Spec.Stages[i].Steps)
+	}
+	return g
+}
+
+// addList records the order of the steps in list l
+func (g *pipelineGraph) addList(l int, steps []pipelinev1.PipelineStep) {
+	names := make([]string, len(steps))
+	for i := range steps {
+		g.steps = append(g.steps, &steps[i])
+		names[i] = steps[i].Name
+		g.list[steps[i].Name] = l
+		g.position[steps[i].Name] = i
+	}
+	g.lists[l] = sequentialList{
+		names:   names,
+		waiting: make([]int, len(names)+1),
+		failed:  make([]int, len(names)+1),
+		skipped: make([]int, len(names)+1),
+	}
+}
+
+// stepStatus returns the status of a step, or nil if it has none
+func (g *pipelineGraph) stepStatus(name string) *pipelinev1.StepStatus {
+	return g.statuses[name]
+}
+
+// stepPhase returns the phase of a step as the steps and stages after it see it:
+// Pending if it has no status yet, and Succeeded for an allowed failure
+func (g *pipelineGraph) stepPhase(name string) pipelinev1.StepPhase {
+	status := g.statuses[name]
+	switch {
+	case status == nil || status.Phase == "":
+		return pipelinev1.StepPhasePending
+	case isAllowedFailure(status):
+		return pipelinev1.StepPhaseSucceeded
+	}
+	return status.Phase
+}
+
+// stageOf returns the stage containing a step and its index, or nil for top-level steps
+func (g *pipelineGraph) stageOf(stepName string) (*pipelinev1.PipelineStage, int) {
+	l, ok := g.list[stepName]
+	if !ok || l == 0 {
+		return nil, -1
+	}
+	return &g.pipeline.Spec.Stages[l-1], l - 1
+}
+
+// stage returns a stage and its index by name, or nil if there is none
+func (g *pipelineGraph) stage(name string) (*pipelinev1.PipelineStage, int) {
+	i, ok := g.stages[name]
+	if !ok {
+		return nil, -1
+	}
+	return &g.pipeline.Spec.Stages[i], i
+}
+
+// stepChanged refreshes the derived state after the phase of a step changed
+func (g *pipelineGraph) stepChanged(name string) {
+	l, ok := g.list[name]
+	if !ok {
+		return
+	}
+	if list := &g.lists[l]; g.position[name] < list.valid {
+		list.valid = g.position[name]
+	}
+	if l > 0 {
+		g.stagePhases[l-1] = ""
+	}
+}
+
+// predecessorCounts returns how many of the steps before a step in its list are still
+// waiting to finish, how many failed and how many were skipped
+func (g *pipelineGraph) predecessorCounts(name string) (waiting int, failed int, skipped int) {
+	l, ok := g.list[name]
+	if !ok {
+		return 0, 0, 0
+	}
+	list := &g.lists[l]
+	pos := g.position[name]
+
+	for ; list.valid < pos; list.valid++ {
+		i := list.valid
+		list.waiting[i+1], list.failed[i+1], list.skipped[i+1] = list.waiting[i], list.failed[i], list.skipped[i]
+		switch g.stepPhase(list.names[i]) {
+		case pipelinev1.StepPhasePending, pipelinev1.StepPhaseRunning:
+			list.waiting[i+1]++
+		case pipelinev1.StepPhaseFailed:
+			list.failed[i+1]++
+		case pipelinev1.StepPhaseSkipped:
+			list.skipped[i+1]++
+		}
+	}
+	return list.waiting[pos], list.failed[pos], list.skipped[pos]
+}
+
+// skipPolicy returns how skipped dependencies count for a step: its own skipPolicy,
+// else the pipeline's, else Propagate. Stage gates pass a nil step for the pipeline's
+func (g *pipelineGraph) skipPolicy(step *pipelinev1.PipelineStep) pipelinev1.SkipPolicy {
+	if step != nil && step.SkipPolicy != "" {
+		return step.SkipPolicy
+	}
+	if g.pipeline.Spec.SkipPolicy != "" {
+		return g.pipeline.Spec.SkipPolicy
+	}
+	return pipelinev1.SkipPropagate
+}
+
+// stagePhase returns the rolled-up phase of a stage, and false if there is no such stage
+func (g *pipelineGraph) stagePhase(name string) (pipelinev1.StepPhase, bool) {
+	stage, i := g.stage(name)
+	if stage == nil {
+		return "", false
+	}
+	if g.stagePhases[i] == "" {
+		g.stagePhases[i] = g.stageStatus(stage).Phase
+	}
+	return g.stagePhases[i], true
+}
@@ -0,0 +1,205 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+
+	pipelinev1 "github.com/yaacov/jobrunner/api/v1"
+)
+
+func TestPipelineGraphPredecessorCounts(t *testing.T) {
+	pipeline := newStagedPipeline(map[string]pipelinev1.StepPhase{
+		"setup":   pipelinev1.StepPhaseSucceeded,
+		"compile": pipelinev1.StepPhaseFailed,
+	})
+	g := newPipelineGraph(pipeline)
+
+	tests := []struct {
+		step        string
+		wantWaiting int
+		wantFailed  int
+	}{
+		{step: "setup"},
+		{step: "compile"},
+		{step: "package", wantFailed: 1},
+		{step: "unit"},
+		{step: "unknown"},
+	}
+	for _, tt := range tests {
+		waiting, failed, _ := g.predecessorCounts(tt.step)
+		if waiting != tt.wantWaiting || failed != tt.wantFailed {
+			t.Errorf("%s: expected (%d, %d), got (%d, %d)", tt.step, tt.wantWaiting, tt.wantFailed, waiting, failed)
+		}
+	}
+
+	t.Run("counts follow reported phase changes", func(t *testing.T) {
+		if phase, _ := g.stagePhase("build"); phase != pipelinev1.StepPhaseRunning {
+			t.Fatalf("expected stage build to be Running, got %s", phase)
+		}
+
+		g.stepStatus("compile").Phase = pipelinev1.StepPhaseRunning
+		g.stepChanged("compile")
+
+		if waiting, failed, _ := g.predecessorCounts("package"); waiting != 1 || failed != 0 {
+			t.Errorf("expected (1, 0), got (%d, %d)", waiting, failed)
+		}
+		if phase, _ := g.stagePhase("build"); phase != pipelinev1.StepPhaseRunning {
+			t.Errorf("expected stage build to be Running, got %s", phase)
+		}
+
+		g.stepStatus("compile").Phase = pipelinev1.StepPhaseSucceeded
+		g.stepStatus("package").Phase = pipelinev1.StepPhaseSucceeded
+		g.stepChanged("compile")
+		g.stepChanged("package")
+		if phase, _ := g.stagePhase("build"); phase != pipelinev1.StepPhaseSucceeded {
+			t.Errorf("expected stage build to be Succeeded, got %s", phase)
+		}
+	})
+}
+
+func TestPipelineGraphStages(t *testing.T) {
+	g := newPipelineGraph(newStagedPipeline(nil))
+
+	if stage, i := g.stageOf("unit"); stage == nil || stage.Name != "test" || i != 1 {
+		t.Errorf("expected step unit in stage test at 1, got %v at %d", stage, i)
+	}
+	if stage, _ := g.stageOf("setup"); stage != nil {
+		t.Errorf("expected top-level step setup to have no stage, got %s", stage.Name)
+	}
+	if _, found := g.stagePhase("deploy"); found {
+		t.Errorf("expected unknown stage not to be found")
+	}
+	if phase := g.stepPhase("unknown"); phase != pipelinev1.StepPhasePending {
+		t.Errorf("expected a step without status to be Pending, got %s", phase)
+	}
+}
+
+// newLargePipeline returns a sequential pipeline of n steps, split over stages of 100
+// steps, where the first half of the steps has succeeded
+func newLargePipeline(n int) *pipelinev1.Pipeline {
+	pipeline := &pipelinev1.Pipeline{
+		ObjectMeta: metav1.ObjectMeta{Name: "large", Namespace: "default", UID: types.UID("large-uid")},
+	}
+	for i := 0; i < n; i++ {
+		step := pipelinev1.PipelineStep{
+			Name: fmt.Sprintf("step-%d", i),
+			JobSpec: &batchv1.JobSpec{Template: corev1.PodTemplateSpec{Spec: corev1.PodSpec{
+				RestartPolicy: corev1.RestartPolicyNever,
+				Containers:    []corev1.Container{{Name: "main", Image: "busybox"}},
+			}}},
+		}
+		if i%100 == 0 {
+			pipeline.Spec.Stages = append(pipeline.Spec.Stages, pipelinev1.PipelineStage{Name: fmt.Sprintf("stage-%d", i/100)})
+		}
+		stage := &pipeline.Spec.Stages[len(pipeline.Spec.Stages)-1]
+		stage.Steps = append(stage.Steps, step)
+
+		phase := pipelinev1.StepPhasePending
+		if i < n/2 {
+			phase = pipelinev1.StepPhaseSucceeded
+		}
+		pipeline.Status.Steps = append(pipeline.Status.Steps, pipelinev1.StepStatus{Name: step.Name, Phase: phase})
+	}
+	return pipeline
+}
+
+var largePipelineSizes = []int{1000, 5000}
+
+func BenchmarkAreDependenciesSatisfied(b *testing.B) {
+	r := &PipelineReconciler{}
+	for _, n := range largePipelineSizes {
+		pipeline := newLargePipeline(n)
+		b.Run(fmt.Sprintf("steps=%d", n), func(b *testing.B) {
+			for b.Loop() {
+				g := newPipelineGraph(pipeline)
+				for i := range g.steps {
+					r.areDependenciesSatisfied(g, g.steps[i])
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkAnalyzePipelineState(b *testing.B) {
+	r := &PipelineReconciler{}
+	for _, n := range largePipelineSizes {
+		pipeline := newLargePipeline(n)
+		pipeline.Status.Steps[0].Phase = pipelinev1.StepPhaseFailed
+		b.Run(fmt.Sprintf("steps=%d", n), func(b *testing.B) {
+			for b.Loop() {
+				r.analyzePipelineState(pipeline)
+			}
+		})
+	}
+}
+
+func BenchmarkUpdateSummaryStatus(b *testing.B) {
+	r := &PipelineReconciler{}
+	for _, n := range largePipelineSizes {
+		pipeline := newLargePipeline(n)
+		b.Run(fmt.Sprintf("steps=%d", n), func(b *testing.B) {
+			for b.Loop() {
+				r.updateSummaryStatus(pipeline)
+			}
+		})
+	}
+}
+
+func BenchmarkStartReadySteps(b *testing.B) {
+	ctx := context.Background()
+	for _, n := range largePipelineSizes {
+		pipeline := newLargePipeline(n)
+		c := newFakeClient(pipeline)
+		r := &PipelineReconciler{Client: c, Scheme: c.Scheme()}
+		b.Run(fmt.Sprintf("steps=%d", n), func(b *testing.B) {
+			for b.Loop() {
+				if err := r.startReadySteps(ctx, pipeline.DeepCopy()); err != nil {
+					b.Fatalf("unexpected error: %v", err)
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkResolveSteps(b *testing.B) {
+	ctx := context.Background()
+	for _, n := range largePipelineSizes {
+		pipeline := newLargePipeline(n)
+		pipeline.Spec.ResolveSteps = true
+		c := newFakeClient(pipeline)
+		r := &PipelineReconciler{Client: c, Scheme: c.Scheme()}
+		// Later reconciles replace the entries the first one recorded
+		if err := r.resolveSteps(ctx, pipeline); err != nil {
+			b.Fatalf("unexpected error: %v", err)
+		}
+		b.Run(fmt.Sprintf("steps=%d", n), func(b *testing.B) {
+			for b.Loop() {
+				if err := r.resolveSteps(ctx, pipeline); err != nil {
+					b.Fatalf("unexpected error: %v", err)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,77 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	pipelinev1 "github.com/yaacov/jobrunner/api/v1"
+)
+
+// applyHooks injects the pipeline's pre hooks as init containers and its post hooks
+// as native sidecars, with the same container defaults and shared volume as the
+// step's own containers
+func (r *PipelineReconciler) applyHooks(pipeline *pipelinev1.Pipeline, step *pipelinev1.PipelineStep, job *batchv1.Job) {
+	hooks := pipeline.Spec.Hooks
+	if hooks == nil || step.DisableHooks || (len(hooks.Pre) == 0 && len(hooks.Post) == 0) {
+		return
+	}
+
+	// Apply defaults to a scratch pod spec holding only the hook containers,
+	// so the step's own init containers are left untouched
+	hookSpec := &corev1.PodSpec{}
+	for i := range hooks.Pre {
+		hookSpec.Containers = append(hookSpec.Containers, *hooks.Pre[i].DeepCopy())
+	}
+	for i := range hooks.Post {
+		hookSpec.Containers = append(hookSpec.Containers, *hooks.Post[i].DeepCopy())
+	}
+
+	if pipeline.Spec.PodTemplate != nil {
+		r.applyContainerDefaults(pipeline.Spec.PodTemplate, hookSpec)
+	}
+
+	if pipeline.Spec.SharedVolume != nil {
+		volumeMount := sharedVolumeMount(pipeline.Spec.SharedVolume)
+		for i := range hookSpec.Containers {
+			hookSpec.Containers[i].VolumeMounts = append(hookSpec.Containers[i].VolumeMounts, volumeMount)
+		}
+	}
+
+	pre := hookSpec.Containers[:len(hooks.Pre)]
+	post := hookSpec.Containers[len(hooks.Pre):]
+
+	// Post hooks are native sidecars: they start before and stop after the step's containers
+	restartAlways := corev1.ContainerRestartPolicyAlways
+	for i := range post {
+		post[i].RestartPolicy = &restartAlways
+	}
+
+	podSpec := &job.Spec.Template.Spec
+	initContainers := make([]corev1.Container, 0, len(pre)+len(podSpec.InitContainers)+len(post))
+	initContainers = append(initContainers, pre...)
+	initContainers = append(initContainers, podSpec.InitContainers...)
+	initContainers = append(initContainers, post...)
+	podSpec.InitContainers = initContainers
+
+	log.Log.V(1).Info("Injected pipeline hooks",
+		"step", step.Name,
+		"preHooks", len(pre),
+		"postHooks", len(post))
+}
@@ -0,0 +1,149 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"testing"
+
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+
+	pipelinev1 "github.com/yaacov/jobrunner/api/v1"
+)
+
+func TestApplyHooks(t *testing.T) {
+	r := &PipelineReconciler{}
+
+	hooks := &pipelinev1.PipelineHooks{
+		Pre:  []corev1.Container{{Name: "fetch-credentials"}},
+		Post: []corev1.Container{{Name: "upload-logs"}},
+	}
+
+	newJob := func() *batchv1.Job {
+		return &batchv1.Job{
+			Spec: batchv1.JobSpec{
+				Template: corev1.PodTemplateSpec{
+					Spec: corev1.PodSpec{
+						InitContainers: []corev1.Container{{Name: "user-init"}},
+						Containers:     []corev1.Container{{Name: "main"}},
+					},
+				},
+			},
+		}
+	}
+
+	tests := []struct {
+		name      string
+		pipeline  *pipelinev1.Pipeline
+		step      *pipelinev1.PipelineStep
+		wantCheck func(t *testing.T, podSpec *corev1.PodSpec)
+	}{
+		{
+			name:     "does nothing without hooks",
+			pipeline: &pipelinev1.Pipeline{},
+			step:     &pipelinev1.PipelineStep{Name: "build"},
+			wantCheck: func(t *testing.T, podSpec *corev1.PodSpec) {
+				if len(podSpec.InitContainers) != 1 {
+					t.Errorf("expected 1 init container, got %d", len(podSpec.InitContainers))
+				}
+			},
+		},
+		{
+			name:     "injects pre hooks first and post hooks as sidecars",
+			pipeline: &pipelinev1.Pipeline{Spec: pipelinev1.PipelineSpec{Hooks: hooks}},
+			step:     &pipelinev1.PipelineStep{Name: "build"},
+			wantCheck: func(t *testing.T, podSpec *corev1.PodSpec) {
+				names := []string{}
+				for _, c := range podSpec.InitContainers {
+					names = append(names, c.Name)
+				}
+				want := []string{"fetch-credentials", "user-init", "upload-logs"}
+				if len(names) != len(want) {
+					t.Fatalf("expected init containers %v, got %v", want, names)
+				}
+				for i := range want {
+					if names[i] != want[i] {
+						t.Fatalf("expected init containers %v, got %v", want, names)
+					}
+				}
+				if podSpec.InitContainers[0].RestartPolicy != nil {
+					t.Error("expected pre hook to be a regular init container")
+				}
+				if podSpec.InitContainers[1].RestartPolicy != nil {
+					t.Error("expected user init container to be unchanged")
+				}
+				post := podSpec.InitContainers[2].RestartPolicy
+				if post == nil || *post != corev1.ContainerRestartPolicyAlways {
+					t.Error("expected post hook to be a native sidecar")
+				}
+			},
+		},
+		{
+			name:     "step can opt out of hooks",
+			pipeline: &pipelinev1.Pipeline{Spec: pipelinev1.PipelineSpec{Hooks: hooks}},
+			step:     &pipelinev1.PipelineStep{Name: "build", DisableHooks: true},
+			wantCheck: func(t *testing.T, podSpec *corev1.PodSpec) {
+				if len(podSpec.InitContainers) != 1 || podSpec.InitContainers[0].Name != "user-init" {
+					t.Errorf("expected only the user init container, got %v", podSpec.InitContainers)
+				}
+			},
+		},
+		{
+			name: "hooks get container defaults and shared volume",
+			pipeline: &pipelinev1.Pipeline{Spec: pipelinev1.PipelineSpec{
+				Hooks: hooks,
+				PodTemplate: &pipelinev1.PodTemplateDefaults{
+					Image:   "busybox",
+					Env:     []corev1.EnvVar{{Name: "ENV", Value: "prod"}},
+					EnvFrom: []corev1.EnvFromSource{{ConfigMapRef: &corev1.ConfigMapEnvSource{LocalObjectReference: corev1.LocalObjectReference{Name: "common"}}}},
+				},
+				SharedVolume: &pipelinev1.SharedVolumeSpec{
+					VolumeSource: corev1.VolumeSource{EmptyDir: &corev1.EmptyDirVolumeSource{}},
+				},
+			}},
+			step: &pipelinev1.PipelineStep{Name: "build"},
+			wantCheck: func(t *testing.T, podSpec *corev1.PodSpec) {
+				for _, c := range []corev1.Container{podSpec.InitContainers[0], podSpec.InitContainers[2]} {
+					if c.Image != "busybox" {
+						t.Errorf("%s: expected default image, got %q", c.Name, c.Image)
+					}
+					if len(c.Env) != 1 || len(c.EnvFrom) != 1 {
+						t.Errorf("%s: expected env and envFrom, got %v %v", c.Name, c.Env, c.EnvFrom)
+					}
+					if len(c.VolumeMounts) != 1 || c.VolumeMounts[0].MountPath != "/workspace" {
+						t.Errorf("%s: expected shared volume mount, got %v", c.Name, c.VolumeMounts)
+					}
+				}
+				if podSpec.InitContainers[1].Image != "" || len(podSpec.InitContainers[1].Env) != 0 {
+					t.Error("expected user init container to be unchanged")
+				}
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			job := newJob()
+			r.applyHooks(tt.pipeline, tt.step, job)
+			tt.wantCheck(t, &job.Spec.Template.Spec)
+		})
+	}
+
+	if hooks.Pre[0].Image != "" || hooks.Post[0].RestartPolicy != nil {
+		t.Error("expected pipeline hooks to be unchanged")
+	}
+}
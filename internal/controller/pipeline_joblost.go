@@ -0,0 +1,87 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"fmt"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	pipelinev1 "github.com/yaacov/jobrunner/api/v1"
+)
+
+// stepReasonJobLost marks a step failed because its Job was deleted before it finished
+const stepReasonJobLost = "JobLost"
+
+// handleLostJob applies spec.onJobLost to an unfinished step whose Job is gone
+// Returns true if the step status changed
+func (r *PipelineReconciler) handleLostJob(ctx context.Context, pipeline *pipelinev1.Pipeline, stepStatus *pipelinev1.StepStatus) bool {
+	logger := log.FromContext(ctx)
+	message := fmt.Sprintf("Job %s was deleted before the step finished", stepStatus.JobName)
+
+	switch pipeline.Spec.OnJobLost {
+	case pipelinev1.JobLostIgnore:
+		logger.Info("Job lost, ignoring", "job", stepStatus.JobName, "step", stepStatus.Name)
+		return false
+
+	case pipelinev1.JobLostRecreate:
+		// A pending step without a Job is started again once its dependencies are checked
+		logger.Info("Job lost, recreating", "job", stepStatus.JobName, "step", stepStatus.Name)
+		resetStepAttempt(stepStatus)
+		return true
+
+	default:
+		logger.Info("Job lost, failing step", "job", stepStatus.JobName, "step", stepStatus.Name)
+		now := metav1.Now()
+		stepStatus.Phase = pipelinev1.StepPhaseFailed
+		stepStatus.Reason = stepReasonJobLost
+		stepStatus.Message = message
+		stepStatus.CompletionTime = &now
+		if stepStatus.StartTime != nil {
+			stepStatus.Duration = roundedDuration(stepStatus.StartTime, stepStatus.CompletionTime)
+		}
+		return true
+	}
+}
+
+// handleLostChildJob applies spec.onJobLost to an unfinished forEach item whose Job is gone
+// Returns true if the item changed
+func (r *PipelineReconciler) handleLostChildJob(ctx context.Context, pipeline *pipelinev1.Pipeline, stepStatus *pipelinev1.StepStatus, child *pipelinev1.ForEachChildStatus) bool {
+	logger := log.FromContext(ctx)
+
+	switch pipeline.Spec.OnJobLost {
+	case pipelinev1.JobLostIgnore:
+		logger.Info("Job lost, ignoring", "job", child.JobName, "step", stepStatus.Name, "index", child.Index)
+		return false
+
+	case pipelinev1.JobLostRecreate:
+		// Pending items are started again as parallelism allows
+		logger.Info("Job lost, recreating", "job", child.JobName, "step", stepStatus.Name, "index", child.Index)
+		child.Phase = pipelinev1.StepPhasePending
+		child.JobName = ""
+		return true
+
+	default:
+		logger.Info("Job lost, failing item", "job", child.JobName, "step", stepStatus.Name, "index", child.Index)
+		child.Phase = pipelinev1.StepPhaseFailed
+		stepStatus.Reason = stepReasonJobLost
+		stepStatus.Message = fmt.Sprintf("Job %s of item %d was deleted before it finished", child.JobName, child.Index)
+		return true
+	}
+}
@@ -0,0 +1,162 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"testing"
+
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+
+	pipelinev1 "github.com/yaacov/jobrunner/api/v1"
+)
+
+func newJobLostPipeline(policy pipelinev1.JobLostPolicy) *pipelinev1.Pipeline {
+	return &pipelinev1.Pipeline{
+		ObjectMeta: metav1.ObjectMeta{Name: "release", Namespace: "default"},
+		Spec: pipelinev1.PipelineSpec{
+			OnJobLost: policy,
+			Steps: []pipelinev1.PipelineStep{
+				{Name: "build", JobSpec: &batchv1.JobSpec{Template: corev1.PodTemplateSpec{Spec: corev1.PodSpec{
+					RestartPolicy: corev1.RestartPolicyNever,
+					Containers:    []corev1.Container{{Name: "main", Image: "busybox"}},
+				}}}},
+				{Name: "test", JobSpec: &batchv1.JobSpec{Template: corev1.PodTemplateSpec{Spec: corev1.PodSpec{
+					RestartPolicy: corev1.RestartPolicyNever,
+					Containers:    []corev1.Container{{Name: "main", Image: "busybox"}},
+				}}}},
+			},
+		},
+		Status: pipelinev1.PipelineStatus{Steps: []pipelinev1.StepStatus{
+			{Name: "build", Phase: pipelinev1.StepPhaseSucceeded, JobName: "release-build"},
+			{Name: "test", Phase: pipelinev1.StepPhaseRunning, JobName: "release-test", Attempt: 1},
+		}},
+	}
+}
+
+func TestUpdateStepStatusesJobLost(t *testing.T) {
+	ctx := context.Background()
+
+	tests := []struct {
+		name       string
+		policy     pipelinev1.JobLostPolicy
+		wantPhase  pipelinev1.StepPhase
+		wantReason string
+		wantJob    string
+	}{
+		{
+			name:       "fails the step by default",
+			wantPhase:  pipelinev1.StepPhaseFailed,
+			wantReason: "JobLost",
+			wantJob:    "release-test",
+		},
+		{
+			name:      "recreate resets the step to pending",
+			policy:    pipelinev1.JobLostRecreate,
+			wantPhase: pipelinev1.StepPhasePending,
+		},
+		{
+			name:      "ignore keeps the step running",
+			policy:    pipelinev1.JobLostIgnore,
+			wantPhase: pipelinev1.StepPhaseRunning,
+			wantJob:   "release-test",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pipeline := newJobLostPipeline(tt.policy)
+			c := newFakeClient(pipeline)
+			r := &PipelineReconciler{Client: c, Scheme: c.Scheme()}
+
+			if err := r.updateStepStatuses(ctx, pipeline); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			build := pipeline.Status.Steps[0]
+			if build.Phase != pipelinev1.StepPhaseSucceeded || build.Reason != "" {
+				t.Errorf("expected the finished step to keep its phase, got %s (%s)", build.Phase, build.Reason)
+			}
+
+			test := pipeline.Status.Steps[1]
+			if test.Phase != tt.wantPhase || test.Reason != tt.wantReason || test.JobName != tt.wantJob {
+				t.Errorf("expected phase %s, reason %q and job %q, got %s, %q and %q",
+					tt.wantPhase, tt.wantReason, tt.wantJob, test.Phase, test.Reason, test.JobName)
+			}
+		})
+	}
+
+	t.Run("recreated step gets a new job", func(t *testing.T) {
+		pipeline := newJobLostPipeline(pipelinev1.JobLostRecreate)
+		c := newFakeClient(pipeline)
+		r := &PipelineReconciler{Client: c, Scheme: c.Scheme()}
+
+		if err := r.updateStepStatuses(ctx, pipeline); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if err := r.startReadySteps(ctx, pipeline); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		test := pipeline.Status.Steps[1]
+		if test.Phase != pipelinev1.StepPhaseRunning || test.Attempt != 2 {
+			t.Errorf("expected the step to run a second attempt, got %s attempt %d", test.Phase, test.Attempt)
+		}
+		job := &batchv1.Job{}
+		if err := c.Get(ctx, types.NamespacedName{Namespace: "default", Name: test.JobName}, job); err != nil {
+			t.Fatalf("expected the job to be recreated: %v", err)
+		}
+		if job.Name != stepJobName(pipeline, "test", 2) || job.Labels[stepAttemptLabel] != "2" {
+			t.Errorf("expected a job for the second attempt, got %q with labels %v", job.Name, job.Labels)
+		}
+	})
+}
+
+func TestHandleLostChildJob(t *testing.T) {
+	ctx := context.Background()
+
+	tests := []struct {
+		name      string
+		policy    pipelinev1.JobLostPolicy
+		wantPhase pipelinev1.StepPhase
+		wantJob   string
+	}{
+		{name: "fail", policy: pipelinev1.JobLostFail, wantPhase: pipelinev1.StepPhaseFailed, wantJob: "release-shard-0"},
+		{name: "recreate", policy: pipelinev1.JobLostRecreate, wantPhase: pipelinev1.StepPhasePending},
+		{name: "ignore", policy: pipelinev1.JobLostIgnore, wantPhase: pipelinev1.StepPhaseRunning, wantJob: "release-shard-0"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := &PipelineReconciler{}
+			pipeline := &pipelinev1.Pipeline{Spec: pipelinev1.PipelineSpec{OnJobLost: tt.policy}}
+			stepStatus := &pipelinev1.StepStatus{Name: "shard", Phase: pipelinev1.StepPhaseRunning, Children: []pipelinev1.ForEachChildStatus{
+				{Index: 0, Phase: pipelinev1.StepPhaseRunning, JobName: "release-shard-0"},
+			}}
+
+			r.handleLostChildJob(ctx, pipeline, stepStatus, &stepStatus.Children[0])
+
+			child := stepStatus.Children[0]
+			if child.Phase != tt.wantPhase || child.JobName != tt.wantJob {
+				t.Errorf("expected phase %s and job %q, got %s and %q", tt.wantPhase, tt.wantJob, child.Phase, child.JobName)
+			}
+		})
+	}
+}
@@ -0,0 +1,123 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strings"
+
+	batchv1 "k8s.io/api/batch/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	pipelinev1 "github.com/yaacov/jobrunner/api/v1"
+)
+
+const (
+	// runUIDLabel identifies the pipeline run a Job belongs to, by the pipeline's UID
+	runUIDLabel = "pipeline.yaacov.io/run-uid"
+	// stepAttemptLabel numbers the Jobs created for a step or forEach item, starting at 1
+	stepAttemptLabel = "pipeline.yaacov.io/step-attempt"
+
+	// nameHashLength is the number of hex characters of the hash in generated names
+	nameHashLength = 6
+)
+
+// stepJobName returns the Job name for an attempt of a step:
+// <pipeline>-<step>-<hash>-<attempt>
+func stepJobName(pipeline *pipelinev1.Pipeline, stepName string, attempt int32) string {
+	return runJobName(pipeline, fmt.Sprintf("%s-%s", pipeline.Name, stepName), attempt)
+}
+
+// itemJobName returns the Job name for an attempt of a forEach item:
+// <pipeline>-<step>-<index>-<hash>-<attempt>
+func itemJobName(pipeline *pipelinev1.Pipeline, stepName string, index, attempt int32) string {
+	return runJobName(pipeline, fmt.Sprintf("%s-%s-%d", pipeline.Name, stepName, index), attempt)
+}
+
+// runJobName appends a hash of the pipeline UID and base, and the attempt, to base
+// The hash keeps Jobs of a re-created pipeline apart from terminating Jobs of a deleted
+// one with the same name, and keeps names unique when base is truncated to fit
+func runJobName(pipeline *pipelinev1.Pipeline, base string, attempt int32) string {
+	return fitName(base, shortHash(string(pipeline.UID)+"/"+base), fmt.Sprintf("-%d", attempt))
+}
+
+// fitName joins prefix, hash and suffix as <prefix>-<hash><suffix>, truncating prefix
+// so the result is a valid DNS label
+func fitName(prefix, hash, suffix string) string {
+	maxPrefix := validation.DNS1123LabelMaxLength - len(hash) - len(suffix) - 1
+	if len(prefix) > maxPrefix {
+		prefix = strings.TrimRight(prefix[:maxPrefix], "-")
+	}
+	return fmt.Sprintf("%s-%s%s", prefix, hash, suffix)
+}
+
+// shortHash returns the first nameHashLength hex characters of the SHA-256 of s
+func shortHash(s string) string {
+	sum := sha256.Sum256([]byte(s))
+	return hex.EncodeToString(sum[:])[:nameHashLength]
+}
+
+// listRunJobs returns the Jobs of the pipeline's current run, by name, from the cache
+// Jobs are looked up by the owner index, and must carry the run UID label and be
+// controlled by the pipeline
+func (r *PipelineReconciler) listRunJobs(ctx context.Context, pipeline *pipelinev1.Pipeline) (map[string]*batchv1.Job, error) {
+	jobList := &batchv1.JobList{}
+	if err := r.List(ctx, jobList, client.InNamespace(pipeline.Namespace),
+		client.MatchingFields{jobOwnerIndex: pipeline.Name},
+		client.MatchingLabels{runUIDLabel: string(pipeline.UID)},
+	); err != nil {
+		return nil, err
+	}
+
+	jobs := make(map[string]*batchv1.Job, len(jobList.Items))
+	for i := range jobList.Items {
+		job := &jobList.Items[i]
+		if metav1.IsControlledBy(job, pipeline) {
+			jobs[job.Name] = job
+		}
+	}
+	return jobs, nil
+}
+
+// findRunJob returns the named Job of the pipeline's current run, or nil if it is gone
+// Jobs missing from the listing are read from the API server, since a Job created moments
+// ago may not have reached the cache yet and Jobs created before the run UID label was
+// introduced are not labeled
+func (r *PipelineReconciler) findRunJob(ctx context.Context, pipeline *pipelinev1.Pipeline, jobs map[string]*batchv1.Job, jobName string) (*batchv1.Job, error) {
+	if job, ok := jobs[jobName]; ok {
+		return job, nil
+	}
+
+	job := &batchv1.Job{}
+	if err := r.reader().Get(ctx, types.NamespacedName{Name: jobName, Namespace: pipeline.Namespace}, job); err != nil {
+		if apierrors.IsNotFound(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	if !metav1.IsControlledBy(job, pipeline) {
+		return nil, nil
+	}
+	return job, nil
+}
@@ -0,0 +1,118 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	batchv1 "k8s.io/api/batch/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+
+	pipelinev1 "github.com/yaacov/jobrunner/api/v1"
+)
+
+func TestJobNames(t *testing.T) {
+	pipeline := &pipelinev1.Pipeline{ObjectMeta: metav1.ObjectMeta{Name: "nightly", UID: types.UID("uid-1")}}
+	longPipeline := &pipelinev1.Pipeline{ObjectMeta: metav1.ObjectMeta{
+		Name: strings.Repeat("p", 63),
+		UID:  types.UID("uid-1"),
+	}}
+
+	tests := []struct {
+		name       string
+		jobName    string
+		wantPrefix string
+		wantSuffix string
+	}{
+		{name: "step", jobName: stepJobName(pipeline, "build", 1), wantPrefix: "nightly-build-", wantSuffix: "-1"},
+		{name: "item", jobName: itemJobName(pipeline, "shard", 3, 1), wantPrefix: "nightly-shard-3-", wantSuffix: "-1"},
+		{name: "later attempt", jobName: stepJobName(pipeline, "build", 12), wantPrefix: "nightly-build-", wantSuffix: "-12"},
+		{name: "long names are shortened", jobName: itemJobName(longPipeline, strings.Repeat("s", 63), 999, 100), wantPrefix: "ppp", wantSuffix: "-100"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if errs := validation.IsDNS1123Label(tt.jobName); len(errs) > 0 {
+				t.Errorf("expected %q to be a valid DNS label: %v", tt.jobName, errs)
+			}
+			if !strings.HasPrefix(tt.jobName, tt.wantPrefix) || !strings.HasSuffix(tt.jobName, tt.wantSuffix) {
+				t.Errorf("expected %q to start with %q and end with %q", tt.jobName, tt.wantPrefix, tt.wantSuffix)
+			}
+		})
+	}
+
+	t.Run("a new run gets new names", func(t *testing.T) {
+		recreated := pipeline.DeepCopy()
+		recreated.UID = types.UID("uid-2")
+		if stepJobName(pipeline, "build", 1) == stepJobName(recreated, "build", 1) {
+			t.Errorf("expected different job names for different pipeline UIDs")
+		}
+	})
+
+	t.Run("shortened names stay unique", func(t *testing.T) {
+		a := stepJobName(longPipeline, strings.Repeat("s", 62)+"a", 1)
+		b := stepJobName(longPipeline, strings.Repeat("s", 62)+"b", 1)
+		if a == b {
+			t.Errorf("expected different job names for different steps, got %q", a)
+		}
+	})
+}
+
+func TestListRunJobs(t *testing.T) {
+	ctx := context.Background()
+
+	pipeline := &pipelinev1.Pipeline{ObjectMeta: metav1.ObjectMeta{Name: "nightly", Namespace: "default", UID: types.UID("uid-1")}}
+	previous := &pipelinev1.Pipeline{ObjectMeta: metav1.ObjectMeta{Name: "nightly", Namespace: "default", UID: types.UID("uid-0")}}
+
+	newJob := func(owner *pipelinev1.Pipeline, name string) *batchv1.Job {
+		job := &batchv1.Job{ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: "default",
+			Labels:    map[string]string{runUIDLabel: string(owner.UID)},
+		}}
+		if err := controllerutil.SetControllerReference(owner, job, newFakeClient().Scheme()); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		return job
+	}
+
+	current := newJob(pipeline, stepJobName(pipeline, "build", 1))
+	stale := newJob(previous, stepJobName(previous, "build", 1))
+	c := newFakeClient(pipeline, current, stale)
+	r := &PipelineReconciler{Client: c, Scheme: c.Scheme()}
+
+	jobs, err := r.listRunJobs(ctx, pipeline)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(jobs) != 1 || jobs[current.Name] == nil {
+		t.Errorf("expected only the job of the current run, got %v", jobs)
+	}
+
+	job, err := r.findRunJob(ctx, pipeline, jobs, stale.Name)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if job != nil {
+		t.Errorf("expected the job of a previous run not to be found, got %q", job.Name)
+	}
+}
@@ -0,0 +1,102 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"fmt"
+
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/yaml"
+
+	pipelinev1 "github.com/yaacov/jobrunner/api/v1"
+)
+
+// jobControllerLabels are labels the Job controller adds to a Job's pod template
+// They are tied to the source Job's UID and must not be copied to a new Job
+var jobControllerLabels = []string{
+	batchv1.ControllerUidLabel,
+	batchv1.JobNameLabel,
+	"controller-uid",
+	"job-name",
+}
+
+// fetchJobRef reads the referenced object and extracts its job template
+func (r *PipelineReconciler) fetchJobRef(ctx context.Context, namespace string, ref *pipelinev1.JobReference) (*batchv1.JobSpec, *pipelinev1.ResolvedJobReference, error) {
+	key := types.NamespacedName{Namespace: namespace, Name: ref.Name}
+	resolved := &pipelinev1.ResolvedJobReference{Kind: ref.Kind, Name: ref.Name}
+
+	switch ref.Kind {
+	case pipelinev1.JobReferenceKindCronJob:
+		cronJob := &batchv1.CronJob{}
+		if err := r.reader().Get(ctx, key, cronJob); err != nil {
+			return nil, nil, err
+		}
+		resolved.ResourceVersion = cronJob.ResourceVersion
+		return cronJob.Spec.JobTemplate.Spec.DeepCopy(), resolved, nil
+
+	case pipelinev1.JobReferenceKindJob:
+		job := &batchv1.Job{}
+		if err := r.reader().Get(ctx, key, job); err != nil {
+			return nil, nil, err
+		}
+		resolved.ResourceVersion = job.ResourceVersion
+		jobSpec := job.Spec.DeepCopy()
+		clearJobControllerFields(jobSpec)
+		return jobSpec, resolved, nil
+
+	case pipelinev1.JobReferenceKindConfigMap:
+		configMap := &corev1.ConfigMap{}
+		if err := r.reader().Get(ctx, key, configMap); err != nil {
+			return nil, nil, err
+		}
+		data, ok := configMap.Data[ref.GetKey()]
+		if !ok {
+			return nil, nil, fmt.Errorf("key %q not found in ConfigMap", ref.GetKey())
+		}
+		jobSpec := &batchv1.JobSpec{}
+		if err := yaml.UnmarshalStrict([]byte(data), jobSpec); err != nil {
+			return nil, nil, fmt.Errorf("parsing JobSpec from key %q: %w", ref.GetKey(), err)
+		}
+		resolved.ResourceVersion = configMap.ResourceVersion
+		return jobSpec, resolved, nil
+	}
+
+	return nil, nil, fmt.Errorf("unsupported jobRef kind %q", ref.Kind)
+}
+
+// clearJobControllerFields removes the selector and labels generated by the Job
+// controller so a spec copied from an existing Job can be used for a new one
+func clearJobControllerFields(jobSpec *batchv1.JobSpec) {
+	jobSpec.Selector = nil
+	jobSpec.ManualSelector = nil
+	for _, label := range jobControllerLabels {
+		delete(jobSpec.Template.Labels, label)
+	}
+}
+
+// reader returns the uncached reader for referenced objects, falling back to the cached client
+// Referenced CronJobs, Jobs and ConfigMaps are read directly to avoid caching them cluster-wide
+func (r *PipelineReconciler) reader() client.Reader {
+	if r.APIReader != nil {
+		return r.APIReader
+	}
+	return r.Client
+}
@@ -0,0 +1,269 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	pipelinev1 "github.com/yaacov/jobrunner/api/v1"
+)
+
+func TestResolveJobSpec(t *testing.T) {
+	podSpec := corev1.PodSpec{
+		Containers:    []corev1.Container{{Name: "main", Image: "busybox"}},
+		RestartPolicy: corev1.RestartPolicyNever,
+	}
+
+	objects := []client.Object{
+		&batchv1.CronJob{
+			ObjectMeta: metav1.ObjectMeta{Name: "nightly", Namespace: "default"},
+			Spec: batchv1.CronJobSpec{
+				Schedule: "@daily",
+				JobTemplate: batchv1.JobTemplateSpec{
+					Spec: batchv1.JobSpec{
+						BackoffLimit: int32Ptr(2),
+						Template:     corev1.PodTemplateSpec{Spec: podSpec},
+					},
+				},
+			},
+		},
+		&batchv1.Job{
+			ObjectMeta: metav1.ObjectMeta{Name: "existing", Namespace: "default"},
+			Spec: batchv1.JobSpec{
+				Selector:       &metav1.LabelSelector{MatchLabels: map[string]string{batchv1.ControllerUidLabel: "abc"}},
+				ManualSelector: boolPtr(false),
+				Template: corev1.PodTemplateSpec{
+					ObjectMeta: metav1.ObjectMeta{Labels: map[string]string{
+						batchv1.ControllerUidLabel: "abc",
+						batchv1.JobNameLabel:       "existing",
+						"controller-uid":           "abc",
+						"job-name":                 "existing",
+						"app":                      "keep",
+					}},
+					Spec: podSpec,
+				},
+			},
+		},
+		&corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Name: "job-templates", Namespace: "default"},
+			Data: map[string]string{
+				"jobSpec": `
+backoffLimit: 1
+template:
+  spec:
+    containers:
+      - name: main
+        image: fedora
+    restartPolicy: Never
+`,
+				"broken": "template: [",
+			},
+		},
+	}
+
+	tests := []struct {
+		name      string
+		step      *pipelinev1.PipelineStep
+		wantErr   bool
+		wantCheck func(t *testing.T, jobSpec *batchv1.JobSpec, stepStatus *pipelinev1.StepStatus)
+	}{
+		{
+			name: "returns a copy of the inline job spec",
+			step: &pipelinev1.PipelineStep{
+				Name:    "inline",
+				JobSpec: &batchv1.JobSpec{Template: corev1.PodTemplateSpec{Spec: podSpec}},
+			},
+			wantCheck: func(t *testing.T, jobSpec *batchv1.JobSpec, stepStatus *pipelinev1.StepStatus) {
+				if jobSpec.Template.Spec.Containers[0].Image != "busybox" {
+					t.Errorf("expected inline image, got %s", jobSpec.Template.Spec.Containers[0].Image)
+				}
+				if stepStatus.JobRef != nil {
+					t.Error("expected no resolved jobRef for inline step")
+				}
+			},
+		},
+		{
+			name: "copies job template from cronjob",
+			step: &pipelinev1.PipelineStep{
+				Name:   "from-cron",
+				JobRef: &pipelinev1.JobReference{Kind: pipelinev1.JobReferenceKindCronJob, Name: "nightly"},
+			},
+			wantCheck: func(t *testing.T, jobSpec *batchv1.JobSpec, stepStatus *pipelinev1.StepStatus) {
+				if jobSpec.BackoffLimit == nil || *jobSpec.BackoffLimit != 2 {
+					t.Errorf("expected backoffLimit 2 from cronjob, got %v", jobSpec.BackoffLimit)
+				}
+				if stepStatus.JobRef == nil || stepStatus.JobRef.Kind != pipelinev1.JobReferenceKindCronJob || stepStatus.JobRef.Name != "nightly" {
+					t.Errorf("expected resolved cronjob reference, got %+v", stepStatus.JobRef)
+				}
+				if stepStatus.JobRef.ResourceVersion == "" {
+					t.Error("expected resourceVersion to be recorded")
+				}
+			},
+		},
+		{
+			name: "copies spec from job without controller fields",
+			step: &pipelinev1.PipelineStep{
+				Name:   "from-job",
+				JobRef: &pipelinev1.JobReference{Kind: pipelinev1.JobReferenceKindJob, Name: "existing"},
+			},
+			wantCheck: func(t *testing.T, jobSpec *batchv1.JobSpec, stepStatus *pipelinev1.StepStatus) {
+				if jobSpec.Selector != nil || jobSpec.ManualSelector != nil {
+					t.Error("expected selector fields to be cleared")
+				}
+				labels := jobSpec.Template.Labels
+				for _, label := range jobControllerLabels {
+					if _, exists := labels[label]; exists {
+						t.Errorf("expected label %s to be removed", label)
+					}
+				}
+				if labels["app"] != "keep" {
+					t.Errorf("expected user label to be kept, got %v", labels)
+				}
+			},
+		},
+		{
+			name: "parses job spec from configmap",
+			step: &pipelinev1.PipelineStep{
+				Name:   "from-configmap",
+				JobRef: &pipelinev1.JobReference{Kind: pipelinev1.JobReferenceKindConfigMap, Name: "job-templates"},
+			},
+			wantCheck: func(t *testing.T, jobSpec *batchv1.JobSpec, stepStatus *pipelinev1.StepStatus) {
+				if jobSpec.Template.Spec.Containers[0].Image != "fedora" {
+					t.Errorf("expected image fedora, got %s", jobSpec.Template.Spec.Containers[0].Image)
+				}
+				if stepStatus.JobRef == nil || stepStatus.JobRef.Kind != pipelinev1.JobReferenceKindConfigMap {
+					t.Errorf("expected resolved configmap reference, got %+v", stepStatus.JobRef)
+				}
+			},
+		},
+		{
+			name: "errors on missing configmap key",
+			step: &pipelinev1.PipelineStep{
+				Name:   "missing-key",
+				JobRef: &pipelinev1.JobReference{Kind: pipelinev1.JobReferenceKindConfigMap, Name: "job-templates", Key: "other"},
+			},
+			wantErr: true,
+		},
+		{
+			name: "errors on invalid configmap yaml",
+			step: &pipelinev1.PipelineStep{
+				Name:   "broken-yaml",
+				JobRef: &pipelinev1.JobReference{Kind: pipelinev1.JobReferenceKindConfigMap, Name: "job-templates", Key: "broken"},
+			},
+			wantErr: true,
+		},
+		{
+			name:    "errors on a step without a body",
+			step:    &pipelinev1.PipelineStep{Name: "empty"},
+			wantErr: true,
+		},
+		{
+			name: "errors on missing referenced object",
+			step: &pipelinev1.PipelineStep{
+				Name:   "missing",
+				JobRef: &pipelinev1.JobReference{Kind: pipelinev1.JobReferenceKindCronJob, Name: "does-not-exist"},
+			},
+			wantErr: true,
+		},
+	}
+
+	r := &PipelineReconciler{Client: newFakeClient(objects...)}
+	pipeline := &pipelinev1.Pipeline{ObjectMeta: metav1.ObjectMeta{Name: "test", Namespace: "default"}}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stepStatus := &pipelinev1.StepStatus{Name: tt.step.Name}
+			jobSpec, err := r.resolveJobSpec(context.Background(), pipeline, tt.step, stepStatus)
+			if tt.wantErr {
+				if err == nil {
+					t.Error("expected error, got nil")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			tt.wantCheck(t, jobSpec, stepStatus)
+		})
+	}
+}
+
+func TestResolveJobSpecDoesNotShareInlineSpec(t *testing.T) {
+	r := &PipelineReconciler{}
+	step := &pipelinev1.PipelineStep{
+		Name: "inline",
+		JobSpec: &batchv1.JobSpec{Template: corev1.PodTemplateSpec{Spec: corev1.PodSpec{
+			Containers: []corev1.Container{{Name: "main"}},
+		}}},
+	}
+
+	jobSpec, err := r.resolveJobSpec(context.Background(), &pipelinev1.Pipeline{}, step, &pipelinev1.StepStatus{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	jobSpec.Template.Spec.Containers[0].Image = "changed"
+
+	if step.JobSpec.Template.Spec.Containers[0].Image != "" {
+		t.Error("expected pipeline spec to be unchanged after modifying the resolved job spec")
+	}
+}
+
+func TestJobRefStepOmitsJobSpec(t *testing.T) {
+	step := pipelinev1.PipelineStep{
+		Name:   "nightly",
+		JobRef: &pipelinev1.JobReference{Kind: pipelinev1.JobReferenceKindCronJob, Name: "nightly"},
+	}
+
+	raw, err := json.Marshal(step)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if strings.Contains(string(raw), "jobSpec") {
+		t.Errorf("expected a step with a jobRef to be written without jobSpec, got %s", raw)
+	}
+}
+
+// newFakeClient returns a fake client with the pipeline and core schemes registered
+func newFakeClient(objects ...client.Object) client.Client {
+	return newFakeClientBuilder(objects...).Build()
+}
+
+// newFakeClientBuilder returns a fake client builder with the schemes and Job indexes
+// the controller registers with its manager
+func newFakeClientBuilder(objects ...client.Object) *fake.ClientBuilder {
+	scheme := runtime.NewScheme()
+	_ = clientgoscheme.AddToScheme(scheme)
+	_ = pipelinev1.AddToScheme(scheme)
+
+	return fake.NewClientBuilder().
+		WithScheme(scheme).
+		WithObjects(objects...).
+		WithStatusSubresource(&pipelinev1.Pipeline{}).
+		WithIndex(&batchv1.Job{}, jobOwnerIndex, indexJobOwner).
+		WithIndex(&batchv1.Job{}, jobPipelineIndex, indexJobPipeline)
+}
@@ -19,6 +19,7 @@ package controller
 import (
 	"context"
 	"fmt"
+	"strconv"
 
 	batchv1 "k8s.io/api/batch/v1"
 	corev1 "k8s.io/api/core/v1"
@@ -33,9 +34,31 @@ import (
 func (r *PipelineReconciler) startReadySteps(ctx context.Context, pipeline *pipelinev1.Pipeline) error {
 	logger := log.FromContext(ctx)
 
-	for i := range pipeline.Spec.Steps {
-		step := &pipeline.Spec.Steps[i]
-		stepStatus := r.getStepStatus(pipeline, step.Name)
+	// Index the pipeline once; each phase change below is reported back to the graph
+	g := newPipelineGraph(pipeline)
+	defer r.updateSummaryStatus(pipeline)
+
+	for i := range g.steps {
+		step := g.steps[i]
+		stepStatus := g.stepStatus(step.Name)
+		if stepStatus == nil {
+			// Steps added to the spec after the pipeline started are not run
+			continue
+		}
+
+		// Start further forEach items as earlier ones finish
+		if step.ForEach != "" && stepStatus.Phase == pipelinev1.StepPhaseRunning {
+			started, err := r.startForEachChildren(ctx, g, step, stepStatus)
+			if err != nil {
+				logger.Error(err, "unable to start forEach items", "step", step.Name)
+				r.recordJobConflict(pipeline, err)
+				return err
+			}
+			if started {
+				logger.V(1).Info("Started forEach items", "step", step.Name)
+			}
+			continue
+		}
 
 		// Skip if already started
 		if stepStatus.Phase != pipelinev1.StepPhasePending {
@@ -43,32 +66,41 @@ func (r *PipelineReconciler) startReadySteps(ctx context.Context, pipeline *pipe
 		}
 
 		// Check if this step should be skipped based on conditions
-		if r.shouldSkipStep(pipeline, step) {
+		if r.shouldSkipStep(g, step) {
 			logger.Info("Skipping step due to unmet conditions", "step", step.Name)
 			stepStatus.Phase = pipelinev1.StepPhaseSkipped
-			if err := r.Status().Update(ctx, pipeline); err != nil {
-				return err
-			}
+			g.stepChanged(step.Name)
 			continue
 		}
 
 		// Check if dependencies are satisfied
-		ready, shouldSkip := r.areDependenciesSatisfied(pipeline, step)
+		ready, shouldSkip := r.areDependenciesSatisfied(g, step)
 		if shouldSkip {
 			logger.Info("Skipping step due to dependency conditions", "step", step.Name)
 			stepStatus.Phase = pipelinev1.StepPhaseSkipped
-			if err := r.Status().Update(ctx, pipeline); err != nil {
-				return err
-			}
+			g.stepChanged(step.Name)
 			continue
 		}
 		if !ready {
 			continue
 		}
 
+		// forEach steps expand into one Job per item
+		if step.ForEach != "" {
+			if err := r.startForEachStep(ctx, g, step, stepStatus); err != nil {
+				logger.Error(err, "unable to start forEach step", "step", step.Name)
+				r.recordJobConflict(pipeline, err)
+				return err
+			}
+			logger.Info("Started forEach step", "step", step.Name, "items", len(stepStatus.Children))
+			g.stepChanged(step.Name)
+			continue
+		}
+
 		// Create the job for this step
-		if err := r.createJobForStep(ctx, pipeline, step, stepStatus); err != nil {
+		if err := r.createJobForStep(ctx, g, step, stepStatus); err != nil {
 			logger.Error(err, "unable to create job for step", "step", step.Name)
+			r.recordJobConflict(pipeline, err)
 			return err
 		}
 
@@ -76,44 +108,94 @@ func (r *PipelineReconciler) startReadySteps(ctx context.Context, pipeline *pipe
 
 		// Update status to Running
 		stepStatus.Phase = pipelinev1.StepPhaseRunning
-		if err := r.Status().Update(ctx, pipeline); err != nil {
-			return err
-		}
+		g.stepChanged(step.Name)
 	}
 
 	return nil
 }
 
 // createJobForStep creates a Kubernetes Job for a pipeline step
-func (r *PipelineReconciler) createJobForStep(ctx context.Context, pipeline *pipelinev1.Pipeline, step *pipelinev1.PipelineStep, stepStatus *pipelinev1.StepStatus) error {
+func (r *PipelineReconciler) createJobForStep(ctx context.Context, g *pipelineGraph, step *pipelinev1.PipelineStep, stepStatus *pipelinev1.StepStatus) error {
 	logger := log.FromContext(ctx)
-	jobName := fmt.Sprintf("%s-%s", pipeline.Name, step.Name)
-	stepStatus.JobName = jobName
+	pipeline := g.pipeline
+	attempt := stepStatus.Attempt + 1
+	jobName := stepJobName(pipeline, step.Name, attempt)
 
 	logger.Info("Creating job for step",
 		"step", step.Name,
 		"job", jobName,
 		"pipeline", pipeline.Name)
 
+	job, err := r.buildJobForStep(ctx, pipeline, step, stepStatus, jobName, attempt)
+	if err != nil {
+		return err
+	}
+	applyOOMRetries(pipeline, stepStatus, nil, job)
+	g.recordResolvedStep(step.Name, &job.Spec)
+
+	// Create the job, adopting it if an earlier reconcile already did
+	if err := r.createOrAdoptJob(ctx, pipeline, job); err != nil {
+		logger.Error(err, "Failed to create job", "job", jobName, "step", step.Name)
+		return err
+	}
+	stepStatus.JobName = jobName
+	stepStatus.Attempt = attempt
+
+	logger.Info("Job created successfully",
+		"job", jobName,
+		"step", step.Name,
+		"namespace", pipeline.Namespace)
+	return nil
+}
+
+// buildJobForStep builds the Job object for a step with all pipeline defaults applied
+func (r *PipelineReconciler) buildJobForStep(ctx context.Context, pipeline *pipelinev1.Pipeline, step *pipelinev1.PipelineStep, stepStatus *pipelinev1.StepStatus, jobName string, attempt int32) (*batchv1.Job, error) {
+	logger := log.FromContext(ctx)
+
+	// Resolve the job spec, copying it from the referenced object if needed
+	jobSpec, err := r.resolveJobSpec(ctx, pipeline, step, stepStatus)
+	if err != nil {
+		logger.Error(err, "Failed to resolve job spec", "step", step.Name)
+		return nil, err
+	}
+
 	job := &batchv1.Job{
 		ObjectMeta: metav1.ObjectMeta{
 			Name:      jobName,
 			Namespace: pipeline.Namespace,
 			Labels: map[string]string{
-				"pipeline.yaacov.io/pipeline": pipeline.Name,
-				"pipeline.yaacov.io/step":     step.Name,
+				pipelineLabel:    pipeline.Name,
+				stepLabel:        step.Name,
+				runUIDLabel:      string(pipeline.UID),
+				stepAttemptLabel: strconv.Itoa(int(attempt)),
 			},
 		},
-		Spec: step.JobSpec,
+		Spec: *jobSpec,
+	}
+
+	// Step pods carry the pipeline labels, so their changes can be routed to the pipeline
+	if job.Spec.Template.Labels == nil {
+		job.Spec.Template.Labels = make(map[string]string)
 	}
+	job.Spec.Template.Labels[pipelineLabel] = pipeline.Name
+	job.Spec.Template.Labels[stepLabel] = step.Name
 
-	// Set backoffLimit to 0 if not specified (fail fast for pipeline steps)
+	// Apply Job defaults to the fields the step leaves unset
+	if pipeline.Spec.JobDefaults != nil {
+		logger.V(1).Info("Applying job defaults", "step", step.Name)
+	}
+	r.applyJobDefaults(pipeline, &job.Spec)
+
+	// Set backoffLimit to 0 if still not specified (fail fast for pipeline steps)
 	if job.Spec.BackoffLimit == nil {
 		backoffLimit := int32(0)
 		job.Spec.BackoffLimit = &backoffLimit
 		logger.V(1).Info("Setting default backoffLimit to 0 for pipeline step", "step", step.Name)
 	}
 
+	// Disrupted pods are replaced without using up backoffLimit when disruption retries are on
+	applyDisruptionPolicy(pipeline, &job.Spec)
+
 	// Apply pod template defaults
 	if pipeline.Spec.PodTemplate != nil {
 		logger.V(1).Info("Applying pod template defaults", "step", step.Name)
@@ -129,23 +211,57 @@ func (r *PipelineReconciler) createJobForStep(ctx context.Context, pipeline *pip
 	}
 	r.applySharedVolume(pipeline, step, job)
 
+	// Inject pipeline hooks unless the step opts out
+	r.applyHooks(pipeline, step, job)
+
 	// Set controller reference
 	if err := controllerutil.SetControllerReference(pipeline, job, r.Scheme); err != nil {
 		logger.Error(err, "Failed to set controller reference", "job", jobName)
-		return err
+		return nil, err
 	}
 
-	// Create the job
-	if err := r.Create(ctx, job); err != nil {
-		logger.Error(err, "Failed to create job", "job", jobName, "step", step.Name)
-		return err
+	return job, nil
+}
+
+// resolveJobSpec returns a copy of the JobSpec to run for a step
+// Steps using templateRef run the template body pinned in their status; steps using
+// jobRef copy the spec from the referenced object and record the resolved source
+func (r *PipelineReconciler) resolveJobSpec(ctx context.Context, pipeline *pipelinev1.Pipeline, step *pipelinev1.PipelineStep, stepStatus *pipelinev1.StepStatus) (*batchv1.JobSpec, error) {
+	if step.TemplateRef != nil {
+		if stepStatus.Template == nil {
+			return nil, fmt.Errorf("template %s/%s for step %q was not resolved", step.TemplateRef.GetKind(), step.TemplateRef.Name, step.Name)
+		}
+		if stepStatus.Template.JobSpec == nil {
+			return nil, fmt.Errorf("template %s/%s for step %q has no jobSpec", step.TemplateRef.GetKind(), step.TemplateRef.Name, step.Name)
+		}
+		return stepStatus.Template.JobSpec.DeepCopy(), nil
 	}
 
-	logger.Info("Job created successfully",
-		"job", jobName,
+	if step.JobRef == nil {
+		if step.JobSpec == nil {
+			return nil, fmt.Errorf("step %q has no jobSpec, jobRef or templateRef", step.Name)
+		}
+		return step.JobSpec.DeepCopy(), nil
+	}
+
+	jobSpec, resolved, err := r.fetchJobRef(ctx, pipeline.Namespace, step.JobRef)
+	if err != nil {
+		return nil, fmt.Errorf("resolving jobRef %s/%s for step %q: %w", step.JobRef.Kind, step.JobRef.Name, step.Name, err)
+	}
+
+	log.FromContext(ctx).Info("Resolved job template from reference",
 		"step", step.Name,
-		"namespace", pipeline.Namespace)
-	return nil
+		"kind", resolved.Kind,
+		"name", resolved.Name,
+		"resourceVersion", resolved.ResourceVersion)
+
+	stepStatus.JobRef = resolved
+	return jobSpec, nil
+}
+
+// applyJobDefaults applies pipeline-level Job defaults to the fields a job spec leaves unset
+func (r *PipelineReconciler) applyJobDefaults(pipeline *pipelinev1.Pipeline, jobSpec *batchv1.JobSpec) {
+	pipeline.Spec.JobDefaults.ApplyTo(jobSpec)
 }
 
 // applyPodTemplateDefaults applies pipeline-level pod template defaults to a job
@@ -295,10 +411,7 @@ func (r *PipelineReconciler) applySharedVolume(pipeline *pipelinev1.Pipeline, st
 	podSpec.Volumes = append(podSpec.Volumes, volume)
 
 	// Mount volume in all containers
-	volumeMount := corev1.VolumeMount{
-		Name:      sharedVol.GetName(),
-		MountPath: sharedVol.GetMountPath(),
-	}
+	volumeMount := sharedVolumeMount(sharedVol)
 
 	containerCount := len(podSpec.Containers)
 	for i := range podSpec.Containers {
@@ -310,3 +423,11 @@ func (r *PipelineReconciler) applySharedVolume(pipeline *pipelinev1.Pipeline, st
 		"containerCount", containerCount,
 		"volumeName", sharedVol.GetName())
 }
+
+// sharedVolumeMount returns the mount used for the shared volume in every container
+func sharedVolumeMount(sharedVol *pipelinev1.SharedVolumeSpec) corev1.VolumeMount {
+	return corev1.VolumeMount{
+		Name:      sharedVol.GetName(),
+		MountPath: sharedVol.GetMountPath(),
+	}
+}
@@ -23,6 +23,7 @@ import (
 	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/utils/ptr"
 
 	pipelinev1 "github.com/yaacov/jobrunner/api/v1"
 )
@@ -595,6 +596,97 @@ func TestApplyPodTemplateDefaults(t *testing.T) {
 	}
 }
 
+func TestApplyJobDefaults(t *testing.T) {
+	r := &PipelineReconciler{}
+	defaults := &pipelinev1.JobDefaults{
+		BackoffLimit:            int32Ptr(2),
+		ActiveDeadlineSeconds:   ptr.To[int64](3600),
+		TTLSecondsAfterFinished: int32Ptr(600),
+		PodFailurePolicy: &batchv1.PodFailurePolicy{Rules: []batchv1.PodFailurePolicyRule{{
+			Action: batchv1.PodFailurePolicyActionIgnore,
+			OnPodConditions: []batchv1.PodFailurePolicyOnPodConditionsPattern{{
+				Type:   corev1.DisruptionTarget,
+				Status: corev1.ConditionTrue,
+			}},
+		}}},
+		CompletionMode:       ptr.To(batchv1.IndexedCompletion),
+		PodReplacementPolicy: ptr.To(batchv1.Failed),
+	}
+
+	tests := []struct {
+		name      string
+		defaults  *pipelinev1.JobDefaults
+		jobSpec   batchv1.JobSpec
+		wantCheck func(t *testing.T, jobSpec *batchv1.JobSpec)
+	}{
+		{
+			name:     "applies defaults to unset fields",
+			defaults: defaults,
+			wantCheck: func(t *testing.T, jobSpec *batchv1.JobSpec) {
+				if jobSpec.BackoffLimit == nil || *jobSpec.BackoffLimit != 2 {
+					t.Errorf("expected backoffLimit 2, got %v", jobSpec.BackoffLimit)
+				}
+				if jobSpec.ActiveDeadlineSeconds == nil || *jobSpec.ActiveDeadlineSeconds != 3600 {
+					t.Errorf("expected activeDeadlineSeconds 3600, got %v", jobSpec.ActiveDeadlineSeconds)
+				}
+				if jobSpec.TTLSecondsAfterFinished == nil || *jobSpec.TTLSecondsAfterFinished != 600 {
+					t.Errorf("expected ttlSecondsAfterFinished 600, got %v", jobSpec.TTLSecondsAfterFinished)
+				}
+				if jobSpec.PodFailurePolicy == nil || len(jobSpec.PodFailurePolicy.Rules) != 1 {
+					t.Errorf("expected the default pod failure policy, got %+v", jobSpec.PodFailurePolicy)
+				}
+				if jobSpec.CompletionMode == nil || *jobSpec.CompletionMode != batchv1.IndexedCompletion {
+					t.Errorf("expected completionMode Indexed, got %v", jobSpec.CompletionMode)
+				}
+				if jobSpec.PodReplacementPolicy == nil || *jobSpec.PodReplacementPolicy != batchv1.Failed {
+					t.Errorf("expected podReplacementPolicy Failed, got %v", jobSpec.PodReplacementPolicy)
+				}
+
+				// The job spec gets its own copies, not pointers into the pipeline spec
+				*jobSpec.BackoffLimit = 5
+				jobSpec.PodFailurePolicy.Rules[0].Action = batchv1.PodFailurePolicyActionFailJob
+				if *defaults.BackoffLimit != 2 || defaults.PodFailurePolicy.Rules[0].Action != batchv1.PodFailurePolicyActionIgnore {
+					t.Error("expected the pipeline defaults to be unchanged")
+				}
+			},
+		},
+		{
+			name:     "keeps fields the step sets",
+			defaults: defaults,
+			jobSpec: batchv1.JobSpec{
+				BackoffLimit:          int32Ptr(0),
+				ActiveDeadlineSeconds: ptr.To[int64](60),
+				CompletionMode:        ptr.To(batchv1.NonIndexedCompletion),
+			},
+			wantCheck: func(t *testing.T, jobSpec *batchv1.JobSpec) {
+				if *jobSpec.BackoffLimit != 0 || *jobSpec.ActiveDeadlineSeconds != 60 || *jobSpec.CompletionMode != batchv1.NonIndexedCompletion {
+					t.Errorf("expected the step's values to win, got %+v", jobSpec)
+				}
+				if jobSpec.TTLSecondsAfterFinished == nil || *jobSpec.TTLSecondsAfterFinished != 600 {
+					t.Errorf("expected the default ttlSecondsAfterFinished for the unset field, got %v", jobSpec.TTLSecondsAfterFinished)
+				}
+			},
+		},
+		{
+			name: "does nothing without job defaults",
+			wantCheck: func(t *testing.T, jobSpec *batchv1.JobSpec) {
+				if jobSpec.BackoffLimit != nil || jobSpec.PodFailurePolicy != nil {
+					t.Errorf("expected the job spec to remain unchanged, got %+v", jobSpec)
+				}
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pipeline := &pipelinev1.Pipeline{Spec: pipelinev1.PipelineSpec{JobDefaults: tt.defaults}}
+			jobSpec := tt.jobSpec.DeepCopy()
+			r.applyJobDefaults(pipeline, jobSpec)
+			tt.wantCheck(t, jobSpec)
+		})
+	}
+}
+
 func TestApplySharedVolume(t *testing.T) {
 	r := &PipelineReconciler{}
 
@@ -745,3 +837,7 @@ func boolPtr(b bool) *bool {
 func stringPtr(s string) *string {
 	return &s
 }
+
+func int32Ptr(i int32) *int32 {
+	return &i
+}
@@ -0,0 +1,299 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"slices"
+	"sort"
+	"strconv"
+
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	pipelinev1 "github.com/yaacov/jobrunner/api/v1"
+)
+
+const (
+	// logArchiveKey is the ConfigMap key holding the gzip compressed logs of an attempt
+	logArchiveKey = "logs.gz"
+	// logArchiveAttemptLabel holds the step attempt, or forEach item attempt, whose Job
+	// produced the logs, matching stepAttemptLabel on that Job
+	logArchiveAttemptLabel = "pipeline.yaacov.io/attempt"
+	// logArchivePodLabel numbers the pods of a Job by creation, starting at 1
+	logArchivePodLabel = "pipeline.yaacov.io/pod"
+	// logArchiveDefaultImage copies staged logs to the PVC
+	logArchiveDefaultImage = "busybox:1.36"
+
+	// maxArchivedContainerLogBytes bounds the log read from a single container
+	maxArchivedContainerLogBytes = 8 << 20
+	// maxArchiveBytes keeps the compressed logs well under the 1MiB ConfigMap limit
+	maxArchiveBytes = 700 << 10
+
+	// archiveCopyBackoffLimit and archiveCopyTTLSeconds configure the PVC copy Job
+	archiveCopyBackoffLimit = 3
+	archiveCopyTTLSeconds   = 300
+)
+
+// archiveStepLogs archives the logs of a finished Job of a step and appends their
+// locations to the step status
+// Archiving is best-effort: a failure is logged and reported as a Warning event, and
+// does not hold back the step
+func (r *PipelineReconciler) archiveStepLogs(ctx context.Context, pipeline *pipelinev1.Pipeline, stepStatus *pipelinev1.StepStatus, jobName string, attempt int32) {
+	locations, err := r.archiveJobLogs(ctx, pipeline, stepStatus.Name, jobName, attempt)
+	if err != nil {
+		log.FromContext(ctx).Error(err, "Failed to archive step logs", "step", stepStatus.Name, "job", jobName)
+		if r.Recorder != nil {
+			r.Recorder.Eventf(pipeline, corev1.EventTypeWarning, "LogArchiveFailed",
+				"Failed to archive the logs of step %s, job %s: %v", stepStatus.Name, jobName, err)
+		}
+	}
+	for _, location := range locations {
+		if !slices.Contains(stepStatus.ArchivedLogs, location) {
+			stepStatus.ArchivedLogs = append(stepStatus.ArchivedLogs, location)
+		}
+	}
+}
+
+// archiveJobLogs archives the logs of every pod of a finished Job of the given attempt
+// and returns where each pod's logs were stored
+// Archiving is idempotent: pods that were already archived are left as they are
+func (r *PipelineReconciler) archiveJobLogs(ctx context.Context, pipeline *pipelinev1.Pipeline, stepName, jobName string, attempt int32) ([]string, error) {
+	logger := log.FromContext(ctx)
+
+	archive := pipeline.Spec.LogArchive
+	if archive == nil {
+		return nil, nil
+	}
+	if r.Clientset == nil {
+		logger.Info("Skipping log archival, no clientset to read pod logs", "step", stepName)
+		return nil, nil
+	}
+
+	podList := &corev1.PodList{}
+	if err := r.reader().List(ctx, podList, client.InNamespace(pipeline.Namespace), client.MatchingLabels{
+		batchv1.JobNameLabel: jobName,
+	}); err != nil {
+		return nil, fmt.Errorf("listing pods: %w", err)
+	}
+	pods := podList.Items
+	sort.SliceStable(pods, func(i, j int) bool {
+		return pods[i].CreationTimestamp.Before(&pods[j].CreationTimestamp)
+	})
+
+	locations := make([]string, 0, len(pods))
+	for i := range pods {
+		podNumber := i + 1
+		compressed, err := compressLogs(r.readPodLogs(ctx, &pods[i]))
+		if err != nil {
+			return locations, err
+		}
+
+		var location string
+		switch archive.Target {
+		case pipelinev1.LogArchiveTargetPVC:
+			location, err = r.archiveToPVC(ctx, pipeline, stepName, jobName, attempt, podNumber, compressed)
+		default:
+			location, err = r.archiveToConfigMap(ctx, pipeline, stepName, jobName, attempt, podNumber, compressed)
+		}
+		if err != nil {
+			return locations, err
+		}
+		locations = append(locations, location)
+	}
+
+	logger.Info("Archived step logs", "step", stepName, "job", jobName, "attempt", attempt, "pods", len(locations))
+	return locations, nil
+}
+
+// readPodLogs concatenates the logs of all containers of a pod, each under a header
+// Containers whose logs cannot be read are noted in place of their logs
+func (r *PipelineReconciler) readPodLogs(ctx context.Context, pod *corev1.Pod) []byte {
+	var buf bytes.Buffer
+	containers := append(append([]corev1.Container{}, pod.Spec.InitContainers...), pod.Spec.Containers...)
+	for _, container := range containers {
+		fmt.Fprintf(&buf, "==> pod %s container %s <==\n", pod.Name, container.Name)
+
+		limitBytes := int64(maxArchivedContainerLogBytes)
+		raw, err := r.Clientset.CoreV1().Pods(pod.Namespace).GetLogs(pod.Name, &corev1.PodLogOptions{
+			Container:  container.Name,
+			LimitBytes: &limitBytes,
+		}).DoRaw(ctx)
+		if err != nil {
+			fmt.Fprintf(&buf, "(logs unavailable: %v)\n", err)
+			continue
+		}
+		buf.Write(raw)
+		if len(raw) > 0 && raw[len(raw)-1] != '\n' {
+			buf.WriteByte('\n')
+		}
+	}
+	return buf.Bytes()
+}
+
+// compressLogs gzips logs, dropping their beginning until the result fits maxArchiveBytes
+func compressLogs(logs []byte) ([]byte, error) {
+	for {
+		var buf bytes.Buffer
+		writer := gzip.NewWriter(&buf)
+		if _, err := writer.Write(logs); err != nil {
+			return nil, err
+		}
+		if err := writer.Close(); err != nil {
+			return nil, err
+		}
+		if buf.Len() <= maxArchiveBytes {
+			return buf.Bytes(), nil
+		}
+
+		// The end of a log usually explains how the attempt ended, so it is kept
+		logs = append([]byte("(earlier logs truncated)\n"), logs[len(logs)/2:]...)
+	}
+}
+
+// logArchiveName names the archive of one pod of a Job: <job>-logs-<pod>, with the
+// Job name shortened and hashed when the result would not fit a DNS label
+// Job names already carry the attempt, so archives of different attempts never collide
+func logArchiveName(jobName string, podNumber int) string {
+	suffix := fmt.Sprintf("-logs-%d", podNumber)
+	if len(jobName)+len(suffix) <= validation.DNS1123LabelMaxLength {
+		return jobName + suffix
+	}
+	return fitName(jobName, shortHash(jobName), suffix)
+}
+
+// logArchiveConfigMap builds the ConfigMap holding the compressed logs of one pod
+func logArchiveConfigMap(pipeline *pipelinev1.Pipeline, stepName, jobName string, attempt int32, podNumber int, compressed []byte) *corev1.ConfigMap {
+	return &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      logArchiveName(jobName, podNumber),
+			Namespace: pipeline.Namespace,
+			Labels: map[string]string{
+				pipelineLabel:          pipeline.Name,
+				stepLabel:              stepName,
+				batchv1.JobNameLabel:   jobName,
+				logArchiveAttemptLabel: strconv.Itoa(int(attempt)),
+				logArchivePodLabel:     strconv.Itoa(podNumber),
+			},
+		},
+		BinaryData: map[string][]byte{logArchiveKey: compressed},
+	}
+}
+
+// archiveToConfigMap stores the logs of one pod in its own ConfigMap
+func (r *PipelineReconciler) archiveToConfigMap(ctx context.Context, pipeline *pipelinev1.Pipeline, stepName, jobName string, attempt int32, podNumber int, compressed []byte) (string, error) {
+	configMap := logArchiveConfigMap(pipeline, stepName, jobName, attempt, podNumber, compressed)
+	if pipeline.Spec.LogArchive.Retain != pipelinev1.LogArchiveRetainForever {
+		if err := controllerutil.SetOwnerReference(pipeline, configMap, r.Scheme); err != nil {
+			return "", err
+		}
+	}
+
+	if err := r.Create(ctx, configMap); err != nil && !apierrors.IsAlreadyExists(err) {
+		return "", err
+	}
+	return configMap.Name, nil
+}
+
+// archiveToPVC writes the logs of one pod to the archive claim
+// The controller cannot mount the claim, so the logs are staged in a ConfigMap and a
+// short-lived Job copies them; the staging ConfigMap is owned by, and deleted with, that Job
+func (r *PipelineReconciler) archiveToPVC(ctx context.Context, pipeline *pipelinev1.Pipeline, stepName, jobName string, attempt int32, podNumber int, compressed []byte) (string, error) {
+	archive := pipeline.Spec.LogArchive
+	name := logArchiveName(jobName, podNumber)
+	path := fmt.Sprintf("%s/%s/%s.log.gz", pipeline.Name, pipeline.UID, name)
+
+	image := archive.Image
+	if image == "" {
+		image = logArchiveDefaultImage
+	}
+	backoffLimit := int32(archiveCopyBackoffLimit)
+	ttlSeconds := int32(archiveCopyTTLSeconds)
+
+	copyJob := &batchv1.Job{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: pipeline.Namespace,
+			Labels: map[string]string{
+				pipelineLabel:          pipeline.Name,
+				stepLabel:              stepName,
+				logArchiveAttemptLabel: strconv.Itoa(int(attempt)),
+				logArchivePodLabel:     strconv.Itoa(podNumber),
+			},
+		},
+		Spec: batchv1.JobSpec{
+			BackoffLimit:            &backoffLimit,
+			TTLSecondsAfterFinished: &ttlSeconds,
+			Template: corev1.PodTemplateSpec{Spec: corev1.PodSpec{
+				RestartPolicy: corev1.RestartPolicyNever,
+				Containers: []corev1.Container{{
+					Name:    "archive",
+					Image:   image,
+					Command: []string{"sh", "-c", `mkdir -p "$(dirname "/archive/$ARCHIVE_PATH")" && cp /staging/` + logArchiveKey + ` "/archive/$ARCHIVE_PATH"`},
+					Env:     []corev1.EnvVar{{Name: "ARCHIVE_PATH", Value: path}},
+					VolumeMounts: []corev1.VolumeMount{
+						{Name: "staging", MountPath: "/staging", ReadOnly: true},
+						{Name: "archive", MountPath: "/archive"},
+					},
+				}},
+				Volumes: []corev1.Volume{
+					{Name: "staging", VolumeSource: corev1.VolumeSource{
+						ConfigMap: &corev1.ConfigMapVolumeSource{LocalObjectReference: corev1.LocalObjectReference{Name: name}},
+					}},
+					{Name: "archive", VolumeSource: corev1.VolumeSource{
+						PersistentVolumeClaim: &corev1.PersistentVolumeClaimVolumeSource{ClaimName: archive.ClaimName},
+					}},
+				},
+			}},
+		},
+	}
+	// The copy pod runs with the pipeline's service account and scheduling, like its steps
+	r.applyPodTemplateDefaults(pipeline, copyJob)
+	if err := controllerutil.SetOwnerReference(pipeline, copyJob, r.Scheme); err != nil {
+		return "", err
+	}
+
+	if err := r.Create(ctx, copyJob); err != nil {
+		if !apierrors.IsAlreadyExists(err) {
+			return "", err
+		}
+		if err := r.Get(ctx, types.NamespacedName{Name: name, Namespace: pipeline.Namespace}, copyJob); err != nil {
+			return "", err
+		}
+	}
+
+	// The copy pod waits for its ConfigMap volume, so staging after the Job is safe
+	staging := logArchiveConfigMap(pipeline, stepName, jobName, attempt, podNumber, compressed)
+	if err := controllerutil.SetOwnerReference(copyJob, staging, r.Scheme); err != nil {
+		return "", err
+	}
+	if err := r.Create(ctx, staging); err != nil && !apierrors.IsAlreadyExists(err) {
+		return "", err
+	}
+
+	return path, nil
+}
@@ -0,0 +1,276 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/rand"
+	"errors"
+	"io"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	kubefake "k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/interceptor"
+
+	pipelinev1 "github.com/yaacov/jobrunner/api/v1"
+)
+
+func gunzip(t *testing.T, data []byte) string {
+	t.Helper()
+	reader, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("invalid gzip data: %v", err)
+	}
+	out, err := io.ReadAll(reader)
+	if err != nil {
+		t.Fatalf("invalid gzip data: %v", err)
+	}
+	return string(out)
+}
+
+func TestCompressLogs(t *testing.T) {
+	t.Run("small logs are kept whole", func(t *testing.T) {
+		compressed, err := compressLogs([]byte("hello\nworld\n"))
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if got := gunzip(t, compressed); got != "hello\nworld\n" {
+			t.Errorf("unexpected logs %q", got)
+		}
+	})
+
+	t.Run("large logs keep their end", func(t *testing.T) {
+		// Random bytes do not compress, forcing truncation
+		logs := make([]byte, 2*maxArchiveBytes)
+		if _, err := rand.Read(logs); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		logs = append(logs, []byte("\nexit status 1\n")...)
+
+		compressed, err := compressLogs(logs)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if len(compressed) > maxArchiveBytes {
+			t.Errorf("expected at most %d bytes, got %d", maxArchiveBytes, len(compressed))
+		}
+		got := gunzip(t, compressed)
+		if !strings.HasPrefix(got, "(earlier logs truncated)") || !strings.HasSuffix(got, "exit status 1\n") {
+			t.Errorf("expected the truncated beginning to be marked and the end kept")
+		}
+	})
+}
+
+func newArchivePipeline(archive *pipelinev1.LogArchiveSpec) (*pipelinev1.Pipeline, []*corev1.Pod) {
+	pipeline := &pipelinev1.Pipeline{
+		ObjectMeta: metav1.ObjectMeta{Name: "release", Namespace: "default", UID: types.UID("release-uid")},
+		Spec:       pipelinev1.PipelineSpec{LogArchive: archive},
+	}
+
+	now := time.Now()
+	var pods []*corev1.Pod
+	for i, name := range []string{"release-build-second", "release-build-first"} {
+		pods = append(pods, &corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:              name,
+				Namespace:         "default",
+				CreationTimestamp: metav1.NewTime(now.Add(-time.Duration(i) * time.Minute)),
+				Labels:            map[string]string{batchv1.JobNameLabel: "release-build"},
+			},
+			Spec: corev1.PodSpec{Containers: []corev1.Container{{Name: "main"}}},
+		})
+	}
+	return pipeline, pods
+}
+
+func TestArchiveJobLogs(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("does nothing unless enabled", func(t *testing.T) {
+		pipeline, pods := newArchivePipeline(nil)
+		c := newFakeClient(pods[0], pods[1])
+		r := &PipelineReconciler{Client: c, Scheme: c.Scheme(), Clientset: kubefake.NewClientset(pods[0], pods[1])}
+
+		locations, err := r.archiveJobLogs(ctx, pipeline, "build", "release-build", 2)
+		if err != nil || len(locations) != 0 {
+			t.Errorf("expected no archives, got %v (error %v)", locations, err)
+		}
+	})
+
+	t.Run("stores each pod in a ConfigMap", func(t *testing.T) {
+		pipeline, pods := newArchivePipeline(&pipelinev1.LogArchiveSpec{
+			Target: pipelinev1.LogArchiveTargetConfigMap,
+			Retain: pipelinev1.LogArchiveRetainWithPipeline,
+		})
+		c := newFakeClient(pipeline, pods[0], pods[1])
+		r := &PipelineReconciler{Client: c, Scheme: c.Scheme(), Clientset: kubefake.NewClientset(pods[0], pods[1])}
+
+		locations, err := r.archiveJobLogs(ctx, pipeline, "build", "release-build", 2)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if len(locations) != 2 || locations[0] != "release-build-logs-1" || locations[1] != "release-build-logs-2" {
+			t.Fatalf("unexpected archive locations %v", locations)
+		}
+
+		configMap := &corev1.ConfigMap{}
+		if err := c.Get(ctx, types.NamespacedName{Namespace: "default", Name: "release-build-logs-1"}, configMap); err != nil {
+			t.Fatalf("expected the first pod to be archived: %v", err)
+		}
+		logs := gunzip(t, configMap.BinaryData[logArchiveKey])
+		if !strings.Contains(logs, "==> pod release-build-first container main <==") || !strings.Contains(logs, "fake logs") {
+			t.Errorf("expected the oldest pod's logs in the first archive, got %q", logs)
+		}
+		if configMap.Labels["pipeline.yaacov.io/step"] != "build" || configMap.Labels[logArchiveAttemptLabel] != "2" ||
+			configMap.Labels[logArchivePodLabel] != "1" {
+			t.Errorf("unexpected labels %v", configMap.Labels)
+		}
+		if len(configMap.OwnerReferences) != 1 || configMap.OwnerReferences[0].UID != pipeline.UID {
+			t.Errorf("expected the archive to be owned by the pipeline, got %+v", configMap.OwnerReferences)
+		}
+
+		if _, err := r.archiveJobLogs(ctx, pipeline, "build", "release-build", 2); err != nil {
+			t.Errorf("expected archiving again to succeed, got %v", err)
+		}
+	})
+
+	t.Run("keeps archives forever when asked", func(t *testing.T) {
+		pipeline, pods := newArchivePipeline(&pipelinev1.LogArchiveSpec{
+			Target: pipelinev1.LogArchiveTargetConfigMap,
+			Retain: pipelinev1.LogArchiveRetainForever,
+		})
+		c := newFakeClient(pipeline, pods[0])
+		r := &PipelineReconciler{Client: c, Scheme: c.Scheme(), Clientset: kubefake.NewClientset(pods[0])}
+
+		if _, err := r.archiveJobLogs(ctx, pipeline, "build", "release-build", 2); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		configMap := &corev1.ConfigMap{}
+		if err := c.Get(ctx, types.NamespacedName{Namespace: "default", Name: "release-build-logs-1"}, configMap); err != nil {
+			t.Fatalf("expected the attempt to be archived: %v", err)
+		}
+		if len(configMap.OwnerReferences) != 0 {
+			t.Errorf("expected no owner, got %+v", configMap.OwnerReferences)
+		}
+	})
+
+	t.Run("copies pods to a PVC", func(t *testing.T) {
+		pipeline, pods := newArchivePipeline(&pipelinev1.LogArchiveSpec{
+			Target:    pipelinev1.LogArchiveTargetPVC,
+			ClaimName: "audit-logs",
+		})
+		pipeline.Spec.ServiceAccountName = "pipeline-runner"
+		pipeline.Spec.PodTemplate = &pipelinev1.PodTemplateDefaults{
+			NodeSelector: map[string]string{"storage": "archive"},
+			Tolerations:  []corev1.Toleration{{Key: "dedicated", Operator: corev1.TolerationOpExists}},
+		}
+		c := newFakeClient(pipeline, pods[0])
+		r := &PipelineReconciler{Client: c, Scheme: c.Scheme(), Clientset: kubefake.NewClientset(pods[0])}
+
+		locations, err := r.archiveJobLogs(ctx, pipeline, "build", "release-build", 2)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if len(locations) != 1 || locations[0] != "release/release-uid/release-build-logs-1.log.gz" {
+			t.Fatalf("unexpected archive locations %v", locations)
+		}
+
+		copyJob := &batchv1.Job{}
+		if err := c.Get(ctx, types.NamespacedName{Namespace: "default", Name: "release-build-logs-1"}, copyJob); err != nil {
+			t.Fatalf("expected a copy job: %v", err)
+		}
+		podSpec := copyJob.Spec.Template.Spec
+		if podSpec.Containers[0].Image != logArchiveDefaultImage {
+			t.Errorf("expected the default image, got %q", podSpec.Containers[0].Image)
+		}
+		if podSpec.ServiceAccountName != "pipeline-runner" || podSpec.NodeSelector["storage"] != "archive" || len(podSpec.Tolerations) != 1 {
+			t.Errorf("expected the pipeline's pod template to apply, got %+v", podSpec)
+		}
+		if podSpec.Volumes[1].PersistentVolumeClaim == nil || podSpec.Volumes[1].PersistentVolumeClaim.ClaimName != "audit-logs" {
+			t.Errorf("expected the archive claim to be mounted, got %+v", podSpec.Volumes)
+		}
+
+		staging := &corev1.ConfigMap{}
+		if err := c.Get(ctx, types.NamespacedName{Namespace: "default", Name: "release-build-logs-1"}, staging); err != nil {
+			t.Fatalf("expected a staging ConfigMap: %v", err)
+		}
+		if len(staging.OwnerReferences) != 1 || staging.OwnerReferences[0].Kind != "Job" {
+			t.Errorf("expected the staging ConfigMap to be owned by the copy job, got %+v", staging.OwnerReferences)
+		}
+
+		if _, err := r.archiveJobLogs(ctx, pipeline, "build", "release-build", 2); err != nil {
+			t.Errorf("expected archiving again to succeed, got %v", err)
+		}
+	})
+}
+
+func TestArchiveStepLogs(t *testing.T) {
+	ctx := context.Background()
+	archive := &pipelinev1.LogArchiveSpec{Target: pipelinev1.LogArchiveTargetConfigMap}
+
+	t.Run("appends the archives of each attempt once", func(t *testing.T) {
+		pipeline, pods := newArchivePipeline(archive)
+		c := newFakeClient(pipeline, pods[0])
+		r := &PipelineReconciler{Client: c, Scheme: c.Scheme(), Clientset: kubefake.NewClientset(pods[0])}
+		stepStatus := &pipelinev1.StepStatus{Name: "build", ArchivedLogs: []string{"release-build-0-logs-1"}}
+
+		r.archiveStepLogs(ctx, pipeline, stepStatus, "release-build", 2)
+		r.archiveStepLogs(ctx, pipeline, stepStatus, "release-build", 2)
+		if want := []string{"release-build-0-logs-1", "release-build-logs-1"}; !slices.Equal(stepStatus.ArchivedLogs, want) {
+			t.Errorf("expected archived logs %v, got %v", want, stepStatus.ArchivedLogs)
+		}
+	})
+
+	t.Run("reports failures without failing the step", func(t *testing.T) {
+		pipeline, pods := newArchivePipeline(archive)
+		c := newFakeClientBuilder(pipeline, pods[0]).
+			WithInterceptorFuncs(interceptor.Funcs{
+				Create: func(ctx context.Context, c client.WithWatch, obj client.Object, opts ...client.CreateOption) error {
+					return apierrors.NewForbidden(corev1.Resource("configmaps"), obj.GetName(), errors.New("quota exceeded"))
+				},
+			}).
+			Build()
+		recorder := record.NewFakeRecorder(1)
+		r := &PipelineReconciler{Client: c, Scheme: c.Scheme(), Clientset: kubefake.NewClientset(pods[0]), Recorder: recorder}
+		stepStatus := &pipelinev1.StepStatus{Name: "build"}
+
+		r.archiveStepLogs(ctx, pipeline, stepStatus, "release-build", 1)
+		if len(stepStatus.ArchivedLogs) != 0 {
+			t.Errorf("expected no archived logs, got %v", stepStatus.ArchivedLogs)
+		}
+		select {
+		case event := <-recorder.Events:
+			if !strings.HasPrefix(event, "Warning LogArchiveFailed") || !strings.Contains(event, "quota exceeded") {
+				t.Errorf("unexpected event %q", event)
+			}
+		default:
+			t.Errorf("expected a warning event")
+		}
+	})
+}
@@ -0,0 +1,233 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"slices"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	pipelinev1 "github.com/yaacov/jobrunner/api/v1"
+)
+
+const (
+	// stepDetailsKey is the ConfigMap key holding the gzip compressed step details
+	stepDetailsKey = "details.json.gz"
+	// stepDetailsSuffix names the step details ConfigMap after its pipeline
+	stepDetailsSuffix = "-step-details"
+
+	// statusOverflowBytes is the size of the status from which step details move out,
+	// a quarter of the default etcd request limit
+	statusOverflowBytes = 256 << 10
+)
+
+// stepDetails is the content of the step details ConfigMap
+type stepDetails struct {
+	ResolvedSteps []pipelinev1.ResolvedStep `json:"resolvedSteps,omitempty"`
+	Steps         map[string]stepDetail     `json:"steps,omitempty"`
+}
+
+// stepDetail holds the fields moved out of one step's status
+type stepDetail struct {
+	Containers   []pipelinev1.ContainerTermination `json:"containers,omitempty"`
+	ArchivedLogs []string                          `json:"archivedLogs,omitempty"`
+}
+
+// stepDetailsName names the step details ConfigMap of a pipeline
+func stepDetailsName(pipeline *pipelinev1.Pipeline) string {
+	if len(pipeline.Name)+len(stepDetailsSuffix) <= validation.DNS1123SubdomainMaxLength {
+		return pipeline.Name + stepDetailsSuffix
+	}
+	return fitName(pipeline.Name, shortHash(pipeline.Name), stepDetailsSuffix)
+}
+
+// overflowStepDetails moves the resolved steps and each step's containers and archived
+// logs from the status to the step details ConfigMap when spec.statusOverflow is ConfigMap
+// Details move once the status grows past statusOverflowBytes, and from then on every
+// reconcile moves new details too, so readers only ever look in one place for them
+func (r *PipelineReconciler) overflowStepDetails(ctx context.Context, pipeline *pipelinev1.Pipeline) error {
+	if pipeline.Spec.StatusOverflow != pipelinev1.StatusOverflowConfigMap || !hasStepDetails(&pipeline.Status) {
+		return nil
+	}
+	if pipeline.Status.StepDetailsConfigMap == "" {
+		status, err := json.Marshal(&pipeline.Status)
+		if err != nil {
+			return err
+		}
+		if len(status) < statusOverflowBytes {
+			return nil
+		}
+	}
+
+	name := stepDetailsName(pipeline)
+	configMap := &corev1.ConfigMap{}
+	details := &stepDetails{}
+	exists := true
+	err := r.reader().Get(ctx, types.NamespacedName{Namespace: pipeline.Namespace, Name: name}, configMap)
+	switch {
+	case apierrors.IsNotFound(err):
+		exists = false
+		configMap = &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: pipeline.Namespace,
+			Labels:    map[string]string{pipelineLabel: pipeline.Name},
+		}}
+		if err := controllerutil.SetControllerReference(pipeline, configMap, r.Scheme); err != nil {
+			return err
+		}
+	case err != nil:
+		return err
+	case !metav1.IsControlledBy(configMap, pipeline):
+		return fmt.Errorf("configmap %s already exists and is not controlled by pipeline %s", name, pipeline.Name)
+	default:
+		if details, err = decodeStepDetails(configMap.BinaryData[stepDetailsKey]); err != nil {
+			return fmt.Errorf("reading configmap %s: %w", name, err)
+		}
+	}
+
+	mergeStepDetails(details, &pipeline.Status)
+	data, err := encodeStepDetails(details)
+	if err != nil {
+		return err
+	}
+	configMap.BinaryData = map[string][]byte{stepDetailsKey: data}
+
+	if exists {
+		err = r.Update(ctx, configMap)
+	} else {
+		err = r.Create(ctx, configMap)
+	}
+	if err != nil {
+		return err
+	}
+
+	// Only drop the details from the status once they are stored
+	pipeline.Status.ResolvedSteps = nil
+	for i := range pipeline.Status.Steps {
+		pipeline.Status.Steps[i].Containers = nil
+		pipeline.Status.Steps[i].ArchivedLogs = nil
+	}
+	if pipeline.Status.StepDetailsConfigMap == "" {
+		log.FromContext(ctx).Info("Moved step details out of the pipeline status", "configMap", name)
+	}
+	pipeline.Status.StepDetailsConfigMap = name
+	return nil
+}
+
+// hasStepDetails returns true if the status holds details that overflow to the ConfigMap
+func hasStepDetails(status *pipelinev1.PipelineStatus) bool {
+	if len(status.ResolvedSteps) > 0 {
+		return true
+	}
+	for i := range status.Steps {
+		if len(status.Steps[i].Containers) > 0 || len(status.Steps[i].ArchivedLogs) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// mergeStepDetails adds the details in the status to those already stored
+// Resolved steps and containers replace the stored ones of the same step, and archived
+// logs are added to the stored ones, since forEach items and retries archive separately
+func mergeStepDetails(details *stepDetails, status *pipelinev1.PipelineStatus) {
+	stored := make(map[string]int, len(details.ResolvedSteps))
+	for i := range details.ResolvedSteps {
+		stored[details.ResolvedSteps[i].Name] = i
+	}
+	for _, resolved := range status.ResolvedSteps {
+		i, ok := stored[resolved.Name]
+		if !ok {
+			stored[resolved.Name] = len(details.ResolvedSteps)
+			details.ResolvedSteps = append(details.ResolvedSteps, *resolved.DeepCopy())
+			continue
+		}
+		details.ResolvedSteps[i] = *resolved.DeepCopy()
+	}
+
+	if details.Steps == nil {
+		details.Steps = map[string]stepDetail{}
+	}
+	for i := range status.Steps {
+		stepStatus := &status.Steps[i]
+		detail := details.Steps[stepStatus.Name]
+		if len(stepStatus.Containers) > 0 {
+			detail.Containers = slices.Clone(stepStatus.Containers)
+		}
+		for _, location := range stepStatus.ArchivedLogs {
+			if !slices.Contains(detail.ArchivedLogs, location) {
+				detail.ArchivedLogs = append(detail.ArchivedLogs, location)
+			}
+		}
+		if len(detail.Containers) > 0 || len(detail.ArchivedLogs) > 0 {
+			details.Steps[stepStatus.Name] = detail
+		}
+	}
+}
+
+// encodeStepDetails serializes step details as gzip compressed JSON
+func encodeStepDetails(details *stepDetails) ([]byte, error) {
+	raw, err := json.Marshal(details)
+	if err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	writer := gzip.NewWriter(&buf)
+	if _, err := writer.Write(raw); err != nil {
+		return nil, err
+	}
+	if err := writer.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// decodeStepDetails reads step details written by encodeStepDetails
+func decodeStepDetails(data []byte) (*stepDetails, error) {
+	details := &stepDetails{}
+	if len(data) == 0 {
+		return details, nil
+	}
+
+	reader, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = reader.Close() }()
+
+	raw, err := io.ReadAll(reader)
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(raw, details); err != nil {
+		return nil, err
+	}
+	return details, nil
+}
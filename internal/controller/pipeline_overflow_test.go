@@ -0,0 +1,133 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"slices"
+	"strings"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+
+	pipelinev1 "github.com/yaacov/jobrunner/api/v1"
+)
+
+func newOverflowPipeline(policy pipelinev1.StatusOverflowPolicy, messageBytes int) *pipelinev1.Pipeline {
+	return &pipelinev1.Pipeline{
+		ObjectMeta: metav1.ObjectMeta{Name: "large", Namespace: "default", UID: types.UID("large-uid")},
+		Spec:       pipelinev1.PipelineSpec{StatusOverflow: policy},
+		Status: pipelinev1.PipelineStatus{
+			Steps: []pipelinev1.StepStatus{{
+				Name:         "build",
+				Phase:        pipelinev1.StepPhaseFailed,
+				Message:      strings.Repeat("x", messageBytes),
+				Containers:   []pipelinev1.ContainerTermination{{Name: "main", ExitCode: 1}},
+				ArchivedLogs: []string{"build-logs-2"},
+			}},
+			ResolvedSteps: []pipelinev1.ResolvedStep{{Name: "build"}},
+		},
+	}
+}
+
+func TestOverflowStepDetails(t *testing.T) {
+	ctx := context.Background()
+
+	tests := []struct {
+		name         string
+		policy       pipelinev1.StatusOverflowPolicy
+		messageBytes int
+		overflowed   bool
+		wantMoved    bool
+	}{
+		{name: "keeps details when overflow is off", policy: pipelinev1.StatusOverflowNone, messageBytes: statusOverflowBytes},
+		{name: "keeps details of a small status", policy: pipelinev1.StatusOverflowConfigMap},
+		{name: "moves details of a large status", policy: pipelinev1.StatusOverflowConfigMap, messageBytes: statusOverflowBytes, wantMoved: true},
+		{name: "keeps moving details once overflowed", policy: pipelinev1.StatusOverflowConfigMap, overflowed: true, wantMoved: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pipeline := newOverflowPipeline(tt.policy, tt.messageBytes)
+			c := newFakeClient(pipeline)
+			r := &PipelineReconciler{Client: c, Scheme: c.Scheme()}
+
+			if tt.overflowed {
+				// An earlier pass already moved the logs of the first attempt
+				earlier := newOverflowPipeline(tt.policy, 0)
+				earlier.Status.Steps[0].ArchivedLogs = []string{"build-logs-1"}
+				earlier.Status.StepDetailsConfigMap = stepDetailsName(earlier)
+				pipeline.Status.StepDetailsConfigMap = stepDetailsName(pipeline)
+				if err := r.overflowStepDetails(ctx, earlier); err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+			}
+
+			if err := r.overflowStepDetails(ctx, pipeline); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			build := pipeline.Status.Steps[0]
+			moved := pipeline.Status.StepDetailsConfigMap != "" && len(build.Containers) == 0 &&
+				len(build.ArchivedLogs) == 0 && len(pipeline.Status.ResolvedSteps) == 0
+			if moved != tt.wantMoved {
+				t.Fatalf("expected details moved %v, got status %+v", tt.wantMoved, pipeline.Status)
+			}
+			if !tt.wantMoved {
+				return
+			}
+
+			configMap := &corev1.ConfigMap{}
+			if err := c.Get(ctx, types.NamespacedName{Namespace: "default", Name: "large-step-details"}, configMap); err != nil {
+				t.Fatalf("expected a step details ConfigMap: %v", err)
+			}
+			if !metav1.IsControlledBy(configMap, pipeline) {
+				t.Errorf("expected the ConfigMap to be controlled by the pipeline, got %+v", configMap.OwnerReferences)
+			}
+			details, err := decodeStepDetails(configMap.BinaryData[stepDetailsKey])
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			wantLogs := []string{"build-logs-2"}
+			if tt.overflowed {
+				wantLogs = []string{"build-logs-1", "build-logs-2"}
+			}
+			detail := details.Steps["build"]
+			if !slices.Equal(detail.ArchivedLogs, wantLogs) || len(detail.Containers) != 1 || len(details.ResolvedSteps) != 1 {
+				t.Errorf("expected the build details with logs %v, got %+v", wantLogs, details)
+			}
+		})
+	}
+}
+
+func TestOverflowStepDetailsConflict(t *testing.T) {
+	pipeline := newOverflowPipeline(pipelinev1.StatusOverflowConfigMap, statusOverflowBytes)
+	existing := &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "large-step-details", Namespace: "default"}}
+	c := newFakeClient(pipeline, existing)
+	r := &PipelineReconciler{Client: c, Scheme: c.Scheme()}
+
+	err := r.overflowStepDetails(context.Background(), pipeline)
+	if err == nil || !strings.Contains(err.Error(), "not controlled by pipeline large") {
+		t.Fatalf("expected an ownership error, got %v", err)
+	}
+	if pipeline.Status.StepDetailsConfigMap != "" || len(pipeline.Status.Steps[0].Containers) == 0 {
+		t.Errorf("expected the details to stay in the status, got %+v", pipeline.Status)
+	}
+}
@@ -18,7 +18,6 @@ package controller
 
 import (
 	"context"
-	"time"
 
 	batchv1 "k8s.io/api/batch/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
@@ -26,8 +25,10 @@ import (
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
 	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 
 	pipelinev1 "github.com/yaacov/jobrunner/api/v1"
+	"github.com/yaacov/jobrunner/internal/validation"
 )
 
 // reconcileDelete handles cleanup when a Pipeline is being deleted
@@ -38,8 +39,8 @@ func (r *PipelineReconciler) reconcileDelete(ctx context.Context, pipeline *pipe
 	if controllerutil.ContainsFinalizer(pipeline, pipelineFinalizer) {
 		// Cleanup: delete all jobs created by this pipeline
 		jobList := &batchv1.JobList{}
-		if err := r.List(ctx, jobList, client.InNamespace(pipeline.Namespace), client.MatchingLabels{
-			"pipeline.yaacov.io/pipeline": pipeline.Name,
+		if err := r.List(ctx, jobList, client.InNamespace(pipeline.Namespace), client.MatchingFields{
+			jobPipelineIndex: pipeline.Name,
 		}); err != nil {
 			logger.Error(err, "Failed to list jobs for cleanup")
 			return ctrl.Result{}, err
@@ -74,7 +75,7 @@ func (r *PipelineReconciler) reconcilePipeline(ctx context.Context, pipeline *pi
 	logger.V(1).Info("Reconciling pipeline",
 		"pipeline", pipeline.Name,
 		"phase", pipeline.Status.Phase,
-		"stepCount", len(pipeline.Spec.Steps))
+		"stepCount", len(pipeline.Status.Steps))
 
 	// Initialize step statuses if needed
 	if len(pipeline.Status.Steps) == 0 {
@@ -91,6 +92,12 @@ func (r *PipelineReconciler) reconcilePipeline(ctx context.Context, pipeline *pi
 		return ctrl.Result{}, err
 	}
 
+	// With failurePolicy FailFast, a failed step stops the steps that are not handling it
+	if err := r.failFast(ctx, pipeline); err != nil {
+		logger.Error(err, "Failed to cancel steps after a failure")
+		return ctrl.Result{}, err
+	}
+
 	// Analyze pipeline completion state
 	pipelineState := r.analyzePipelineState(pipeline)
 	logger.V(1).Info("Pipeline state analyzed",
@@ -98,13 +105,11 @@ func (r *PipelineReconciler) reconcilePipeline(ctx context.Context, pipeline *pi
 		"anyFailed", pipelineState.anyFailed,
 		"anyRunning", pipelineState.anyRunning,
 		"anyPending", pipelineState.anyPending,
-		"hasPendingFailureHandlers", pipelineState.hasPendingFailureHandlers)
+		"hasPendingFailureHandlers", pipelineState.hasPendingFailureHandlers,
+		"stalled", pipelineState.deadlock != nil)
 
 	// Update pipeline phase based on analysis
-	if err := r.updatePipelinePhase(ctx, pipeline, pipelineState); err != nil {
-		logger.Error(err, "Failed to update pipeline phase")
-		return ctrl.Result{}, err
-	}
+	r.updatePipelinePhase(ctx, pipeline, pipelineState)
 
 	// If pipeline is complete, no need to requeue
 	if pipeline.Status.Phase == pipelinev1.PipelinePhaseSucceeded ||
@@ -113,7 +118,7 @@ func (r *PipelineReconciler) reconcilePipeline(ctx context.Context, pipeline *pi
 		return ctrl.Result{}, nil
 	}
 
-	// If pipeline is suspended, log it but still requeue to detect when resumed
+	// A suspended pipeline continues when the Job update that resumes it arrives
 	if pipeline.Status.Phase == pipelinev1.PipelinePhaseSuspended {
 		logger.Info("Pipeline is suspended, waiting for jobs to be resumed",
 			"suspendedSteps", pipelineState.suspendedSteps)
@@ -125,9 +130,12 @@ func (r *PipelineReconciler) reconcilePipeline(ctx context.Context, pipeline *pi
 		return ctrl.Result{}, err
 	}
 
-	// Requeue to check status again
-	logger.V(1).Info("Requeuing pipeline for status check")
-	return ctrl.Result{RequeueAfter: 10 * time.Second}, nil
+	// Reflect the steps just started or skipped in the phase written with this pass
+	r.updatePipelinePhase(ctx, pipeline, r.analyzePipelineState(pipeline))
+
+	// Job and pod events drive the next pass; only a stuck pod waits on the grace period
+	// of spec.stuckPods, which no event marks
+	return ctrl.Result{RequeueAfter: stuckRequeueAfter(pipeline)}, nil
 }
 
 // pipelineState represents the current state of the pipeline
@@ -139,13 +147,16 @@ type pipelineState struct {
 	anySuspended              bool
 	suspendedSteps            []string
 	hasPendingFailureHandlers bool
+	// deadlock holds the steps that can never start, resolved by updatePipelinePhase
+	deadlock *deadlock
 }
 
-// analyzePipelineState analyzes the current state of all steps
+// analyzePipelineState analyzes the current state of all steps without changing them
 func (r *PipelineReconciler) analyzePipelineState(pipeline *pipelinev1.Pipeline) pipelineState {
 	state := pipelineState{
 		allSucceeded:   true,
 		suspendedSteps: []string{},
+		deadlock:       r.findDeadlock(pipeline),
 	}
 
 	succeededCount := 0
@@ -162,6 +173,10 @@ func (r *PipelineReconciler) analyzePipelineState(pipeline *pipelinev1.Pipeline)
 		case pipelinev1.StepPhaseSkipped:
 			skippedCount++
 		case pipelinev1.StepPhaseFailed:
+			if isAllowedFailure(&stepStatus) {
+				succeededCount++
+				continue
+			}
 			failedCount++
 			state.anyFailed = true
 			state.allSucceeded = false
@@ -183,17 +198,25 @@ func (r *PipelineReconciler) analyzePipelineState(pipeline *pipelinev1.Pipeline)
 
 	// Check if there are any failure handlers that could still run
 	if state.anyPending && state.anyFailed {
-		state.hasPendingFailureHandlers = r.hasPendingFailureHandlers(pipeline)
+		state.hasPendingFailureHandlers = r.hasPendingFailureHandlers(newPipelineGraph(pipeline))
 	}
 
 	return state
 }
 
 // updatePipelinePhase updates the pipeline phase based on current state
-func (r *PipelineReconciler) updatePipelinePhase(ctx context.Context, pipeline *pipelinev1.Pipeline, state pipelineState) error {
+func (r *PipelineReconciler) updatePipelinePhase(ctx context.Context, pipeline *pipelinev1.Pipeline, state pipelineState) {
 	logger := log.FromContext(ctx)
 	oldPhase := pipeline.Status.Phase
 
+	// Steps that can never start are marked first, so the phase reflects them
+	if state.deadlock != nil {
+		r.resolveDeadlock(ctx, pipeline, state.deadlock)
+		state = r.analyzePipelineState(pipeline)
+	} else {
+		clearStalled(pipeline)
+	}
+
 	// Determine new phase
 	if state.anySuspended && !state.anyRunning {
 		// Pipeline is suspended - a step is waiting to be resumed
@@ -201,17 +224,11 @@ func (r *PipelineReconciler) updatePipelinePhase(ctx context.Context, pipeline *
 	} else if state.anyFailed && !state.anyRunning && !state.anySuspended && !state.hasPendingFailureHandlers {
 		// Pipeline failed and no cleanup/failure handlers are pending
 		pipeline.Status.Phase = pipelinev1.PipelinePhaseFailed
-		if pipeline.Status.CompletionTime == nil {
-			now := metav1.Now()
-			pipeline.Status.CompletionTime = &now
-		}
+		setPipelineCompletion(pipeline)
 	} else if state.allSucceeded {
 		// All steps completed successfully (or were skipped)
 		pipeline.Status.Phase = pipelinev1.PipelinePhaseSucceeded
-		if pipeline.Status.CompletionTime == nil {
-			now := metav1.Now()
-			pipeline.Status.CompletionTime = &now
-		}
+		setPipelineCompletion(pipeline)
 	} else if state.anyRunning || state.hasPendingFailureHandlers {
 		pipeline.Status.Phase = pipelinev1.PipelinePhaseRunning
 	}
@@ -219,22 +236,35 @@ func (r *PipelineReconciler) updatePipelinePhase(ctx context.Context, pipeline *
 	// Update conditions
 	r.updateConditions(pipeline, state)
 
-	// Update status if changed
 	if oldPhase != pipeline.Status.Phase {
 		logger.Info("Pipeline phase changed", "old", oldPhase, "new", pipeline.Status.Phase)
-		if err := r.Status().Update(ctx, pipeline); err != nil {
-			return err
-		}
 	}
+}
 
-	return nil
+// setPipelineCompletion records when the pipeline completed and how long it ran
+func setPipelineCompletion(pipeline *pipelinev1.Pipeline) {
+	if pipeline.Status.CompletionTime == nil {
+		now := metav1.Now()
+		pipeline.Status.CompletionTime = &now
+	}
+	if pipeline.Status.StartTime != nil && pipeline.Status.Duration == nil {
+		pipeline.Status.Duration = roundedDuration(pipeline.Status.StartTime, pipeline.Status.CompletionTime)
+	}
 }
 
 // initializeStepStatuses creates initial status entries for all steps
 func (r *PipelineReconciler) initializeStepStatuses(ctx context.Context, pipeline *pipelinev1.Pipeline) error {
 	logger := log.FromContext(ctx)
 
-	for _, step := range pipeline.Spec.Steps {
+	// Reject specs the validating webhook would have rejected, in case it is not installed
+	if errs := validation.ValidatePipeline(pipeline); len(errs) > 0 {
+		err := errs.ToAggregate()
+		logger.Error(err, "Invalid pipeline spec")
+		r.setNotReadyCondition(pipeline, "InvalidSpec", err.Error())
+		return reconcile.TerminalError(err)
+	}
+
+	for _, step := range pipeline.Spec.AllSteps() {
 		pipeline.Status.Steps = append(pipeline.Status.Steps, pipelinev1.StepStatus{
 			Name:  step.Name,
 			Phase: pipelinev1.StepPhasePending,
@@ -242,11 +272,25 @@ func (r *PipelineReconciler) initializeStepStatuses(ctx context.Context, pipelin
 		logger.V(1).Info("Initialized step status", "step", step.Name, "phase", "Pending")
 	}
 
-	if err := r.Status().Update(ctx, pipeline); err != nil {
-		logger.Error(err, "Failed to update pipeline status during initialization")
+	// Resolve and pin step templates before any step starts
+	if err := r.resolveStepTemplates(ctx, pipeline); err != nil {
+		logger.Error(err, "Failed to resolve step templates")
+		pipeline.Status.Steps = nil
+		r.setNotReadyCondition(pipeline, "TemplateResolutionFailed", err.Error())
 		return err
 	}
 
+	// Record the effective job spec of each step before any Job is created
+	if err := r.resolveSteps(ctx, pipeline); err != nil {
+		logger.Error(err, "Failed to resolve effective step specs")
+		pipeline.Status.Steps = nil
+		pipeline.Status.ResolvedSteps = nil
+		r.setNotReadyCondition(pipeline, "StepResolutionFailed", err.Error())
+		return err
+	}
+
+	r.updateSummaryStatus(pipeline)
+
 	logger.Info("Step statuses initialized", "count", len(pipeline.Status.Steps))
 	return nil
 }
@@ -75,8 +75,8 @@ func TestAnalyzePipelineState(t *testing.T) {
 			pipeline: &pipelinev1.Pipeline{
 				Spec: pipelinev1.PipelineSpec{
 					Steps: []pipelinev1.PipelineStep{
-						{Name: "step1", JobSpec: batchv1.JobSpec{}},
-						{Name: "step2", JobSpec: batchv1.JobSpec{}},
+						{Name: "step1", JobSpec: &batchv1.JobSpec{}},
+						{Name: "step2", JobSpec: &batchv1.JobSpec{}},
 					},
 				},
 				Status: pipelinev1.PipelineStatus{
@@ -129,14 +129,14 @@ func TestAnalyzePipelineState(t *testing.T) {
 			pipeline: &pipelinev1.Pipeline{
 				Spec: pipelinev1.PipelineSpec{
 					Steps: []pipelinev1.PipelineStep{
-						{Name: "step1", JobSpec: batchv1.JobSpec{}},
+						{Name: "step1", JobSpec: &batchv1.JobSpec{}},
 						{
 							Name: "cleanup",
 							RunIf: &pipelinev1.RunIfCondition{
 								Condition: pipelinev1.RunIfConditionFail,
 								Steps:     []string{"step1"},
 							},
-							JobSpec: batchv1.JobSpec{},
+							JobSpec: &batchv1.JobSpec{},
 						},
 					},
 				},
@@ -158,10 +158,10 @@ func TestAnalyzePipelineState(t *testing.T) {
 			pipeline: &pipelinev1.Pipeline{
 				Spec: pipelinev1.PipelineSpec{
 					Steps: []pipelinev1.PipelineStep{
-						{Name: "step1", JobSpec: batchv1.JobSpec{}},
-						{Name: "step2", JobSpec: batchv1.JobSpec{}},
-						{Name: "step3", JobSpec: batchv1.JobSpec{}},
-						{Name: "step4", JobSpec: batchv1.JobSpec{}},
+						{Name: "step1", JobSpec: &batchv1.JobSpec{}},
+						{Name: "step2", JobSpec: &batchv1.JobSpec{}},
+						{Name: "step3", JobSpec: &batchv1.JobSpec{}},
+						{Name: "step4", JobSpec: &batchv1.JobSpec{}},
 					},
 				},
 				Status: pipelinev1.PipelineStatus{
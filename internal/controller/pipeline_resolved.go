@@ -0,0 +1,82 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"fmt"
+
+	batchv1 "k8s.io/api/batch/v1"
+
+	pipelinev1 "github.com/yaacov/jobrunner/api/v1"
+)
+
+// resolveSteps records the effective JobSpec of every step in status.resolvedSteps
+// Steps using jobRef are recorded when their Job is built, since the referenced
+// object is only read at that point
+func (r *PipelineReconciler) resolveSteps(ctx context.Context, pipeline *pipelinev1.Pipeline) error {
+	if !pipeline.Spec.ResolveSteps {
+		return nil
+	}
+
+	g := newPipelineGraph(pipeline)
+	for i := range g.steps {
+		step := g.steps[i]
+		if step.JobRef != nil {
+			continue
+		}
+
+		stepStatus := g.stepStatus(step.Name)
+		if stepStatus == nil {
+			continue
+		}
+
+		attempt := stepStatus.Attempt + 1
+		job, err := r.buildJobForStep(ctx, pipeline, step, stepStatus, stepJobName(pipeline, step.Name, attempt), attempt)
+		if err != nil {
+			return fmt.Errorf("resolving step %q: %w", step.Name, err)
+		}
+		g.recordResolvedStep(step.Name, &job.Spec)
+	}
+
+	return nil
+}
+
+// recordResolvedStep stores or replaces the effective JobSpec of a step
+func (g *pipelineGraph) recordResolvedStep(stepName string, jobSpec *batchv1.JobSpec) {
+	pipeline := g.pipeline
+	if !pipeline.Spec.ResolveSteps {
+		return
+	}
+
+	if g.resolved == nil {
+		g.resolved = make(map[string]int, len(g.steps))
+		for i := range pipeline.Status.ResolvedSteps {
+			g.resolved[pipeline.Status.ResolvedSteps[i].Name] = i
+		}
+	}
+
+	if i, ok := g.resolved[stepName]; ok {
+		pipeline.Status.ResolvedSteps[i].JobSpec = jobSpec.DeepCopy()
+		return
+	}
+	g.resolved[stepName] = len(pipeline.Status.ResolvedSteps)
+	pipeline.Status.ResolvedSteps = append(pipeline.Status.ResolvedSteps, pipelinev1.ResolvedStep{
+		Name:    stepName,
+		JobSpec: jobSpec.DeepCopy(),
+	})
+}
@@ -0,0 +1,155 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"testing"
+
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+
+	pipelinev1 "github.com/yaacov/jobrunner/api/v1"
+)
+
+func newResolvePipeline(resolve bool) *pipelinev1.Pipeline {
+	return &pipelinev1.Pipeline{
+		ObjectMeta: metav1.ObjectMeta{Name: "release", Namespace: "default", UID: types.UID("release-uid")},
+		Spec: pipelinev1.PipelineSpec{
+			ResolveSteps:       resolve,
+			ServiceAccountName: "pipeline-runner",
+			PodTemplate: &pipelinev1.PodTemplateDefaults{
+				Image: "registry.example.com/builder:1.0",
+				Env:   []corev1.EnvVar{{Name: "LOG_LEVEL", Value: "debug"}},
+			},
+			SharedVolume: &pipelinev1.SharedVolumeSpec{},
+			Steps: []pipelinev1.PipelineStep{
+				{
+					Name: "build",
+					JobSpec: &batchv1.JobSpec{Template: corev1.PodTemplateSpec{Spec: corev1.PodSpec{
+						RestartPolicy: corev1.RestartPolicyNever,
+						Containers:    []corev1.Container{{Name: "main"}},
+					}}},
+				},
+				{
+					Name:   "deploy",
+					JobRef: &pipelinev1.JobReference{Kind: pipelinev1.JobReferenceKindCronJob, Name: "deploy"},
+				},
+			},
+		},
+		Status: pipelinev1.PipelineStatus{Steps: []pipelinev1.StepStatus{
+			{Name: "build", Phase: pipelinev1.StepPhasePending},
+			{Name: "deploy", Phase: pipelinev1.StepPhasePending},
+		}},
+	}
+}
+
+func TestResolveSteps(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("does nothing unless enabled", func(t *testing.T) {
+		c := newFakeClient()
+		r := &PipelineReconciler{Client: c, Scheme: c.Scheme()}
+		pipeline := newResolvePipeline(false)
+
+		if err := r.resolveSteps(ctx, pipeline); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if len(pipeline.Status.ResolvedSteps) != 0 {
+			t.Errorf("expected no resolved steps, got %d", len(pipeline.Status.ResolvedSteps))
+		}
+	})
+
+	t.Run("records the effective spec of inline steps", func(t *testing.T) {
+		c := newFakeClient()
+		r := &PipelineReconciler{Client: c, Scheme: c.Scheme()}
+		pipeline := newResolvePipeline(true)
+
+		if err := r.resolveSteps(ctx, pipeline); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if len(pipeline.Status.ResolvedSteps) != 1 || pipeline.Status.ResolvedSteps[0].Name != "build" {
+			t.Fatalf("expected only the inline step to be resolved, got %+v", pipeline.Status.ResolvedSteps)
+		}
+
+		spec := pipeline.Status.ResolvedSteps[0].JobSpec
+		if spec.BackoffLimit == nil || *spec.BackoffLimit != 0 {
+			t.Errorf("expected default backoffLimit 0, got %v", spec.BackoffLimit)
+		}
+		podSpec := spec.Template.Spec
+		if podSpec.ServiceAccountName != "pipeline-runner" {
+			t.Errorf("expected service account to be applied, got %q", podSpec.ServiceAccountName)
+		}
+		if podSpec.Containers[0].Image != "registry.example.com/builder:1.0" {
+			t.Errorf("expected default image to be applied, got %q", podSpec.Containers[0].Image)
+		}
+		if len(podSpec.Containers[0].Env) != 1 || len(podSpec.Containers[0].VolumeMounts) != 1 {
+			t.Errorf("expected env and shared volume mount, got %+v", podSpec.Containers[0])
+		}
+
+		// The step's own spec must not be modified
+		if pipeline.Spec.Steps[0].JobSpec.Template.Spec.Containers[0].Image != "" {
+			t.Error("expected the pipeline spec to be left unchanged")
+		}
+	})
+
+	t.Run("applies job defaults before the default backoffLimit", func(t *testing.T) {
+		c := newFakeClient()
+		r := &PipelineReconciler{Client: c, Scheme: c.Scheme()}
+		pipeline := newResolvePipeline(true)
+		pipeline.Spec.JobDefaults = &pipelinev1.JobDefaults{BackoffLimit: int32Ptr(3), TTLSecondsAfterFinished: int32Ptr(600)}
+
+		if err := r.resolveSteps(ctx, pipeline); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		spec := pipeline.Status.ResolvedSteps[0].JobSpec
+		if spec.BackoffLimit == nil || *spec.BackoffLimit != 3 {
+			t.Errorf("expected backoffLimit 3 from the job defaults, got %v", spec.BackoffLimit)
+		}
+		if spec.TTLSecondsAfterFinished == nil || *spec.TTLSecondsAfterFinished != 600 {
+			t.Errorf("expected ttlSecondsAfterFinished 600 from the job defaults, got %v", spec.TTLSecondsAfterFinished)
+		}
+	})
+
+	t.Run("matches the created job", func(t *testing.T) {
+		c := newFakeClient()
+		r := &PipelineReconciler{Client: c, Scheme: c.Scheme()}
+		pipeline := newResolvePipeline(true)
+
+		if err := r.resolveSteps(ctx, pipeline); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		preview := pipeline.Status.ResolvedSteps[0].JobSpec.DeepCopy()
+
+		if err := r.createJobForStep(ctx, newPipelineGraph(pipeline), &pipeline.Spec.Steps[0], &pipeline.Status.Steps[0]); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		job := &batchv1.Job{}
+		if err := c.Get(ctx, types.NamespacedName{Namespace: "default", Name: pipeline.Status.Steps[0].JobName}, job); err != nil {
+			t.Fatalf("expected job to be created: %v", err)
+		}
+		if !equality.Semantic.DeepEqual(*preview, job.Spec) {
+			t.Errorf("expected resolved spec to match the created job\nresolved: %+v\njob: %+v", preview, job.Spec)
+		}
+		if len(pipeline.Status.ResolvedSteps) != 1 {
+			t.Errorf("expected the entry to be replaced, got %d entries", len(pipeline.Status.ResolvedSteps))
+		}
+	})
+}
@@ -0,0 +1,184 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	pipelinev1 "github.com/yaacov/jobrunner/api/v1"
+)
+
+// checkStageGate checks whether the stage containing a step may start
+// Returns (ready, shouldSkip) with the same meaning as areDependenciesSatisfied
+func (r *PipelineReconciler) checkStageGate(g *pipelineGraph, stage *pipelinev1.PipelineStage, stageIndex int) (ready bool, shouldSkip bool) {
+	var stepNames, stageNames []string
+	checkAll, checkFailure := true, false
+
+	switch {
+	case stage.RunIf != nil:
+		stepNames, stageNames = stage.RunIf.Steps, stage.RunIf.Stages
+		checkAll, checkFailure = stage.RunIf.RequiresAll(), stage.RunIf.IsCheckingFailure()
+	case len(stage.DependsOn) > 0:
+		stageNames = stage.DependsOn
+	case stageIndex > 0:
+		// Default: wait for the previous stage
+		stageNames = []string{g.pipeline.Spec.Stages[stageIndex-1].Name}
+	default:
+		// The first stage waits for all top-level steps
+		stepNames = g.lists[0].names
+	}
+
+	// DependsOn must also hold when combined with runIf
+	if stage.RunIf != nil && len(stage.DependsOn) > 0 {
+		met, complete := r.checkUnitStatuses(g, nil, stage.DependsOn, true, false, g.skipPolicy(nil))
+		if !complete {
+			return false, false
+		}
+		if !met {
+			log.Log.Info("Stage skipped - dependsOn stages did not succeed",
+				"stage", stage.Name,
+				"dependsOn", stage.DependsOn)
+			return false, true
+		}
+	}
+
+	if len(stepNames) == 0 && len(stageNames) == 0 {
+		return true, false
+	}
+
+	met, complete := r.checkUnitStatuses(g, stepNames, stageNames, checkAll, checkFailure, g.skipPolicy(nil))
+	if !complete {
+		log.Log.V(1).Info("Stage waiting for its dependencies",
+			"stage", stage.Name,
+			"steps", stepNames,
+			"stages", stageNames)
+		return false, false
+	}
+	if !met {
+		log.Log.Info("Stage skipped - conditions not met",
+			"stage", stage.Name,
+			"steps", stepNames,
+			"stages", stageNames)
+		return false, true
+	}
+	return true, false
+}
+
+// stageStatus rolls up the phases of a stage's steps
+func (g *pipelineGraph) stageStatus(stage *pipelinev1.PipelineStage) pipelinev1.StageStatus {
+	status := pipelinev1.StageStatus{Name: stage.Name}
+	counts := &status.StepCounts
+
+	for i := range stage.Steps {
+		counts.Total++
+		switch g.stepPhase(stage.Steps[i].Name) {
+		case pipelinev1.StepPhasePending:
+			counts.Pending++
+		case pipelinev1.StepPhaseRunning:
+			counts.Running++
+		case pipelinev1.StepPhaseSuspended:
+			counts.Suspended++
+		case pipelinev1.StepPhaseSucceeded:
+			counts.Succeeded++
+		case pipelinev1.StepPhaseFailed:
+			counts.Failed++
+		case pipelinev1.StepPhaseSkipped:
+			counts.Skipped++
+		}
+	}
+
+	started := counts.Total - counts.Pending
+	switch {
+	case counts.Running > 0:
+		status.Phase = pipelinev1.StepPhaseRunning
+	case counts.Suspended > 0:
+		status.Phase = pipelinev1.StepPhaseSuspended
+	case counts.Pending > 0 && started > counts.Skipped:
+		// Some steps ran and others are still waiting
+		status.Phase = pipelinev1.StepPhaseRunning
+	case counts.Pending > 0:
+		status.Phase = pipelinev1.StepPhasePending
+	case counts.Failed > 0:
+		status.Phase = pipelinev1.StepPhaseFailed
+	case counts.Skipped == counts.Total:
+		status.Phase = pipelinev1.StepPhaseSkipped
+	default:
+		status.Phase = pipelinev1.StepPhaseSucceeded
+	}
+
+	return status
+}
+
+// updateStageStatuses refreshes the rolled-up stage statuses
+// Returns true if any stage status changed
+func (r *PipelineReconciler) updateStageStatuses(pipeline *pipelinev1.Pipeline) bool {
+	if len(pipeline.Spec.Stages) == 0 {
+		return false
+	}
+
+	g := newPipelineGraph(pipeline)
+	now := metav1.Now()
+	changed := len(pipeline.Status.Stages) != len(pipeline.Spec.Stages)
+	stages := make([]pipelinev1.StageStatus, 0, len(pipeline.Spec.Stages))
+
+	for i := range pipeline.Spec.Stages {
+		stage := &pipeline.Spec.Stages[i]
+		status := g.stageStatus(stage)
+
+		var previous *pipelinev1.StageStatus
+		if i < len(pipeline.Status.Stages) && pipeline.Status.Stages[i].Name == stage.Name {
+			previous = &pipeline.Status.Stages[i]
+		}
+		if previous != nil {
+			status.StartTime = previous.StartTime
+			status.CompletionTime = previous.CompletionTime
+		}
+
+		started := status.StepCounts.Total - status.StepCounts.Pending - status.StepCounts.Skipped
+		if status.StartTime == nil && started > 0 {
+			status.StartTime = &now
+		}
+		if status.CompletionTime == nil && isTerminalStepPhase(status.Phase) {
+			status.CompletionTime = &now
+		}
+
+		if previous == nil || previous.Phase != status.Phase || previous.StepCounts != status.StepCounts ||
+			(previous.StartTime == nil) != (status.StartTime == nil) ||
+			(previous.CompletionTime == nil) != (status.CompletionTime == nil) {
+			changed = true
+			if previous == nil || previous.Phase != status.Phase {
+				log.Log.Info("Stage phase changed",
+					"pipeline", pipeline.Name,
+					"stage", stage.Name,
+					"phase", status.Phase)
+			}
+		}
+
+		stages = append(stages, status)
+	}
+
+	pipeline.Status.Stages = stages
+	return changed
+}
+
+// isTerminalStepPhase returns true for phases a step or stage never leaves
+func isTerminalStepPhase(phase pipelinev1.StepPhase) bool {
+	return phase == pipelinev1.StepPhaseSucceeded ||
+		phase == pipelinev1.StepPhaseFailed ||
+		phase == pipelinev1.StepPhaseSkipped
+}
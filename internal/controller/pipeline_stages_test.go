@@ -0,0 +1,262 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"testing"
+
+	pipelinev1 "github.com/yaacov/jobrunner/api/v1"
+)
+
+// newStagedPipeline builds a pipeline with a top-level "setup" step and two stages,
+// "build" (compile, package) and "test" (unit), with the given step phases
+func newStagedPipeline(phases map[string]pipelinev1.StepPhase) *pipelinev1.Pipeline {
+	pipeline := &pipelinev1.Pipeline{
+		Spec: pipelinev1.PipelineSpec{
+			Steps: []pipelinev1.PipelineStep{{Name: "setup"}},
+			Stages: []pipelinev1.PipelineStage{
+				{Name: "build", Steps: []pipelinev1.PipelineStep{{Name: "compile"}, {Name: "package"}}},
+				{Name: "test", Steps: []pipelinev1.PipelineStep{{Name: "unit"}}},
+			},
+		},
+	}
+	for _, step := range pipeline.Spec.AllSteps() {
+		phase, ok := phases[step.Name]
+		if !ok {
+			phase = pipelinev1.StepPhasePending
+		}
+		pipeline.Status.Steps = append(pipeline.Status.Steps, pipelinev1.StepStatus{Name: step.Name, Phase: phase})
+	}
+	return pipeline
+}
+
+func TestAreDependenciesSatisfiedInStages(t *testing.T) {
+	r := &PipelineReconciler{}
+
+	tests := []struct {
+		name      string
+		phases    map[string]pipelinev1.StepPhase
+		modify    func(p *pipelinev1.Pipeline)
+		step      string
+		wantReady bool
+		wantSkip  bool
+	}{
+		{
+			name:      "first stage waits for top-level steps",
+			step:      "compile",
+			wantReady: false,
+		},
+		{
+			name:      "first stage starts after top-level steps succeed",
+			phases:    map[string]pipelinev1.StepPhase{"setup": pipelinev1.StepPhaseSucceeded},
+			step:      "compile",
+			wantReady: true,
+		},
+		{
+			name:      "steps within a stage run sequentially",
+			phases:    map[string]pipelinev1.StepPhase{"setup": pipelinev1.StepPhaseSucceeded, "compile": pipelinev1.StepPhaseRunning},
+			step:      "package",
+			wantReady: false,
+		},
+		{
+			name:      "next step in a stage starts when the previous one succeeds",
+			phases:    map[string]pipelinev1.StepPhase{"setup": pipelinev1.StepPhaseSucceeded, "compile": pipelinev1.StepPhaseSucceeded},
+			step:      "package",
+			wantReady: true,
+		},
+		{
+			name:      "next stage waits for the previous stage",
+			phases:    map[string]pipelinev1.StepPhase{"setup": pipelinev1.StepPhaseSucceeded, "compile": pipelinev1.StepPhaseSucceeded},
+			step:      "unit",
+			wantReady: false,
+		},
+		{
+			name: "next stage starts when the previous stage succeeds",
+			phases: map[string]pipelinev1.StepPhase{
+				"setup": pipelinev1.StepPhaseSucceeded, "compile": pipelinev1.StepPhaseSucceeded, "package": pipelinev1.StepPhaseSucceeded,
+			},
+			step:      "unit",
+			wantReady: true,
+		},
+		{
+			name: "next stage is skipped when the previous stage fails",
+			phases: map[string]pipelinev1.StepPhase{
+				"setup": pipelinev1.StepPhaseSucceeded, "compile": pipelinev1.StepPhaseFailed, "package": pipelinev1.StepPhaseSkipped,
+			},
+			step:     "unit",
+			wantSkip: true,
+		},
+		{
+			name: "stage runIf on failure of another stage",
+			modify: func(p *pipelinev1.Pipeline) {
+				p.Spec.Stages[1].RunIf = &pipelinev1.RunIfCondition{
+					Condition: pipelinev1.RunIfConditionFail,
+					Stages:    []string{"build"},
+				}
+			},
+			phases: map[string]pipelinev1.StepPhase{
+				"setup": pipelinev1.StepPhaseSucceeded, "compile": pipelinev1.StepPhaseFailed, "package": pipelinev1.StepPhaseSkipped,
+			},
+			step:      "unit",
+			wantReady: true,
+		},
+		{
+			name: "stage runIf on failure is skipped when the stage succeeds",
+			modify: func(p *pipelinev1.Pipeline) {
+				p.Spec.Stages[1].RunIf = &pipelinev1.RunIfCondition{
+					Condition: pipelinev1.RunIfConditionFail,
+					Stages:    []string{"build"},
+				}
+			},
+			phases: map[string]pipelinev1.StepPhase{
+				"setup": pipelinev1.StepPhaseSucceeded, "compile": pipelinev1.StepPhaseSucceeded, "package": pipelinev1.StepPhaseSucceeded,
+			},
+			step:     "unit",
+			wantSkip: true,
+		},
+		{
+			name: "step runIf can reference a stage",
+			modify: func(p *pipelinev1.Pipeline) {
+				p.Spec.Steps = append(p.Spec.Steps, pipelinev1.PipelineStep{
+					Name:  "notify",
+					RunIf: &pipelinev1.RunIfCondition{Stages: []string{"test"}},
+				})
+				p.Status.Steps = append(p.Status.Steps, pipelinev1.StepStatus{Name: "notify", Phase: pipelinev1.StepPhasePending})
+			},
+			phases:    map[string]pipelinev1.StepPhase{"setup": pipelinev1.StepPhaseSucceeded, "unit": pipelinev1.StepPhaseSucceeded},
+			step:      "notify",
+			wantReady: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pipeline := newStagedPipeline(tt.phases)
+			if tt.modify != nil {
+				tt.modify(pipeline)
+			}
+
+			var step *pipelinev1.PipelineStep
+			steps := pipeline.Spec.AllSteps()
+			for i := range steps {
+				if steps[i].Name == tt.step {
+					step = &steps[i]
+				}
+			}
+
+			ready, skip := r.areDependenciesSatisfied(newPipelineGraph(pipeline), step)
+			if ready != tt.wantReady || skip != tt.wantSkip {
+				t.Errorf("areDependenciesSatisfied() = (%v, %v), want (%v, %v)", ready, skip, tt.wantReady, tt.wantSkip)
+			}
+		})
+	}
+}
+
+func TestStageStatus(t *testing.T) {
+	tests := []struct {
+		name      string
+		phases    map[string]pipelinev1.StepPhase
+		wantPhase pipelinev1.StepPhase
+	}{
+		{
+			name:      "pending when no step started",
+			wantPhase: pipelinev1.StepPhasePending,
+		},
+		{
+			name:      "running while a step runs",
+			phases:    map[string]pipelinev1.StepPhase{"compile": pipelinev1.StepPhaseRunning},
+			wantPhase: pipelinev1.StepPhaseRunning,
+		},
+		{
+			name:      "running between steps",
+			phases:    map[string]pipelinev1.StepPhase{"compile": pipelinev1.StepPhaseSucceeded},
+			wantPhase: pipelinev1.StepPhaseRunning,
+		},
+		{
+			name:      "suspended when a step is suspended",
+			phases:    map[string]pipelinev1.StepPhase{"compile": pipelinev1.StepPhaseSuspended},
+			wantPhase: pipelinev1.StepPhaseSuspended,
+		},
+		{
+			name:      "succeeded when all steps succeed",
+			phases:    map[string]pipelinev1.StepPhase{"compile": pipelinev1.StepPhaseSucceeded, "package": pipelinev1.StepPhaseSucceeded},
+			wantPhase: pipelinev1.StepPhaseSucceeded,
+		},
+		{
+			name:      "failed when any step fails",
+			phases:    map[string]pipelinev1.StepPhase{"compile": pipelinev1.StepPhaseFailed, "package": pipelinev1.StepPhaseSkipped},
+			wantPhase: pipelinev1.StepPhaseFailed,
+		},
+		{
+			name:      "skipped when all steps are skipped",
+			phases:    map[string]pipelinev1.StepPhase{"compile": pipelinev1.StepPhaseSkipped, "package": pipelinev1.StepPhaseSkipped},
+			wantPhase: pipelinev1.StepPhaseSkipped,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pipeline := newStagedPipeline(tt.phases)
+			status := newPipelineGraph(pipeline).stageStatus(&pipeline.Spec.Stages[0])
+			if status.Phase != tt.wantPhase {
+				t.Errorf("expected phase %s, got %s", tt.wantPhase, status.Phase)
+			}
+			if status.StepCounts.Total != 2 {
+				t.Errorf("expected 2 steps, got %d", status.StepCounts.Total)
+			}
+		})
+	}
+}
+
+func TestUpdateStageStatuses(t *testing.T) {
+	r := &PipelineReconciler{}
+
+	pipeline := newStagedPipeline(map[string]pipelinev1.StepPhase{"setup": pipelinev1.StepPhaseSucceeded})
+	if !r.updateStageStatuses(pipeline) {
+		t.Fatal("expected initial stage statuses to be a change")
+	}
+	if len(pipeline.Status.Stages) != 2 || pipeline.Status.Stages[0].StartTime != nil {
+		t.Fatalf("expected 2 pending stages, got %+v", pipeline.Status.Stages)
+	}
+	if r.updateStageStatuses(pipeline) {
+		t.Error("expected no change when step phases are unchanged")
+	}
+
+	pipeline.Status.Steps[1].Phase = pipelinev1.StepPhaseRunning
+	if !r.updateStageStatuses(pipeline) {
+		t.Fatal("expected a change when a stage starts")
+	}
+	build := pipeline.Status.Stages[0]
+	if build.Phase != pipelinev1.StepPhaseRunning || build.StartTime == nil || build.CompletionTime != nil {
+		t.Errorf("expected running stage with start time, got %+v", build)
+	}
+	startTime := build.StartTime
+
+	pipeline.Status.Steps[1].Phase = pipelinev1.StepPhaseSucceeded
+	pipeline.Status.Steps[2].Phase = pipelinev1.StepPhaseSucceeded
+	r.updateStageStatuses(pipeline)
+	build = pipeline.Status.Stages[0]
+	if build.Phase != pipelinev1.StepPhaseSucceeded || build.CompletionTime == nil {
+		t.Errorf("expected succeeded stage with completion time, got %+v", build)
+	}
+	if !build.StartTime.Equal(startTime) {
+		t.Error("expected start time to be preserved")
+	}
+	if build.StepCounts.Succeeded != 2 {
+		t.Errorf("expected 2 succeeded steps, got %d", build.StepCounts.Succeeded)
+	}
+}
@@ -23,56 +23,105 @@ import (
 
 	batchv1 "k8s.io/api/batch/v1"
 	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
-	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/util/retry"
+	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/log"
 
 	pipelinev1 "github.com/yaacov/jobrunner/api/v1"
 )
 
-// updateStepStatuses fetches and updates the status of all running jobs
+// updateStepStatuses refreshes the status of all running jobs in memory
 func (r *PipelineReconciler) updateStepStatuses(ctx context.Context, pipeline *pipelinev1.Pipeline) error {
 	logger := log.FromContext(ctx)
 
+	jobs, err := r.listRunJobs(ctx, pipeline)
+	if err != nil {
+		logger.Error(err, "Failed to list pipeline jobs")
+		return err
+	}
+
 	changed := false
 	checkedJobs := 0
 
 	for i := range pipeline.Status.Steps {
 		stepStatus := &pipeline.Status.Steps[i]
+
+		// A cancelled or stuck step's Jobs are being deleted and must not bring it back,
+		// and an allowed failure keeps the reason it was recorded with
+		if stepStatus.Reason == stepReasonCancelled || isStuckFailure(stepStatus) || isAllowedFailure(stepStatus) {
+			continue
+		}
+
+		// forEach steps track one Job per item
+		if len(stepStatus.Children) > 0 {
+			oldPhase := stepStatus.Phase
+			childrenChanged, err := r.updateForEachChildren(ctx, pipeline, jobs, stepStatus)
+			if err != nil {
+				return err
+			}
+			if oldPhase != pipelinev1.StepPhaseFailed && allowFailure(specStep(pipeline, stepStatus.Name), stepStatus) {
+				logger.Info("Step failure allowed", "step", stepStatus.Name, "message", stepStatus.Message)
+			}
+			changed = changed || childrenChanged
+			continue
+		}
+
+		// A Job created by a reconcile whose status update was lost is picked up again
+		if recoverStepJob(ctx, jobs, stepStatus) {
+			changed = true
+		}
+
 		if stepStatus.JobName == "" {
 			continue
 		}
 
 		checkedJobs++
 
-		// Fetch the job
-		job := &batchv1.Job{}
-		if err := r.Get(ctx, types.NamespacedName{
-			Name:      stepStatus.JobName,
-			Namespace: pipeline.Namespace,
-		}, job); err != nil {
-			if apierrors.IsNotFound(err) {
-				logger.Info("Job not found, may have been deleted",
-					"job", stepStatus.JobName,
-					"step", stepStatus.Name)
-				continue
-			}
+		// A finished step keeps its recorded phase once its Job is cleaned up
+		if _, listed := jobs[stepStatus.JobName]; !listed && isTerminalStepPhase(stepStatus.Phase) {
+			continue
+		}
+
+		job, err := r.findRunJob(ctx, pipeline, jobs, stepStatus.JobName)
+		if err != nil {
 			logger.Error(err, "Failed to fetch job",
 				"job", stepStatus.JobName,
 				"step", stepStatus.Name)
 			return err
 		}
+		if job == nil {
+			if r.handleLostJob(ctx, pipeline, stepStatus) {
+				changed = true
+			}
+			continue
+		}
 
 		// Update status from job
 		oldPhase := stepStatus.Phase
-		stepStatus.JobStatus = &job.Status
+		before := stepStatus.DeepCopy()
+		stepStatus.JobStatus = summarizeJobStatus(&job.Status)
+		recordJobDetails(stepStatus, job, pipeline.Spec.StepCompletion)
 
 		// Determine phase from job conditions
-		newPhase := r.determineStepPhase(job, stepStatus.Phase)
+		newPhase := r.determineStepPhase(job, stepStatus.Phase, pipeline.Spec.StepCompletion)
 
 		if oldPhase != newPhase {
+			// Results must be read before the step's pods can be cleaned up
+			if newPhase == pipelinev1.StepPhaseSucceeded && isResultsSource(pipeline, stepStatus.Name) {
+				if err := r.collectStepResults(ctx, pipeline, stepStatus); err != nil {
+					return err
+				}
+			}
+
+			// Archive logs while the Job's pods still exist
+			if isTerminalStepPhase(newPhase) {
+				r.archiveStepLogs(ctx, pipeline, stepStatus, stepStatus.JobName, stepStatus.Attempt)
+			}
+
 			stepStatus.Phase = newPhase
 			logger.Info("Step phase changed",
 				"step", stepStatus.Name,
@@ -89,14 +138,54 @@ func (r *PipelineReconciler) updateStepStatuses(ctx context.Context, pipeline *p
 				"phase", stepStatus.Phase,
 				"active", job.Status.Active)
 		}
+
+		var pod *corev1.Pod
+		if needsPodDetails(stepStatus, oldPhase) {
+			pod, err = r.stepPod(ctx, pipeline, stepStatus)
+			if err != nil {
+				return err
+			}
+			if pod != nil {
+				recordPodDetails(stepStatus, pod)
+				// Capture why the step failed before the Job's TTL removes the pod
+				if stepStatus.Phase == pipelinev1.StepPhaseFailed {
+					r.recordFailureDiagnostics(ctx, stepStatus, pod)
+				}
+			}
+		}
+
+		// A pod stuck past the grace period of spec.stuckPods fails its step early
+		if stepStatus.Phase == pipelinev1.StepPhaseRunning {
+			if err := r.failStuckStep(ctx, pipeline, stepStatus); err != nil {
+				return err
+			}
+		}
+
+		// Failures caused outside the step's code start a new attempt with a new Job
+		if oldPhase != pipelinev1.StepPhaseFailed && stepStatus.Phase == pipelinev1.StepPhaseFailed &&
+			r.autoRetry(ctx, pipeline, stepStatus, nil, stepStatus.JobName, stepStatus.Attempt, pod) {
+			resetStepAttempt(stepStatus)
+			changed = true
+			continue
+		}
+
+		// Failures are allowed once the exit codes of the failed pod are known
+		if oldPhase != pipelinev1.StepPhaseFailed && allowFailure(specStep(pipeline, stepStatus.Name), stepStatus) {
+			logger.Info("Step failure allowed", "step", stepStatus.Name, "message", stepStatus.Message)
+		}
+
+		if !equality.Semantic.DeepEqual(before, stepStatus) {
+			changed = true
+		}
+	}
+
+	// Roll up stage statuses and progress from the refreshed step phases
+	if r.updateSummaryStatus(pipeline) {
+		changed = true
 	}
 
 	if changed {
-		logger.Info("Updating pipeline status", "changedSteps", true)
-		if err := r.Status().Update(ctx, pipeline); err != nil {
-			logger.Error(err, "Failed to update pipeline status")
-			return err
-		}
+		logger.Info("Step statuses changed", "checkedJobs", checkedJobs)
 	} else {
 		logger.V(1).Info("No step status changes detected", "checkedJobs", checkedJobs)
 	}
@@ -105,22 +194,27 @@ func (r *PipelineReconciler) updateStepStatuses(ctx context.Context, pipeline *p
 }
 
 // determineStepPhase determines the step phase based on job status
-func (r *PipelineReconciler) determineStepPhase(job *batchv1.Job, currentPhase pipelinev1.StepPhase) pipelinev1.StepPhase {
+func (r *PipelineReconciler) determineStepPhase(job *batchv1.Job, currentPhase pipelinev1.StepPhase, completion pipelinev1.StepCompletion) pipelinev1.StepPhase {
 	// Check job conditions for terminal states
-	for _, condition := range job.Status.Conditions {
-		if condition.Type == batchv1.JobComplete && condition.Status == corev1.ConditionTrue {
+	if condition := jobOutcomeCondition(job, completion); condition != nil {
+		if isJobSuccessCondition(condition) {
 			log.Log.V(1).Info("Job completed successfully",
 				"job", job.Name,
+				"condition", condition.Type,
 				"completionTime", condition.LastTransitionTime)
 			return pipelinev1.StepPhaseSucceeded
-		} else if condition.Type == batchv1.JobFailed && condition.Status == corev1.ConditionTrue {
-			log.Log.Info("Job failed",
-				"job", job.Name,
-				"reason", condition.Reason,
-				"message", condition.Message,
-				"failedPods", job.Status.Failed)
-			return pipelinev1.StepPhaseFailed
-		} else if condition.Type == batchv1.JobSuspended && condition.Status == corev1.ConditionTrue {
+		}
+		log.Log.Info("Job failed",
+			"job", job.Name,
+			"condition", condition.Type,
+			"reason", condition.Reason,
+			"message", condition.Message,
+			"failedPods", job.Status.Failed)
+		return pipelinev1.StepPhaseFailed
+	}
+
+	for _, condition := range job.Status.Conditions {
+		if condition.Type == batchv1.JobSuspended && condition.Status == corev1.ConditionTrue {
 			log.Log.Info("Job suspended",
 				"job", job.Name,
 				"reason", condition.Reason,
@@ -176,7 +270,7 @@ func (r *PipelineReconciler) updateConditions(pipeline *pipelinev1.Pipeline, sta
 			Type:               "Ready",
 			Status:             metav1.ConditionFalse,
 			Reason:             "Running",
-			Message:            fmt.Sprintf("Pipeline is running (%d/%d steps completed, %d running)", completedCount, len(pipeline.Status.Steps), runningCount),
+			Message:            fmt.Sprintf("Pipeline is running (%d/%d steps completed, %d running)", completedCount, len(pipeline.Status.Steps), runningCount) + stuckStepsMessage(pipeline),
 			LastTransitionTime: now,
 		}
 
@@ -210,10 +304,10 @@ func (r *PipelineReconciler) updateConditions(pipeline *pipelinev1.Pipeline, sta
 		}
 
 	case pipelinev1.PipelinePhaseFailed:
-		// Find which steps failed
+		// Find which steps failed, leaving out allowed failures
 		failedSteps := []string{}
 		for _, step := range pipeline.Status.Steps {
-			if step.Phase == pipelinev1.StepPhaseFailed {
+			if step.Phase == pipelinev1.StepPhaseFailed && !isAllowedFailure(&step) {
 				failedSteps = append(failedSteps, step.Name)
 			}
 		}
@@ -222,6 +316,7 @@ func (r *PipelineReconciler) updateConditions(pipeline *pipelinev1.Pipeline, sta
 		if len(failedSteps) > 0 {
 			message = fmt.Sprintf("Pipeline failed (failed steps: %v)", failedSteps)
 		}
+		message += failureDiagnosticsMessage(pipeline)
 
 		condition = metav1.Condition{
 			Type:               "Ready",
@@ -245,3 +340,53 @@ func (r *PipelineReconciler) updateConditions(pipeline *pipelinev1.Pipeline, sta
 
 	meta.SetStatusCondition(&pipeline.Status.Conditions, condition)
 }
+
+// patchStatus writes the status computed during a reconcile with a single merge patch
+// Nothing is written when the status is unchanged. On a conflict the patch is rebased on
+// the latest pipeline and retried, unless another writer changed the status in the meantime
+func (r *PipelineReconciler) patchStatus(ctx context.Context, original, pipeline *pipelinev1.Pipeline) error {
+	if equality.Semantic.DeepEqual(original.Status, pipeline.Status) {
+		return nil
+	}
+
+	status := pipeline.Status.DeepCopy()
+	base := original
+	return retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		err := r.Status().Patch(ctx, pipeline, client.MergeFromWithOptions(base, client.MergeFromWithOptimisticLock{}))
+		if !apierrors.IsConflict(err) {
+			return err
+		}
+
+		latest := &pipelinev1.Pipeline{}
+		if getErr := r.reader().Get(ctx, client.ObjectKeyFromObject(pipeline), latest); getErr != nil {
+			return getErr
+		}
+		if !equality.Semantic.DeepEqual(latest.Status, base.Status) {
+			// The status this pass started from is stale, the next reconcile starts over
+			return fmt.Errorf("status of pipeline %s was changed by another writer: %v", pipeline.Name, err)
+		}
+
+		log.FromContext(ctx).V(1).Info("Retrying status update on the latest pipeline", "resourceVersion", latest.ResourceVersion)
+		base = latest
+		latest.DeepCopyInto(pipeline)
+		pipeline.Status = *status.DeepCopy()
+		return err
+	})
+}
+
+// setNotReadyCondition records why the pipeline cannot make progress in its Ready condition
+func (r *PipelineReconciler) setNotReadyCondition(pipeline *pipelinev1.Pipeline, reason, message string) {
+	log.Log.Info("Updating pipeline condition",
+		"pipeline", pipeline.Name,
+		"condition", "Ready",
+		"status", metav1.ConditionFalse,
+		"reason", reason,
+		"message", message)
+
+	meta.SetStatusCondition(&pipeline.Status.Conditions, metav1.Condition{
+		Type:    "Ready",
+		Status:  metav1.ConditionFalse,
+		Reason:  reason,
+		Message: message,
+	})
+}
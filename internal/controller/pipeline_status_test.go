@@ -17,11 +17,17 @@ limitations under the License.
 package controller
 
 import (
+	"context"
 	"testing"
 
 	batchv1 "k8s.io/api/batch/v1"
 	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/interceptor"
 
 	pipelinev1 "github.com/yaacov/jobrunner/api/v1"
 )
@@ -33,6 +39,7 @@ func TestDetermineStepPhase(t *testing.T) {
 		name         string
 		job          *batchv1.Job
 		currentPhase pipelinev1.StepPhase
+		completion   pipelinev1.StepCompletion
 		want         pipelinev1.StepPhase
 	}{
 		{
@@ -142,11 +149,87 @@ func TestDetermineStepPhase(t *testing.T) {
 			currentPhase: pipelinev1.StepPhaseRunning,
 			want:         pipelinev1.StepPhaseSucceeded,
 		},
+		{
+			name: "success criteria met succeeds while pods terminate",
+			job: &batchv1.Job{
+				ObjectMeta: metav1.ObjectMeta{Name: "test-job"},
+				Status: batchv1.JobStatus{
+					Conditions: []batchv1.JobCondition{
+						{
+							Type:   batchv1.JobSuccessCriteriaMet,
+							Status: corev1.ConditionTrue,
+							Reason: batchv1.JobReasonSuccessPolicy,
+						},
+					},
+					Active: 2,
+				},
+			},
+			currentPhase: pipelinev1.StepPhaseRunning,
+			want:         pipelinev1.StepPhaseSucceeded,
+		},
+		{
+			name: "failure target fails while pods terminate",
+			job: &batchv1.Job{
+				ObjectMeta: metav1.ObjectMeta{Name: "test-job"},
+				Status: batchv1.JobStatus{
+					Conditions: []batchv1.JobCondition{
+						{
+							Type:   batchv1.JobFailureTarget,
+							Status: corev1.ConditionTrue,
+							Reason: batchv1.JobReasonPodFailurePolicy,
+						},
+					},
+					Active: 1,
+				},
+			},
+			currentPhase: pipelinev1.StepPhaseRunning,
+			want:         pipelinev1.StepPhaseFailed,
+		},
+		{
+			name: "failure target waits for pods with PodsTerminated",
+			job: &batchv1.Job{
+				ObjectMeta: metav1.ObjectMeta{Name: "test-job"},
+				Status: batchv1.JobStatus{
+					Conditions: []batchv1.JobCondition{
+						{
+							Type:   batchv1.JobFailureTarget,
+							Status: corev1.ConditionTrue,
+							Reason: batchv1.JobReasonPodFailurePolicy,
+						},
+					},
+					Active: 1,
+				},
+			},
+			currentPhase: pipelinev1.StepPhaseRunning,
+			completion:   pipelinev1.StepCompletionPodsTerminated,
+			want:         pipelinev1.StepPhaseRunning,
+		},
+		{
+			name: "failed takes precedence over suspended",
+			job: &batchv1.Job{
+				ObjectMeta: metav1.ObjectMeta{Name: "test-job"},
+				Status: batchv1.JobStatus{
+					Conditions: []batchv1.JobCondition{
+						{
+							Type:   batchv1.JobSuspended,
+							Status: corev1.ConditionTrue,
+						},
+						{
+							Type:   batchv1.JobFailed,
+							Status: corev1.ConditionTrue,
+							Reason: batchv1.JobReasonDeadlineExceeded,
+						},
+					},
+				},
+			},
+			currentPhase: pipelinev1.StepPhaseSuspended,
+			want:         pipelinev1.StepPhaseFailed,
+		},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			got := r.determineStepPhase(tt.job, tt.currentPhase)
+			got := r.determineStepPhase(tt.job, tt.currentPhase, tt.completion)
 			if got != tt.want {
 				t.Errorf("determineStepPhase() = %v, want %v", got, tt.want)
 			}
@@ -359,3 +442,210 @@ func TestUpdateConditionsPreservesExisting(t *testing.T) {
 		t.Error("expected CustomCondition to be preserved")
 	}
 }
+
+func TestPatchStatus(t *testing.T) {
+	ctx := context.Background()
+
+	newPipeline := func() *pipelinev1.Pipeline {
+		return &pipelinev1.Pipeline{
+			ObjectMeta: metav1.ObjectMeta{Name: "release", Namespace: "default"},
+			Status: pipelinev1.PipelineStatus{
+				Phase: pipelinev1.PipelinePhaseRunning,
+				Steps: []pipelinev1.StepStatus{{Name: "build", Phase: pipelinev1.StepPhaseRunning}},
+			},
+		}
+	}
+
+	t.Run("skips unchanged status", func(t *testing.T) {
+		c := newFakeClient(newPipeline())
+		r := &PipelineReconciler{Client: c, Scheme: c.Scheme()}
+
+		pipeline := &pipelinev1.Pipeline{}
+		if err := c.Get(ctx, types.NamespacedName{Namespace: "default", Name: "release"}, pipeline); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		original := pipeline.DeepCopy()
+
+		if err := r.patchStatus(ctx, original, pipeline); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if pipeline.ResourceVersion != original.ResourceVersion {
+			t.Errorf("expected no write, resourceVersion changed from %s to %s", original.ResourceVersion, pipeline.ResourceVersion)
+		}
+	})
+
+	t.Run("writes job status changes", func(t *testing.T) {
+		c := newFakeClient(newPipeline())
+		r := &PipelineReconciler{Client: c, Scheme: c.Scheme()}
+
+		pipeline := &pipelinev1.Pipeline{}
+		if err := c.Get(ctx, types.NamespacedName{Namespace: "default", Name: "release"}, pipeline); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		original := pipeline.DeepCopy()
+		pipeline.Status.Steps[0].JobStatus = &pipelinev1.JobStatusSummary{Active: 1}
+
+		if err := r.patchStatus(ctx, original, pipeline); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		stored := &pipelinev1.Pipeline{}
+		if err := c.Get(ctx, types.NamespacedName{Namespace: "default", Name: "release"}, stored); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if jobStatus := stored.Status.Steps[0].JobStatus; jobStatus == nil || jobStatus.Active != 1 {
+			t.Errorf("expected the job status to be stored, got %+v", jobStatus)
+		}
+	})
+
+	t.Run("writes condition changes without a phase change", func(t *testing.T) {
+		c := newFakeClient(newPipeline())
+		r := &PipelineReconciler{Client: c, Scheme: c.Scheme()}
+
+		pipeline := &pipelinev1.Pipeline{}
+		if err := c.Get(ctx, types.NamespacedName{Namespace: "default", Name: "release"}, pipeline); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		original := pipeline.DeepCopy()
+		r.setNotReadyCondition(pipeline, "JobConflict", "job release-build already exists")
+
+		if err := r.patchStatus(ctx, original, pipeline); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		stored := &pipelinev1.Pipeline{}
+		if err := c.Get(ctx, types.NamespacedName{Namespace: "default", Name: "release"}, stored); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if ready := meta.FindStatusCondition(stored.Status.Conditions, "Ready"); ready == nil || ready.Reason != "JobConflict" {
+			t.Errorf("expected the condition to be stored, got %+v", ready)
+		}
+	})
+
+	t.Run("rebases on metadata changes", func(t *testing.T) {
+		c := newFakeClient(newPipeline())
+		r := &PipelineReconciler{Client: c, Scheme: c.Scheme()}
+
+		pipeline := &pipelinev1.Pipeline{}
+		if err := c.Get(ctx, types.NamespacedName{Namespace: "default", Name: "release"}, pipeline); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		original := pipeline.DeepCopy()
+		pipeline.Status.Steps[0].Phase = pipelinev1.StepPhaseSucceeded
+
+		// Someone labels the pipeline while the reconcile runs
+		labeled := original.DeepCopy()
+		labeled.Labels = map[string]string{"team": "release"}
+		if err := c.Update(ctx, labeled); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		if err := r.patchStatus(ctx, original, pipeline); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		stored := &pipelinev1.Pipeline{}
+		if err := c.Get(ctx, types.NamespacedName{Namespace: "default", Name: "release"}, stored); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if stored.Status.Steps[0].Phase != pipelinev1.StepPhaseSucceeded || stored.Labels["team"] != "release" {
+			t.Errorf("expected both the status and the label to be kept, got %s and %v", stored.Status.Steps[0].Phase, stored.Labels)
+		}
+	})
+
+	t.Run("gives up when another writer changed the status", func(t *testing.T) {
+		c := newFakeClient(newPipeline())
+		r := &PipelineReconciler{Client: c, Scheme: c.Scheme()}
+
+		pipeline := &pipelinev1.Pipeline{}
+		if err := c.Get(ctx, types.NamespacedName{Namespace: "default", Name: "release"}, pipeline); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		original := pipeline.DeepCopy()
+		pipeline.Status.Steps[0].Phase = pipelinev1.StepPhaseSucceeded
+
+		other := original.DeepCopy()
+		other.Status.Steps[0].Phase = pipelinev1.StepPhaseFailed
+		if err := c.Status().Update(ctx, other); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		if err := r.patchStatus(ctx, original, pipeline); err == nil {
+			t.Fatalf("expected an error")
+		}
+
+		stored := &pipelinev1.Pipeline{}
+		if err := c.Get(ctx, types.NamespacedName{Namespace: "default", Name: "release"}, stored); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if stored.Status.Steps[0].Phase != pipelinev1.StepPhaseFailed {
+			t.Errorf("expected the other writer's status to be kept, got %s", stored.Status.Steps[0].Phase)
+		}
+	})
+}
+
+func TestReconcileWritesStatusOnce(t *testing.T) {
+	ctx := context.Background()
+
+	pipeline := &pipelinev1.Pipeline{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:       "release",
+			Namespace:  "default",
+			Finalizers: []string{pipelineFinalizer},
+		},
+		Spec: pipelinev1.PipelineSpec{Steps: []pipelinev1.PipelineStep{
+			{Name: "build", JobSpec: &batchv1.JobSpec{Template: corev1.PodTemplateSpec{Spec: corev1.PodSpec{
+				RestartPolicy: corev1.RestartPolicyNever,
+				Containers:    []corev1.Container{{Name: "main", Image: "busybox"}},
+			}}}},
+			{Name: "test", RunIf: &pipelinev1.RunIfCondition{Steps: []string{}}, JobSpec: &batchv1.JobSpec{Template: corev1.PodTemplateSpec{Spec: corev1.PodSpec{
+				RestartPolicy: corev1.RestartPolicyNever,
+				Containers:    []corev1.Container{{Name: "main", Image: "busybox"}},
+			}}}},
+		}},
+	}
+
+	updates, patches := 0, 0
+	c := newFakeClientBuilder(pipeline).
+		WithInterceptorFuncs(interceptor.Funcs{
+			SubResourceUpdate: func(ctx context.Context, c client.Client, subResource string, obj client.Object, opts ...client.SubResourceUpdateOption) error {
+				updates++
+				return c.SubResource(subResource).Update(ctx, obj, opts...)
+			},
+			SubResourcePatch: func(ctx context.Context, c client.Client, subResource string, obj client.Object, patch client.Patch, opts ...client.SubResourcePatchOption) error {
+				patches++
+				return c.SubResource(subResource).Patch(ctx, obj, patch, opts...)
+			},
+		}).
+		Build()
+	r := &PipelineReconciler{Client: c, Scheme: c.Scheme()}
+
+	if _, err := r.Reconcile(ctx, ctrl.Request{NamespacedName: types.NamespacedName{Namespace: "default", Name: "release"}}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if updates != 0 || patches != 1 {
+		t.Errorf("expected a single status patch, got %d updates and %d patches", updates, patches)
+	}
+
+	stored := &pipelinev1.Pipeline{}
+	if err := c.Get(ctx, types.NamespacedName{Namespace: "default", Name: "release"}, stored); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(stored.Status.Steps) != 2 || stored.Status.StartTime == nil {
+		t.Fatalf("expected the initialized status to be stored, got %+v", stored.Status)
+	}
+	for _, step := range stored.Status.Steps {
+		if step.Phase != pipelinev1.StepPhaseRunning || step.JobName == "" {
+			t.Errorf("expected step %s to be running, got %s with job %q", step.Name, step.Phase, step.JobName)
+		}
+	}
+
+	// A pass that changes nothing writes nothing
+	patches = 0
+	if _, err := r.Reconcile(ctx, ctrl.Request{NamespacedName: types.NamespacedName{Namespace: "default", Name: "release"}}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if patches != 0 {
+		t.Errorf("expected no status write, got %d patches", patches)
+	}
+}
@@ -0,0 +1,223 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/utils/ptr"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	pipelinev1 "github.com/yaacov/jobrunner/api/v1"
+)
+
+// Step reasons derived from the step's pod rather than its Job
+const (
+	stepReasonOOMKilled     = "OOMKilled"
+	stepReasonUnschedulable = corev1.PodReasonUnschedulable
+)
+
+// recordJobDetails copies timings, the failure reason and the index progress of a step's
+// Job into its status
+func recordJobDetails(stepStatus *pipelinev1.StepStatus, job *batchv1.Job, completion pipelinev1.StepCompletion) {
+	stepStatus.StartTime = job.Status.StartTime
+	stepStatus.CompletionTime = job.Status.CompletionTime
+
+	// The Job's completion time is only set once its pods terminated
+	if condition := jobOutcomeCondition(job, completion); condition != nil {
+		if stepStatus.CompletionTime == nil {
+			completionTime := condition.LastTransitionTime
+			stepStatus.CompletionTime = &completionTime
+		}
+		if !isJobSuccessCondition(condition) {
+			stepStatus.Reason = condition.Reason
+			stepStatus.Message = condition.Message
+		}
+	}
+
+	stepStatus.Indexes = nil
+	if job.Spec.CompletionMode != nil && *job.Spec.CompletionMode == batchv1.IndexedCompletion {
+		stepStatus.Indexes = &pipelinev1.IndexStatus{
+			Completions: ptr.Deref(job.Spec.Completions, 1),
+			Completed:   job.Status.CompletedIndexes,
+			Failed:      ptr.Deref(job.Status.FailedIndexes, ""),
+		}
+	}
+
+	stepStatus.Duration = nil
+	if stepStatus.StartTime != nil && stepStatus.CompletionTime != nil {
+		stepStatus.Duration = roundedDuration(stepStatus.StartTime, stepStatus.CompletionTime)
+	}
+}
+
+// summarizeJobStatus keeps the pod counts and timings of a Job's status
+// Conditions and the per-index bookkeeping of indexed Jobs are left out, since a step
+// status is kept for every step of a pipeline that may have thousands; the completed and
+// failed indexes are kept on the step status as interval lists instead
+// Returns nil until the Job controller reports anything, so a new Job costs no write
+func summarizeJobStatus(status *batchv1.JobStatus) *pipelinev1.JobStatusSummary {
+	summary := &pipelinev1.JobStatusSummary{
+		StartTime:      status.StartTime,
+		CompletionTime: status.CompletionTime,
+		Active:         status.Active,
+		Ready:          status.Ready,
+		Terminating:    status.Terminating,
+		Succeeded:      status.Succeeded,
+		Failed:         status.Failed,
+	}
+	if *summary == (pipelinev1.JobStatusSummary{}) {
+		return nil
+	}
+	return summary
+}
+
+// jobOutcomeCondition returns the condition that decides a Job's outcome: Complete or
+// Failed, or with OutcomeKnown the SuccessCriteriaMet or FailureTarget condition the Job
+// gets first, while its pods are still terminating. Returns nil while the outcome is open
+func jobOutcomeCondition(job *batchv1.Job, completion pipelinev1.StepCompletion) *batchv1.JobCondition {
+	var early *batchv1.JobCondition
+	for i := range job.Status.Conditions {
+		condition := &job.Status.Conditions[i]
+		if condition.Status != corev1.ConditionTrue {
+			continue
+		}
+		switch condition.Type {
+		case batchv1.JobComplete, batchv1.JobFailed:
+			return condition
+		case batchv1.JobSuccessCriteriaMet, batchv1.JobFailureTarget:
+			if early == nil && completion != pipelinev1.StepCompletionPodsTerminated {
+				early = condition
+			}
+		}
+	}
+	return early
+}
+
+// isJobSuccessCondition returns true if a Job outcome condition reports success
+func isJobSuccessCondition(condition *batchv1.JobCondition) bool {
+	return condition.Type == batchv1.JobComplete || condition.Type == batchv1.JobSuccessCriteriaMet
+}
+
+// needsPodDetails returns true when the step's pod may have information the status lacks:
+// while a step runs, since its pod may be stuck, and once when the step finishes
+func needsPodDetails(stepStatus *pipelinev1.StepStatus, oldPhase pipelinev1.StepPhase) bool {
+	if stepStatus.Phase == pipelinev1.StepPhaseRunning {
+		return true
+	}
+	return oldPhase != stepStatus.Phase && isTerminalStepPhase(stepStatus.Phase)
+}
+
+// stepPod returns the most recent pod of the step's Job, or nil if it has none
+// A running step is checked on every pass, so its pod is read from the cache; pod
+// events bring the step back once the cache catches up
+func (r *PipelineReconciler) stepPod(ctx context.Context, pipeline *pipelinev1.Pipeline, stepStatus *pipelinev1.StepStatus) (*corev1.Pod, error) {
+	if stepStatus.Phase == pipelinev1.StepPhaseRunning {
+		return newestJobPod(ctx, r.Client, pipeline, stepStatus.JobName)
+	}
+	return r.jobPod(ctx, pipeline, stepStatus.JobName)
+}
+
+// jobPod returns the most recent pod of a Job of the pipeline, or nil if it has none
+func (r *PipelineReconciler) jobPod(ctx context.Context, pipeline *pipelinev1.Pipeline, jobName string) (*corev1.Pod, error) {
+	return newestJobPod(ctx, r.reader(), pipeline, jobName)
+}
+
+// newestJobPod lists the pods of a Job of the pipeline and returns the most recent one
+func newestJobPod(ctx context.Context, reader client.Reader, pipeline *pipelinev1.Pipeline, jobName string) (*corev1.Pod, error) {
+	podList := &corev1.PodList{}
+	if err := reader.List(ctx, podList, client.InNamespace(pipeline.Namespace), client.MatchingLabels{
+		batchv1.JobNameLabel: jobName,
+	}); err != nil {
+		log.FromContext(ctx).Error(err, "Failed to list pods for step details", "job", jobName)
+		return nil, err
+	}
+	return newestPod(podList.Items), nil
+}
+
+// recordPodDetails records the pod name, node name and container exit codes of the step's
+// most recent pod, and refines the step reason when the pod was OOM killed or is stuck
+func recordPodDetails(stepStatus *pipelinev1.StepStatus, pod *corev1.Pod) {
+	stepStatus.PodName = pod.Name
+	stepStatus.NodeName = pod.Spec.NodeName
+	stepStatus.Containers = nil
+	for _, containerStatus := range pod.Status.ContainerStatuses {
+		terminated := containerStatus.State.Terminated
+		if terminated == nil {
+			continue
+		}
+		stepStatus.Containers = append(stepStatus.Containers, pipelinev1.ContainerTermination{
+			Name:     containerStatus.Name,
+			ExitCode: terminated.ExitCode,
+			Reason:   terminated.Reason,
+		})
+		if terminated.Reason == stepReasonOOMKilled {
+			stepStatus.Reason = stepReasonOOMKilled
+			stepStatus.Message = fmt.Sprintf("container %s was OOM killed (exit code %d)", containerStatus.Name, terminated.ExitCode)
+		}
+	}
+
+	// A stuck reason only holds until the pod makes progress
+	recordStuckPod(stepStatus, pod)
+}
+
+// newestPod returns the most recently created pod, or nil if there are none
+func newestPod(pods []corev1.Pod) *corev1.Pod {
+	var newest *corev1.Pod
+	for i := range pods {
+		if newest == nil || newest.CreationTimestamp.Before(&pods[i].CreationTimestamp) {
+			newest = &pods[i]
+		}
+	}
+	return newest
+}
+
+// updateSummaryStatus refreshes the stage roll-ups and the pipeline progress
+// Returns true if either changed
+func (r *PipelineReconciler) updateSummaryStatus(pipeline *pipelinev1.Pipeline) bool {
+	stagesChanged := r.updateStageStatuses(pipeline)
+	progressChanged := updateProgress(pipeline)
+	return stagesChanged || progressChanged
+}
+
+// updateProgress sets status.progress to the number of finished steps out of the total
+// Returns true if it changed
+func updateProgress(pipeline *pipelinev1.Pipeline) bool {
+	finished := 0
+	for _, step := range pipeline.Status.Steps {
+		if isTerminalStepPhase(step.Phase) {
+			finished++
+		}
+	}
+
+	progress := fmt.Sprintf("%d/%d", finished, len(pipeline.Status.Steps))
+	if pipeline.Status.Progress == progress {
+		return false
+	}
+	pipeline.Status.Progress = progress
+	return true
+}
+
+// roundedDuration returns the time between start and end rounded to the second
+func roundedDuration(start, end *metav1.Time) *metav1.Duration {
+	return &metav1.Duration{Duration: end.Sub(start.Time).Round(time.Second)}
+}
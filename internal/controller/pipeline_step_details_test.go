@@ -0,0 +1,267 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/utils/ptr"
+
+	pipelinev1 "github.com/yaacov/jobrunner/api/v1"
+)
+
+func TestRecordJobDetails(t *testing.T) {
+	start := metav1.NewTime(time.Date(2025, 1, 1, 10, 0, 0, 0, time.UTC))
+	end := metav1.NewTime(start.Add(90*time.Second + 400*time.Millisecond))
+
+	failureTarget := batchv1.JobStatus{
+		StartTime: &start,
+		Conditions: []batchv1.JobCondition{{
+			Type:               batchv1.JobFailureTarget,
+			Status:             corev1.ConditionTrue,
+			Reason:             batchv1.JobReasonPodFailurePolicy,
+			Message:            "Container main for pod default/release-build-abc failed with exit code 42",
+			LastTransitionTime: end,
+		}},
+		Terminating: ptr.To[int32](1),
+	}
+
+	tests := []struct {
+		name         string
+		status       batchv1.JobStatus
+		completion   pipelinev1.StepCompletion
+		wantReason   string
+		wantDuration time.Duration
+	}{
+		{
+			name:   "running job has no duration",
+			status: batchv1.JobStatus{StartTime: &start, Active: 1},
+		},
+		{
+			name:         "completed job",
+			status:       batchv1.JobStatus{StartTime: &start, CompletionTime: &end},
+			wantDuration: 90 * time.Second,
+		},
+		{
+			name: "failed job uses the failure condition",
+			status: batchv1.JobStatus{
+				StartTime: &start,
+				Conditions: []batchv1.JobCondition{{
+					Type:               batchv1.JobFailed,
+					Status:             corev1.ConditionTrue,
+					Reason:             batchv1.JobReasonBackoffLimitExceeded,
+					Message:            "Job has reached the specified backoff limit",
+					LastTransitionTime: end,
+				}},
+			},
+			wantReason:   batchv1.JobReasonBackoffLimitExceeded,
+			wantDuration: 90 * time.Second,
+		},
+		{
+			name:         "failure target finishes the step while pods terminate",
+			status:       failureTarget,
+			wantReason:   batchv1.JobReasonPodFailurePolicy,
+			wantDuration: 90 * time.Second,
+		},
+		{
+			name:       "failure target waits for pods to terminate",
+			status:     failureTarget,
+			completion: pipelinev1.StepCompletionPodsTerminated,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stepStatus := &pipelinev1.StepStatus{Name: "build"}
+			recordJobDetails(stepStatus, &batchv1.Job{Status: tt.status}, tt.completion)
+
+			if stepStatus.StartTime == nil || !stepStatus.StartTime.Equal(&start) {
+				t.Errorf("expected start time %v, got %v", start, stepStatus.StartTime)
+			}
+			if stepStatus.Reason != tt.wantReason {
+				t.Errorf("expected reason %q, got %q", tt.wantReason, stepStatus.Reason)
+			}
+			if tt.wantDuration == 0 {
+				if stepStatus.Duration != nil {
+					t.Errorf("expected no duration, got %v", stepStatus.Duration)
+				}
+				return
+			}
+			if stepStatus.Duration == nil || stepStatus.Duration.Duration != tt.wantDuration {
+				t.Errorf("expected duration %v, got %v", tt.wantDuration, stepStatus.Duration)
+			}
+		})
+	}
+}
+
+func newStepPod(name string, created time.Time, status corev1.PodStatus) *corev1.Pod {
+	return &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:              name,
+			Namespace:         "default",
+			CreationTimestamp: metav1.NewTime(created),
+			Labels:            map[string]string{batchv1.JobNameLabel: "release-build"},
+		},
+		Spec:   corev1.PodSpec{NodeName: "node-" + name},
+		Status: status,
+	}
+}
+
+// recordStepPod records the details of the step's newest pod, which must exist
+func recordStepPod(t *testing.T, r *PipelineReconciler, pipeline *pipelinev1.Pipeline, stepStatus *pipelinev1.StepStatus) {
+	t.Helper()
+	pod, err := r.stepPod(context.Background(), pipeline, stepStatus)
+	if err != nil || pod == nil {
+		t.Fatalf("expected a pod for the step, got %v (error %v)", pod, err)
+	}
+	recordPodDetails(stepStatus, pod)
+}
+
+func TestSummarizeJobStatus(t *testing.T) {
+	start := metav1.NewTime(time.Date(2025, 1, 1, 10, 0, 0, 0, time.UTC))
+
+	if summary := summarizeJobStatus(&batchv1.JobStatus{}); summary != nil {
+		t.Errorf("expected no summary before the Job controller reports, got %+v", summary)
+	}
+
+	summary := summarizeJobStatus(&batchv1.JobStatus{
+		StartTime:  &start,
+		Active:     1,
+		Ready:      ptr.To[int32](1),
+		Conditions: []batchv1.JobCondition{{Type: batchv1.JobSuspended, Status: corev1.ConditionFalse}},
+	})
+	want := &pipelinev1.JobStatusSummary{StartTime: &start, Active: 1, Ready: ptr.To[int32](1)}
+	if !equality.Semantic.DeepEqual(summary, want) {
+		t.Errorf("expected %+v, got %+v", want, summary)
+	}
+}
+
+func TestRecordPodDetails(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now()
+	pipeline := &pipelinev1.Pipeline{ObjectMeta: metav1.ObjectMeta{Name: "release", Namespace: "default"}}
+
+	t.Run("records the newest pod and OOM kills", func(t *testing.T) {
+		c := newFakeClient(
+			newStepPod("first", now.Add(-time.Minute), corev1.PodStatus{}),
+			newStepPod("retry", now, corev1.PodStatus{ContainerStatuses: []corev1.ContainerStatus{{
+				Name:  "main",
+				State: corev1.ContainerState{Terminated: &corev1.ContainerStateTerminated{ExitCode: 137, Reason: "OOMKilled"}},
+			}}}),
+		)
+		r := &PipelineReconciler{Client: c}
+		stepStatus := &pipelinev1.StepStatus{
+			Name:    "build",
+			JobName: "release-build",
+			Reason:  batchv1.JobReasonBackoffLimitExceeded,
+		}
+
+		recordStepPod(t, r, pipeline, stepStatus)
+		if stepStatus.PodName != "retry" || stepStatus.NodeName != "node-retry" {
+			t.Errorf("expected the newest pod, got pod %q on node %q", stepStatus.PodName, stepStatus.NodeName)
+		}
+		if len(stepStatus.Containers) != 1 || stepStatus.Containers[0].ExitCode != 137 {
+			t.Errorf("expected exit code 137, got %+v", stepStatus.Containers)
+		}
+		if stepStatus.Reason != "OOMKilled" {
+			t.Errorf("expected reason OOMKilled, got %q", stepStatus.Reason)
+		}
+	})
+
+	t.Run("reports unschedulable pods until they are scheduled", func(t *testing.T) {
+		pod := newStepPod("pending", now, corev1.PodStatus{Conditions: []corev1.PodCondition{{
+			Type:    corev1.PodScheduled,
+			Status:  corev1.ConditionFalse,
+			Reason:  corev1.PodReasonUnschedulable,
+			Message: "0/3 nodes are available: 3 Insufficient memory.",
+		}}})
+		pod.Spec.NodeName = ""
+		c := newFakeClient(pod)
+		r := &PipelineReconciler{Client: c}
+		stepStatus := &pipelinev1.StepStatus{Name: "build", JobName: "release-build", Phase: pipelinev1.StepPhaseRunning}
+
+		recordStepPod(t, r, pipeline, stepStatus)
+		if stepStatus.Reason != "Unschedulable" || stepStatus.Message == "" {
+			t.Errorf("expected an Unschedulable reason, got %q: %q", stepStatus.Reason, stepStatus.Message)
+		}
+		if !needsPodDetails(stepStatus, pipelinev1.StepPhaseRunning) {
+			t.Error("expected an unscheduled step to be checked again")
+		}
+
+		pod.Status.Conditions[0].Status = corev1.ConditionTrue
+		if err := c.Status().Update(ctx, pod); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		pod.Spec.NodeName = "node-1"
+		if err := c.Update(ctx, pod); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		recordStepPod(t, r, pipeline, stepStatus)
+		if stepStatus.Reason != "" || stepStatus.NodeName != "node-1" {
+			t.Errorf("expected the reason to clear once scheduled, got %q on node %q", stepStatus.Reason, stepStatus.NodeName)
+		}
+	})
+}
+
+func TestRecordJobDetailsIndexes(t *testing.T) {
+	job := &batchv1.Job{
+		Spec: batchv1.JobSpec{
+			CompletionMode: ptr.To(batchv1.IndexedCompletion),
+			Completions:    ptr.To[int32](8),
+		},
+		Status: batchv1.JobStatus{CompletedIndexes: "0-3,5", FailedIndexes: ptr.To("6")},
+	}
+	stepStatus := &pipelinev1.StepStatus{Name: "shards"}
+
+	recordJobDetails(stepStatus, job, "")
+	want := pipelinev1.IndexStatus{Completions: 8, Completed: "0-3,5", Failed: "6"}
+	if stepStatus.Indexes == nil || *stepStatus.Indexes != want {
+		t.Fatalf("expected indexes %+v, got %+v", want, stepStatus.Indexes)
+	}
+
+	job.Spec.CompletionMode = ptr.To(batchv1.NonIndexedCompletion)
+	recordJobDetails(stepStatus, job, "")
+	if stepStatus.Indexes != nil {
+		t.Errorf("expected no indexes for a non-indexed job, got %+v", stepStatus.Indexes)
+	}
+}
+
+func TestUpdateProgress(t *testing.T) {
+	pipeline := &pipelinev1.Pipeline{Status: pipelinev1.PipelineStatus{Steps: []pipelinev1.StepStatus{
+		{Name: "a", Phase: pipelinev1.StepPhaseSucceeded},
+		{Name: "b", Phase: pipelinev1.StepPhaseFailed},
+		{Name: "c", Phase: pipelinev1.StepPhaseSkipped},
+		{Name: "d", Phase: pipelinev1.StepPhaseRunning},
+		{Name: "e", Phase: pipelinev1.StepPhasePending},
+	}}}
+
+	if !updateProgress(pipeline) {
+		t.Error("expected progress to change")
+	}
+	if pipeline.Status.Progress != "3/5" {
+		t.Errorf("expected progress 3/5, got %q", pipeline.Status.Progress)
+	}
+	if updateProgress(pipeline) {
+		t.Error("expected progress to be unchanged")
+	}
+}
@@ -0,0 +1,205 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	pipelinev1 "github.com/yaacov/jobrunner/api/v1"
+)
+
+// defaultStuckGracePeriod applies when spec.stuckPods does not set gracePeriod
+const defaultStuckGracePeriod = 5 * time.Minute
+
+// stuckWaitingReasons maps the waiting reasons of a container that cannot make progress
+// on its own to the stuck state failFastOn names them by
+var stuckWaitingReasons = map[string]pipelinev1.StuckReason{
+	"ErrImagePull":               pipelinev1.StuckImagePullBackOff,
+	"ImagePullBackOff":           pipelinev1.StuckImagePullBackOff,
+	"InvalidImageName":           pipelinev1.StuckImagePullBackOff,
+	"ErrImageNeverPull":          pipelinev1.StuckImagePullBackOff,
+	"CrashLoopBackOff":           pipelinev1.StuckCrashLoopBackOff,
+	"CreateContainerConfigError": "",
+	"CreateContainerError":       "",
+}
+
+// isStuckReason returns true if a step reason reports a pod that is stuck
+func isStuckReason(reason string) bool {
+	_, ok := stuckWaitingReasons[reason]
+	return ok || reason == stepReasonUnschedulable
+}
+
+// stuckState returns the stuck state failFastOn names a step reason by, or "" if the
+// reason is not a stuck state failFastOn covers
+func stuckState(reason string) pipelinev1.StuckReason {
+	if reason == stepReasonUnschedulable {
+		return pipelinev1.StuckUnschedulable
+	}
+	return stuckWaitingReasons[reason]
+}
+
+// sameStuckState returns true if two stuck reasons describe the same stuck state, as
+// ErrImagePull and ImagePullBackOff do
+func sameStuckState(a, b string) bool {
+	if state := stuckState(a); state != "" {
+		return state == stuckState(b)
+	}
+	return a == b
+}
+
+// stuckPodReason returns why a pod cannot make progress: Unschedulable while the scheduler
+// cannot place it, or the waiting reason of a container such as ImagePullBackOff
+// Returns "" if the pod is not stuck
+func stuckPodReason(pod *corev1.Pod) (reason string, message string) {
+	for _, condition := range pod.Status.Conditions {
+		if condition.Type == corev1.PodScheduled && condition.Status == corev1.ConditionFalse &&
+			condition.Reason == corev1.PodReasonUnschedulable {
+			return stepReasonUnschedulable, condition.Message
+		}
+	}
+
+	statuses := append(append([]corev1.ContainerStatus{}, pod.Status.InitContainerStatuses...), pod.Status.ContainerStatuses...)
+	for _, containerStatus := range statuses {
+		waiting := containerStatus.State.Waiting
+		if waiting == nil {
+			continue
+		}
+		if _, ok := stuckWaitingReasons[waiting.Reason]; !ok {
+			continue
+		}
+		message := fmt.Sprintf("container %s is waiting", containerStatus.Name)
+		if waiting.Message != "" {
+			message += ": " + waiting.Message
+		}
+		return waiting.Reason, message
+	}
+	return "", ""
+}
+
+// recordStuckPod reports a stuck pod in the step's reason and message, keeping when it
+// got stuck in stuckSince. A stuck reason is cleared once the pod makes progress
+func recordStuckPod(stepStatus *pipelinev1.StepStatus, pod *corev1.Pod) {
+	previous := ""
+	if isStuckReason(stepStatus.Reason) {
+		previous = stepStatus.Reason
+		stepStatus.Reason = ""
+		stepStatus.Message = ""
+	}
+
+	reason, message := stuckPodReason(pod)
+	if reason == "" || stepStatus.Reason != "" {
+		stepStatus.StuckSince = nil
+		return
+	}
+	stepStatus.Reason = reason
+	stepStatus.Message = message
+
+	// A pod moving from ErrImagePull to ImagePullBackOff is still stuck on the same thing
+	if stepStatus.StuckSince == nil || !sameStuckState(previous, reason) {
+		now := metav1.Now()
+		stepStatus.StuckSince = &now
+	}
+}
+
+// stuckGracePeriod returns how long a step's pod may stay stuck in its current state
+// before the step fails, or false if spec.stuckPods does not fail steps on that state
+func stuckGracePeriod(pipeline *pipelinev1.Pipeline, stepStatus *pipelinev1.StepStatus) (time.Duration, bool) {
+	policy := pipeline.Spec.StuckPods
+	if policy == nil || stepStatus.Phase != pipelinev1.StepPhaseRunning || stepStatus.StuckSince == nil {
+		return 0, false
+	}
+	state := stuckState(stepStatus.Reason)
+	if state == "" || !slices.Contains(policy.FailFastOn, state) {
+		return 0, false
+	}
+	if policy.GracePeriod == nil {
+		return defaultStuckGracePeriod, true
+	}
+	return policy.GracePeriod.Duration, true
+}
+
+// failStuckStep fails a running step whose pod stayed stuck past the grace period of
+// spec.stuckPods, deleting its Job. The stuck reason is kept as the step's reason
+func (r *PipelineReconciler) failStuckStep(ctx context.Context, pipeline *pipelinev1.Pipeline, stepStatus *pipelinev1.StepStatus) error {
+	gracePeriod, ok := stuckGracePeriod(pipeline, stepStatus)
+	if !ok || time.Since(stepStatus.StuckSince.Time) < gracePeriod {
+		return nil
+	}
+
+	if err := r.cancelJob(ctx, pipeline, stepStatus, stepStatus.JobName, stepStatus.Attempt); err != nil {
+		return err
+	}
+	now := metav1.Now()
+	stepStatus.Phase = pipelinev1.StepPhaseFailed
+	stepStatus.Message = fmt.Sprintf("stuck in %s for more than %s: %s", stepStatus.Reason, gracePeriod, stepStatus.Message)
+	stepStatus.CompletionTime = &now
+	if stepStatus.StartTime != nil {
+		stepStatus.Duration = roundedDuration(stepStatus.StartTime, stepStatus.CompletionTime)
+	}
+	log.FromContext(ctx).Info("Step failed, pod stuck",
+		"step", stepStatus.Name,
+		"job", stepStatus.JobName,
+		"reason", stepStatus.Reason,
+		"stuckSince", stepStatus.StuckSince)
+	return nil
+}
+
+// isStuckFailure returns true if a step was failed by spec.stuckPods; its Job is being
+// deleted and must not bring it back
+func isStuckFailure(stepStatus *pipelinev1.StepStatus) bool {
+	return stepStatus.Phase == pipelinev1.StepPhaseFailed && stepStatus.StuckSince != nil && isStuckReason(stepStatus.Reason)
+}
+
+// stuckRequeueAfter returns how long until the next running step outlasts the grace
+// period of spec.stuckPods, or 0 if no step is waiting on it
+func stuckRequeueAfter(pipeline *pipelinev1.Pipeline) time.Duration {
+	var next time.Duration
+	for i := range pipeline.Status.Steps {
+		stepStatus := &pipeline.Status.Steps[i]
+		gracePeriod, ok := stuckGracePeriod(pipeline, stepStatus)
+		if !ok {
+			continue
+		}
+		remaining := max(time.Until(stepStatus.StuckSince.Add(gracePeriod)), time.Second)
+		if next == 0 || remaining < next {
+			next = remaining
+		}
+	}
+	return next
+}
+
+// stuckStepsMessage lists the running steps whose pods are stuck, for the Ready condition
+func stuckStepsMessage(pipeline *pipelinev1.Pipeline) string {
+	var stuck []string
+	for _, step := range pipeline.Status.Steps {
+		if step.Phase == pipelinev1.StepPhaseRunning && isStuckReason(step.Reason) {
+			stuck = append(stuck, fmt.Sprintf("%s: %s", step.Name, step.Reason))
+		}
+	}
+	if len(stuck) == 0 {
+		return ""
+	}
+	return fmt.Sprintf("; stuck steps: %s", strings.Join(stuck, ", "))
+}
@@ -0,0 +1,198 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+
+	pipelinev1 "github.com/yaacov/jobrunner/api/v1"
+)
+
+// waitingContainer returns the status of a container waiting with a reason
+func waitingContainer(name, reason string) corev1.ContainerStatus {
+	return corev1.ContainerStatus{
+		Name:  name,
+		State: corev1.ContainerState{Waiting: &corev1.ContainerStateWaiting{Reason: reason, Message: "Back-off pulling image"}},
+	}
+}
+
+func TestRecordStuckPod(t *testing.T) {
+	earlier := metav1.NewTime(time.Now().Add(-time.Hour).Truncate(time.Second))
+
+	tests := []struct {
+		name         string
+		reason       string
+		waiting      string
+		wantReason   string
+		wantKeptTime bool
+	}{
+		{name: "image pull failure is reported", waiting: "ErrImagePull", wantReason: "ErrImagePull"},
+		{name: "back-off keeps the time the pull first failed", reason: "ErrImagePull", waiting: "ImagePullBackOff", wantReason: "ImagePullBackOff", wantKeptTime: true},
+		{name: "another stuck state starts over", reason: "ImagePullBackOff", waiting: "CrashLoopBackOff", wantReason: "CrashLoopBackOff"},
+		{name: "stuck reason clears once the container starts", reason: "ImagePullBackOff", waiting: "ContainerCreating"},
+		{name: "job failure reason is kept", reason: "DeadlineExceeded", waiting: "ImagePullBackOff", wantReason: "DeadlineExceeded"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stepStatus := &pipelinev1.StepStatus{Name: "build", Phase: pipelinev1.StepPhaseRunning, Reason: tt.reason}
+			if isStuckReason(tt.reason) {
+				stepStatus.StuckSince = earlier.DeepCopy()
+			}
+			pod := newStepPod("release-build-abc", time.Now(), corev1.PodStatus{
+				ContainerStatuses: []corev1.ContainerStatus{waitingContainer("main", tt.waiting)},
+			})
+
+			recordStuckPod(stepStatus, pod)
+			if stepStatus.Reason != tt.wantReason {
+				t.Fatalf("expected reason %q, got %q", tt.wantReason, stepStatus.Reason)
+			}
+			stuck := isStuckReason(tt.wantReason)
+			if (stepStatus.StuckSince != nil) != stuck {
+				t.Fatalf("expected stuckSince set %v, got %v", stuck, stepStatus.StuckSince)
+			}
+			if stuck && stepStatus.StuckSince.Equal(&earlier) != tt.wantKeptTime {
+				t.Errorf("expected the earlier stuckSince kept %v, got %v", tt.wantKeptTime, stepStatus.StuckSince)
+			}
+			if stuck && !strings.HasPrefix(stepStatus.Message, "container main is waiting") {
+				t.Errorf("unexpected message %q", stepStatus.Message)
+			}
+		})
+	}
+}
+
+func TestUpdateStepStatusesStuckPod(t *testing.T) {
+	ctx := context.Background()
+
+	tests := []struct {
+		name        string
+		failFastOn  []pipelinev1.StuckReason
+		stuckFor    time.Duration
+		wantPhase   pipelinev1.StepPhase
+		wantRequeue bool
+	}{
+		{
+			name:       "stuck past the grace period fails the step",
+			failFastOn: []pipelinev1.StuckReason{pipelinev1.StuckImagePullBackOff},
+			stuckFor:   2 * time.Minute,
+			wantPhase:  pipelinev1.StepPhaseFailed,
+		},
+		{
+			name:        "stuck within the grace period requeues",
+			failFastOn:  []pipelinev1.StuckReason{pipelinev1.StuckImagePullBackOff},
+			stuckFor:    10 * time.Second,
+			wantPhase:   pipelinev1.StepPhaseRunning,
+			wantRequeue: true,
+		},
+		{
+			name:       "other stuck states keep running",
+			failFastOn: []pipelinev1.StuckReason{pipelinev1.StuckUnschedulable},
+			stuckFor:   2 * time.Minute,
+			wantPhase:  pipelinev1.StepPhaseRunning,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stuckSince := metav1.NewTime(time.Now().Add(-tt.stuckFor))
+			pipeline := &pipelinev1.Pipeline{
+				ObjectMeta: metav1.ObjectMeta{Name: "release", Namespace: "default", UID: types.UID("release-uid")},
+				Spec: pipelinev1.PipelineSpec{
+					StuckPods: &pipelinev1.StuckPodPolicy{FailFastOn: tt.failFastOn, GracePeriod: &metav1.Duration{Duration: time.Minute}},
+					Steps:     []pipelinev1.PipelineStep{{Name: "build"}},
+				},
+				Status: pipelinev1.PipelineStatus{
+					Phase: pipelinev1.PipelinePhaseRunning,
+					Steps: []pipelinev1.StepStatus{{
+						Name:       "build",
+						Phase:      pipelinev1.StepPhaseRunning,
+						JobName:    "release-build",
+						Attempt:    1,
+						Reason:     "ImagePullBackOff",
+						StuckSince: &stuckSince,
+					}},
+				},
+			}
+			job := &batchv1.Job{
+				ObjectMeta: metav1.ObjectMeta{Name: "release-build", Namespace: "default", Labels: map[string]string{runUIDLabel: "release-uid"}},
+				Status:     batchv1.JobStatus{Active: 1},
+			}
+			pod := newStepPod("release-build-abc", time.Now(), corev1.PodStatus{
+				ContainerStatuses: []corev1.ContainerStatus{waitingContainer("main", "ImagePullBackOff")},
+			})
+			c := newFakeClient(pipeline)
+			if err := controllerutil.SetControllerReference(pipeline, job, c.Scheme()); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			for _, obj := range []client.Object{job, pod} {
+				if err := c.Create(ctx, obj); err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+			}
+			r := &PipelineReconciler{Client: c, Scheme: c.Scheme()}
+
+			if err := r.updateStepStatuses(ctx, pipeline); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			build := r.getStepStatus(pipeline, "build")
+			if build.Phase != tt.wantPhase || build.Reason != "ImagePullBackOff" {
+				t.Fatalf("expected build %s with reason ImagePullBackOff, got %s with %q", tt.wantPhase, build.Phase, build.Reason)
+			}
+			if requeue := stuckRequeueAfter(pipeline); (requeue > 0) != tt.wantRequeue || requeue > time.Minute {
+				t.Errorf("expected requeue %v, got %s", tt.wantRequeue, requeue)
+			}
+
+			if tt.wantPhase == pipelinev1.StepPhaseRunning {
+				r.updateConditions(pipeline, r.analyzePipelineState(pipeline))
+				ready := meta.FindStatusCondition(pipeline.Status.Conditions, "Ready")
+				if ready == nil || !strings.Contains(ready.Message, "stuck steps: build: ImagePullBackOff") {
+					t.Errorf("expected the Ready condition to report the stuck step, got %+v", ready)
+				}
+				return
+			}
+
+			err := c.Get(ctx, types.NamespacedName{Namespace: "default", Name: "release-build"}, &batchv1.Job{})
+			if !apierrors.IsNotFound(err) {
+				t.Errorf("expected the stuck step's job to be deleted, got %v", err)
+			}
+			// The Job is still listed while it is being deleted
+			completed := build.CompletionTime.DeepCopy()
+			job.ResourceVersion = ""
+			if err := c.Create(ctx, job); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if err := r.updateStepStatuses(ctx, pipeline); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if build := r.getStepStatus(pipeline, "build"); build.Phase != pipelinev1.StepPhaseFailed || !build.CompletionTime.Equal(completed) {
+				t.Errorf("expected the stuck step to stay Failed as recorded, got %s completed at %v", build.Phase, build.CompletionTime)
+			}
+		})
+	}
+}
@@ -0,0 +1,172 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+
+	batchv1 "k8s.io/api/batch/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	pipelinev1 "github.com/yaacov/jobrunner/api/v1"
+)
+
+// templateParamPattern matches $(params.<name>) placeholders in a template body
+var templateParamPattern = regexp.MustCompile(`\$\(params\.([a-zA-Z_][a-zA-Z0-9_-]*)\)`)
+
+// resolveStepTemplates resolves the templates referenced by the pipeline's steps
+// and pins the rendered job body in each step status, so later template edits
+// do not change a pipeline that has already started
+func (r *PipelineReconciler) resolveStepTemplates(ctx context.Context, pipeline *pipelinev1.Pipeline) error {
+	logger := log.FromContext(ctx)
+
+	g := newPipelineGraph(pipeline)
+	for i := range g.steps {
+		step := g.steps[i]
+		if step.TemplateRef == nil {
+			continue
+		}
+
+		resolved, err := r.resolveStepTemplate(ctx, pipeline.Namespace, step.TemplateRef)
+		if err != nil {
+			return fmt.Errorf("step %q: %w", step.Name, err)
+		}
+
+		stepStatus := g.stepStatus(step.Name)
+		stepStatus.Template = resolved
+
+		logger.Info("Resolved step template",
+			"step", step.Name,
+			"kind", resolved.Kind,
+			"template", resolved.Name,
+			"resourceVersion", resolved.ResourceVersion)
+	}
+
+	return nil
+}
+
+// resolveStepTemplate fetches a template and renders it with the given parameters
+func (r *PipelineReconciler) resolveStepTemplate(ctx context.Context, namespace string, ref *pipelinev1.StepTemplateRef) (*pipelinev1.ResolvedStepTemplate, error) {
+	kind := ref.GetKind()
+
+	var spec *pipelinev1.StepTemplateSpec
+	var objectMeta *metav1.ObjectMeta
+
+	switch kind {
+	case pipelinev1.StepTemplateKindNamespaced:
+		template := &pipelinev1.StepTemplate{}
+		if err := r.Get(ctx, types.NamespacedName{Namespace: namespace, Name: ref.Name}, template); err != nil {
+			if apierrors.IsNotFound(err) {
+				return nil, fmt.Errorf("%s %q not found in namespace %q", kind, ref.Name, namespace)
+			}
+			return nil, err
+		}
+		spec, objectMeta = &template.Spec, &template.ObjectMeta
+
+	case pipelinev1.StepTemplateKindCluster:
+		template := &pipelinev1.ClusterStepTemplate{}
+		if err := r.Get(ctx, types.NamespacedName{Name: ref.Name}, template); err != nil {
+			if apierrors.IsNotFound(err) {
+				return nil, fmt.Errorf("%s %q not found", kind, ref.Name)
+			}
+			return nil, err
+		}
+		spec, objectMeta = &template.Spec, &template.ObjectMeta
+
+	default:
+		return nil, fmt.Errorf("unsupported template kind %q", kind)
+	}
+
+	jobSpec, err := renderStepTemplate(spec, ref.Params)
+	if err != nil {
+		return nil, fmt.Errorf("%s %q: %w", kind, ref.Name, err)
+	}
+
+	return &pipelinev1.ResolvedStepTemplate{
+		Kind:            kind,
+		Name:            ref.Name,
+		ResourceVersion: objectMeta.ResourceVersion,
+		Generation:      objectMeta.Generation,
+		JobSpec:         jobSpec,
+	}, nil
+}
+
+// renderStepTemplate substitutes $(params.<name>) placeholders in the template job body
+// Unknown parameters, missing required parameters and unresolved placeholders are errors
+func renderStepTemplate(spec *pipelinev1.StepTemplateSpec, params []pipelinev1.TemplateParam) (*batchv1.JobSpec, error) {
+	values := map[string]string{}
+	for _, declared := range spec.Params {
+		if declared.Default != nil {
+			values[declared.Name] = *declared.Default
+		}
+	}
+
+	declared := map[string]bool{}
+	for _, p := range spec.Params {
+		declared[p.Name] = true
+	}
+	for _, p := range params {
+		if !declared[p.Name] {
+			return nil, fmt.Errorf("unknown parameter %q", p.Name)
+		}
+		values[p.Name] = p.Value
+	}
+
+	missing := []string{}
+	for _, p := range spec.Params {
+		if _, ok := values[p.Name]; !ok {
+			missing = append(missing, p.Name)
+		}
+	}
+	if len(missing) > 0 {
+		return nil, fmt.Errorf("missing required parameters: %s", strings.Join(missing, ", "))
+	}
+
+	raw, err := json.Marshal(spec.JobSpec)
+	if err != nil {
+		return nil, err
+	}
+
+	var unresolved []string
+	rendered := templateParamPattern.ReplaceAllStringFunc(string(raw), func(placeholder string) string {
+		name := templateParamPattern.FindStringSubmatch(placeholder)[1]
+		value, ok := values[name]
+		if !ok {
+			unresolved = append(unresolved, name)
+			return placeholder
+		}
+		// Escape the value so it stays a valid JSON string fragment
+		escaped, _ := json.Marshal(value)
+		return string(escaped[1 : len(escaped)-1])
+	})
+	if len(unresolved) > 0 {
+		return nil, fmt.Errorf("undeclared parameters referenced: %s", strings.Join(unresolved, ", "))
+	}
+
+	jobSpec := &batchv1.JobSpec{}
+	if err := json.Unmarshal([]byte(rendered), jobSpec); err != nil {
+		return nil, fmt.Errorf("rendering template: %w", err)
+	}
+	return jobSpec, nil
+}
@@ -0,0 +1,210 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	pipelinev1 "github.com/yaacov/jobrunner/api/v1"
+)
+
+func TestRenderStepTemplate(t *testing.T) {
+	spec := &pipelinev1.StepTemplateSpec{
+		Params: []pipelinev1.StepTemplateParam{
+			{Name: "url"},
+			{Name: "revision", Default: stringPtr("main")},
+		},
+		JobSpec: batchv1.JobSpec{
+			Template: corev1.PodTemplateSpec{
+				Spec: corev1.PodSpec{
+					Containers: []corev1.Container{{
+						Name:    "main",
+						Image:   "alpine/git",
+						Command: []string{"git", "clone", "--branch", "$(params.revision)", "$(params.url)"},
+					}},
+				},
+			},
+		},
+	}
+
+	tests := []struct {
+		name        string
+		spec        *pipelinev1.StepTemplateSpec
+		params      []pipelinev1.TemplateParam
+		wantCommand []string
+		wantErr     string
+	}{
+		{
+			name:        "substitutes params and defaults",
+			spec:        spec,
+			params:      []pipelinev1.TemplateParam{{Name: "url", Value: "https://example.com/repo.git"}},
+			wantCommand: []string{"git", "clone", "--branch", "main", "https://example.com/repo.git"},
+		},
+		{
+			name: "overrides defaults",
+			spec: spec,
+			params: []pipelinev1.TemplateParam{
+				{Name: "url", Value: "u"},
+				{Name: "revision", Value: "v1.0"},
+			},
+			wantCommand: []string{"git", "clone", "--branch", "v1.0", "u"},
+		},
+		{
+			name:        "escapes values containing quotes",
+			spec:        spec,
+			params:      []pipelinev1.TemplateParam{{Name: "url", Value: `say "hi"`}},
+			wantCommand: []string{"git", "clone", "--branch", "main", `say "hi"`},
+		},
+		{
+			name:    "errors on missing required param",
+			spec:    spec,
+			wantErr: "missing required parameters: url",
+		},
+		{
+			name: "errors on unknown param",
+			spec: spec,
+			params: []pipelinev1.TemplateParam{
+				{Name: "url", Value: "u"},
+				{Name: "other", Value: "x"},
+			},
+			wantErr: `unknown parameter "other"`,
+		},
+		{
+			name: "errors on undeclared placeholder",
+			spec: &pipelinev1.StepTemplateSpec{
+				JobSpec: batchv1.JobSpec{
+					Template: corev1.PodTemplateSpec{
+						Spec: corev1.PodSpec{
+							Containers: []corev1.Container{{Name: "main", Image: "$(params.image)"}},
+						},
+					},
+				},
+			},
+			wantErr: "undeclared parameters referenced: image",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			jobSpec, err := renderStepTemplate(tt.spec, tt.params)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("expected error containing %q, got %v", tt.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			got := jobSpec.Template.Spec.Containers[0].Command
+			if strings.Join(got, "|") != strings.Join(tt.wantCommand, "|") {
+				t.Errorf("expected command %v, got %v", tt.wantCommand, got)
+			}
+		})
+	}
+}
+
+func TestResolveStepTemplates(t *testing.T) {
+	jobSpec := batchv1.JobSpec{
+		Template: corev1.PodTemplateSpec{
+			Spec: corev1.PodSpec{
+				Containers: []corev1.Container{{Name: "main", Image: "$(params.image)"}},
+			},
+		},
+	}
+	params := []pipelinev1.StepTemplateParam{{Name: "image", Default: stringPtr("busybox")}}
+
+	r := &PipelineReconciler{Client: newFakeClient(
+		&pipelinev1.StepTemplate{
+			ObjectMeta: metav1.ObjectMeta{Name: "local", Namespace: "default", Generation: 3},
+			Spec:       pipelinev1.StepTemplateSpec{Params: params, JobSpec: jobSpec},
+		},
+		&pipelinev1.ClusterStepTemplate{
+			ObjectMeta: metav1.ObjectMeta{Name: "shared"},
+			Spec:       pipelinev1.StepTemplateSpec{Params: params, JobSpec: jobSpec},
+		},
+	)}
+
+	newPipeline := func(refs ...*pipelinev1.StepTemplateRef) *pipelinev1.Pipeline {
+		pipeline := &pipelinev1.Pipeline{ObjectMeta: metav1.ObjectMeta{Name: "test", Namespace: "default"}}
+		for i, ref := range refs {
+			name := string(rune('a' + i))
+			pipeline.Spec.Steps = append(pipeline.Spec.Steps, pipelinev1.PipelineStep{Name: name, TemplateRef: ref})
+			pipeline.Status.Steps = append(pipeline.Status.Steps, pipelinev1.StepStatus{Name: name})
+		}
+		return pipeline
+	}
+
+	t.Run("pins namespaced and cluster templates", func(t *testing.T) {
+		pipeline := newPipeline(
+			&pipelinev1.StepTemplateRef{Name: "local"},
+			&pipelinev1.StepTemplateRef{Kind: pipelinev1.StepTemplateKindCluster, Name: "shared",
+				Params: []pipelinev1.TemplateParam{{Name: "image", Value: "fedora"}}},
+		)
+
+		if err := r.resolveStepTemplates(context.Background(), pipeline); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		local := pipeline.Status.Steps[0].Template
+		if local == nil || local.Kind != pipelinev1.StepTemplateKindNamespaced || local.Generation != 3 || local.ResourceVersion == "" {
+			t.Errorf("expected pinned namespaced template, got %+v", local)
+		}
+		if local.JobSpec.Template.Spec.Containers[0].Image != "busybox" {
+			t.Errorf("expected default image, got %s", local.JobSpec.Template.Spec.Containers[0].Image)
+		}
+
+		shared := pipeline.Status.Steps[1].Template
+		if shared == nil || shared.Kind != pipelinev1.StepTemplateKindCluster {
+			t.Fatalf("expected pinned cluster template, got %+v", shared)
+		}
+		if shared.JobSpec.Template.Spec.Containers[0].Image != "fedora" {
+			t.Errorf("expected image fedora, got %s", shared.JobSpec.Template.Spec.Containers[0].Image)
+		}
+	})
+
+	t.Run("errors on missing template", func(t *testing.T) {
+		pipeline := newPipeline(&pipelinev1.StepTemplateRef{Name: "missing"})
+
+		err := r.resolveStepTemplates(context.Background(), pipeline)
+		if err == nil || !strings.Contains(err.Error(), `StepTemplate "missing" not found`) {
+			t.Errorf("expected not found error, got %v", err)
+		}
+	})
+
+	t.Run("pinned template is used when the step starts", func(t *testing.T) {
+		pipeline := newPipeline(&pipelinev1.StepTemplateRef{Name: "local"})
+		if err := r.resolveStepTemplates(context.Background(), pipeline); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		pipeline.Status.Steps[0].Template.JobSpec.Template.Spec.Containers[0].Image = "pinned"
+
+		resolved, err := r.resolveJobSpec(context.Background(), pipeline, &pipeline.Spec.Steps[0], &pipeline.Status.Steps[0])
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if resolved.Template.Spec.Containers[0].Image != "pinned" {
+			t.Errorf("expected pinned job spec, got image %s", resolved.Template.Spec.Containers[0].Image)
+		}
+	})
+}
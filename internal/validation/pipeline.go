@@ -0,0 +1,548 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package validation checks Pipeline specs for problems the CRD schema cannot express.
+// It is shared by the admission webhook and the controller.
+package validation
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	utilvalidation "k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+
+	pipelinev1 "github.com/yaacov/jobrunner/api/v1"
+)
+
+// stepEntry is a step together with its location in the spec
+type stepEntry struct {
+	step  *pipelinev1.PipelineStep
+	path  *field.Path
+	stage *pipelinev1.PipelineStage
+}
+
+// ValidatePipeline checks a pipeline spec for problems the CRD schema cannot express:
+// duplicate names, references to unknown steps or stages, dependency cycles,
+// Jobs the API server would reject once spec.jobDefaults apply, allowed exit codes
+// without allowFailure, and stage steps, which the CRD stores schemaless
+func ValidatePipeline(pipeline *pipelinev1.Pipeline) field.ErrorList {
+	specPath := field.NewPath("spec")
+	entries := stepEntries(pipeline, specPath)
+
+	allErrs := validateNames(pipeline, entries, specPath)
+	allErrs = append(allErrs, validateReferences(pipeline, entries, specPath)...)
+
+	// Cycles are only meaningful once every reference resolves
+	if len(allErrs) == 0 {
+		allErrs = append(allErrs, validateNoCycles(pipeline, entries, specPath)...)
+	}
+
+	allErrs = append(allErrs, validateJobs(pipeline, entries)...)
+	allErrs = append(allErrs, validateAllowFailure(entries)...)
+	allErrs = append(allErrs, validateStageSteps(pipeline, entries, specPath)...)
+	return allErrs
+}
+
+// stepEntries lists the top-level steps followed by the steps of each stage
+func stepEntries(pipeline *pipelinev1.Pipeline, specPath *field.Path) []stepEntry {
+	entries := []stepEntry{}
+	for i := range pipeline.Spec.Steps {
+		entries = append(entries, stepEntry{
+			step: &pipeline.Spec.Steps[i],
+			path: specPath.Child("steps").Index(i),
+		})
+	}
+	for i := range pipeline.Spec.Stages {
+		stage := &pipeline.Spec.Stages[i]
+		for j := range stage.Steps {
+			entries = append(entries, stepEntry{
+				step:  &stage.Steps[j],
+				path:  specPath.Child("stages").Index(i).Child("steps").Index(j),
+				stage: stage,
+			})
+		}
+	}
+	return entries
+}
+
+// validateNames rejects duplicate names; steps and stages share one namespace
+func validateNames(pipeline *pipelinev1.Pipeline, entries []stepEntry, specPath *field.Path) field.ErrorList {
+	var allErrs field.ErrorList
+	seen := map[string]bool{}
+
+	for _, entry := range entries {
+		if seen[entry.step.Name] {
+			allErrs = append(allErrs, field.Duplicate(entry.path.Child("name"), entry.step.Name))
+		}
+		seen[entry.step.Name] = true
+	}
+	for i, stage := range pipeline.Spec.Stages {
+		if seen[stage.Name] {
+			allErrs = append(allErrs, field.Duplicate(specPath.Child("stages").Index(i).Child("name"), stage.Name))
+		}
+		seen[stage.Name] = true
+	}
+
+	return allErrs
+}
+
+// validateReferences checks that runIf, dependsOn and forEach point at existing steps and stages
+func validateReferences(pipeline *pipelinev1.Pipeline, entries []stepEntry, specPath *field.Path) field.ErrorList {
+	var allErrs field.ErrorList
+
+	steps := map[string]*pipelinev1.PipelineStage{}
+	for _, entry := range entries {
+		steps[entry.step.Name] = entry.stage
+	}
+	stages := map[string]bool{}
+	for _, stage := range pipeline.Spec.Stages {
+		stages[stage.Name] = true
+	}
+
+	for _, entry := range entries {
+		step := entry.step
+
+		if step.RunIf != nil {
+			runIfPath := entry.path.Child("runIf")
+			for i, name := range step.RunIf.Steps {
+				path := runIfPath.Child("steps").Index(i)
+				if _, ok := steps[name]; !ok {
+					allErrs = append(allErrs, field.NotFound(path, name))
+				} else if name == step.Name {
+					allErrs = append(allErrs, field.Invalid(path, name, "a step cannot depend on itself"))
+				}
+			}
+			for i, name := range step.RunIf.Stages {
+				path := runIfPath.Child("stages").Index(i)
+				if !stages[name] {
+					allErrs = append(allErrs, field.NotFound(path, name))
+				} else if entry.stage != nil && name == entry.stage.Name {
+					allErrs = append(allErrs, field.Invalid(path, name, "a step cannot depend on the stage that contains it"))
+				}
+			}
+		}
+
+		if step.ForEach != "" {
+			path := entry.path.Child("forEach")
+			sourceName, _, ok := step.ForEachSource()
+			if !ok {
+				allErrs = append(allErrs, field.Invalid(path, step.ForEach, "must be $(steps.<step>.results.<name>)"))
+			} else if _, exists := steps[sourceName]; !exists {
+				allErrs = append(allErrs, field.NotFound(path, step.ForEach))
+			} else if sourceName == step.Name {
+				allErrs = append(allErrs, field.Invalid(path, step.ForEach, "a step cannot iterate over its own results"))
+			}
+		}
+	}
+
+	for i := range pipeline.Spec.Stages {
+		stage := &pipeline.Spec.Stages[i]
+		stagePath := specPath.Child("stages").Index(i)
+
+		for j, name := range stage.DependsOn {
+			path := stagePath.Child("dependsOn").Index(j)
+			if !stages[name] {
+				allErrs = append(allErrs, field.NotFound(path, name))
+			} else if name == stage.Name {
+				allErrs = append(allErrs, field.Invalid(path, name, "a stage cannot depend on itself"))
+			}
+		}
+
+		if stage.RunIf != nil {
+			runIfPath := stagePath.Child("runIf")
+			for j, name := range stage.RunIf.Steps {
+				path := runIfPath.Child("steps").Index(j)
+				if owner, ok := steps[name]; !ok {
+					allErrs = append(allErrs, field.NotFound(path, name))
+				} else if owner == stage {
+					allErrs = append(allErrs, field.Invalid(path, name, "a stage cannot depend on its own steps"))
+				}
+			}
+			for j, name := range stage.RunIf.Stages {
+				path := runIfPath.Child("stages").Index(j)
+				if !stages[name] {
+					allErrs = append(allErrs, field.NotFound(path, name))
+				} else if name == stage.Name {
+					allErrs = append(allErrs, field.Invalid(path, name, "a stage cannot depend on itself"))
+				}
+			}
+		}
+	}
+
+	return allErrs
+}
+
+// dependencyNode is a vertex in the execution graph: a step, a stage as a unit
+// (done when all of its steps are done), or a stage's start gate
+type dependencyNode struct {
+	kind string
+	name string
+}
+
+func (n dependencyNode) String() string {
+	if n.kind == "gate" {
+		return fmt.Sprintf("stage %q start", n.name)
+	}
+	return fmt.Sprintf("%s %q", n.kind, n.name)
+}
+
+// validateNoCycles rejects pipelines whose steps and stages wait on each other
+// The graph follows the controller's rules, including the implicit sequential
+// order of steps without runIf and the default order of stages
+func validateNoCycles(pipeline *pipelinev1.Pipeline, entries []stepEntry, specPath *field.Path) field.ErrorList {
+	edges := map[dependencyNode][]dependencyNode{}
+	paths := map[dependencyNode]*field.Path{}
+	order := []dependencyNode{}
+
+	addNode := func(node dependencyNode, path *field.Path) {
+		paths[node] = path
+		order = append(order, node)
+	}
+	runIfEdges := func(runIf *pipelinev1.RunIfCondition) []dependencyNode {
+		deps := []dependencyNode{}
+		for _, name := range runIf.Steps {
+			deps = append(deps, dependencyNode{"step", name})
+		}
+		for _, name := range runIf.Stages {
+			deps = append(deps, dependencyNode{"stage", name})
+		}
+		return deps
+	}
+
+	// Steps
+	previous := map[*pipelinev1.PipelineStage][]string{}
+	for _, entry := range entries {
+		step := entry.step
+		node := dependencyNode{"step", step.Name}
+		path := entry.path
+		if step.RunIf != nil {
+			path = path.Child("runIf")
+		}
+		addNode(node, path)
+
+		if entry.stage != nil {
+			edges[node] = append(edges[node], dependencyNode{"gate", entry.stage.Name})
+		}
+		if step.RunIf != nil {
+			edges[node] = append(edges[node], runIfEdges(step.RunIf)...)
+		} else {
+			for _, name := range previous[entry.stage] {
+				edges[node] = append(edges[node], dependencyNode{"step", name})
+			}
+		}
+		if sourceName, _, ok := step.ForEachSource(); ok {
+			edges[node] = append(edges[node], dependencyNode{"step", sourceName})
+		}
+		previous[entry.stage] = append(previous[entry.stage], step.Name)
+	}
+
+	// Stages and their start gates
+	for i := range pipeline.Spec.Stages {
+		stage := &pipeline.Spec.Stages[i]
+		stagePath := specPath.Child("stages").Index(i)
+
+		unit := dependencyNode{"stage", stage.Name}
+		for _, step := range stage.Steps {
+			edges[unit] = append(edges[unit], dependencyNode{"step", step.Name})
+		}
+
+		gate := dependencyNode{"gate", stage.Name}
+		switch {
+		case stage.RunIf != nil:
+			addNode(gate, stagePath.Child("runIf"))
+			edges[gate] = runIfEdges(stage.RunIf)
+			for _, name := range stage.DependsOn {
+				edges[gate] = append(edges[gate], dependencyNode{"stage", name})
+			}
+		case len(stage.DependsOn) > 0:
+			addNode(gate, stagePath.Child("dependsOn"))
+			for _, name := range stage.DependsOn {
+				edges[gate] = append(edges[gate], dependencyNode{"stage", name})
+			}
+		case i > 0:
+			addNode(gate, stagePath)
+			edges[gate] = []dependencyNode{{"stage", pipeline.Spec.Stages[i-1].Name}}
+		default:
+			addNode(gate, stagePath)
+			for _, name := range previous[nil] {
+				edges[gate] = append(edges[gate], dependencyNode{"step", name})
+			}
+		}
+	}
+
+	// Depth-first search; an edge back to a node on the stack closes a cycle
+	var allErrs field.ErrorList
+	const (
+		unvisited = iota
+		visiting
+		done
+	)
+	state := map[dependencyNode]int{}
+	stack := []dependencyNode{}
+
+	var visit func(node dependencyNode)
+	visit = func(node dependencyNode) {
+		state[node] = visiting
+		stack = append(stack, node)
+
+		for _, dep := range edges[node] {
+			switch state[dep] {
+			case unvisited:
+				visit(dep)
+			case visiting:
+				start := 0
+				for i := range stack {
+					if stack[i] == dep {
+						start = i
+					}
+				}
+				cycle := []string{}
+				for _, n := range stack[start:] {
+					cycle = append(cycle, n.String())
+				}
+				cycle = append(cycle, dep.String())
+
+				// Report the cycle at the first node that has a location in the spec
+				reportAt, reportName := specPath, node.name
+				for _, n := range stack[start:] {
+					if path, ok := paths[n]; ok {
+						reportAt, reportName = path, n.name
+						break
+					}
+				}
+				allErrs = append(allErrs, field.Invalid(reportAt, reportName,
+					"dependency cycle: "+strings.Join(cycle, " waits for ")))
+			}
+		}
+
+		stack = stack[:len(stack)-1]
+		state[node] = done
+	}
+
+	for _, node := range order {
+		if state[node] == unvisited {
+			visit(node)
+		}
+	}
+
+	return allErrs
+}
+
+// validateJobs checks the parts of each step's Job the API server would reject at run time,
+// once spec.jobDefaults fill in what the step leaves unset
+func validateJobs(pipeline *pipelinev1.Pipeline, entries []stepEntry) field.ErrorList {
+	var allErrs field.ErrorList
+
+	for _, entry := range entries {
+		step := entry.step
+
+		// Referenced bodies are only known when the step starts
+		if step.JobRef != nil || step.TemplateRef != nil {
+			continue
+		}
+		jobPath := entry.path.Child("jobSpec")
+		if step.JobSpec == nil {
+			allErrs = append(allErrs, field.Required(jobPath, "one of jobSpec, jobRef or templateRef must be set"))
+			continue
+		}
+		jobSpec := step.JobSpec.DeepCopy()
+		pipeline.Spec.JobDefaults.ApplyTo(jobSpec)
+
+		path := jobPath.Child("template", "spec", "restartPolicy")
+		switch policy := jobSpec.Template.Spec.RestartPolicy; policy {
+		case corev1.RestartPolicyNever:
+		case corev1.RestartPolicyOnFailure:
+			if jobSpec.PodFailurePolicy != nil {
+				allErrs = append(allErrs, field.Invalid(path, policy,
+					"podFailurePolicy, from the step or spec.jobDefaults, requires Never"))
+			}
+		case "":
+			allErrs = append(allErrs, field.Required(path, "Jobs require OnFailure or Never"))
+		default:
+			allErrs = append(allErrs, field.NotSupported(path, policy,
+				[]corev1.RestartPolicy{corev1.RestartPolicyOnFailure, corev1.RestartPolicyNever}))
+		}
+
+		if jobSpec.CompletionMode != nil && *jobSpec.CompletionMode == batchv1.IndexedCompletion && jobSpec.Completions == nil {
+			allErrs = append(allErrs, field.Required(jobPath.Child("completions"),
+				"Indexed completion mode, from the step or spec.jobDefaults, requires completions"))
+		}
+	}
+
+	return allErrs
+}
+
+// validateAllowFailure checks that allowed exit codes come with allowFailure, on steps
+// that record the exit codes of their pod
+func validateAllowFailure(entries []stepEntry) field.ErrorList {
+	var allErrs field.ErrorList
+
+	for _, entry := range entries {
+		step := entry.step
+		if len(step.AllowFailureExitCodes) == 0 {
+			continue
+		}
+		path := entry.path.Child("allowFailureExitCodes")
+		switch {
+		case !step.AllowFailure:
+			allErrs = append(allErrs, field.Invalid(path, step.AllowFailureExitCodes, "requires allowFailure"))
+		case step.ForEach != "":
+			allErrs = append(allErrs, field.Forbidden(path, "forEach steps do not record exit codes"))
+		}
+	}
+
+	return allErrs
+}
+
+// validateStageSteps checks the steps of each stage against the rules the CRD schema
+// enforces for spec.steps; stage steps are stored schemaless to keep the CRD compact
+func validateStageSteps(pipeline *pipelinev1.Pipeline, entries []stepEntry, specPath *field.Path) field.ErrorList {
+	var allErrs field.ErrorList
+
+	for i, stage := range pipeline.Spec.Stages {
+		if len(stage.Steps) == 0 {
+			allErrs = append(allErrs, field.Required(specPath.Child("stages").Index(i).Child("steps"), "a stage needs at least one step"))
+		}
+	}
+
+	for _, entry := range entries {
+		if entry.stage == nil {
+			continue
+		}
+		step, path := entry.step, entry.path
+
+		if step.Name == "" {
+			allErrs = append(allErrs, field.Required(path.Child("name"), ""))
+		} else {
+			for _, msg := range utilvalidation.IsDNS1123Label(step.Name) {
+				allErrs = append(allErrs, field.Invalid(path.Child("name"), step.Name, msg))
+			}
+		}
+
+		if step.RunIf != nil {
+			allErrs = append(allErrs, validateRunIf(step.RunIf, path.Child("runIf"))...)
+		}
+		if step.JobRef != nil {
+			allErrs = append(allErrs, validateJobRef(step.JobRef, path.Child("jobRef"))...)
+		}
+		if step.TemplateRef != nil {
+			allErrs = append(allErrs, validateTemplateRef(step.TemplateRef, path.Child("templateRef"))...)
+		}
+		if step.JobRef != nil && step.TemplateRef != nil {
+			allErrs = append(allErrs, field.Forbidden(path.Child("templateRef"), "jobRef and templateRef are mutually exclusive"))
+		}
+		if step.JobSpec != nil && step.JobRef == nil && step.TemplateRef == nil {
+			allErrs = append(allErrs, validateContainers(&step.JobSpec.Template.Spec, path.Child("jobSpec", "template", "spec"))...)
+		}
+
+		if step.ForEachParallelism != nil && *step.ForEachParallelism < 1 {
+			allErrs = append(allErrs, field.Invalid(path.Child("forEachParallelism"), *step.ForEachParallelism, "must be at least 1"))
+		}
+		if step.SkipPolicy != "" {
+			allErrs = append(allErrs, validateEnum(path.Child("skipPolicy"), step.SkipPolicy,
+				pipelinev1.SkipPropagate, pipelinev1.SkipTreatAsSuccess, pipelinev1.SkipTreatAsFailure, pipelinev1.SkipIgnore)...)
+		}
+		for i, code := range step.AllowFailureExitCodes {
+			if code < 1 || code > 255 {
+				allErrs = append(allErrs, field.Invalid(path.Child("allowFailureExitCodes").Index(i), code, "must be between 1 and 255"))
+			}
+		}
+	}
+
+	return allErrs
+}
+
+// validateRunIf checks a stage step's runIf like the CRD schema checks spec.steps
+func validateRunIf(runIf *pipelinev1.RunIfCondition, path *field.Path) field.ErrorList {
+	var allErrs field.ErrorList
+	if runIf.Condition != "" {
+		allErrs = append(allErrs, validateEnum(path.Child("condition"), runIf.Condition,
+			pipelinev1.RunIfConditionSuccess, pipelinev1.RunIfConditionFail)...)
+	}
+	if runIf.Operator != "" {
+		allErrs = append(allErrs, validateEnum(path.Child("operator"), runIf.Operator,
+			pipelinev1.RunIfOperatorAnd, pipelinev1.RunIfOperatorOr)...)
+	}
+	if len(runIf.Steps) == 0 && len(runIf.Stages) == 0 {
+		allErrs = append(allErrs, field.Required(path, "at least one step or stage must be referenced"))
+	}
+	return allErrs
+}
+
+// validateJobRef checks a stage step's jobRef like the CRD schema checks spec.steps
+func validateJobRef(ref *pipelinev1.JobReference, path *field.Path) field.ErrorList {
+	allErrs := validateEnum(path.Child("kind"), ref.Kind,
+		pipelinev1.JobReferenceKindCronJob, pipelinev1.JobReferenceKindJob, pipelinev1.JobReferenceKindConfigMap)
+	if ref.Name == "" {
+		allErrs = append(allErrs, field.Required(path.Child("name"), ""))
+	}
+	return allErrs
+}
+
+// validateTemplateRef checks a stage step's templateRef like the CRD schema checks spec.steps
+func validateTemplateRef(ref *pipelinev1.StepTemplateRef, path *field.Path) field.ErrorList {
+	var allErrs field.ErrorList
+	if ref.Kind != "" {
+		allErrs = append(allErrs, validateEnum(path.Child("kind"), ref.Kind,
+			pipelinev1.StepTemplateKindNamespaced, pipelinev1.StepTemplateKindCluster)...)
+	}
+	if ref.Name == "" {
+		allErrs = append(allErrs, field.Required(path.Child("name"), ""))
+	}
+	seen := map[string]bool{}
+	for i, param := range ref.Params {
+		paramPath := path.Child("params").Index(i).Child("name")
+		switch {
+		case param.Name == "":
+			allErrs = append(allErrs, field.Required(paramPath, ""))
+		case seen[param.Name]:
+			allErrs = append(allErrs, field.Duplicate(paramPath, param.Name))
+		}
+		seen[param.Name] = true
+	}
+	return allErrs
+}
+
+// validateContainers checks that a stage step's pod has containers, all of them named
+func validateContainers(podSpec *corev1.PodSpec, path *field.Path) field.ErrorList {
+	var allErrs field.ErrorList
+	if len(podSpec.Containers) == 0 {
+		allErrs = append(allErrs, field.Required(path.Child("containers"), ""))
+	}
+	for i, container := range podSpec.InitContainers {
+		if container.Name == "" {
+			allErrs = append(allErrs, field.Required(path.Child("initContainers").Index(i).Child("name"), ""))
+		}
+	}
+	for i, container := range podSpec.Containers {
+		if container.Name == "" {
+			allErrs = append(allErrs, field.Required(path.Child("containers").Index(i).Child("name"), ""))
+		}
+	}
+	return allErrs
+}
+
+// validateEnum reports value unless it is one of allowed
+func validateEnum[T ~string](path *field.Path, value T, allowed ...T) field.ErrorList {
+	if slices.Contains(allowed, value) {
+		return nil
+	}
+	return field.ErrorList{field.NotSupported(path, value, allowed)}
+}
@@ -0,0 +1,307 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package validation
+
+import (
+	"strings"
+	"testing"
+
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/utils/ptr"
+
+	pipelinev1 "github.com/yaacov/jobrunner/api/v1"
+)
+
+// step returns a valid inline step with optional runIf dependencies
+func step(name string, runIfSteps ...string) pipelinev1.PipelineStep {
+	s := pipelinev1.PipelineStep{
+		Name: name,
+		JobSpec: &batchv1.JobSpec{Template: corev1.PodTemplateSpec{Spec: corev1.PodSpec{
+			RestartPolicy: corev1.RestartPolicyNever,
+			Containers:    []corev1.Container{{Name: "main", Image: "busybox"}},
+		}}},
+	}
+	if len(runIfSteps) > 0 {
+		s.RunIf = &pipelinev1.RunIfCondition{Steps: runIfSteps}
+	}
+	return s
+}
+
+func TestValidatePipeline(t *testing.T) {
+	tests := []struct {
+		name     string
+		spec     pipelinev1.PipelineSpec
+		wantErrs []string
+	}{
+		{
+			name: "valid sequential pipeline",
+			spec: pipelinev1.PipelineSpec{Steps: []pipelinev1.PipelineStep{step("build"), step("test")}},
+		},
+		{
+			name: "valid conditional pipeline with stages",
+			spec: pipelinev1.PipelineSpec{
+				Steps: []pipelinev1.PipelineStep{step("checkout")},
+				Stages: []pipelinev1.PipelineStage{
+					{Name: "build", Steps: []pipelinev1.PipelineStep{step("compile"), step("package")}},
+					{Name: "test", DependsOn: []string{"build"}, Steps: []pipelinev1.PipelineStep{step("unit"), step("lint", "compile")}},
+					{
+						Name:  "report",
+						RunIf: &pipelinev1.RunIfCondition{Condition: pipelinev1.RunIfConditionFail, Stages: []string{"build", "test"}},
+						Steps: []pipelinev1.PipelineStep{step("notify")},
+					},
+				},
+			},
+		},
+		{
+			name: "duplicate step names",
+			spec: pipelinev1.PipelineSpec{
+				Steps:  []pipelinev1.PipelineStep{step("build")},
+				Stages: []pipelinev1.PipelineStage{{Name: "ci", Steps: []pipelinev1.PipelineStep{step("build")}}},
+			},
+			wantErrs: []string{`spec.stages[0].steps[0].name: Duplicate value: "build"`},
+		},
+		{
+			name: "stage named like a step",
+			spec: pipelinev1.PipelineSpec{
+				Steps:  []pipelinev1.PipelineStep{step("build")},
+				Stages: []pipelinev1.PipelineStage{{Name: "build", Steps: []pipelinev1.PipelineStep{step("compile")}}},
+			},
+			wantErrs: []string{`spec.stages[0].name: Duplicate value: "build"`},
+		},
+		{
+			name:     "runIf references unknown step",
+			spec:     pipelinev1.PipelineSpec{Steps: []pipelinev1.PipelineStep{step("test"), step("deploy", "tset")}},
+			wantErrs: []string{`spec.steps[1].runIf.steps[0]: Not found: "tset"`},
+		},
+		{
+			name:     "runIf references itself",
+			spec:     pipelinev1.PipelineSpec{Steps: []pipelinev1.PipelineStep{step("deploy", "deploy")}},
+			wantErrs: []string{`spec.steps[0].runIf.steps[0]: Invalid value: "deploy": a step cannot depend on itself`},
+		},
+		{
+			name: "unknown stage references",
+			spec: pipelinev1.PipelineSpec{
+				Stages: []pipelinev1.PipelineStage{
+					{Name: "build", Steps: []pipelinev1.PipelineStep{step("compile")}},
+					{
+						Name:      "test",
+						DependsOn: []string{"biuld"},
+						RunIf:     &pipelinev1.RunIfCondition{Stages: []string{"test"}},
+						Steps:     []pipelinev1.PipelineStep{step("unit")},
+					},
+				},
+			},
+			wantErrs: []string{
+				`spec.stages[1].dependsOn[0]: Not found: "biuld"`,
+				`spec.stages[1].runIf.stages[0]: Invalid value: "test": a stage cannot depend on itself`,
+			},
+		},
+		{
+			name: "forEach references unknown step",
+			spec: pipelinev1.PipelineSpec{Steps: []pipelinev1.PipelineStep{
+				func() pipelinev1.PipelineStep {
+					s := step("process")
+					s.ForEach = "$(steps.discover.results.shards)"
+					return s
+				}(),
+			}},
+			wantErrs: []string{`spec.steps[0].forEach: Not found: "$(steps.discover.results.shards)"`},
+		},
+		{
+			name:     "explicit cycle",
+			spec:     pipelinev1.PipelineSpec{Steps: []pipelinev1.PipelineStep{step("a", "b"), step("b", "a")}},
+			wantErrs: []string{`spec.steps[0].runIf: Invalid value: "a": dependency cycle: step "a" waits for step "b" waits for step "a"`},
+		},
+		{
+			name:     "cycle through implicit sequential order",
+			spec:     pipelinev1.PipelineSpec{Steps: []pipelinev1.PipelineStep{step("build", "test"), step("test")}},
+			wantErrs: []string{`dependency cycle: step "build" waits for step "test" waits for step "build"`},
+		},
+		{
+			name: "cycle through a stage",
+			spec: pipelinev1.PipelineSpec{
+				Steps: []pipelinev1.PipelineStep{
+					func() pipelinev1.PipelineStep {
+						s := step("setup")
+						s.RunIf = &pipelinev1.RunIfCondition{Stages: []string{"build"}}
+						return s
+					}(),
+				},
+				Stages: []pipelinev1.PipelineStage{{Name: "build", Steps: []pipelinev1.PipelineStep{step("compile")}}},
+			},
+			wantErrs: []string{`step "setup" waits for stage "build" waits for step "compile" waits for stage "build" start waits for step "setup"`},
+		},
+		{
+			name: "invalid restart policies",
+			spec: pipelinev1.PipelineSpec{Steps: []pipelinev1.PipelineStep{
+				func() pipelinev1.PipelineStep {
+					s := step("always")
+					s.JobSpec.Template.Spec.RestartPolicy = corev1.RestartPolicyAlways
+					return s
+				}(),
+				func() pipelinev1.PipelineStep {
+					s := step("unset")
+					s.JobSpec.Template.Spec.RestartPolicy = ""
+					return s
+				}(),
+				{Name: "referenced", TemplateRef: &pipelinev1.StepTemplateRef{Name: "clone"}},
+			}},
+			wantErrs: []string{
+				`spec.steps[0].jobSpec.template.spec.restartPolicy: Unsupported value: "Always"`,
+				`spec.steps[1].jobSpec.template.spec.restartPolicy: Required value`,
+			},
+		},
+		{
+			name: "job defaults the step's job cannot take",
+			spec: pipelinev1.PipelineSpec{
+				JobDefaults: &pipelinev1.JobDefaults{
+					PodFailurePolicy: &batchv1.PodFailurePolicy{Rules: []batchv1.PodFailurePolicyRule{{
+						Action:      batchv1.PodFailurePolicyActionFailJob,
+						OnExitCodes: &batchv1.PodFailurePolicyOnExitCodesRequirement{Operator: batchv1.PodFailurePolicyOnExitCodesOpIn, Values: []int32{42}},
+					}}},
+					CompletionMode: ptr.To(batchv1.IndexedCompletion),
+				},
+				Steps: []pipelinev1.PipelineStep{
+					func() pipelinev1.PipelineStep {
+						s := step("shards")
+						s.JobSpec.Completions = ptr.To[int32](3)
+						return s
+					}(),
+					func() pipelinev1.PipelineStep {
+						s := step("flaky")
+						s.JobSpec.Completions = ptr.To[int32](1)
+						s.JobSpec.Template.Spec.RestartPolicy = corev1.RestartPolicyOnFailure
+						return s
+					}(),
+					step("single"),
+					func() pipelinev1.PipelineStep {
+						s := step("plain")
+						s.JobSpec.CompletionMode = ptr.To(batchv1.NonIndexedCompletion)
+						return s
+					}(),
+				},
+			},
+			wantErrs: []string{
+				`spec.steps[1].jobSpec.template.spec.restartPolicy: Invalid value: "OnFailure": podFailurePolicy, from the step or spec.jobDefaults, requires Never`,
+				`spec.steps[2].jobSpec.completions: Required value: Indexed completion mode, from the step or spec.jobDefaults, requires completions`,
+			},
+		},
+		{
+			name: "allowed exit codes",
+			spec: pipelinev1.PipelineSpec{Steps: []pipelinev1.PipelineStep{
+				step("discover"),
+				func() pipelinev1.PipelineStep {
+					s := step("lint")
+					s.AllowFailure = true
+					s.AllowFailureExitCodes = []int32{1}
+					return s
+				}(),
+				func() pipelinev1.PipelineStep {
+					s := step("scan")
+					s.AllowFailureExitCodes = []int32{2}
+					return s
+				}(),
+				func() pipelinev1.PipelineStep {
+					s := step("process")
+					s.ForEach = "$(steps.discover.results.shards)"
+					s.AllowFailure = true
+					s.AllowFailureExitCodes = []int32{1}
+					return s
+				}(),
+			}},
+			wantErrs: []string{
+				`spec.steps[2].allowFailureExitCodes: Invalid value: []int32{2}: requires allowFailure`,
+				`spec.steps[3].allowFailureExitCodes: Forbidden: forEach steps do not record exit codes`,
+			},
+		},
+		{
+			name: "stage steps are checked like spec.steps",
+			spec: pipelinev1.PipelineSpec{
+				Stages: []pipelinev1.PipelineStage{
+					{Name: "empty"},
+					{Name: "ci", Steps: []pipelinev1.PipelineStep{
+						step("Build"),
+						func() pipelinev1.PipelineStep {
+							s := step("test")
+							s.RunIf = &pipelinev1.RunIfCondition{Operator: "xor"}
+							s.SkipPolicy = "Sometimes"
+							s.ForEachParallelism = ptr.To[int32](0)
+							s.JobSpec.Template.Spec.Containers = nil
+							return s
+						}(),
+						func() pipelinev1.PipelineStep {
+							s := step("deploy")
+							s.JobRef = &pipelinev1.JobReference{Kind: "Deployment"}
+							s.TemplateRef = &pipelinev1.StepTemplateRef{
+								Name:   "deploy",
+								Params: []pipelinev1.TemplateParam{{Name: "env"}, {Name: "env"}},
+							}
+							return s
+						}(),
+						func() pipelinev1.PipelineStep {
+							s := step("scan")
+							s.AllowFailure = true
+							s.AllowFailureExitCodes = []int32{0, 2}
+							return s
+						}(),
+					}},
+				},
+			},
+			wantErrs: []string{
+				`spec.stages[0].steps: Required value: a stage needs at least one step`,
+				`spec.stages[1].steps[0].name: Invalid value: "Build"`,
+				`spec.stages[1].steps[1].runIf.operator: Unsupported value: "xor"`,
+				`spec.stages[1].steps[1].runIf: Required value: at least one step or stage must be referenced`,
+				`spec.stages[1].steps[1].jobSpec.template.spec.containers: Required value`,
+				`spec.stages[1].steps[1].forEachParallelism: Invalid value: 0: must be at least 1`,
+				`spec.stages[1].steps[1].skipPolicy: Unsupported value: "Sometimes"`,
+				`spec.stages[1].steps[2].jobRef.kind: Unsupported value: "Deployment"`,
+				`spec.stages[1].steps[2].jobRef.name: Required value`,
+				`spec.stages[1].steps[2].templateRef.params[1].name: Duplicate value: "env"`,
+				`spec.stages[1].steps[2].templateRef: Forbidden: jobRef and templateRef are mutually exclusive`,
+				`spec.stages[1].steps[3].allowFailureExitCodes[0]: Invalid value: 0: must be between 1 and 255`,
+			},
+		},
+		{
+			name: "long names are shortened in job names",
+			spec: pipelinev1.PipelineSpec{Steps: []pipelinev1.PipelineStep{
+				step("a-very-long-step-name-that-does-not-fit-in-a-job-name"),
+			}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pipeline := &pipelinev1.Pipeline{
+				ObjectMeta: metav1.ObjectMeta{Name: "nightly-build", Namespace: "default"},
+				Spec:       tt.spec,
+			}
+
+			errs := ValidatePipeline(pipeline)
+			if len(errs) != len(tt.wantErrs) {
+				t.Fatalf("expected %d errors, got %d: %v", len(tt.wantErrs), len(errs), errs)
+			}
+			for i, want := range tt.wantErrs {
+				if !strings.Contains(errs[i].Error(), want) {
+					t.Errorf("error %d: expected %q to contain %q", i, errs[i].Error(), want)
+				}
+			}
+		})
+	}
+}
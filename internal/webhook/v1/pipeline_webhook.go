@@ -0,0 +1,97 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1
+
+import (
+	"context"
+	"fmt"
+
+	"k8s.io/apimachinery/pkg/api/equality"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	ctrl "sigs.k8s.io/controller-runtime"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/webhook"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+
+	pipelinev1 "github.com/yaacov/jobrunner/api/v1"
+	"github.com/yaacov/jobrunner/internal/validation"
+)
+
+// log is for logging in this package.
+var pipelinelog = logf.Log.WithName("pipeline-resource")
+
+// SetupPipelineWebhookWithManager registers the webhook for Pipeline in the manager.
+func SetupPipelineWebhookWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewWebhookManagedBy(mgr).For(&pipelinev1.Pipeline{}).
+		WithValidator(&PipelineCustomValidator{}).
+		Complete()
+}
+
+// +kubebuilder:webhook:path=/validate-pipeline-yaacov-io-v1-pipeline,mutating=false,failurePolicy=fail,sideEffects=None,groups=pipeline.yaacov.io,resources=pipelines,verbs=create;update,versions=v1,name=vpipeline-v1.kb.io,admissionReviewVersions=v1
+
+// PipelineCustomValidator struct is responsible for validating the Pipeline resource
+// when it is created, updated, or deleted.
+type PipelineCustomValidator struct{}
+
+var _ webhook.CustomValidator = &PipelineCustomValidator{}
+
+// ValidateCreate implements webhook.CustomValidator so a webhook will be registered for the type Pipeline.
+func (v *PipelineCustomValidator) ValidateCreate(_ context.Context, obj runtime.Object) (admission.Warnings, error) {
+	pipeline, ok := obj.(*pipelinev1.Pipeline)
+	if !ok {
+		return nil, fmt.Errorf("expected a Pipeline object but got %T", obj)
+	}
+	pipelinelog.Info("Validation for Pipeline upon creation", "name", pipeline.GetName())
+
+	return nil, validate(pipeline)
+}
+
+// ValidateUpdate implements webhook.CustomValidator so a webhook will be registered for the type Pipeline.
+func (v *PipelineCustomValidator) ValidateUpdate(_ context.Context, oldObj, newObj runtime.Object) (admission.Warnings, error) {
+	pipeline, ok := newObj.(*pipelinev1.Pipeline)
+	if !ok {
+		return nil, fmt.Errorf("expected a Pipeline object for the newObj but got %T", newObj)
+	}
+	oldPipeline, ok := oldObj.(*pipelinev1.Pipeline)
+	if !ok {
+		return nil, fmt.Errorf("expected a Pipeline object for the oldObj but got %T", oldObj)
+	}
+	pipelinelog.Info("Validation for Pipeline upon update", "name", pipeline.GetName())
+
+	// Metadata-only updates, such as removing the finalizer, must go through even for a
+	// pipeline admitted before the current rules, or it could never be deleted
+	if pipeline.DeletionTimestamp != nil || equality.Semantic.DeepEqual(oldPipeline.Spec, pipeline.Spec) {
+		return nil, nil
+	}
+
+	return nil, validate(pipeline)
+}
+
+// ValidateDelete implements webhook.CustomValidator so a webhook will be registered for the type Pipeline.
+func (v *PipelineCustomValidator) ValidateDelete(_ context.Context, _ runtime.Object) (admission.Warnings, error) {
+	return nil, nil
+}
+
+// validate returns an Invalid API error listing every problem in the pipeline spec
+func validate(pipeline *pipelinev1.Pipeline) error {
+	allErrs := validation.ValidatePipeline(pipeline)
+	if len(allErrs) == 0 {
+		return nil
+	}
+	return apierrors.NewInvalid(pipelinev1.GroupVersion.WithKind("Pipeline").GroupKind(), pipeline.Name, allErrs)
+}
@@ -0,0 +1,92 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	pipelinev1 "github.com/yaacov/jobrunner/api/v1"
+)
+
+// step returns a valid inline step with optional runIf dependencies
+func step(name string, runIfSteps ...string) pipelinev1.PipelineStep {
+	s := pipelinev1.PipelineStep{
+		Name: name,
+		JobSpec: &batchv1.JobSpec{Template: corev1.PodTemplateSpec{Spec: corev1.PodSpec{
+			RestartPolicy: corev1.RestartPolicyNever,
+			Containers:    []corev1.Container{{Name: "main", Image: "busybox"}},
+		}}},
+	}
+	if len(runIfSteps) > 0 {
+		s.RunIf = &pipelinev1.RunIfCondition{Steps: runIfSteps}
+	}
+	return s
+}
+
+func TestPipelineCustomValidator(t *testing.T) {
+	validator := &PipelineCustomValidator{}
+	ctx := context.Background()
+
+	valid := &pipelinev1.Pipeline{
+		ObjectMeta: metav1.ObjectMeta{Name: "ok"},
+		Spec:       pipelinev1.PipelineSpec{Steps: []pipelinev1.PipelineStep{step("build")}},
+	}
+	invalid := &pipelinev1.Pipeline{
+		ObjectMeta: metav1.ObjectMeta{Name: "bad"},
+		Spec:       pipelinev1.PipelineSpec{Steps: []pipelinev1.PipelineStep{step("build", "missing")}},
+	}
+
+	if _, err := validator.ValidateCreate(ctx, valid); err != nil {
+		t.Errorf("expected valid pipeline to be admitted, got %v", err)
+	}
+
+	_, err := validator.ValidateCreate(ctx, invalid)
+	if !apierrors.IsInvalid(err) {
+		t.Fatalf("expected an Invalid error, got %v", err)
+	}
+	if !strings.Contains(err.Error(), `spec.steps[0].runIf.steps[0]: Not found: "missing"`) {
+		t.Errorf("expected error to point at the field, got %v", err)
+	}
+
+	if _, err := validator.ValidateUpdate(ctx, valid, invalid); !apierrors.IsInvalid(err) {
+		t.Errorf("expected update to be rejected, got %v", err)
+	}
+
+	// An invalid pipeline admitted before the current rules must still lose its finalizer
+	withFinalizer := invalid.DeepCopy()
+	withFinalizer.Finalizers = []string{"pipeline.yaacov.io/finalizer"}
+	if _, err := validator.ValidateUpdate(ctx, withFinalizer, invalid); err != nil {
+		t.Errorf("expected finalizer-only update to be admitted, got %v", err)
+	}
+	deleting := withFinalizer.DeepCopy()
+	now := metav1.Now()
+	deleting.DeletionTimestamp = &now
+	deleting.Spec.Steps = append(deleting.Spec.Steps, step("other", "missing"))
+	if _, err := validator.ValidateUpdate(ctx, withFinalizer, deleting); err != nil {
+		t.Errorf("expected update of a deleted pipeline to be admitted, got %v", err)
+	}
+	if _, err := validator.ValidateDelete(ctx, invalid); err != nil {
+		t.Errorf("expected delete to be allowed, got %v", err)
+	}
+}
@@ -588,7 +588,7 @@ export class PipelineCanvas extends LitElement {
   }
 
   private getStepImage(step: PipelineStep): string {
-    return step.jobSpec.template.spec.containers[0]?.image || 'default';
+    return step.jobSpec?.template.spec.containers[0]?.image || 'default';
   }
 
   private addStep(type: 'bash' | 'python' | 'kubectl' | 'custom') {
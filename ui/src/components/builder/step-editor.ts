@@ -5,8 +5,8 @@
 
 import { LitElement, html, css } from 'lit';
 import { customElement, property, state } from 'lit/decorators.js';
-import type { PipelineStep, EnvVar, EnvFromSource } from '../../types/pipeline.js';
-import { validateStepName } from '../../lib/graph-layout.js';
+import type { PipelineStep, EnvVar, EnvFromSource, JobSpec } from '../../types/pipeline.js';
+import { createDefaultStep, validateStepName } from '../../lib/graph-layout.js';
 import { k8sClient } from '../../lib/k8s-client.js';
 import type { EditorLanguage } from '../shared/code-editor.js';
 
@@ -407,8 +407,13 @@ export class StepEditor extends LitElement {
     }
   }
 
+  /** The builder only edits inline steps; others get a default job spec */
+  private get jobSpec(): JobSpec {
+    return this.step?.jobSpec ?? createDefaultStep(this.step?.name ?? '').jobSpec;
+  }
+
   private getContainer() {
-    return this.step?.jobSpec.template.spec.containers[0];
+    return this.step && this.jobSpec.template.spec.containers[0];
   }
 
   private isScriptMode(): boolean {
@@ -445,18 +450,18 @@ export class StepEditor extends LitElement {
   private updateImage(image: string) {
     if (!this.step) return;
 
-    const containers = [...(this.step.jobSpec.template.spec.containers || [])];
+    const containers = [...(this.jobSpec.template.spec.containers || [])];
     if (containers.length > 0) {
       containers[0] = { ...containers[0], image };
     }
 
     this.dispatchUpdate({
       jobSpec: {
-        ...this.step.jobSpec,
+        ...this.jobSpec,
         template: {
-          ...this.step.jobSpec.template,
+          ...this.jobSpec.template,
           spec: {
-            ...this.step.jobSpec.template.spec,
+            ...this.jobSpec.template.spec,
             containers,
           },
         },
@@ -470,7 +475,7 @@ export class StepEditor extends LitElement {
     // Parse command string into array (split by spaces, respecting quotes would be ideal but keeping simple)
     const command = commandStr.trim() ? commandStr.split(/\s+/) : [];
 
-    const containers = [...(this.step.jobSpec.template.spec.containers || [])];
+    const containers = [...(this.jobSpec.template.spec.containers || [])];
     if (containers.length > 0) {
       containers[0] = {
         ...containers[0],
@@ -480,11 +485,11 @@ export class StepEditor extends LitElement {
 
     this.dispatchUpdate({
       jobSpec: {
-        ...this.step.jobSpec,
+        ...this.jobSpec,
         template: {
-          ...this.step.jobSpec.template,
+          ...this.jobSpec.template,
           spec: {
-            ...this.step.jobSpec.template.spec,
+            ...this.jobSpec.template.spec,
             containers,
           },
         },
@@ -513,7 +518,7 @@ export class StepEditor extends LitElement {
       args = lines.length > 0 ? lines : [];
     }
 
-    const containers = [...(this.step.jobSpec.template.spec.containers || [])];
+    const containers = [...(this.jobSpec.template.spec.containers || [])];
     if (containers.length > 0) {
       containers[0] = {
         ...containers[0],
@@ -523,11 +528,11 @@ export class StepEditor extends LitElement {
 
     this.dispatchUpdate({
       jobSpec: {
-        ...this.step.jobSpec,
+        ...this.jobSpec,
         template: {
-          ...this.step.jobSpec.template,
+          ...this.jobSpec.template,
           spec: {
-            ...this.step.jobSpec.template.spec,
+            ...this.jobSpec.template.spec,
             containers,
           },
         },
@@ -546,7 +551,7 @@ export class StepEditor extends LitElement {
   private updateEnvFrom(envFrom: EnvFromSource[]) {
     if (!this.step) return;
 
-    const containers = [...(this.step.jobSpec.template.spec.containers || [])];
+    const containers = [...(this.jobSpec.template.spec.containers || [])];
     if (containers.length > 0) {
       containers[0] = {
         ...containers[0],
@@ -556,11 +561,11 @@ export class StepEditor extends LitElement {
 
     this.dispatchUpdate({
       jobSpec: {
-        ...this.step.jobSpec,
+        ...this.jobSpec,
         template: {
-          ...this.step.jobSpec.template,
+          ...this.jobSpec.template,
           spec: {
-            ...this.step.jobSpec.template.spec,
+            ...this.jobSpec.template.spec,
             containers,
           },
         },
@@ -588,18 +593,18 @@ export class StepEditor extends LitElement {
   private updateEnvVars(envVars: EnvVar[]) {
     if (!this.step) return;
 
-    const containers = [...(this.step.jobSpec.template.spec.containers || [])];
+    const containers = [...(this.jobSpec.template.spec.containers || [])];
     if (containers.length > 0) {
       containers[0] = { ...containers[0], env: envVars };
     }
 
     this.dispatchUpdate({
       jobSpec: {
-        ...this.step.jobSpec,
+        ...this.jobSpec,
         template: {
-          ...this.step.jobSpec.template,
+          ...this.jobSpec.template,
           spec: {
-            ...this.step.jobSpec.template.spec,
+            ...this.jobSpec.template.spec,
             containers,
           },
         },
@@ -877,11 +882,11 @@ export class StepEditor extends LitElement {
                     type="number"
                     id="backoff-limit"
                     min="0"
-                    .value=${String(this.step.jobSpec.backoffLimit ?? 6)}
+                    .value=${String(this.jobSpec.backoffLimit ?? 6)}
                     @input=${(e: Event) => {
                       const value = parseInt((e.target as HTMLInputElement).value) || 6;
                       this.dispatchUpdate({
-                        jobSpec: { ...this.step!.jobSpec, backoffLimit: value },
+                        jobSpec: { ...this.jobSpec, backoffLimit: value },
                       });
                     }}
                   />
@@ -893,13 +898,13 @@ export class StepEditor extends LitElement {
                     type="number"
                     id="active-deadline"
                     min="0"
-                    .value=${String(this.step.jobSpec.activeDeadlineSeconds || '')}
+                    .value=${String(this.jobSpec.activeDeadlineSeconds || '')}
                     placeholder="No limit"
                     @input=${(e: Event) => {
                       const value = parseInt((e.target as HTMLInputElement).value);
                       this.dispatchUpdate({
                         jobSpec: {
-                          ...this.step!.jobSpec,
+                          ...this.jobSpec,
                           activeDeadlineSeconds: value || undefined,
                         },
                       });
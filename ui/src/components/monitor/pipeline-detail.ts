@@ -529,7 +529,7 @@ export class PipelineDetail extends LitElement {
   }
 
   private getStepImage(step: PipelineStep): string {
-    const container = step.jobSpec.template.spec.containers[0];
+    const container = step.jobSpec?.template.spec.containers[0];
     return container?.image || 'default';
   }
 
@@ -314,6 +314,15 @@ export class StepDetail extends LitElement {
             this.logsError = null;
           }
         }
+      } else if (this.status.archivedLogs?.length) {
+        // The pods are gone; fall back to the latest attempt archived in a ConfigMap
+        const latest = this.status.archivedLogs[this.status.archivedLogs.length - 1];
+        const archived = latest.includes('/')
+          ? `Logs were archived to a PVC at ${latest}`
+          : await k8sClient.getArchivedLogs(this.namespace, latest);
+        if (archived !== this.logs) {
+          this.logs = archived;
+        }
       } else if (this.logs !== '') {
         this.logs = '';
       }
@@ -364,7 +373,7 @@ export class StepDetail extends LitElement {
   }
 
   private getImage(): string {
-    return this.step?.jobSpec.template.spec.containers[0]?.image || '-';
+    return this.step?.jobSpec?.template.spec.containers[0]?.image || '-';
   }
 
   private async copyToClipboard(text: string) {
@@ -507,7 +516,7 @@ export class StepDetail extends LitElement {
   }
 
   private renderSpec() {
-    const container = this.step?.jobSpec.template.spec.containers[0];
+    const container = this.step?.jobSpec?.template.spec.containers[0];
 
     return html`
       <ul class="spec-list">
@@ -522,12 +531,12 @@ export class StepDetail extends LitElement {
         <li class="spec-item">
           <span class="spec-key">Restart Policy</span>
           <span class="spec-value"
-            >${this.step?.jobSpec.template.spec.restartPolicy || 'Never'}</span
+            >${this.step?.jobSpec?.template.spec.restartPolicy || 'Never'}</span
           >
         </li>
         <li class="spec-item">
           <span class="spec-key">Backoff Limit</span>
-          <span class="spec-value">${this.step?.jobSpec.backoffLimit ?? 6}</span>
+          <span class="spec-value">${this.step?.jobSpec?.backoffLimit ?? 6}</span>
         </li>
         ${this.step?.runIf
           ? html`
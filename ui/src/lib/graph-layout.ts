@@ -4,7 +4,14 @@
  */
 
 import ELK, { type ElkNode, type ElkExtendedEdge } from 'elkjs/lib/elk.bundled.js';
-import type { Pipeline, PipelineNode, PipelineEdge, PipelineGraph } from '../types/pipeline.js';
+import type {
+  JobSpec,
+  Pipeline,
+  PipelineNode,
+  PipelineEdge,
+  PipelineGraph,
+  PipelineStep,
+} from '../types/pipeline.js';
 
 const elk = new ELK();
 
@@ -133,7 +140,7 @@ export function createEmptyPipeline(name: string, namespace = 'default'): Pipeli
 /**
  * Create a new step with default values
  */
-export function createDefaultStep(name: string): Pipeline['spec']['steps'][0] {
+export function createDefaultStep(name: string): PipelineStep & { jobSpec: JobSpec } {
   return {
     name,
     jobSpec: {
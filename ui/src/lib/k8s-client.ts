@@ -188,6 +188,23 @@ export class K8sClient {
     return response.text();
   }
 
+  /**
+   * Get logs archived in a ConfigMap by spec.logArchive
+   */
+  async getArchivedLogs(namespace: string, configMapName: string): Promise<string> {
+    const configMap = await this.request<{ binaryData?: Record<string, string> }>(
+      `/api/v1/namespaces/${namespace}/configmaps/${configMapName}`
+    );
+    const encoded = configMap.binaryData?.['logs.gz'];
+    if (!encoded) {
+      return '';
+    }
+
+    const compressed = Uint8Array.from(atob(encoded), (c) => c.charCodeAt(0));
+    const stream = new Blob([compressed]).stream().pipeThrough(new DecompressionStream('gzip'));
+    return new Response(stream).text();
+  }
+
   /**
    * Stream pod logs
    */
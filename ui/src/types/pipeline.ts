@@ -41,6 +41,9 @@ export interface PipelineSpec {
   /** List of steps/jobs to run */
   steps: PipelineStep[];
 
+  /** Groups of steps run after the top-level steps */
+  stages?: PipelineStage[];
+
   /** Service account for all jobs (can be overridden per step) */
   serviceAccountName?: string;
 
@@ -49,6 +52,88 @@ export interface PipelineSpec {
 
   /** Common pod configuration applied to all steps */
   podTemplate?: PodTemplateDefaults;
+
+  /** Common Job settings applied to steps that leave them unset */
+  jobDefaults?: JobDefaults;
+
+  /** Containers injected before and after every step */
+  hooks?: PipelineHooks;
+
+  /** Record each step's effective job spec in status.resolvedSteps */
+  resolveSteps?: boolean;
+
+  /** Archive the logs of every step attempt when it finishes */
+  logArchive?: LogArchiveSpec;
+
+  /** What happens to a running step whose Job is deleted (default Fail) */
+  onJobLost?: 'Fail' | 'Recreate' | 'Ignore';
+
+  /** Where step details go once the status grows large (default None) */
+  statusOverflow?: 'None' | 'ConfigMap';
+
+  /** How skipped steps and stages count for the steps waiting for them (default Propagate) */
+  skipPolicy?: SkipPolicy;
+
+  /** What happens to the other steps once a step fails */
+  failurePolicy?: FailurePolicy;
+
+  /** Retries steps whose pods were disrupted or OOM killed */
+  autoRetry?: AutoRetrySpec;
+
+  /** Fails steps whose pods stay stuck */
+  stuckPods?: StuckPodPolicy;
+
+  /** When steps finish: as soon as the Job outcome is known, or once its pods terminated (default OutcomeKnown) */
+  stepCompletion?: 'OutcomeKnown' | 'PodsTerminated';
+}
+
+export interface StuckPodPolicy {
+  /** Stuck states that fail a step once they outlast the grace period */
+  failFastOn?: ('ImagePullBackOff' | 'Unschedulable' | 'CrashLoopBackOff')[];
+
+  /** How long a pod may stay stuck, e.g. "5m" (default 5m) */
+  gracePeriod?: string;
+}
+
+export interface AutoRetrySpec {
+  /** Retries per step after evictions, preemptions and node drains (off when not set) */
+  maxDisruptionRetries?: number;
+
+  /** Retries OOM killed steps with more memory (off when not set) */
+  oomKilled?: {
+    maxRetries?: number;
+    memoryIncreasePercent?: number;
+    maxMemory: string;
+  };
+}
+
+export interface FailurePolicy {
+  /** FailFast cancels running steps and skips pending ones (default Continue) */
+  mode?: 'Continue' | 'FailFast';
+}
+
+export type SkipPolicy = 'Propagate' | 'TreatAsSuccess' | 'TreatAsFailure' | 'Ignore';
+
+export interface LogArchiveSpec {
+  /** Where logs are archived */
+  target: 'ConfigMap' | 'PVC';
+
+  /** Whether archived ConfigMaps are deleted with the pipeline (default WithPipeline) */
+  retain?: 'WithPipeline' | 'Forever';
+
+  /** PersistentVolumeClaim logs are written to, for the PVC target */
+  claimName?: string;
+
+  /** Image of the job copying logs to the PVC (default busybox:1.36) */
+  image?: string;
+}
+
+export interface PipelineHooks {
+  /** Init containers run before each step */
+  pre?: Container[];
+
+  /** Native sidecars run alongside each step, stopped after it exits */
+  post?: Container[];
 }
 
 export interface PipelineStep {
@@ -58,8 +143,54 @@ export interface PipelineStep {
   /** Conditional execution - if not specified, runs sequentially */
   runIf?: RunIfCondition;
 
-  /** Kubernetes Job specification */
-  jobSpec: JobSpec;
+  /** Kubernetes Job specification (ignored when jobRef or templateRef is set) */
+  jobSpec?: JobSpec;
+
+  /** Copy the job template from an existing CronJob, Job or ConfigMap */
+  jobRef?: JobReference;
+
+  /** Use a StepTemplate or ClusterStepTemplate as the step body */
+  templateRef?: StepTemplateRef;
+
+  /** Opt this step out of the pipeline hooks */
+  disableHooks?: boolean;
+
+  /** Run once per item of a result list, e.g. $(steps.discover.results.shards) */
+  forEach?: string;
+
+  /** Maximum forEach items running at once (default: all) */
+  forEachParallelism?: number;
+
+  /** Override the pipeline skipPolicy for this step's dependencies */
+  skipPolicy?: SkipPolicy;
+
+  /** Let the step fail without failing the pipeline */
+  allowFailure?: boolean;
+
+  /** Only allow failures where every failed container exited with one of these codes */
+  allowFailureExitCodes?: number[];
+}
+
+export interface StepTemplateRef {
+  /** Template kind (default: StepTemplate) */
+  kind?: 'StepTemplate' | 'ClusterStepTemplate';
+
+  /** Template name */
+  name: string;
+
+  /** Parameter values passed to the template */
+  params?: { name: string; value: string }[];
+}
+
+export interface JobReference {
+  /** Kind of the referenced object */
+  kind: 'CronJob' | 'Job' | 'ConfigMap';
+
+  /** Name of the referenced object in the pipeline's namespace */
+  name: string;
+
+  /** ConfigMap data key holding the JobSpec YAML (default: jobSpec) */
+  key?: string;
 }
 
 export interface RunIfCondition {
@@ -71,6 +202,23 @@ export interface RunIfCondition {
 
   /** List of step names to check */
   steps: string[];
+
+  /** List of stage names to check, each as a unit */
+  stages?: string[];
+}
+
+export interface PipelineStage {
+  /** Stage name, unique across steps and stages */
+  name: string;
+
+  /** Stages that must succeed before this stage starts (default: previous stage) */
+  dependsOn?: string[];
+
+  /** Conditional execution of the whole stage */
+  runIf?: RunIfCondition;
+
+  /** Steps in this stage */
+  steps: PipelineStep[];
 }
 
 export interface SharedVolumeSpec {
@@ -93,6 +241,25 @@ export interface SharedVolumeSpec {
   secret?: { secretName: string };
 }
 
+export interface JobDefaults {
+  /** Retries before a step's Job fails (JobRunner default: 0) */
+  backoffLimit?: number;
+
+  /** Maximum duration of a step's Job in seconds */
+  activeDeadlineSeconds?: number;
+
+  /** Delete a step's Job this many seconds after it finished */
+  ttlSecondsAfterFinished?: number;
+
+  /** How pod failures count against the backoff limit */
+  podFailurePolicy?: PodFailurePolicy;
+
+  completionMode?: 'NonIndexed' | 'Indexed';
+
+  /** When failed pods are replaced */
+  podReplacementPolicy?: 'TerminatingOrFailed' | 'Failed';
+}
+
 export interface PodTemplateDefaults {
   /** Default container image for steps */
   image?: string;
@@ -148,6 +315,17 @@ export interface JobSpec {
   ttlSecondsAfterFinished?: number;
   parallelism?: number;
   completions?: number;
+  completionMode?: 'NonIndexed' | 'Indexed';
+  podFailurePolicy?: PodFailurePolicy;
+  podReplacementPolicy?: 'TerminatingOrFailed' | 'Failed';
+}
+
+export interface PodFailurePolicy {
+  rules: {
+    action: 'FailJob' | 'FailIndex' | 'Ignore' | 'Count';
+    onExitCodes?: { containerName?: string; operator: 'In' | 'NotIn'; values: number[] };
+    onPodConditions?: { type: string; status?: string }[];
+  }[];
 }
 
 export interface PodTemplateSpec {
@@ -360,6 +538,14 @@ export interface ContainerPort {
 // PipelineStatus
 // ============================================
 
+export interface ResolvedStep {
+  /** Step name */
+  name: string;
+
+  /** Job spec after pipeline defaults are applied */
+  jobSpec: JobSpec;
+}
+
 export interface PipelineStatus {
   /** Current phase of the pipeline */
   phase: PipelinePhase;
@@ -370,9 +556,24 @@ export interface PipelineStatus {
   /** When the pipeline completed */
   completionTime?: string;
 
+  /** How long the pipeline ran, e.g. "1m30s" */
+  duration?: string;
+
+  /** Finished steps out of the total, e.g. "3/7" */
+  progress?: string;
+
   /** Status of each step */
   steps: StepStatus[];
 
+  /** Rolled-up status of each stage */
+  stages?: StageStatus[];
+
+  /** Effective job spec of each step, when spec.resolveSteps is set */
+  resolvedSteps?: ResolvedStep[];
+
+  /** ConfigMap holding the step details moved out of the status */
+  stepDetailsConfigMap?: string;
+
   /** Kubernetes-style conditions */
   conditions?: Condition[];
 }
@@ -381,6 +582,28 @@ export type PipelinePhase = 'Pending' | 'Running' | 'Suspended' | 'Succeeded' |
 
 export type StepPhase = 'Pending' | 'Running' | 'Suspended' | 'Succeeded' | 'Failed' | 'Skipped';
 
+export interface ContainerTermination {
+  /** Container name */
+  name: string;
+
+  /** Exit code of the container */
+  exitCode: number;
+
+  /** Why the container terminated, e.g. Completed, Error or OOMKilled */
+  reason?: string;
+}
+
+export interface FailureDiagnostics {
+  /** Container the diagnostics were read from */
+  container?: string;
+
+  /** Termination message of the container, truncated */
+  terminationMessage?: string;
+
+  /** Last lines of the container's log, truncated */
+  logTail?: string;
+}
+
 export interface StepStatus {
   /** Step name */
   name: string;
@@ -391,26 +614,130 @@ export interface StepStatus {
   /** Name of the Job created for this step */
   jobName?: string;
 
-  /** Status from the underlying Kubernetes Job */
+  /** Number of Jobs created for this step, counting recreated ones */
+  attempt?: number;
+
+  /** Pod counts and timings of the underlying Kubernetes Job */
   jobStatus?: JobStatus;
+
+  /** Jobs the controller retried after a disruption or OOM kill */
+  autoRetries?: AutoRetry[];
+
+  /** Object the job template was copied from (jobRef steps) */
+  jobRef?: ResolvedJobReference;
+
+  /** Template version pinned when the pipeline started (templateRef steps) */
+  template?: ResolvedStepTemplate;
+
+  /** When the step's Job started */
+  startTime?: string;
+
+  /** When the step's Job finished */
+  completionTime?: string;
+
+  /** How long the step ran, e.g. "45s" */
+  duration?: string;
+
+  /** Short reason such as BackoffLimitExceeded, DeadlineExceeded, OOMKilled, Unschedulable, ImagePullBackOff, Unreachable, Cancelled or AllowedFailure */
+  reason?: string;
+
+  /** Explains the reason, or why the step failed outside of its Job */
+  message?: string;
+
+  /** Per-index progress of an Indexed Job */
+  indexes?: IndexStatus;
+
+  /** When the step's pod got stuck in the state reason names */
+  stuckSince?: string;
+
+  /** Most recent pod of the step */
+  podName?: string;
+
+  /** Node the most recent pod was scheduled to */
+  nodeName?: string;
+
+  /** How each container of the most recent pod terminated */
+  containers?: ContainerTermination[];
+
+  /** Termination message and log tail of the failed pod */
+  diagnostics?: FailureDiagnostics;
+
+  /** Archived logs of each attempt: ConfigMap names, or file paths on the archive PVC */
+  archivedLogs?: string[];
+
+  /** Results emitted through the termination message (forEach sources) */
+  results?: Record<string, string>;
+
+  /** One entry per item of a forEach step */
+  children?: ForEachChildStatus[];
+}
+
+export interface IndexStatus {
+  /** Number of indexes the Job runs */
+  completions: number;
+
+  /** Indexes that succeeded as intervals, e.g. "0-3,5" */
+  completed?: string;
+
+  /** Indexes that failed, for Jobs with backoffLimitPerIndex */
+  failed?: string;
+}
+
+export interface AutoRetry {
+  attempt: number;
+  index?: number;
+  jobName: string;
+  reason: 'Disrupted' | 'OOMKilled';
+  message?: string;
+  time: string;
+}
+
+export interface ForEachChildStatus {
+  index: number;
+  item: string;
+  phase?: StepPhase;
+  jobName?: string;
+  attempt?: number;
+}
+
+export interface StageStatus {
+  name: string;
+  phase: StepPhase;
+  startTime?: string;
+  completionTime?: string;
+  stepCounts: {
+    total: number;
+    pending?: number;
+    running?: number;
+    suspended?: number;
+    succeeded?: number;
+    failed?: number;
+    skipped?: number;
+  };
+}
+
+export interface ResolvedStepTemplate {
+  kind: 'StepTemplate' | 'ClusterStepTemplate';
+  name: string;
+  resourceVersion?: string;
+  generation?: number;
+  jobSpec: JobSpec;
+}
+
+export interface ResolvedJobReference {
+  kind: 'CronJob' | 'Job' | 'ConfigMap';
+  name: string;
+  resourceVersion?: string;
 }
 
 export interface JobStatus {
   active?: number;
+  ready?: number;
+  terminating?: number;
   succeeded?: number;
   failed?: number;
   startTime?: string;
   completionTime?: string;
-  conditions?: JobCondition[];
-}
-
-export interface JobCondition {
-  type: 'Complete' | 'Failed' | 'Suspended';
-  status: 'True' | 'False' | 'Unknown';
-  reason?: string;
-  message?: string;
-  lastProbeTime?: string;
-  lastTransitionTime?: string;
 }
 
 export interface Condition {